@@ -6,9 +6,10 @@ Package backoff contains some basic implementations and a selector by strategy n
 package backoff
 
 import (
-	"math/rand"
 	"strings"
 	"time"
+
+	"github.com/luraproject/lura/v2/random"
 )
 
 // GetByName returns the WaitBeforeRetry function implementing the strategy
@@ -26,6 +27,18 @@ func GetByName(strategy string) TimeToWaitBeforeRetry {
 	return DefaultBackoff
 }
 
+// GetByNameWithSource is like GetByName, but the jitter of the strategies adding it is
+// generated with the received source
+func GetByNameWithSource(strategy string, src random.Source) TimeToWaitBeforeRetry {
+	switch strings.ToLower(strategy) {
+	case "linear-jitter":
+		return func(i int) time.Duration { return jitter(src, i) }
+	case "exponential-jitter":
+		return func(i int) time.Duration { return jitter(src, int(1<<uint(i))) }
+	}
+	return GetByName(strategy)
+}
+
 // TimeToWaitBeforeRetry returns the duration to wait before retrying for the
 // given time
 type TimeToWaitBeforeRetry func(int) time.Duration
@@ -46,7 +59,7 @@ func ExponentialBackoff(i int) time.Duration {
 // ExponentialJitterBackoff returns ever increasing backoffs by a power of 2
 // with +/- 0-33% to prevent sychronized requests.
 func ExponentialJitterBackoff(i int) time.Duration {
-	return jitter(int(1 << uint(i)))
+	return jitter(random.Default(), int(1<<uint(i)))
 }
 
 // LinearBackoff returns increasing durations, each a second longer than the last
@@ -57,20 +70,14 @@ func LinearBackoff(i int) time.Duration {
 // LinearJitterBackoff returns increasing durations, each a second longer than the last
 // with +/- 0-33% to prevent sychronized requests.
 func LinearJitterBackoff(i int) time.Duration {
-	return jitter(i)
-}
-
-var random *rand.Rand
-
-func init() {
-	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	return jitter(random.Default(), i)
 }
 
 // jitter keeps the +/- 0-33% logic in one place
-func jitter(i int) time.Duration {
+func jitter(src random.Source, i int) time.Duration {
 	ms := i * 1000
 	maxJitter := ms/3 + 1
-	ms += random.Intn(2*maxJitter) - maxJitter
+	ms += src.Intn(2*maxJitter) - maxJitter
 	if ms <= 0 {
 		ms = 1
 	}
@@ -5,6 +5,8 @@ package backoff
 import (
 	"testing"
 	"time"
+
+	"github.com/luraproject/lura/v2/random"
 )
 
 func TestExponentialBackoff(t *testing.T) {
@@ -32,3 +34,37 @@ func TestDefaultBackoff(t *testing.T) {
 		}
 	}
 }
+
+func TestGetByNameWithSource(t *testing.T) {
+	for _, tc := range []struct {
+		strategy string
+		base     func(int) int
+	}{
+		{strategy: "linear-jitter", base: func(i int) int { return i * 1000 }},
+		{strategy: "exponential-jitter", base: func(i int) int { return (1 << uint(i)) * 1000 }},
+	} {
+		backoff := GetByNameWithSource(tc.strategy, random.NewSource(42))
+		expected := random.NewSource(42)
+		for i := 1; i < 10; i++ {
+			ms := tc.base(i)
+			maxJitter := ms/3 + 1
+			want := time.Duration(ms+expected.Intn(2*maxJitter)-maxJitter) * time.Millisecond
+			if have := backoff(i); have != want {
+				t.Errorf("%s #%d: have: %s, want: %s", tc.strategy, i, have, want)
+			}
+		}
+	}
+
+	if v := int(GetByNameWithSource("linear", random.NewSource(1))(3) / time.Second); v != 3 {
+		t.Errorf("the strategies without jitter should not change. have: %d", v)
+	}
+}
+
+func TestLinearJitterBackoff(t *testing.T) {
+	for i := 1; i < 10; i++ {
+		v := LinearJitterBackoff(i)
+		if min, max := time.Duration(i*2000/3-1)*time.Millisecond, time.Duration(i*4000/3+1)*time.Millisecond; v < min || v > max {
+			t.Errorf("#%d: %s out of the jitter range [%s, %s]", i, v, min, max)
+		}
+	}
+}
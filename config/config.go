@@ -286,6 +286,9 @@ type Backend struct {
 	HeadersToPass []string `mapstructure:"input_headers"`
 	// QueryStringsToPass has the list of query string params to be sent to the backend
 	QueryStringsToPass []string `mapstructure:"input_query_strings"`
+	// StatusErrors maps the status codes of the backend responses to the errors the gateway
+	// should return. The statuses not included are handled by the status handler of the backend
+	StatusErrors map[int]StatusError `mapstructure:"status_errors"`
 
 	// ParentEndpoint is to be filled by the parent endpoint with its pattern enpoint
 	// so logs and other instrumentation can output better info (thus, it is not loaded
@@ -295,6 +298,21 @@ type Backend struct {
 	// so logs and other instrumentation can output better info (thus, it is not loaded
 	// with `mapstructure` or `json` tags).
 	ParentEndpointMethod string `json:"-" mapstructure:"-"`
+	// ParentEndpointBackends is to be filled by the parent endpoint with its number of backends,
+	// so the components of the backend can tell if the request is shared with other backends
+	// (thus, it is not loaded with `mapstructure` or `json` tags).
+	ParentEndpointBackends int `json:"-" mapstructure:"-"`
+}
+
+// StatusError defines the error returned to the client when the backend responds with a
+// mapped status code
+type StatusError struct {
+	// Error is the name of the error (e.g. "conflict")
+	Error string `mapstructure:"error" json:"error"`
+	// Message is an optional human readable description of the error
+	Message string `mapstructure:"message" json:"message"`
+	// StatusCode is the status code to return. If empty, the backend status code is used
+	StatusCode int `mapstructure:"status_code" json:"status_code"`
 }
 
 // Plugin contains the config required by the plugin module
@@ -328,6 +346,9 @@ type ClientTLS struct {
 	CurvePreferences         []uint16        `mapstructure:"curve_preferences"`
 	CipherSuites             []uint16        `mapstructure:"cipher_suites"`
 	ClientCerts              []ClientTLSCert `mapstructure:"client_certs"`
+	// ClientSessionCacheSize enables the TLS session resumption, keeping the
+	// defined number of sessions in an LRU cache
+	ClientSessionCacheSize int `mapstructure:"client_session_cache_size"`
 }
 
 // ClientTLSCert holds a certificate with its private key to be
@@ -361,8 +382,14 @@ func (e *ExtraConfig) Normalize() {
 	}
 }
 
-// ExtraConfigAlias is the set of alias to accept as namespace
-var ExtraConfigAlias = map[string]string{}
+// ExtraConfigAlias is the set of alias to accept as namespace. It contains the legacy
+// namespaces renamed since the krakend days, and the components can register their own.
+var ExtraConfigAlias = map[string]string{
+	"github_com/devopsfaith/krakend/router/gin":            "github_com/luraproject/lura/router/gin",
+	"github_com/devopsfaith/krakend/transport/http/server": "github_com/luraproject/lura/transport/http/server",
+	"github_com/devopsfaith/krakend/proxy":                 proxyNamespace,
+	"github_com/devopsfaith/krakend/http":                  "github.com/devopsfaith/krakend/http",
+}
 
 var (
 	simpleURLKeysPattern    = regexp.MustCompile(`\{([\w\-\.:/]+)\}`)
@@ -478,6 +505,10 @@ func (s *ServiceConfig) initAsyncAgents() error {
 			b.Decoder = encoding.GetRegister().Get(strings.ToLower(b.Encoding))(b.IsCollection)
 
 			b.ExtraConfig.sanitize()
+
+			if err := validateBackend(b); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -511,10 +542,15 @@ func (s *ServiceConfig) initEndpoints() error {
 
 		e.ExtraConfig.sanitize()
 
+		if err := s.initEndpointPipelines(e); err != nil {
+			return err
+		}
+
 		for j, b := range e.Backend {
 			// we "tell" the backend which is his parent endpoint
 			b.ParentEndpoint = e.Endpoint
 			b.ParentEndpointMethod = e.Method
+			b.ParentEndpointBackends = len(e.Backend)
 			if err := s.initBackendDefaults(i, j); err != nil {
 				return err
 			}
@@ -524,6 +560,14 @@ func (s *ServiceConfig) initEndpoints() error {
 			}
 
 			b.ExtraConfig.sanitize()
+
+			if err := validateBackend(b); err != nil {
+				return err
+			}
+		}
+
+		if err := validateEndpoint(e); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -211,7 +211,7 @@ func TestConfig_init(t *testing.T) {
 		t.Error(err.Error())
 	}
 
-	if hash != "dEnC/Sn/mHYtt5vj+DkI0Ib22D/1liL8zx/WHpT2ajY=" {
+	if hash != "TszpvhQ7tLI/53p7sRvYQ/xZBRzOx7JzbE7KaaIazWg=" {
 		t.Errorf("unexpected hash: %s", hash)
 	}
 }
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvPrefix is the prefix marking a config value as a reference to an environment variable
+const EnvPrefix = "env:"
+
+// ResolveEnvValue returns the value of the environment variable referenced by the received
+// config value (with the format "env:VARIABLE_NAME") or the value itself, if it is not a
+// reference. Secrets like keys and tokens can be kept out of the config files this way.
+func ResolveEnvValue(v string) string {
+	if !strings.HasPrefix(v, EnvPrefix) {
+		return v
+	}
+	return os.Getenv(strings.TrimPrefix(v, EnvPrefix))
+}
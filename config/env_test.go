@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveEnvValue(t *testing.T) {
+	os.Setenv("LURA_TEST_RESOLVE_ENV", "secret")
+	defer os.Unsetenv("LURA_TEST_RESOLVE_ENV")
+
+	for in, expected := range map[string]string{
+		"plain":                     "plain",
+		"env:LURA_TEST_RESOLVE_ENV": "secret",
+		"env:LURA_TEST_UNDEFINED":   "",
+		"":                          "",
+	} {
+		if v := ResolveEnvValue(in); v != expected {
+			t.Errorf("unexpected value for '%s': '%s'", in, v)
+		}
+	}
+}
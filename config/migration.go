@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const proxyNamespace = "github.com/devopsfaith/krakend/proxy"
+
+// MigrationChange describes a single transformation applied to a legacy config, or a legacy
+// construct that could not be migrated
+type MigrationChange struct {
+	// Path is the location of the object containing the construct, like endpoints[0].backend[1]
+	Path string
+	// From is the legacy location of the option
+	From string
+	// To is the current location of the option. It is empty for the unknown constructs.
+	To string
+	// Reason explains why the construct was not migrated
+	Reason string
+}
+
+// String returns a string representation of the MigrationChange
+func (m MigrationChange) String() string {
+	if m.To == "" {
+		return fmt.Sprintf("%s: '%s' not migrated: %s", m.Path, m.From, m.Reason)
+	}
+	return fmt.Sprintf("%s: '%s' moved to '%s'", m.Path, m.From, m.To)
+}
+
+// MigrationReport contains all the transformations applied by a migration
+type MigrationReport struct {
+	// Changes are the migrated options
+	Changes []MigrationChange
+	// Unknown are the legacy constructs kept in place because they could not be migrated
+	Unknown []MigrationChange
+}
+
+// Empty returns true if the migration did not find any legacy construct
+func (m MigrationReport) Empty() bool {
+	return len(m.Changes) == 0 && len(m.Unknown) == 0
+}
+
+type legacyField struct {
+	Old string
+	New string
+}
+
+type legacyOption struct {
+	Old       string
+	Namespace string
+	Key       string
+}
+
+var (
+	legacyEndpointFields = []legacyField{
+		{Old: "querystring_params", New: "input_query_strings"},
+		{Old: "headers_to_pass", New: "input_headers"},
+	}
+	legacyBackendFields = []legacyField{
+		{Old: "whitelist", New: "allow"},
+		{Old: "blacklist", New: "deny"},
+		{Old: "querystring_params", New: "input_query_strings"},
+		{Old: "headers_to_pass", New: "input_headers"},
+	}
+	legacyBackendOptions = []legacyOption{
+		{Old: "shadow", Namespace: proxyNamespace, Key: "shadow"},
+		{Old: "flatmap_filter", Namespace: proxyNamespace, Key: "flatmap_filter"},
+	}
+	legacyEndpointOptions = []legacyOption{
+		{Old: "sequential", Namespace: proxyNamespace, Key: "sequential"},
+	}
+)
+
+// Migrate rewrites the legacy options of the received JSON config into their current
+// locations and returns the updated config, ready to be dumped, with the report of the
+// transformations. The legacy constructs that can not be migrated are kept as they are
+// and listed in the report.
+//
+// The namespaces of the extra configs are renamed with the ExtraConfigAlias table, so the
+// aliases registered by the components are migrated as well. The rest of the namespaces are
+// left untouched.
+func Migrate(data []byte) ([]byte, MigrationReport, error) {
+	cfg := map[string]interface{}{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, MigrationReport{}, err
+	}
+	report := MigrateMap(cfg)
+	res, err := json.MarshalIndent(cfg, "", "  ")
+	return res, report, err
+}
+
+// MigrateMap rewrites the legacy options of the received decoded JSON config in place
+func MigrateMap(cfg map[string]interface{}) MigrationReport {
+	r := &MigrationReport{}
+	migrateExtraConfig(r, "service", cfg)
+
+	endpoints, _ := cfg["endpoints"].([]interface{})
+	for i, e := range endpoints {
+		endpoint, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := fmt.Sprintf("endpoints[%d]", i)
+		migrateFields(r, path, endpoint, legacyEndpointFields)
+		migrateExtraConfig(r, path, endpoint)
+		migrateOptions(r, path, endpoint, legacyEndpointOptions)
+		migrateBackends(r, path, endpoint)
+	}
+
+	agents, _ := cfg["async_agent"].([]interface{})
+	for i, a := range agents {
+		agent, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := fmt.Sprintf("async_agent[%d]", i)
+		migrateExtraConfig(r, path, agent)
+		migrateBackends(r, path, agent)
+	}
+	return *r
+}
+
+func migrateBackends(r *MigrationReport, parent string, cfg map[string]interface{}) {
+	backends, _ := cfg["backend"].([]interface{})
+	for i, b := range backends {
+		backend, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := fmt.Sprintf("%s.backend[%d]", parent, i)
+		migrateFields(r, path, backend, legacyBackendFields)
+		migrateExtraConfig(r, path, backend)
+		migrateOptions(r, path, backend, legacyBackendOptions)
+	}
+}
+
+func migrateFields(r *MigrationReport, path string, cfg map[string]interface{}, fields []legacyField) {
+	for _, f := range fields {
+		v, ok := cfg[f.Old]
+		if !ok {
+			continue
+		}
+		if _, ok := cfg[f.New]; ok {
+			r.Unknown = append(r.Unknown, MigrationChange{
+				Path:   path,
+				From:   f.Old,
+				Reason: fmt.Sprintf("'%s' is already defined", f.New),
+			})
+			continue
+		}
+		cfg[f.New] = v
+		delete(cfg, f.Old)
+		r.Changes = append(r.Changes, MigrationChange{Path: path, From: f.Old, To: f.New})
+	}
+}
+
+func migrateOptions(r *MigrationReport, path string, cfg map[string]interface{}, options []legacyOption) {
+	for _, o := range options {
+		v, ok := cfg[o.Old]
+		if !ok {
+			continue
+		}
+		extra, ok := extraConfigOf(cfg)
+		if !ok {
+			r.Unknown = append(r.Unknown, MigrationChange{Path: path, From: o.Old, Reason: "invalid extra_config"})
+			continue
+		}
+		ns, ok := extra[o.Namespace].(map[string]interface{})
+		if !ok {
+			if _, exists := extra[o.Namespace]; exists {
+				r.Unknown = append(r.Unknown, MigrationChange{Path: path, From: o.Old, Reason: fmt.Sprintf("invalid namespace '%s'", o.Namespace)})
+				continue
+			}
+			ns = map[string]interface{}{}
+			extra[o.Namespace] = ns
+		}
+		if _, ok := ns[o.Key]; ok {
+			r.Unknown = append(r.Unknown, MigrationChange{
+				Path:   path,
+				From:   o.Old,
+				Reason: fmt.Sprintf("'%s' is already defined in the namespace '%s'", o.Key, o.Namespace),
+			})
+			continue
+		}
+		ns[o.Key] = v
+		delete(cfg, o.Old)
+		r.Changes = append(r.Changes, MigrationChange{
+			Path: path,
+			From: o.Old,
+			To:   fmt.Sprintf("extra_config[%s].%s", o.Namespace, o.Key),
+		})
+	}
+}
+
+func migrateExtraConfig(r *MigrationReport, path string, cfg map[string]interface{}) {
+	extra, ok := cfg["extra_config"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, legacy := range keys {
+		current, ok := ExtraConfigAlias[legacy]
+		if !ok {
+			continue
+		}
+
+		from, to := fmt.Sprintf("extra_config[%s]", legacy), fmt.Sprintf("extra_config[%s]", current)
+		target, exists := extra[current]
+		if !exists {
+			extra[current] = extra[legacy]
+			delete(extra, legacy)
+			r.Changes = append(r.Changes, MigrationChange{Path: path, From: from, To: to})
+			continue
+		}
+
+		src, srcOK := extra[legacy].(map[string]interface{})
+		dst, dstOK := target.(map[string]interface{})
+		if !srcOK || !dstOK {
+			r.Unknown = append(r.Unknown, MigrationChange{Path: path, From: from, Reason: fmt.Sprintf("'%s' is already defined", to)})
+			continue
+		}
+		conflict := false
+		for k := range src {
+			if _, ok := dst[k]; ok {
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			r.Unknown = append(r.Unknown, MigrationChange{Path: path, From: from, Reason: fmt.Sprintf("some options are already defined in '%s'", to)})
+			continue
+		}
+		for k, v := range src {
+			dst[k] = v
+		}
+		delete(extra, legacy)
+		r.Changes = append(r.Changes, MigrationChange{Path: path, From: from, To: to})
+	}
+}
+
+func extraConfigOf(cfg map[string]interface{}) (map[string]interface{}, bool) {
+	v, ok := cfg["extra_config"]
+	if !ok || v == nil {
+		extra := map[string]interface{}{}
+		cfg["extra_config"] = extra
+		return extra, true
+	}
+	extra, ok := v.(map[string]interface{})
+	return extra, ok
+}
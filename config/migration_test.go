@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const legacyConfigFixture = `{
+	"version": 3,
+	"name": "legacy",
+	"port": 8080,
+	"host": ["http://example.com"],
+	"extra_config": {
+		"github_com/devopsfaith/krakend/transport/http/server": {
+			"load_shedding": {"max_in_flight": 100}
+		},
+		"github_com/devopsfaith/krakend-cors": {"allow_origins": ["*"]}
+	},
+	"endpoints": [
+		{
+			"endpoint": "/users/{id}",
+			"querystring_params": ["page"],
+			"headers_to_pass": ["X-Tenant"],
+			"sequential": true,
+			"backend": [
+				{
+					"url_pattern": "/users/{id}",
+					"whitelist": ["id", "name"]
+				},
+				{
+					"url_pattern": "/orders/{resp0_id}",
+					"blacklist": ["secret"],
+					"extra_config": {
+						"github_com/devopsfaith/krakend/proxy": {"cache": {"ttl": "1m"}}
+					}
+				},
+				{
+					"url_pattern": "/audit",
+					"shadow": true,
+					"headers_to_pass": ["X-Trace"],
+					"input_headers": ["X-Request-Id"]
+				}
+			]
+		}
+	]
+}`
+
+const currentConfigFixture = `{
+	"version": 3,
+	"name": "current",
+	"port": 8080,
+	"host": ["http://example.com"],
+	"extra_config": {
+		"github_com/luraproject/lura/transport/http/server": {
+			"load_shedding": {"max_in_flight": 100}
+		},
+		"github_com/devopsfaith/krakend-cors": {"allow_origins": ["*"]}
+	},
+	"endpoints": [
+		{
+			"endpoint": "/users/{id}",
+			"input_query_strings": ["page"],
+			"input_headers": ["X-Tenant"],
+			"extra_config": {
+				"github.com/devopsfaith/krakend/proxy": {"sequential": true}
+			},
+			"backend": [
+				{
+					"url_pattern": "/users/{id}",
+					"allow": ["id", "name"]
+				},
+				{
+					"url_pattern": "/orders/{resp0_id}",
+					"deny": ["secret"],
+					"extra_config": {
+						"github.com/devopsfaith/krakend/proxy": {"cache": {"ttl": "1m"}}
+					}
+				},
+				{
+					"url_pattern": "/audit",
+					"headers_to_pass": ["X-Trace"],
+					"input_headers": ["X-Request-Id"],
+					"extra_config": {
+						"github.com/devopsfaith/krakend/proxy": {"shadow": true}
+					}
+				}
+			]
+		}
+	]
+}`
+
+func TestNewMigratingParser(t *testing.T) {
+	var reports []MigrationReport
+	legacy, err := NewMigratingParser(func(string) ([]byte, error) {
+		return []byte(legacyConfigFixture), nil
+	}, func(_ string, r MigrationReport) {
+		reports = append(reports, r)
+	}).Parse("legacy.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var currentReports []MigrationReport
+	current, err := NewMigratingParser(func(string) ([]byte, error) {
+		return []byte(currentConfigFixture), nil
+	}, func(_ string, r MigrationReport) {
+		currentReports = append(currentReports, r)
+	}).Parse("current.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	legacyHash, _ := legacy.Hash()
+	currentHash, _ := current.Hash()
+	if legacyHash != currentHash {
+		t.Errorf("the migrated config is not equivalent to the current one")
+	}
+	if b := current.Endpoints[0].Backend[2]; len(b.HeadersToPass) != 1 || b.HeadersToPass[0] != "X-Request-Id" {
+		t.Errorf("unexpected headers to pass: %v", b.HeadersToPass)
+	}
+
+	if len(reports) != 1 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+		return
+	}
+	expectedChanges := []string{
+		"service: 'extra_config[github_com/devopsfaith/krakend/transport/http/server]' moved to 'extra_config[github_com/luraproject/lura/transport/http/server]'",
+		"endpoints[0]: 'querystring_params' moved to 'input_query_strings'",
+		"endpoints[0]: 'headers_to_pass' moved to 'input_headers'",
+		"endpoints[0]: 'sequential' moved to 'extra_config[github.com/devopsfaith/krakend/proxy].sequential'",
+		"endpoints[0].backend[0]: 'whitelist' moved to 'allow'",
+		"endpoints[0].backend[1]: 'blacklist' moved to 'deny'",
+		"endpoints[0].backend[1]: 'extra_config[github_com/devopsfaith/krakend/proxy]' moved to 'extra_config[github.com/devopsfaith/krakend/proxy]'",
+		"endpoints[0].backend[2]: 'shadow' moved to 'extra_config[github.com/devopsfaith/krakend/proxy].shadow'",
+	}
+	if len(reports[0].Changes) != len(expectedChanges) {
+		t.Errorf("unexpected changes: %v", reports[0].Changes)
+	}
+	for i, c := range reports[0].Changes {
+		if i < len(expectedChanges) && c.String() != expectedChanges[i] {
+			t.Errorf("#%d: unexpected change.\nhave: %s\nwant: %s", i, c.String(), expectedChanges[i])
+		}
+	}
+
+	expectedUnknown := []string{
+		"endpoints[0].backend[2]: 'headers_to_pass' not migrated: 'input_headers' is already defined",
+	}
+	if len(reports[0].Unknown) != len(expectedUnknown) {
+		t.Errorf("unexpected unknown constructs: %v", reports[0].Unknown)
+	}
+	for i, c := range reports[0].Unknown {
+		if i < len(expectedUnknown) && c.String() != expectedUnknown[i] {
+			t.Errorf("#%d: unexpected unknown construct.\nhave: %s\nwant: %s", i, c.String(), expectedUnknown[i])
+		}
+	}
+
+	if len(currentReports) != 1 || len(currentReports[0].Changes) != 0 || len(currentReports[0].Unknown) != len(expectedUnknown) {
+		t.Errorf("the current config should just report the unknown constructs: %v", currentReports)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dump, report, err := Migrate([]byte(legacyConfigFixture))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if report.Empty() {
+		t.Error("the report should not be empty")
+	}
+
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(dump, &data); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := data["extra_config"].(map[string]interface{})["github_com/devopsfaith/krakend-cors"]; !ok {
+		t.Error("the namespaces without an alias should be kept")
+	}
+	for _, legacyKey := range []string{`"whitelist"`, `"blacklist"`, `"querystring_params"`, `"sequential": true,`} {
+		if strings.Contains(string(dump), legacyKey) {
+			t.Errorf("the dump still contains the legacy key %s", legacyKey)
+		}
+	}
+
+	_, report, err = Migrate(dump)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(report.Changes) != 0 {
+		t.Errorf("the migrated config should not require more changes: %v", report.Changes)
+	}
+
+	if _, _, err := Migrate([]byte("{")); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestMigrateMap_registeredAlias(t *testing.T) {
+	ExtraConfigAlias["github_com/devopsfaith/krakend-legacy"] = "github_com/luraproject/lura/legacy"
+	defer delete(ExtraConfigAlias, "github_com/devopsfaith/krakend-legacy")
+
+	cfg := map[string]interface{}{
+		"extra_config": map[string]interface{}{
+			"github_com/devopsfaith/krakend-legacy": map[string]interface{}{"foo": "bar"},
+			"github.com/devopsfaith/krakend-ce":     map[string]interface{}{"foo": "bar"},
+		},
+	}
+	report := MigrateMap(cfg)
+	if len(report.Changes) != 1 || len(report.Unknown) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	extra := cfg["extra_config"].(map[string]interface{})
+	if _, ok := extra["github_com/luraproject/lura/legacy"]; !ok {
+		t.Errorf("the registered alias was not migrated: %v", extra)
+	}
+	if _, ok := extra["github.com/devopsfaith/krakend-ce"]; !ok {
+		t.Errorf("the namespace without an alias should be kept: %v", extra)
+	}
+}
@@ -31,8 +31,16 @@ func NewParserWithFileReader(f FileReaderFunc) Parser {
 	return parser{fileReader: f}
 }
 
+// NewMigratingParser returns a Parser migrating the legacy options of the config files (see
+// Migrate) before parsing them. The report of the migration is sent to the received function
+// if the config contains any legacy construct, so the caller can warn about them.
+func NewMigratingParser(f FileReaderFunc, report func(configFile string, r MigrationReport)) Parser {
+	return parser{fileReader: f, migrationReport: report}
+}
+
 type parser struct {
-	fileReader FileReaderFunc
+	fileReader      FileReaderFunc
+	migrationReport func(string, MigrationReport)
 }
 
 // Parser implements the Parse interface
@@ -43,6 +51,11 @@ func (p parser) Parse(configFile string) (ServiceConfig, error) {
 	if err != nil {
 		return result, CheckErr(err, configFile)
 	}
+	if p.migrationReport != nil {
+		if data, err = p.migrate(configFile, data); err != nil {
+			return result, CheckErr(err, configFile)
+		}
+	}
 	if err = json.Unmarshal(data, &cfg); err != nil {
 		return result, CheckErr(err, configFile)
 	}
@@ -55,6 +68,24 @@ func (p parser) Parse(configFile string) (ServiceConfig, error) {
 	return result, nil
 }
 
+// migrate applies the migration to the raw config. The config is only encoded again when
+// some option was moved, so the current configs are parsed from their original bytes.
+func (p parser) migrate(configFile string, data []byte) ([]byte, error) {
+	cfg := map[string]interface{}{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	report := MigrateMap(cfg)
+	if report.Empty() {
+		return data, nil
+	}
+	p.migrationReport(configFile, report)
+	if len(report.Changes) == 0 {
+		return data, nil
+	}
+	return json.Marshal(cfg)
+}
+
 // CheckErr returns a proper documented error
 func CheckErr(err error, configFile string) error {
 	switch e := err.(type) {
@@ -223,6 +254,7 @@ func (p *parseableServiceConfig) normalize() ServiceConfig {
 			CurvePreferences:         p.ClientTLS.CurvePreferences,
 			CipherSuites:             p.ClientTLS.CipherSuites,
 			ClientCerts:              make([]ClientTLSCert, 0, len(p.ClientTLS.ClientCerts)),
+			ClientSessionCacheSize:   p.ClientTLS.ClientSessionCacheSize,
 		}
 		for _, cc := range p.ClientTLS.ClientCerts {
 			cfg.ClientTLS.ClientCerts = append(cfg.ClientTLS.ClientCerts, ClientTLSCert(cc))
@@ -267,6 +299,7 @@ type parseableClientTLS struct {
 	CurvePreferences         []uint16                 `json:"curve_preferences"`
 	CipherSuites             []uint16                 `json:"cipher_suites"`
 	ClientCerts              []parseableClientTLSCert `json:"client_certs"`
+	ClientSessionCacheSize   int                      `json:"client_session_cache_size"`
 }
 
 type parseableClientTLSCert struct {
@@ -355,22 +388,23 @@ func (p *parseableAsyncAgent) normalize() *AsyncAgent {
 }
 
 type parseableBackend struct {
-	Group                    string            `json:"group"`
-	Method                   string            `json:"method"`
-	Host                     []string          `json:"host"`
-	HostSanitizationDisabled bool              `json:"disable_host_sanitize"`
-	URLPattern               string            `json:"url_pattern"`
-	AllowList                []string          `json:"allow"`
-	DenyList                 []string          `json:"deny"`
-	Mapping                  map[string]string `json:"mapping"`
-	Encoding                 string            `json:"encoding"`
-	IsCollection             bool              `json:"is_collection"`
-	Target                   string            `json:"target"`
-	ExtraConfig              *ExtraConfig      `json:"extra_config,omitempty"`
-	SD                       string            `json:"sd"`
-	HeadersToPass            []string          `json:"input_headers"`
-	SDScheme                 string            `json:"sd_scheme"`
-	QueryStringsToPass       []string          `json:"input_query_strings"`
+	Group                    string              `json:"group"`
+	Method                   string              `json:"method"`
+	Host                     []string            `json:"host"`
+	HostSanitizationDisabled bool                `json:"disable_host_sanitize"`
+	URLPattern               string              `json:"url_pattern"`
+	AllowList                []string            `json:"allow"`
+	DenyList                 []string            `json:"deny"`
+	Mapping                  map[string]string   `json:"mapping"`
+	Encoding                 string              `json:"encoding"`
+	IsCollection             bool                `json:"is_collection"`
+	Target                   string              `json:"target"`
+	ExtraConfig              *ExtraConfig        `json:"extra_config,omitempty"`
+	SD                       string              `json:"sd"`
+	HeadersToPass            []string            `json:"input_headers"`
+	SDScheme                 string              `json:"sd_scheme"`
+	QueryStringsToPass       []string            `json:"input_query_strings"`
+	StatusErrors             map[int]StatusError `json:"status_errors"`
 }
 
 func (p *parseableBackend) normalize() *Backend {
@@ -390,6 +424,7 @@ func (p *parseableBackend) normalize() *Backend {
 		DenyList:                 p.DenyList,
 		HeadersToPass:            p.HeadersToPass,
 		QueryStringsToPass:       p.QueryStringsToPass,
+		StatusErrors:             p.StatusErrors,
 	}
 	if b.SDScheme == "" {
 		b.SDScheme = "http"
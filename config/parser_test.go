@@ -68,7 +68,11 @@ func TestNewParser_ok(t *testing.T) {
                     "host": [
                         "http://127.0.0.1:8080"
                     ],
-                    "url_pattern": "/__debug/supu"
+                    "url_pattern": "/__debug/supu",
+                    "status_errors": {
+                        "409": {"error": "conflict", "message": "the resource already exists"},
+                        "418": {"error": "teapot", "status_code": 503}
+                    }
                 }
             ]
         },
@@ -147,6 +151,17 @@ func TestNewParser_ok(t *testing.T) {
 		t.Error("Extra config is not present in BackendConfig")
 	}
 
+	statusErrors := serviceConfig.Endpoints[1].Backend[0].StatusErrors
+	if len(statusErrors) != 2 {
+		t.Errorf("unexpected status errors: %v", statusErrors)
+	}
+	if e := statusErrors[409]; e.Error != "conflict" || e.Message != "the resource already exists" || e.StatusCode != 0 {
+		t.Errorf("unexpected status error for 409: %+v", e)
+	}
+	if e := statusErrors[418]; e.Error != "teapot" || e.StatusCode != 503 {
+		t.Errorf("unexpected status error for 418: %+v", e)
+	}
+
 	if err := os.Remove(configPath); err != nil {
 		t.FailNow()
 	}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import "fmt"
+
+// PipelinesNamespace is the key of the extra config defining the named pipelines at the
+// service level and referencing them at the endpoint level.
+//
+// A pipeline is a fragment of extra config (usually, a set of response transformations) to
+// share between endpoints:
+//
+//	"extra_config": {
+//		"github_com/luraproject/lura/pipelines": {
+//			"public_user": {
+//				"github.com/devopsfaith/krakend/proxy": {
+//					"unflatten": {},
+//					"clamp": [{ "field": "age", "min": 0, "max": 150 }]
+//				}
+//			}
+//		}
+//	}
+//
+// The endpoints reference the pipelines by name, with a string or a list of strings:
+//
+//	"extra_config": { "github_com/luraproject/lura/pipelines": ["public_user"] }
+//
+// The pipelines are merged into the extra config of the endpoints during the init of the
+// configuration, so the components of the endpoint can not tell them apart from its own
+// extra config. The keys defined by the endpoint win over the ones of the pipelines, and
+// the pipelines listed first win over the next ones.
+const PipelinesNamespace = "github_com/luraproject/lura/pipelines"
+
+// UndefinedPipelineError is the error returned by the configuration init process when an
+// endpoint references a pipeline not defined at the service level
+type UndefinedPipelineError struct {
+	Path     string
+	Method   string
+	Pipeline string
+}
+
+// Error returns a string representation of the UndefinedPipelineError
+func (u *UndefinedPipelineError) Error() string {
+	return fmt.Sprintf("ignoring the '%s %s' endpoint, since the pipeline '%s' is not defined", u.Method, u.Path, u.Pipeline)
+}
+
+func (s *ServiceConfig) initEndpointPipelines(e *EndpointConfig) error {
+	var names []string
+	switch v := e.ExtraConfig[PipelinesNamespace].(type) {
+	case string:
+		names = []string{v}
+	case []interface{}:
+		for _, n := range v {
+			if name, ok := n.(string); ok {
+				names = append(names, name)
+			}
+		}
+	default:
+		return nil
+	}
+
+	pipelines := stringKeys(s.ExtraConfig[PipelinesNamespace])
+	for _, name := range names {
+		pipeline, ok := pipelines[name]
+		if !ok {
+			return &UndefinedPipelineError{Path: e.Endpoint, Method: e.Method, Pipeline: name}
+		}
+		for namespace, cfg := range stringKeys(pipeline) {
+			mergeExtraConfig(e.ExtraConfig, namespace, cfg)
+		}
+	}
+	return nil
+}
+
+// mergeExtraConfig adds the cfg to the namespace of the extra config, keeping the existing
+// values. The namespaces are always copied before adding the missing keys, so the endpoints
+// referencing the same pipeline do not share them.
+func mergeExtraConfig(extra ExtraConfig, namespace string, cfg interface{}) {
+	current, ok := extra[namespace]
+	if !ok {
+		switch cfg.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			current = map[string]interface{}{}
+		default:
+			extra[namespace] = cfg
+			return
+		}
+	}
+
+	base, ok := current.(map[string]interface{})
+	if !ok {
+		return
+	}
+	merged := map[string]interface{}{}
+	for k, v := range stringKeys(cfg) {
+		merged[k] = v
+	}
+	for k, v := range base {
+		merged[k] = v
+	}
+	extra[namespace] = merged
+}
+
+// stringKeys returns the received value as a map with string keys, accepting the maps
+// decoded from yaml files
+func stringKeys(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			res[fmt.Sprintf("%v", k)] = v
+		}
+		return res
+	}
+	return map[string]interface{}{}
+}
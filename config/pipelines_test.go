@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfig_initEndpointPipelines(t *testing.T) {
+	proxyNamespace := "github.com/devopsfaith/krakend/proxy"
+	users := EndpointConfig{
+		Endpoint: "/users",
+		Backend:  []*Backend{{URLPattern: "/users"}},
+		ExtraConfig: ExtraConfig{
+			PipelinesNamespace: "public_user",
+		},
+	}
+	admins := EndpointConfig{
+		Endpoint: "/admins",
+		Backend:  []*Backend{{URLPattern: "/admins"}},
+		ExtraConfig: ExtraConfig{
+			PipelinesNamespace: []interface{}{"public_user", "audit"},
+		},
+	}
+	custom := EndpointConfig{
+		Endpoint: "/custom",
+		Backend:  []*Backend{{URLPattern: "/custom"}},
+		ExtraConfig: ExtraConfig{
+			PipelinesNamespace: []interface{}{"public_user"},
+			proxyNamespace:     map[string]interface{}{"unflatten": map[string]interface{}{"separator": "_"}},
+		},
+	}
+
+	subject := ServiceConfig{
+		Version:   ConfigVersion,
+		Host:      []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{&users, &admins, &custom},
+		ExtraConfig: ExtraConfig{
+			PipelinesNamespace: map[string]interface{}{
+				"public_user": map[string]interface{}{
+					proxyNamespace: map[interface{}]interface{}{
+						"unflatten": map[string]interface{}{},
+						"clamp":     []interface{}{map[string]interface{}{"field": "age", "min": 0.0, "max": 150.0}},
+					},
+				},
+				"audit": map[string]interface{}{
+					proxyNamespace: map[string]interface{}{"unflatten": "ignored"},
+					"audit":        map[string]interface{}{"enabled": true},
+				},
+			},
+		},
+	}
+
+	if err := subject.Init(); err != nil {
+		t.Error("Error at the configuration init:", err.Error())
+		return
+	}
+
+	expected := map[string]interface{}{
+		"unflatten": map[string]interface{}{},
+		"clamp":     []interface{}{map[string]interface{}{"field": "age", "min": 0.0, "max": 150.0}},
+	}
+	if !reflect.DeepEqual(users.ExtraConfig[proxyNamespace], expected) {
+		t.Errorf("unexpected extra config for %s: %v", users.Endpoint, users.ExtraConfig[proxyNamespace])
+	}
+	if !reflect.DeepEqual(admins.ExtraConfig[proxyNamespace], expected) {
+		t.Errorf("unexpected extra config for %s: %v", admins.Endpoint, admins.ExtraConfig[proxyNamespace])
+	}
+	if !reflect.DeepEqual(admins.ExtraConfig["audit"], map[string]interface{}{"enabled": true}) {
+		t.Errorf("unexpected audit config: %v", admins.ExtraConfig["audit"])
+	}
+
+	cfg := custom.ExtraConfig[proxyNamespace].(map[string]interface{})
+	if !reflect.DeepEqual(cfg["unflatten"], map[string]interface{}{"separator": "_"}) {
+		t.Errorf("the endpoint config should win over the pipeline: %v", cfg)
+	}
+	if _, ok := cfg["clamp"]; !ok {
+		t.Errorf("the pipeline keys should be added: %v", cfg)
+	}
+
+	users.ExtraConfig[proxyNamespace].(map[string]interface{})["extra"] = true
+	if _, ok := admins.ExtraConfig[proxyNamespace].(map[string]interface{})["extra"]; ok {
+		t.Error("the endpoints should not share their extra config")
+	}
+}
+
+func TestConfig_initEndpointPipelines_undefined(t *testing.T) {
+	subject := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint:    "/users",
+				Backend:     []*Backend{{URLPattern: "/users"}},
+				ExtraConfig: ExtraConfig{PipelinesNamespace: "unknown"},
+			},
+		},
+	}
+
+	err := subject.Init()
+	if err == nil {
+		t.Error("error expected")
+		return
+	}
+	if err.Error() != "ignoring the 'GET /users' endpoint, since the pipeline 'unknown' is not defined" {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import "sync"
+
+// BackendValidator checks the definition of a backend once its defaults have been applied.
+// The packages consuming the backend config can register validators in order to reject
+// invalid definitions while the configuration is being initialized.
+type BackendValidator func(*Backend) error
+
+// EndpointValidator checks the definition of an endpoint once its defaults and the defaults
+// of its backends have been applied
+type EndpointValidator func(*EndpointConfig) error
+
+var (
+	backendValidators   = []BackendValidator{}
+	backendValidatorsMu = new(sync.RWMutex)
+
+	endpointValidators   = []EndpointValidator{}
+	endpointValidatorsMu = new(sync.RWMutex)
+)
+
+// RegisterBackendValidator adds a validator to the set executed by the Init method
+// over every backend
+func RegisterBackendValidator(v BackendValidator) {
+	backendValidatorsMu.Lock()
+	backendValidators = append(backendValidators, v)
+	backendValidatorsMu.Unlock()
+}
+
+func validateBackend(b *Backend) error {
+	backendValidatorsMu.RLock()
+	defer backendValidatorsMu.RUnlock()
+
+	for _, v := range backendValidators {
+		if err := v(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterEndpointValidator adds a validator to the set executed by the Init method
+// over every endpoint
+func RegisterEndpointValidator(v EndpointValidator) {
+	endpointValidatorsMu.Lock()
+	endpointValidators = append(endpointValidators, v)
+	endpointValidatorsMu.Unlock()
+}
+
+func validateEndpoint(e *EndpointConfig) error {
+	endpointValidatorsMu.RLock()
+	defer endpointValidatorsMu.RUnlock()
+
+	for _, v := range endpointValidators {
+		if err := v(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegisterBackendValidator(t *testing.T) {
+	errWrongBackend := errors.New("wrong backend")
+	RegisterBackendValidator(func(b *Backend) error {
+		if b.URLPattern == "/wrong" {
+			return errWrongBackend
+		}
+		return nil
+	})
+	defer func() {
+		backendValidatorsMu.Lock()
+		backendValidators = backendValidators[:len(backendValidators)-1]
+		backendValidatorsMu.Unlock()
+	}()
+
+	newSubject := func(pattern string) ServiceConfig {
+		return ServiceConfig{
+			Version: ConfigVersion,
+			Host:    []string{"http://127.0.0.1:8080"},
+			Endpoints: []*EndpointConfig{
+				{
+					Endpoint: "/supu",
+					Method:   "GET",
+					Backend:  []*Backend{{URLPattern: pattern}},
+				},
+			},
+		}
+	}
+
+	subject := newSubject("/right")
+	if err := subject.Init(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	subject = newSubject("/wrong")
+	if err := subject.Init(); err != errWrongBackend {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRegisterEndpointValidator(t *testing.T) {
+	errWrongEndpoint := errors.New("wrong endpoint")
+	RegisterEndpointValidator(func(e *EndpointConfig) error {
+		if len(e.Backend) > 1 && e.Backend[1].URLPattern == "/wrong" {
+			return errWrongEndpoint
+		}
+		return nil
+	})
+	defer func() {
+		endpointValidatorsMu.Lock()
+		endpointValidators = endpointValidators[:len(endpointValidators)-1]
+		endpointValidatorsMu.Unlock()
+	}()
+
+	newSubject := func(pattern string) ServiceConfig {
+		return ServiceConfig{
+			Version: ConfigVersion,
+			Host:    []string{"http://127.0.0.1:8080"},
+			Endpoints: []*EndpointConfig{
+				{
+					Endpoint: "/supu",
+					Method:   "GET",
+					Backend:  []*Backend{{URLPattern: "/a"}, {URLPattern: pattern}},
+				},
+			},
+		}
+	}
+
+	subject := newSubject("/right")
+	if err := subject.Init(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	subject = newSubject("/wrong")
+	if err := subject.Init(); err != errWrongEndpoint {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
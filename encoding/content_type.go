@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// MatchContentType checks if the media type of the content type matches the pattern.
+// The parameters of the content type (like the charset) are ignored and the comparison
+// is case insensitive. Both the type and the subtype of the pattern accept wildcards,
+// so patterns like "*/*", "text/*" or "application/*+json" are supported.
+func MatchContentType(pattern, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	patternParts := strings.SplitN(pattern, "/", 2)
+	typeParts := strings.SplitN(mediaType, "/", 2)
+	if len(patternParts) != 2 || len(typeParts) != 2 {
+		return false
+	}
+
+	for i := range patternParts {
+		if ok, err := path.Match(patternParts[i], typeParts[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchAnyContentType checks if the content type matches any of the patterns
+func MatchAnyContentType(patterns []string, contentType string) bool {
+	for _, p := range patterns {
+		if MatchContentType(p, contentType) {
+			return true
+		}
+	}
+	return false
+}
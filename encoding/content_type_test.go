@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import "testing"
+
+func TestMatchContentType(t *testing.T) {
+	for _, tc := range []struct {
+		pattern     string
+		contentType string
+		expected    bool
+	}{
+		{pattern: "application/json", contentType: "application/json", expected: true},
+		{pattern: "application/json", contentType: "Application/JSON; charset=utf-8", expected: true},
+		{pattern: "application/json", contentType: "text/html", expected: false},
+		{pattern: "application/json", contentType: "application/vnd.api+json", expected: false},
+		{pattern: "application/*+json", contentType: "application/vnd.api+json", expected: true},
+		{pattern: "application/*+json", contentType: "application/problem+json; charset=utf-8", expected: true},
+		{pattern: "application/*+json", contentType: "application/json", expected: false},
+		{pattern: "application/*+json", contentType: "text/vnd.api+json", expected: false},
+		{pattern: "text/*", contentType: "text/html", expected: true},
+		{pattern: "*/*", contentType: "image/png", expected: true},
+		{pattern: "*/*", contentType: "", expected: false},
+		{pattern: "application", contentType: "application/json", expected: false},
+		{pattern: "application/[", contentType: "application/json", expected: false},
+	} {
+		if res := MatchContentType(tc.pattern, tc.contentType); res != tc.expected {
+			t.Errorf("%s - %s: unexpected result %t", tc.pattern, tc.contentType, res)
+		}
+	}
+}
+
+func TestMatchAnyContentType(t *testing.T) {
+	patterns := []string{"application/json", "application/*+json"}
+	if !MatchAnyContentType(patterns, "application/hal+json") {
+		t.Error("the content type should match")
+	}
+	if MatchAnyContentType(patterns, "text/html") {
+		t.Error("the content type should not match")
+	}
+	if MatchAnyContentType(nil, "text/html") {
+		t.Error("the content type should not match an empty list")
+	}
+}
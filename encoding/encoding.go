@@ -89,6 +89,15 @@ func SafeJSONDecoder(r io.Reader, v *map[string]interface{}) error {
 	return nil
 }
 
+// AUTO is the key for the content type negotiation: the decoder of every response is selected
+// by its content type (see DecoderRegister.GetByContentType)
+const AUTO = "auto"
+
+// XML is the key for the xml encoding. There is no XML decoder registered by default, but
+// the content types of the XML documents are associated with it, so the decoder registered
+// by an external package under this name is used by the AUTO encoding.
+const XML = "xml"
+
 // STRING is the key for the string encoding
 const STRING = "string"
 
@@ -163,3 +163,60 @@ func (e erroredReader) Error() string {
 func (e erroredReader) Read(_ []byte) (n int, err error) {
 	return 0, e
 }
+
+func TestDecoderRegister_GetByContentType(t *testing.T) {
+	decoders = initDecoderRegister()
+	defer func() { decoders = initDecoderRegister() }()
+
+	if _, ok := decoders.GetByContentType("application/xml"); ok {
+		t.Error("the xml decoder is not registered by default")
+	}
+	if _, ok := decoders.GetByContentType(""); ok {
+		t.Error("the empty content type should not match any decoder")
+	}
+	if _, ok := decoders.GetByContentType("image/png"); ok {
+		t.Error("the unknown content types should not match any decoder")
+	}
+
+	xmlDecoder := func(_ bool) func(io.Reader, *map[string]interface{}) error {
+		return func(_ io.Reader, v *map[string]interface{}) error {
+			*v = map[string]interface{}{"xml": true}
+			return nil
+		}
+	}
+	decoders.Register(XML, xmlDecoder)
+	decoders.Register("csv", NewStringDecoder)
+	decoders.RegisterContentType("csv", "text/csv")
+
+	for _, tc := range []struct {
+		contentType string
+		expected    map[string]interface{}
+	}{
+		{contentType: "application/json; charset=utf-8", expected: map[string]interface{}{"a": "b"}},
+		{contentType: "application/problem+json", expected: map[string]interface{}{"a": "b"}},
+		{contentType: "text/xml", expected: map[string]interface{}{"xml": true}},
+		{contentType: "application/atom+xml", expected: map[string]interface{}{"xml": true}},
+		{contentType: "text/plain", expected: map[string]interface{}{"content": `{"a":"b"}`}},
+		{contentType: "text/csv", expected: map[string]interface{}{"content": `{"a":"b"}`}},
+	} {
+		dec, ok := decoders.GetByContentType(tc.contentType)
+		if !ok {
+			t.Errorf("%s: decoder not found", tc.contentType)
+			continue
+		}
+		var result map[string]interface{}
+		if err := dec(false)(strings.NewReader(`{"a":"b"}`), &result); err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.contentType, err.Error())
+			continue
+		}
+		if len(result) != len(tc.expected) {
+			t.Errorf("%s: unexpected result: %v", tc.contentType, result)
+			continue
+		}
+		for k, v := range tc.expected {
+			if result[k] != v {
+				t.Errorf("%s: unexpected result: %v", tc.contentType, result)
+			}
+		}
+	}
+}
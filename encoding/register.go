@@ -4,6 +4,7 @@ package encoding
 
 import (
 	"io"
+	"sync"
 
 	"github.com/luraproject/lura/v2/register"
 )
@@ -21,7 +22,14 @@ type untypedRegister interface {
 
 // DecoderRegister is the struct responsible of registering the decoder factories
 type DecoderRegister struct {
-	data untypedRegister
+	data         untypedRegister
+	mu           *sync.RWMutex
+	contentTypes []contentTypeDecoder
+}
+
+type contentTypeDecoder struct {
+	pattern string
+	name    string
 }
 
 // Register adds a decoder factory to the register
@@ -42,6 +50,38 @@ func (r *DecoderRegister) Get(name string) func(bool) func(io.Reader, *map[strin
 	return NewJSONDecoder
 }
 
+// RegisterContentType associates the content type patterns (see MatchContentType) with the
+// named decoder, so the backends using the AUTO encoding decode the responses with that
+// content type with it. The patterns are checked in the order they were registered.
+func (r *DecoderRegister) RegisterContentType(name string, patterns ...string) {
+	r.mu.Lock()
+	for _, p := range patterns {
+		r.contentTypes = append(r.contentTypes, contentTypeDecoder{pattern: p, name: name})
+	}
+	r.mu.Unlock()
+}
+
+// GetByContentType returns the factory of the first registered decoder associated with a
+// pattern matching the content type. The flag is false if there is no such decoder.
+func (r *DecoderRegister) GetByContentType(contentType string) (func(bool) func(io.Reader, *map[string]interface{}) error, bool) {
+	if contentType == "" {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, ct := range r.contentTypes {
+		if !MatchContentType(ct.pattern, contentType) {
+			continue
+		}
+		if v, ok := r.data.Get(ct.name); ok {
+			if dec, ok := v.(func(bool) func(io.Reader, *map[string]interface{}) error); ok {
+				return dec, true
+			}
+		}
+	}
+	return nil, false
+}
+
 var (
 	decoders        = initDecoderRegister()
 	defaultDecoders = map[string]func(bool) func(io.Reader, *map[string]interface{}) error{
@@ -50,12 +90,23 @@ var (
 		STRING:    NewStringDecoder,
 		NOOP:      noOpDecoderFactory,
 	}
+	defaultContentTypes = []contentTypeDecoder{
+		{pattern: "application/json", name: JSON},
+		{pattern: "application/*+json", name: JSON},
+		{pattern: "application/xml", name: XML},
+		{pattern: "text/xml", name: XML},
+		{pattern: "application/*+xml", name: XML},
+		{pattern: "text/plain", name: STRING},
+	}
 )
 
 func initDecoderRegister() *DecoderRegister {
-	r := &DecoderRegister{data: register.NewUntyped()}
+	r := &DecoderRegister{data: register.NewUntyped(), mu: new(sync.RWMutex)}
 	for k, v := range defaultDecoders {
 		r.Register(k, v)
 	}
+	for _, ct := range defaultContentTypes {
+		r.RegisterContentType(ct.name, ct.pattern)
+	}
 	return r
 }
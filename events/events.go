@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+	Package events provides a simple hook for exposing the internal counters and gauges of
+	the lura components to the instrumentation layer of the application embedding them.
+*/
+package events
+
+import "sync/atomic"
+
+// Recorder collects the counters and gauges emitted by the lura components
+type Recorder interface {
+	Counter(name string, delta int64, labels map[string]string)
+	Gauge(name string, value int64, labels map[string]string)
+}
+
+// NoOp is the NO-OP recorder
+var NoOp Recorder = noopRecorder{}
+
+type noopRecorder struct{}
+
+func (noopRecorder) Counter(_ string, _ int64, _ map[string]string) {}
+func (noopRecorder) Gauge(_ string, _ int64, _ map[string]string)   {}
+
+type recorderHolder struct {
+	Recorder
+}
+
+var defaultRecorder atomic.Value
+
+func init() {
+	defaultRecorder.Store(recorderHolder{NoOp})
+}
+
+// SetDefaultRecorder replaces the recorder used by the components not receiving an
+// explicit one. Passing a nil recorder restores the NO-OP one.
+func SetDefaultRecorder(r Recorder) {
+	if r == nil {
+		r = NoOp
+	}
+	defaultRecorder.Store(recorderHolder{r})
+}
+
+// DefaultRecorder returns the recorder used by the components not receiving an explicit one
+func DefaultRecorder() Recorder {
+	return defaultRecorder.Load().(recorderHolder).Recorder
+}
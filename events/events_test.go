@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package events
+
+import "testing"
+
+type dummyRecorder struct {
+	counters map[string]int64
+}
+
+func (d *dummyRecorder) Counter(name string, delta int64, _ map[string]string) {
+	d.counters[name] += delta
+}
+
+func (*dummyRecorder) Gauge(_ string, _ int64, _ map[string]string) {}
+
+func TestSetDefaultRecorder(t *testing.T) {
+	defer SetDefaultRecorder(nil)
+
+	if DefaultRecorder() != NoOp {
+		t.Error("the default recorder should be the NoOp one")
+	}
+
+	r := &dummyRecorder{counters: map[string]int64{}}
+	SetDefaultRecorder(r)
+	DefaultRecorder().Counter("foo", 2, nil)
+	DefaultRecorder().Counter("foo", 1, nil)
+
+	if v := r.counters["foo"]; v != 3 {
+		t.Errorf("unexpected counter value: %d", v)
+	}
+
+	SetDefaultRecorder(nil)
+	if DefaultRecorder() != NoOp {
+		t.Error("the default recorder should be the NoOp one")
+	}
+}
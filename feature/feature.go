@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package feature provides a set of named flags the lura components check on every request,
+so some behaviours can be switched on and off at runtime without restarting the service.
+*/
+package feature
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	flags   atomic.Value
+	flagsMu = &sync.Mutex{}
+)
+
+func init() {
+	flags.Store(map[string]bool{})
+}
+
+// Enabled returns the state of the named flag or the fallback value if it has not been set
+func Enabled(name string, fallback bool) bool {
+	if v, ok := flags.Load().(map[string]bool)[name]; ok {
+		return v
+	}
+	return fallback
+}
+
+// Set updates the state of the named flag
+func Set(name string, enabled bool) {
+	update(func(m map[string]bool) { m[name] = enabled })
+}
+
+// Unset removes the named flag, so the components use their default behaviour again
+func Unset(name string) {
+	update(func(m map[string]bool) { delete(m, name) })
+}
+
+// All returns a copy of the flags set
+func All() map[string]bool {
+	current := flags.Load().(map[string]bool)
+	res := make(map[string]bool, len(current))
+	for k, v := range current {
+		res[k] = v
+	}
+	return res
+}
+
+func update(f func(map[string]bool)) {
+	flagsMu.Lock()
+	m := All()
+	f(m)
+	flags.Store(m)
+	flagsMu.Unlock()
+}
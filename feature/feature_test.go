@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package feature
+
+import "testing"
+
+func TestSet(t *testing.T) {
+	defer Unset("foo")
+
+	if !Enabled("foo", true) || Enabled("foo", false) {
+		t.Error("the fallback value should be returned for unknown flags")
+	}
+
+	Set("foo", false)
+	if Enabled("foo", true) {
+		t.Error("the flag should be disabled")
+	}
+	if all := All(); len(all) != 1 || all["foo"] {
+		t.Errorf("unexpected flags: %v", all)
+	}
+
+	Set("foo", true)
+	if !Enabled("foo", false) {
+		t.Error("the flag should be enabled")
+	}
+
+	Unset("foo")
+	if Enabled("foo", false) {
+		t.Error("the fallback value should be returned for removed flags")
+	}
+	if all := All(); len(all) != 0 {
+		t.Errorf("unexpected flags: %v", all)
+	}
+}
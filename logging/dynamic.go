@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LevelController is implemented by the loggers allowing to change their level at runtime
+type LevelController interface {
+	// Level returns the name of the default level
+	Level() string
+	// SetLevel updates the default level
+	SetLevel(level string) error
+	// ModuleLevels returns the levels overriding the default one for some modules
+	ModuleLevels() map[string]string
+	// SetModuleLevel sets the level of the module. An empty level removes the override.
+	SetModuleLevel(module, level string) error
+}
+
+// NewDynamicLogger creates a logger whose levels can be updated at runtime
+func NewDynamicLogger(level string, out io.Writer, prefix string) (*DynamicLogger, error) {
+	l, ok := logLevels[strings.ToUpper(level)]
+	if !ok {
+		return nil, ErrInvalidLogLevel
+	}
+	d := &DynamicLogger{
+		level:  int32(l),
+		mu:     &sync.Mutex{},
+		Prefix: prefix,
+		Logger: log.New(out, "", log.LstdFlags),
+	}
+	d.modules.Store(map[string]int{})
+	return d, nil
+}
+
+// DynamicLogger is a Logger with a default level and a set of per module levels, all of
+// them safe to update while the logger is in use.
+//
+// The module of a message is taken from the tags at the beginning of its first argument,
+// so a message starting with "[ENDPOINT: /foo][Cache]" belongs to the module 'Cache' and,
+// if there is no level defined for it, to the module 'ENDPOINT'.
+type DynamicLogger struct {
+	level   int32
+	modules atomic.Value
+	mu      *sync.Mutex
+	Prefix  string
+	Logger  *log.Logger
+}
+
+// Level implements the LevelController interface
+func (l *DynamicLogger) Level() string {
+	return levelName(int(atomic.LoadInt32(&l.level)))
+}
+
+// SetLevel implements the LevelController interface
+func (l *DynamicLogger) SetLevel(level string) error {
+	lvl, ok := logLevels[strings.ToUpper(level)]
+	if !ok {
+		return ErrInvalidLogLevel
+	}
+	atomic.StoreInt32(&l.level, int32(lvl))
+	return nil
+}
+
+// ModuleLevels implements the LevelController interface
+func (l *DynamicLogger) ModuleLevels() map[string]string {
+	modules := l.modules.Load().(map[string]int)
+	res := make(map[string]string, len(modules))
+	for k, v := range modules {
+		res[k] = levelName(v)
+	}
+	return res
+}
+
+// SetModuleLevel implements the LevelController interface
+func (l *DynamicLogger) SetModuleLevel(module, level string) error {
+	lvl, ok := logLevels[strings.ToUpper(level)]
+	if !ok && level != "" {
+		return ErrInvalidLogLevel
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.modules.Load().(map[string]int)
+	modules := make(map[string]int, len(current)+1)
+	for k, v := range current {
+		modules[k] = v
+	}
+	if level == "" {
+		delete(modules, module)
+	} else {
+		modules[module] = lvl
+	}
+	l.modules.Store(modules)
+	return nil
+}
+
+// Debug logs a message using DEBUG as log level.
+func (l *DynamicLogger) Debug(v ...interface{}) {
+	if !l.enabled(LEVEL_DEBUG, v) {
+		return
+	}
+	l.prependLog("DEBUG:", v...)
+}
+
+// Info logs a message using INFO as log level.
+func (l *DynamicLogger) Info(v ...interface{}) {
+	if !l.enabled(LEVEL_INFO, v) {
+		return
+	}
+	l.prependLog("INFO:", v...)
+}
+
+// Warning logs a message using WARNING as log level.
+func (l *DynamicLogger) Warning(v ...interface{}) {
+	if !l.enabled(LEVEL_WARNING, v) {
+		return
+	}
+	l.prependLog("WARNING:", v...)
+}
+
+// Error logs a message using ERROR as log level.
+func (l *DynamicLogger) Error(v ...interface{}) {
+	if !l.enabled(LEVEL_ERROR, v) {
+		return
+	}
+	l.prependLog("ERROR:", v...)
+}
+
+// Critical logs a message using CRITICAL as log level.
+func (l *DynamicLogger) Critical(v ...interface{}) {
+	l.prependLog("CRITICAL:", v...)
+}
+
+// Fatal is equivalent to l.Critical(fmt.Sprint()) followed by a call to os.Exit(1).
+func (l *DynamicLogger) Fatal(v ...interface{}) {
+	l.prependLog("FATAL:", v...)
+	os.Exit(1)
+}
+
+func (l *DynamicLogger) enabled(level int, v []interface{}) bool {
+	if modules := l.modules.Load().(map[string]int); len(modules) > 0 && len(v) > 0 {
+		if msg, ok := v[0].(string); ok {
+			if lvl, ok := moduleLevel(modules, msg); ok {
+				return level >= lvl
+			}
+		}
+	}
+	return level >= int(atomic.LoadInt32(&l.level))
+}
+
+func (l *DynamicLogger) prependLog(level string, v ...interface{}) {
+	msg := make([]interface{}, len(v)+2)
+	msg[0] = l.Prefix
+	msg[1] = level
+	copy(msg[2:], v)
+	l.Logger.Println(msg...)
+}
+
+// moduleLevel returns the level of the most specific module found in the leading tags of
+// the message
+func moduleLevel(modules map[string]int, msg string) (int, bool) {
+	var tags []string
+	for strings.HasPrefix(msg, "[") {
+		end := strings.Index(msg, "]")
+		if end < 0 {
+			break
+		}
+		tags = append(tags, msg[1:end])
+		msg = msg[end+1:]
+	}
+
+	for i := len(tags) - 1; i >= 0; i-- {
+		if lvl, ok := modules[tags[i]]; ok {
+			return lvl, true
+		}
+		if j := strings.Index(tags[i], ":"); j >= 0 {
+			if lvl, ok := modules[tags[i][:j]]; ok {
+				return lvl, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func levelName(level int) string {
+	for k, v := range logLevels {
+		if v == level {
+			return k
+		}
+	}
+	return ""
+}
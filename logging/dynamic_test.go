@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewDynamicLogger(t *testing.T) {
+	buff := bytes.NewBuffer(make([]byte, 0, 1024))
+	l, err := NewDynamicLogger("ERROR", buff, "pref")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	l.Debug("[ENDPOINT: /foo][Cache]", debugMsg)
+	l.Info(infoMsg)
+	if buff.Len() != 0 {
+		t.Errorf("unexpected output: %s", buff.String())
+	}
+
+	if err := l.SetLevel("debug"); err != nil {
+		t.Error(err)
+		return
+	}
+	if l.Level() != "DEBUG" {
+		t.Errorf("unexpected level: %s", l.Level())
+	}
+	l.Debug("[ENDPOINT: /foo][Cache]", debugMsg)
+	if !strings.Contains(buff.String(), "pref DEBUG: [ENDPOINT: /foo][Cache] "+debugMsg) {
+		t.Errorf("unexpected output: %s", buff.String())
+	}
+
+	if err := l.SetLevel("unknown"); err != ErrInvalidLogLevel {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewDynamicLogger("unknown", buff, "pref"); err != ErrInvalidLogLevel {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDynamicLogger_SetModuleLevel(t *testing.T) {
+	buff := bytes.NewBuffer(make([]byte, 0, 1024))
+	l, _ := NewDynamicLogger("ERROR", buff, "pref")
+
+	if err := l.SetModuleLevel("Cache", "DEBUG"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := l.SetModuleLevel("BACKEND", "INFO"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := l.SetModuleLevel("Cache", "unknown"); err != ErrInvalidLogLevel {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		msg      string
+		logged   bool
+		logLevel func(...interface{})
+	}{
+		{msg: "[ENDPOINT: /foo][Cache] hit", logged: true, logLevel: l.Debug},
+		{msg: "[ENDPOINT: /foo][JSONPatch] applied", logged: false, logLevel: l.Debug},
+		{msg: "[BACKEND: GET /foo -> /bar][Cache] miss", logged: true, logLevel: l.Debug},
+		{msg: "[BACKEND: GET /foo -> /bar][DynamicHost] debug", logged: false, logLevel: l.Debug},
+		{msg: "[BACKEND: GET /foo -> /bar][DynamicHost] info", logged: true, logLevel: l.Info},
+		{msg: "no tags", logged: false, logLevel: l.Warning},
+	} {
+		buff.Reset()
+		tc.logLevel(tc.msg)
+		if logged := buff.Len() > 0; logged != tc.logged {
+			t.Errorf("%s: unexpected output: %s", tc.msg, buff.String())
+		}
+	}
+
+	modules := l.ModuleLevels()
+	if len(modules) != 2 || modules["Cache"] != "DEBUG" || modules["BACKEND"] != "INFO" {
+		t.Errorf("unexpected module levels: %v", modules)
+	}
+
+	l.SetModuleLevel("Cache", "")
+	buff.Reset()
+	l.Debug("[ENDPOINT: /foo][Cache] hit")
+	if buff.Len() != 0 {
+		t.Errorf("unexpected output: %s", buff.String())
+	}
+	if modules := l.ModuleLevels(); len(modules) != 1 {
+		t.Errorf("unexpected module levels: %v", modules)
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	arrayToObjectKey = "array_to_object"
+
+	arrayToObjectLastPolicy  = "last"
+	arrayToObjectFirstPolicy = "first"
+	arrayToObjectErrorPolicy = "error"
+)
+
+// NewArrayToObjectMiddleware creates a proxy middleware that converts an array of the response
+// into an object, using the value of the configured field of each element as its key.
+//
+// The array is located using a dot separated path. The elements that are not objects or that
+// do not contain the field are discarded. When more than one element has the same key, the
+// duplicates policy decides what to do: keep the last element (default), keep the first one
+// or fail with an error.
+func NewArrayToObjectMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := getArrayToObjectConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][ArrayToObject] Keying the elements at '%s' by '%s' (duplicates policy: %s)",
+			endpointConfig.Endpoint,
+			strings.Join(cfg.Path, "."),
+			cfg.Field,
+			cfg.Duplicates,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewArrayToObjectMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if err != nil || resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			if convErr := cfg.Apply(resp.Data); convErr != nil {
+				logger.Debug(fmt.Sprintf("[ENDPOINT: %s][ArrayToObject] %s", endpointConfig.Endpoint, convErr.Error()))
+				resp.IsComplete = false
+				return resp, convErr
+			}
+			return resp, nil
+		}
+	}
+}
+
+type arrayToObjectConfig struct {
+	Path       []string
+	Field      string
+	Duplicates string
+}
+
+// Apply replaces the array found at the configured path with the keyed object
+func (a arrayToObjectConfig) Apply(data map[string]interface{}) error {
+	last := a.Path[len(a.Path)-1]
+	return transformObjects(data, a.Path[:len(a.Path)-1], func(parent map[string]interface{}) error {
+		items, ok := parent[last].([]interface{})
+		if !ok {
+			return nil
+		}
+
+		res := make(map[string]interface{}, len(items))
+		for _, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			key, ok := arrayToObjectKeyValue(obj[a.Field])
+			if !ok {
+				continue
+			}
+			if _, exists := res[key]; exists {
+				switch a.Duplicates {
+				case arrayToObjectFirstPolicy:
+					continue
+				case arrayToObjectErrorPolicy:
+					return fmt.Errorf("duplicated key '%s' converting the array '%s' into an object", key, strings.Join(a.Path, "."))
+				}
+			}
+			res[key] = obj
+		}
+		parent[last] = res
+		return nil
+	})
+}
+
+func arrayToObjectKeyValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case json.Number:
+		return t.String(), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(t), true
+	case bool:
+		return strconv.FormatBool(t), true
+	}
+	return "", false
+}
+
+func getArrayToObjectConfig(extra config.ExtraConfig) (arrayToObjectConfig, bool) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return arrayToObjectConfig{}, ok
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return arrayToObjectConfig{}, ok
+	}
+	tmp, ok := e[arrayToObjectKey].(map[string]interface{})
+	if !ok {
+		return arrayToObjectConfig{}, ok
+	}
+	path, ok := tmp["path"].(string)
+	if !ok || path == "" {
+		return arrayToObjectConfig{}, false
+	}
+	field, ok := tmp["field"].(string)
+	if !ok || field == "" {
+		return arrayToObjectConfig{}, false
+	}
+
+	cfg := arrayToObjectConfig{
+		Path:       strings.Split(path, "."),
+		Field:      field,
+		Duplicates: arrayToObjectLastPolicy,
+	}
+	if policy, ok := tmp["duplicates"].(string); ok {
+		switch policy {
+		case arrayToObjectFirstPolicy, arrayToObjectErrorPolicy, arrayToObjectLastPolicy:
+			cfg.Duplicates = policy
+		}
+	}
+	return cfg, true
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewArrayToObjectMiddleware(t *testing.T) {
+	newData := func() map[string]interface{} {
+		return map[string]interface{}{
+			"data": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"id": json.Number("1"), "name": "a"},
+					map[string]interface{}{"id": "x", "name": "b"},
+					map[string]interface{}{"id": json.Number("1"), "name": "c"},
+					map[string]interface{}{"name": "no id"},
+					"not an object",
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		cfg      map[string]interface{}
+		expected map[string]interface{}
+		err      bool
+	}{
+		{
+			name: "last",
+			cfg:  map[string]interface{}{"path": "data.items", "field": "id"},
+			expected: map[string]interface{}{
+				"1": map[string]interface{}{"id": json.Number("1"), "name": "c"},
+				"x": map[string]interface{}{"id": "x", "name": "b"},
+			},
+		},
+		{
+			name: "first",
+			cfg:  map[string]interface{}{"path": "data.items", "field": "id", "duplicates": "first"},
+			expected: map[string]interface{}{
+				"1": map[string]interface{}{"id": json.Number("1"), "name": "a"},
+				"x": map[string]interface{}{"id": "x", "name": "b"},
+			},
+		},
+		{
+			name: "error",
+			cfg:  map[string]interface{}{"path": "data.items", "field": "id", "duplicates": "error"},
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mw := NewArrayToObjectMiddleware(logging.NoOp, &config.EndpointConfig{
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{arrayToObjectKey: tc.cfg},
+				},
+			})
+			resp, err := mw(dummyProxy(&Response{IsComplete: true, Data: newData()}))(context.Background(), &Request{})
+
+			if tc.err {
+				if err == nil {
+					t.Error("expecting an error")
+				}
+				if resp.IsComplete {
+					t.Error("the response should be incomplete")
+				}
+				if !reflect.DeepEqual(resp.Data, newData()) {
+					t.Errorf("the data should not be modified: %v", resp.Data)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+				return
+			}
+			items := resp.Data["data"].(map[string]interface{})["items"]
+			if !reflect.DeepEqual(items, tc.expected) {
+				t.Errorf("unexpected result: %v", items)
+			}
+		})
+	}
+}
+
+func TestNewArrayToObjectMiddleware_missingArray(t *testing.T) {
+	mw := NewArrayToObjectMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				arrayToObjectKey: map[string]interface{}{"path": "items", "field": "id"},
+			},
+		},
+	})
+	data := map[string]interface{}{"items": "not an array"}
+	resp, err := mw(dummyProxy(&Response{IsComplete: true, Data: data}))(context.Background(), &Request{})
+	if err != nil || !resp.IsComplete {
+		t.Errorf("unexpected result: %v, %v", resp, err)
+	}
+	if data["items"] != "not an array" {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
+
+func TestNewArrayToObjectMiddleware_arrayInPath(t *testing.T) {
+	mw := NewArrayToObjectMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{arrayToObjectKey: map[string]interface{}{"path": "groups.members", "field": "id"}},
+		},
+	})
+	members := []interface{}{map[string]interface{}{"id": "a"}}
+	resp, err := mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data:       map[string]interface{}{"groups": []interface{}{map[string]interface{}{"members": members}}},
+	}))(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := resp.Data["groups"].([]interface{})[0].(map[string]interface{})
+	if !reflect.DeepEqual(group["members"], map[string]interface{}{"a": map[string]interface{}{"id": "a"}}) {
+		t.Errorf("unexpected group: %v", group)
+	}
+}
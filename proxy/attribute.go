@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strings"
+)
+
+// AttributeExtractor returns the value of an attribute of the request and a flag signaling
+// if the attribute was found
+type AttributeExtractor func(*Request) (string, bool)
+
+// NewAttributeExtractor compiles the received spec into an AttributeExtractor. The spec is
+// composed by the source and the name of the attribute, separated by a colon:
+//
+//	header:X-User-Id  the first value of the header
+//	param:id          the value of the url param
+//	query:tenant      the first value of the query string param
+//	body:user.id      the value at the dot separated path of the JSON body
+//
+// The body is only read (and restored) when the extractor is executed, so requests are not
+// buffered unless a body attribute is configured. The returned extractor is safe to be
+// shared across middlewares.
+func NewAttributeExtractor(spec string) (AttributeExtractor, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid attribute spec '%s'", spec)
+	}
+	name := parts[1]
+
+	switch parts[0] {
+	case "header":
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		return func(r *Request) (string, bool) {
+			if vs := r.Headers[name]; len(vs) > 0 {
+				return vs[0], true
+			}
+			return "", false
+		}, nil
+
+	case "param":
+		title := textproto.CanonicalMIMEHeaderKey(name[:1]) + name[1:]
+		return func(r *Request) (string, bool) {
+			if v, ok := r.Params[name]; ok {
+				return v, true
+			}
+			v, ok := r.Params[title]
+			return v, ok
+		}, nil
+
+	case "query":
+		return func(r *Request) (string, bool) {
+			if vs := r.Query[name]; len(vs) > 0 {
+				return vs[0], true
+			}
+			return "", false
+		}, nil
+
+	case "body":
+		path := strings.Split(name, ".")
+		return func(r *Request) (string, bool) {
+			return extractBodyAttribute(r, path)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown source '%s' in the attribute spec '%s'", parts[0], spec)
+}
+
+func extractBodyAttribute(r *Request, path []string) (string, bool) {
+	if r.Body == nil {
+		return "", false
+	}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return "", false
+	}
+
+	var data interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&data); err != nil {
+		return "", false
+	}
+
+	for _, k := range path {
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if data, ok = obj[k]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := data.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return fmt.Sprintf("%t", v), true
+	}
+	return "", false
+}
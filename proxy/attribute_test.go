@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewAttributeExtractor(t *testing.T) {
+	body := `{"user":{"id":42,"name":"foo","admin":true,"tags":["a"]}}`
+	newRequest := func() *Request {
+		return &Request{
+			Headers: map[string][]string{"X-User-Id": {"123", "456"}},
+			Params:  map[string]string{"Id": "abc"},
+			Query:   url.Values{"tenant": {"t1"}},
+			Body:    io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	for _, tc := range []struct {
+		spec     string
+		expected string
+		found    bool
+	}{
+		{spec: "header:X-User-Id", expected: "123", found: true},
+		{spec: "header:x-user-id", expected: "123", found: true},
+		{spec: "header:X-Unknown"},
+		{spec: "param:id", expected: "abc", found: true},
+		{spec: "param:Id", expected: "abc", found: true},
+		{spec: "param:unknown"},
+		{spec: "query:tenant", expected: "t1", found: true},
+		{spec: "query:unknown"},
+		{spec: "body:user.id", expected: "42", found: true},
+		{spec: "body:user.name", expected: "foo", found: true},
+		{spec: "body:user.admin", expected: "true", found: true},
+		{spec: "body:user.tags"},
+		{spec: "body:user.unknown"},
+		{spec: "body:user.id.unknown"},
+	} {
+		extractor, err := NewAttributeExtractor(tc.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.spec, err.Error())
+			continue
+		}
+		req := newRequest()
+		v, ok := extractor(req)
+		if ok != tc.found || v != tc.expected {
+			t.Errorf("%s: unexpected result. have: (%s, %t), want: (%s, %t)", tc.spec, v, ok, tc.expected, tc.found)
+		}
+		b, _ := io.ReadAll(req.Body)
+		if string(b) != body {
+			t.Errorf("%s: the body was not restored: %s", tc.spec, string(b))
+		}
+	}
+}
+
+func TestNewAttributeExtractor_noBody(t *testing.T) {
+	extractor, _ := NewAttributeExtractor("body:id")
+	if v, ok := extractor(&Request{}); ok {
+		t.Errorf("unexpected value: %s", v)
+	}
+	if v, ok := extractor(&Request{Body: io.NopCloser(strings.NewReader("not json"))}); ok {
+		t.Errorf("unexpected value: %s", v)
+	}
+}
+
+func TestNewAttributeExtractor_lazyBody(t *testing.T) {
+	extractor, _ := NewAttributeExtractor("header:X-Foo")
+	body := &dummyRC{r: strings.NewReader(`{"a":1}`), mu: new(sync.Mutex)}
+	req := &Request{Headers: map[string][]string{}, Body: body}
+	extractor(req)
+	if req.Body != body || body.IsClosed() {
+		t.Error("the body should not be buffered by non body extractors")
+	}
+}
+
+func TestNewAttributeExtractor_invalid(t *testing.T) {
+	for _, spec := range []string{"", "header", "header:", "cookie:foo"} {
+		if _, err := NewAttributeExtractor(spec); err == nil {
+			t.Errorf("%s: error expected", spec)
+		}
+	}
+}
+
+func TestNewAttributeExtractor_sharedAcrossMiddlewares(t *testing.T) {
+	extractor, _ := NewAttributeExtractor("body:tenant")
+
+	seen := []string{}
+	mw := func(next ...Proxy) Proxy {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			v, _ := extractor(req)
+			seen = append(seen, v)
+			return next[0](ctx, req)
+		}
+	}
+	limiter := NewConcurrencyLimiterMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"concurrency_limit": map[string]interface{}{
+					"max":    1.0,
+					"client": "body:tenant",
+				},
+			},
+		},
+	})
+	p := mw(limiter(mw(func(_ context.Context, req *Request) (*Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		return &Response{Data: map[string]interface{}{"body": string(b)}}, nil
+	})))
+
+	for _, tenant := range []string{"a", "b"} {
+		resp, err := p(context.Background(), &Request{Body: io.NopCloser(strings.NewReader(`{"tenant":"` + tenant + `"}`))})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if resp.Data["body"] != `{"tenant":"`+tenant+`"}` {
+			t.Errorf("unexpected body: %v", resp.Data["body"])
+		}
+	}
+
+	if strings.Join(seen, ",") != "a,a,b,b" {
+		t.Errorf("unexpected values: %v", seen)
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	backendQueueKey = "queue"
+
+	// BackendQueueDepthGauge is the name of the gauge tracking the requests waiting for a
+	// slot of the backend
+	BackendQueueDepthGauge = "proxy.backend.queue.depth"
+	// BackendQueueRejectedCounter is the name of the counter tracking the requests rejected
+	// because the queue of the backend was full
+	BackendQueueRejectedCounter = "proxy.backend.queue.rejected"
+)
+
+// BackendQueueFullError is the error returned when the backend is processing as many requests
+// as it can and its queue is full
+type BackendQueueFullError struct {
+	Backend string
+}
+
+// Error returns a string representation of the BackendQueueFullError
+func (b BackendQueueFullError) Error() string {
+	return fmt.Sprintf("the queue of the backend %s is full", b.Backend)
+}
+
+// StatusCode returns the status code to send to the client
+func (BackendQueueFullError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// NewBackendQueueMiddleware creates a proxy middleware limiting the number of requests sent
+// concurrently to the backend. The requests over the limit wait in a bounded queue until a
+// slot is released or their context is canceled, and the ones finding the queue full fail
+// fast with a BackendQueueFullError, so a slow backend is exposed instead of piling up
+// requests:
+//
+//	"queue": { "max_concurrent": 10, "max_queued": 50 }
+//
+// The slot of a response carrying a stream (Io) is kept until the stream is consumed, closed
+// or its context is done, because the connection to the backend is still open until then.
+// When the backend has concurrent calls, every one of them takes its own slot.
+//
+// The depth of the queue and the rejections are recorded with the default events recorder,
+// labeled with the backend.
+func NewBackendQueueMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	maxConcurrent, maxQueued, ok := getBackendQueueConfig(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(fmt.Sprintf("[BACKEND: %s %s -> %s][Queue] Sending up to %d concurrent requests (queue size: %d)",
+		remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, maxConcurrent, maxQueued))
+	labels := map[string]string{"backend": remote.URLPattern, "endpoint": remote.ParentEndpoint}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewBackendQueueMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		slots := make(chan struct{}, maxConcurrent)
+		var queued int64
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				recorder := events.DefaultRecorder()
+				depth := atomic.AddInt64(&queued, 1)
+				if depth > maxQueued {
+					atomic.AddInt64(&queued, -1)
+					recorder.Counter(BackendQueueRejectedCounter, 1, labels)
+					return nil, BackendQueueFullError{Backend: remote.URLPattern}
+				}
+				recorder.Gauge(BackendQueueDepthGauge, depth, labels)
+
+				select {
+				case slots <- struct{}{}:
+					recorder.Gauge(BackendQueueDepthGauge, atomic.AddInt64(&queued, -1), labels)
+				case <-ctx.Done():
+					recorder.Gauge(BackendQueueDepthGauge, atomic.AddInt64(&queued, -1), labels)
+					return nil, ctx.Err()
+				}
+			}
+			resp, err := next[0](ctx, request)
+			if resp == nil || resp.Io == nil {
+				<-slots
+				return resp, err
+			}
+			resp.Io = newQueueSlotReader(ctx, resp.Io, func() { <-slots })
+			return resp, err
+		}
+	}
+}
+
+// queueSlotReader holds the slot of the backend queue until the wrapped stream is consumed,
+// closed or its context is done
+type queueSlotReader struct {
+	io.Reader
+	once     *sync.Once
+	released chan struct{}
+	release  func()
+}
+
+func newQueueSlotReader(ctx context.Context, r io.Reader, release func()) io.Reader {
+	q := queueSlotReader{
+		Reader:   r,
+		once:     new(sync.Once),
+		released: make(chan struct{}),
+		release:  release,
+	}
+	go q.releaseOnCancel(ctx)
+	return q
+}
+
+func (q queueSlotReader) Read(b []byte) (int, error) {
+	n, err := q.Reader.Read(b)
+	if err != nil {
+		q.done()
+	}
+	return n, err
+}
+
+func (q queueSlotReader) Close() error {
+	q.done()
+	if c, ok := q.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (q queueSlotReader) done() {
+	q.once.Do(func() {
+		close(q.released)
+		q.release()
+	})
+}
+
+func (q queueSlotReader) releaseOnCancel(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		q.done()
+	case <-q.released:
+	}
+}
+
+func getBackendQueueConfig(extra config.ExtraConfig) (int, int64, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return 0, 0, false
+	}
+	tmp, ok := e[backendQueueKey].(map[string]interface{})
+	if !ok {
+		return 0, 0, false
+	}
+	maxConcurrent, ok := tmp["max_concurrent"].(float64)
+	if !ok || maxConcurrent < 1 {
+		return 0, 0, false
+	}
+	maxQueued, _ := tmp["max_queued"].(float64)
+	if maxQueued < 0 {
+		maxQueued = 0
+	}
+	return int(maxConcurrent), int64(maxQueued), true
+}
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type queueRecorder struct {
+	mu       *sync.Mutex
+	rejected int64
+	depth    int64
+	maxDepth int64
+}
+
+func (r *queueRecorder) Counter(name string, delta int64, labels map[string]string) {
+	if name != BackendQueueRejectedCounter || labels["backend"] != "/slow" {
+		return
+	}
+	r.mu.Lock()
+	r.rejected += delta
+	r.mu.Unlock()
+}
+
+func (r *queueRecorder) Gauge(name string, value int64, labels map[string]string) {
+	if name != BackendQueueDepthGauge || labels["backend"] != "/slow" {
+		return
+	}
+	r.mu.Lock()
+	r.depth = value
+	if value > r.maxDepth {
+		r.maxDepth = value
+	}
+	r.mu.Unlock()
+}
+
+func (r *queueRecorder) get() (int64, int64, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rejected, r.depth, r.maxDepth
+}
+
+func TestNewBackendQueueMiddleware(t *testing.T) {
+	recorder := &queueRecorder{mu: new(sync.Mutex)}
+	events.SetDefaultRecorder(recorder)
+	defer events.SetDefaultRecorder(nil)
+
+	remote := &config.Backend{
+		URLPattern: "/slow",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				backendQueueKey: map[string]interface{}{"max_concurrent": 2.0, "max_queued": 2.0},
+			},
+		},
+	}
+
+	var inFlight, maxInFlight int64
+	release := make(chan struct{})
+	p := NewBackendQueueMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		current := atomic.AddInt64(&inFlight, 1)
+		for {
+			prev := atomic.LoadInt64(&maxInFlight)
+			if current <= prev || atomic.CompareAndSwapInt64(&maxInFlight, prev, current) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt64(&inFlight, -1)
+		return &Response{IsComplete: true}, nil
+	})
+
+	wg := &sync.WaitGroup{}
+	var completed uint64
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p(context.Background(), &Request{}); err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+				return
+			}
+			atomic.AddUint64(&completed, 1)
+		}()
+	}
+
+	// wait until the backend is saturated and the queue is full
+	for i := 0; i < 100; i++ {
+		if _, depth, _ := recorder.get(); depth == 2 && atomic.LoadInt64(&inFlight) == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, depth, _ := recorder.get(); depth != 2 {
+		t.Errorf("unexpected queue depth: %d", depth)
+	}
+
+	for i := 0; i < 4; i++ {
+		resp, err := p(context.Background(), &Request{})
+		if resp != nil {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+		qErr, ok := err.(BackendQueueFullError)
+		if !ok {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if qErr.StatusCode() != http.StatusServiceUnavailable || qErr.Backend != "/slow" {
+			t.Errorf("unexpected error: %+v", qErr)
+		}
+	}
+
+	close(release)
+	wg.Wait()
+
+	if c := atomic.LoadUint64(&completed); c != 4 {
+		t.Errorf("unexpected number of completed requests: %d", c)
+	}
+	if m := atomic.LoadInt64(&maxInFlight); m != 2 {
+		t.Errorf("unexpected number of concurrent requests: %d", m)
+	}
+	rejected, depth, maxDepth := recorder.get()
+	if rejected != 4 {
+		t.Errorf("unexpected number of rejections: %d", rejected)
+	}
+	if depth != 0 || maxDepth != 2 {
+		t.Errorf("unexpected queue depth. current: %d, max: %d", depth, maxDepth)
+	}
+}
+
+func TestNewBackendQueueMiddleware_canceled(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				backendQueueKey: map[string]interface{}{"max_concurrent": 1.0, "max_queued": 1.0},
+			},
+		},
+	}
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	p := NewBackendQueueMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		close(entered)
+		<-release
+		return &Response{IsComplete: true}, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p(context.Background(), &Request{})
+		close(done)
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p(ctx, &Request{}); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the canceled request leaves the queue, so there is room for a new one
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p(ctx, &Request{}); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestNewBackendQueueMiddleware_stream(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				backendQueueKey: map[string]interface{}{"max_concurrent": 1.0},
+			},
+		},
+	}
+	p := NewBackendQueueMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true, Io: strings.NewReader("some body")}, nil
+	})
+
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// the slot is kept while the body is not consumed
+	if _, err := p(context.Background(), &Request{}); err == nil {
+		t.Error("the second request should be rejected while the first body is open")
+	} else if _, ok := err.(BackendQueueFullError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	b, err := io.ReadAll(resp.Io)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if string(b) != "some body" {
+		t.Errorf("unexpected body: %s", string(b))
+	}
+
+	resp, err = p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	resp.Io.(io.Closer).Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err = p(ctx, &Request{}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	cancel()
+
+	// the slot is released in the background once the context is done
+	for i := 0; i < 100; i++ {
+		if _, err = p(context.Background(), &Request{}); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("the slot should be released once the context is done: %v", err)
+	}
+}
+
+func TestNewBackendQueueMiddleware_concurrentCalls(t *testing.T) {
+	remote := &config.Backend{
+		ConcurrentCalls: 3,
+		Timeout:         time.Second,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				backendQueueKey: map[string]interface{}{"max_concurrent": 1.0},
+			},
+		},
+	}
+	var calls int64
+	release := make(chan struct{})
+	queued := NewBackendQueueMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		return &Response{IsComplete: true}, nil
+	})
+	p := NewConcurrentMiddlewareWithLogger(logging.NoOp, remote)(queued)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !resp.IsComplete {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if c := atomic.LoadInt64(&calls); c != 1 {
+		t.Errorf("every concurrent call should take a slot. backend calls: %d", c)
+	}
+}
@@ -4,13 +4,18 @@ package proxy
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"net/url"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/random"
 	"github.com/luraproject/lura/v2/sd"
 )
 
+const adaptiveBalancingKey = "adaptive_balancing"
+
 // NewLoadBalancedMiddleware creates proxy middleware adding the most perfomant balancer
 // over a default subscriber
 func NewLoadBalancedMiddleware(remote *config.Backend) Middleware {
@@ -83,6 +88,22 @@ func NewRandomLoadBalancedMiddlewareWithSubscriberAndLogger(l logging.Logger, su
 	return newLoadBalancedMiddleware(l, sd.NewRandomLB(subscriber))
 }
 
+// NewLoadBalancedMiddlewareWithSource creates proxy middleware adding the most perfomant balancer
+// over the received subscriber, taking its random decisions with the received source
+func NewLoadBalancedMiddlewareWithSource(l logging.Logger, subscriber sd.Subscriber, src random.Source) Middleware {
+	return newLoadBalancedMiddleware(l, sd.NewBalancerWithSource(subscriber, src))
+}
+
+// NewAdaptiveLoadBalancedMiddleware creates proxy middleware adding an adaptive balancer over
+// the received subscriber. The result of every request is reported to the balancer, so the
+// weight of the failing hosts decreases (see sd.NewAdaptiveLB). The default factory uses it
+// for the backends declaring the 'adaptive_balancing' key in their extra config:
+//
+//	"adaptive_balancing": { "decay": 0.1, "min_weight": 0.05 }
+func NewAdaptiveLoadBalancedMiddleware(l logging.Logger, subscriber sd.Subscriber, src random.Source, cfg sd.AdaptiveConfig) Middleware {
+	return newLoadBalancedMiddleware(l, sd.NewAdaptiveLB(subscriber, src, cfg))
+}
+
 func newLoadBalancedMiddleware(l logging.Logger, lb sd.Balancer) Middleware {
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
@@ -90,7 +111,13 @@ func newLoadBalancedMiddleware(l logging.Logger, lb sd.Balancer) Middleware {
 			return nil
 		}
 		return func(ctx context.Context, r *Request) (*Response, error) {
-			host, err := lb.Host()
+			var host string
+			var err error
+			if cb, ok := lb.(sd.ContextBalancer); ok {
+				host, err = cb.HostContext(ctx)
+			} else {
+				host, err = lb.Host()
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -107,7 +134,51 @@ func newLoadBalancedMiddleware(l logging.Logger, lb sd.Balancer) Middleware {
 				}
 			}
 
-			return next[0](ctx, r)
+			fb, ok := lb.(sd.FeedbackBalancer)
+			if !ok {
+				return next[0](ctx, r)
+			}
+			status, ok := ctx.Value(backendStatusKey{}).(*backendStatus)
+			if !ok {
+				status = &backendStatus{}
+				ctx = status.WithContext(ctx)
+			}
+			status.Reset()
+			resp, err := next[0](ctx, r)
+			if !errors.Is(err, context.Canceled) {
+				fb.Report(host, backendHealthy(resp, err, status.Get()))
+			}
+			return resp, err
 		}
 	}
 }
+
+// backendHealthy reports if the result of the request reflects a healthy backend. The errors
+// with a status code under 500 are caused by the request, not by the backend. The errors
+// without a status code are classified with the status received from the backend, if any,
+// so only the transport errors and the 5xx responses count as failures.
+func backendHealthy(resp *Response, err error, received int) bool {
+	if err == nil {
+		return resp == nil || resp.Metadata.StatusCode < http.StatusInternalServerError
+	}
+	var sc interface{ StatusCode() int }
+	if errors.As(err, &sc) {
+		return sc.StatusCode() < http.StatusInternalServerError
+	}
+	return received > 0 && received < http.StatusInternalServerError
+}
+
+func getAdaptiveBalancingConfig(extra config.ExtraConfig) (sd.AdaptiveConfig, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return sd.AdaptiveConfig{}, false
+	}
+	tmp, ok := e[adaptiveBalancingKey].(map[string]interface{})
+	if !ok {
+		return sd.AdaptiveConfig{}, false
+	}
+	cfg := sd.AdaptiveConfig{}
+	cfg.Decay, _ = tmp["decay"].(float64)
+	cfg.MinWeight, _ = tmp["min_weight"].(float64)
+	return cfg, true
+}
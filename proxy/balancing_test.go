@@ -5,13 +5,20 @@ package proxy
 import (
 	"context"
 	"errors"
+	"io"
 	"net"
+	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/random"
+	"github.com/luraproject/lura/v2/sd"
 	"github.com/luraproject/lura/v2/sd/dnssrv"
+	"github.com/luraproject/lura/v2/transport/http/client"
 )
 
 func TestNewLoadBalancedMiddleware_ok(t *testing.T) {
@@ -131,3 +138,86 @@ type explosiveBalancer struct {
 }
 
 func (e explosiveBalancer) Host() (string, error) { return "", e.Error }
+
+func TestNewAdaptiveLoadBalancedMiddleware(t *testing.T) {
+	subscriber := sd.FixedSubscriber{"http://healthy", "http://degraded"}
+	mw := NewAdaptiveLoadBalancedMiddleware(logging.NoOp, subscriber, random.NewSource(1), sd.AdaptiveConfig{Decay: 0.1})
+
+	failures := 0
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		if r.URL.Host == "degraded" {
+			failures++
+			return nil, errors.New("boom")
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	hits := make([]int, 4)
+	for round := range hits {
+		before := failures
+		for i := 0; i < 100; i++ {
+			p(context.Background(), &Request{Path: "/foo"})
+		}
+		hits[round] = failures - before
+	}
+
+	if hits[0] <= hits[len(hits)-1] {
+		t.Errorf("the degraded host should receive progressively less traffic: %v", hits)
+	}
+	if hits[len(hits)-1] == 0 {
+		t.Errorf("the degraded host should keep receiving some traffic: %v", hits)
+	}
+}
+
+func TestBackendHealthy(t *testing.T) {
+	for i, tc := range []struct {
+		resp     *Response
+		err      error
+		received int
+		expected bool
+	}{
+		{resp: &Response{}, expected: true},
+		{resp: &Response{Metadata: Metadata{StatusCode: 503}}},
+		{err: errors.New("boom")},
+		{err: RateLimitError{}, expected: true},
+		{err: BackendQueueFullError{}},
+		{err: client.ErrInvalidStatusCode, received: 404, expected: true},
+		{err: client.ErrInvalidStatusCode, received: 502},
+	} {
+		if res := backendHealthy(tc.resp, tc.err, tc.received); res != tc.expected {
+			t.Errorf("#%d: unexpected result %v", i, res)
+		}
+	}
+}
+
+func TestNewAdaptiveLoadBalancedMiddleware_defaultStatusHandler(t *testing.T) {
+	remote := &config.Backend{}
+	lb := sd.NewAdaptiveLB(sd.FixedSubscriber{"http://missing", "http://broken"}, random.NewSource(1), sd.AdaptiveConfig{})
+	re := func(_ context.Context, req *http.Request) (*http.Response, error) {
+		status := http.StatusNotFound
+		if req.URL.Host == "broken" {
+			status = http.StatusInternalServerError
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	}
+	p := newLoadBalancedMiddleware(logging.NoOp, lb)(
+		NewHTTPProxyDetailed(remote, re, client.DefaultHTTPStatusHandler, DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{
+			Decoder:         encoding.JSONDecoder,
+			EntityFormatter: NewEntityFormatter(remote),
+		})),
+	)
+
+	for i := 0; i < 20; i++ {
+		if _, err := p(context.Background(), &Request{Method: "GET", Path: "/foo"}); err != client.ErrInvalidStatusCode {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+
+	weights := lb.(interface{ Weight(string) float64 })
+	if w := weights.Weight("http://missing"); w != 1 {
+		t.Errorf("the client errors should not degrade the host. weight: %f", w)
+	}
+	if w := weights.Weight("http://broken"); w == 1 {
+		t.Error("the server errors should degrade the host")
+	}
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const bodyDecryptionKey = "decrypt_fields"
+
+var errMalformedCiphertext = errors.New("malformed ciphertext")
+
+// DecryptionError is the error returned when a field of the request body can not be decrypted
+type DecryptionError struct {
+	Field string
+	Err   error
+}
+
+// Error returns a string representation of the DecryptionError
+func (d DecryptionError) Error() string {
+	return fmt.Sprintf("unable to decrypt the field '%s': %s", d.Field, d.Err.Error())
+}
+
+// StatusCode returns the status code to send to the client
+func (DecryptionError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// DecryptionConfigError is the error returned by the endpoints with an invalid decryption
+// config, so the encrypted fields never reach the backends
+type DecryptionConfigError struct {
+	Endpoint string
+	Err      error
+}
+
+// Error returns a string representation of the DecryptionConfigError
+func (d DecryptionConfigError) Error() string {
+	return fmt.Sprintf("unable to decrypt the request body for the endpoint %s: %s", d.Endpoint, d.Err.Error())
+}
+
+// Unwrap returns the error invalidating the decryption config
+func (d DecryptionConfigError) Unwrap() error {
+	return d.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (DecryptionConfigError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// NewBodyDecryptionMiddleware creates a proxy middleware decrypting the configured fields of
+// the JSON request body before forwarding it. The fields are expected to contain the base64
+// encoding of the AES-GCM nonce followed by the sealed data. The key is the base64 encoding
+// of a 16, 24 or 32 bytes secret and it can be referenced from an environment variable
+// with the "env:" prefix. If the key is missing or invalid, all the requests are rejected
+// with a DecryptionConfigError.
+func NewBodyDecryptionMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok, err := getBodyDecryptionConfig(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][BodyDecryption] %s. All the requests will be rejected", endpointConfig.Endpoint, err.Error()))
+		return rejectingBodyDecryptionMiddleware(logger, DecryptionConfigError{Endpoint: endpointConfig.Endpoint, Err: err})
+	}
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	aead, err := newBodyDecryptionAEAD(cfg.Key)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][BodyDecryption] Unable to create the cipher: %s. All the requests will be rejected", endpointConfig.Endpoint, err.Error()))
+		return rejectingBodyDecryptionMiddleware(logger, DecryptionConfigError{Endpoint: endpointConfig.Endpoint, Err: err})
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][BodyDecryption] Decrypting the fields %v of the request body",
+			endpointConfig.Endpoint,
+			cfg.Fields,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewBodyDecryptionMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			err := rewriteJSONBody(request, func(data map[string]interface{}) error {
+				for _, field := range cfg.Fields {
+					if err := decryptField(aead, data, field); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				logger.Debug(fmt.Sprintf("[ENDPOINT: %s][BodyDecryption] %s", endpointConfig.Endpoint, err.Error()))
+				return nil, err
+			}
+			return next[0](ctx, request)
+		}
+	}
+}
+
+func rejectingBodyDecryptionMiddleware(logger logging.Logger, err error) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewBodyDecryptionMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return nil, err
+		}
+	}
+}
+
+func decryptField(aead cipher.AEAD, data map[string]interface{}, field string) error {
+	keys := strings.Split(field, ".")
+	for _, k := range keys[:len(keys)-1] {
+		child, ok := data[k].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		data = child
+	}
+	last := keys[len(keys)-1]
+	v, ok := data[last]
+	if !ok || v == nil {
+		return nil
+	}
+	encrypted, ok := v.(string)
+	if !ok {
+		return DecryptionError{Field: field, Err: errMalformedCiphertext}
+	}
+	raw, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(raw) < aead.NonceSize()+aead.Overhead() {
+		return DecryptionError{Field: field, Err: errMalformedCiphertext}
+	}
+	nonce, sealed := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return DecryptionError{Field: field, Err: err}
+	}
+	data[last] = string(plain)
+	return nil
+}
+
+func newBodyDecryptionAEAD(key string) (cipher.AEAD, error) {
+	k, err := base64.StdEncoding.DecodeString(config.ResolveEnvValue(key))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type bodyDecryptionConfig struct {
+	Key    string
+	Fields []string
+}
+
+func getBodyDecryptionConfig(extra config.ExtraConfig) (bodyDecryptionConfig, bool, error) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return bodyDecryptionConfig{}, false, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return bodyDecryptionConfig{}, false, nil
+	}
+	tmp, ok := e[bodyDecryptionKey].(map[string]interface{})
+	if !ok {
+		return bodyDecryptionConfig{}, false, nil
+	}
+	fields, _ := tmp["fields"].([]interface{})
+	cfg := bodyDecryptionConfig{}
+	for _, f := range fields {
+		if field, ok := f.(string); ok && field != "" {
+			cfg.Fields = append(cfg.Fields, field)
+		}
+	}
+	if len(cfg.Fields) == 0 {
+		return bodyDecryptionConfig{}, false, nil
+	}
+	key, ok := tmp["key"].(string)
+	if !ok || key == "" {
+		return bodyDecryptionConfig{}, false, errors.New("the decryption key is missing")
+	}
+	cfg.Key = key
+	return cfg, true, nil
+}
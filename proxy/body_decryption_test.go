@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewBodyDecryptionMiddleware(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	os.Setenv("LURA_TEST_BODY_KEY", base64.StdEncoding.EncodeToString(key))
+	defer os.Unsetenv("LURA_TEST_BODY_KEY")
+
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	nonce := []byte("123456789012")
+	encrypted := base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte("4111111111111111"), nil))
+
+	mw := NewBodyDecryptionMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				bodyDecryptionKey: map[string]interface{}{
+					"key":    "env:LURA_TEST_BODY_KEY",
+					"fields": []interface{}{"card.number", "missing.field"},
+				},
+			},
+		},
+	})
+
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return nil, err
+		}
+		if string(b) != `{"amount":42.10,"card":{"holder":"foo","number":"4111111111111111"}}` {
+			t.Errorf("unexpected body: %s", string(b))
+		}
+		if v := r.Headers["Content-Length"]; len(v) != 1 || v[0] != "68" {
+			t.Errorf("unexpected content length: %v", v)
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	headers := map[string][]string{"Content-Type": {"application/json"}}
+	resp, err := p(context.Background(), &Request{
+		Body:    io.NopCloser(strings.NewReader(`{"amount":42.10,"card":{"holder":"foo","number":"` + encrypted + `"}}`)),
+		Headers: headers,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp == nil || !resp.IsComplete {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if _, ok := headers["Content-Length"]; ok {
+		t.Error("the original headers have been modified")
+	}
+
+	for _, body := range []string{
+		`{"card":{"number":"not base64!"}}`,
+		`{"card":{"number":"c2hvcnQ="}}`,
+		`{"card":{"number":"` + base64.StdEncoding.EncodeToString(append(nonce, make([]byte, 32)...)) + `"}}`,
+		`{"card":{"number":42}}`,
+	} {
+		resp, err := mw(explosiveProxy(t))(context.Background(), &Request{
+			Body:    io.NopCloser(strings.NewReader(body)),
+			Headers: map[string][]string{},
+		})
+		if resp != nil {
+			t.Errorf("unexpected response: %v", resp)
+		}
+		decErr, ok := err.(DecryptionError)
+		if !ok {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if decErr.StatusCode() != http.StatusBadRequest {
+			t.Errorf("unexpected status code: %d", decErr.StatusCode())
+		}
+		if decErr.Field != "card.number" {
+			t.Errorf("unexpected field: %s", decErr.Field)
+		}
+	}
+}
+
+func TestNewBodyDecryptionMiddleware_wrongKey(t *testing.T) {
+	mw := NewBodyDecryptionMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				bodyDecryptionKey: map[string]interface{}{
+					"key":    base64.StdEncoding.EncodeToString([]byte("short")),
+					"fields": []interface{}{"a"},
+				},
+			},
+		},
+	})
+	resp, err := mw(dummyProxy(&Response{IsComplete: true}))(context.Background(), &Request{})
+	if resp != nil {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	var cErr DecryptionConfigError
+	if !errors.As(err, &cErr) || cErr.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewBodyDecryptionMiddleware_missingKey(t *testing.T) {
+	for _, key := range []interface{}{nil, "", "env:LURA_TEST_UNDEFINED_DECRYPTION_KEY"} {
+		cfg := map[string]interface{}{"fields": []interface{}{"a"}}
+		if key != nil {
+			cfg["key"] = key
+		}
+		mw := NewBodyDecryptionMiddleware(logging.NoOp, &config.EndpointConfig{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{bodyDecryptionKey: cfg},
+			},
+		})
+		resp, err := mw(dummyProxy(&Response{IsComplete: true}))(context.Background(), &Request{})
+		if resp != nil {
+			t.Errorf("%v: unexpected response: %v", key, resp)
+		}
+		var cErr DecryptionConfigError
+		if !errors.As(err, &cErr) {
+			t.Errorf("%v: unexpected error: %v", key, err)
+		}
+	}
+}
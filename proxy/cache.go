@@ -0,0 +1,475 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	cacheKey = "cache"
+
+	defaultCacheMaxItems = 1000
+)
+
+// NewBackendCacheMiddleware creates a proxy middleware caching the complete responses of the
+// backend in memory for the configured TTL.
+//
+// Only the requests using the GET or HEAD methods are cached by default and their cache key
+// is composed by the method, the path, the query string and the headers of the backend
+// request, so the responses are never served to requests with other credentials. The key can
+// be limited to the headers listed under 'vary', but the Authorization and the Cookie headers
+// are always part of it. The methods listed under 'body_methods' are cached too, and the hash
+// of their request body is added to the cache key, so POST based query endpoints can be
+// cached as well.
+//
+// The entries are stored gzipped when their serialized size reaches the optional
+// 'compress_min_size' (in bytes), trading CPU for memory when caching large responses.
+//
+// The negative results (the responses and the errors with one of the listed statuses, 404
+// by default) are cached for their own TTL if the 'negative' block is defined, so the
+// requests for missing resources do not reach the backend again and again:
+//
+//	"cache": { "ttl": "5m", "negative": { "ttl": "10s", "statuses": [404, 410] } }
+//
+// The negative results are classified with the status code received from the backend, so
+// the errors of the status handlers discarding it (like the default one) are cached too.
+func NewBackendCacheMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	cfg, ok := getCacheConfig(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[BACKEND: %s %s -> %s][Cache] Caching up to %d responses for %s",
+			remote.ParentEndpointMethod,
+			remote.ParentEndpoint,
+			remote.URLPattern,
+			cfg.MaxItems,
+			cfg.TTL,
+		),
+	)
+
+	cache := newResponseCache(cfg.MaxItems)
+	cache.compressMinSize = cfg.CompressMinSize
+	registerResponseCache(remote, cache)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewBackendCacheMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			key, ok := cfg.key(request)
+			if !ok {
+				return next[0](ctx, request)
+			}
+
+			if entry, ok := cache.lookup(key, time.Now()); ok {
+				if resp, ok := entry.response(); ok {
+					return resp, entry.err
+				}
+			}
+
+			var status *backendStatus
+			if cfg.NegativeTTL > 0 {
+				status = &backendStatus{}
+				ctx = status.WithContext(ctx)
+			}
+			resp, err := next[0](ctx, request)
+			if cfg.negative(resp, err, status) {
+				if err := cache.SetResult(key, resp, err, time.Now().Add(cfg.NegativeTTL)); err != nil {
+					logger.Debug(fmt.Sprintf("[BACKEND: %s %s -> %s][Cache] Unable to cache the negative response: %s",
+						remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, err.Error()))
+				}
+				return resp, err
+			}
+			if err != nil || resp == nil || !resp.IsComplete || resp.Io != nil {
+				return resp, err
+			}
+
+			if err := cache.Set(key, resp, time.Now().Add(cfg.TTL)); err != nil {
+				logger.Debug(fmt.Sprintf("[BACKEND: %s %s -> %s][Cache] Unable to cache the response: %s",
+					remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, err.Error()))
+			}
+			return resp, nil
+		}
+	}
+}
+
+type cacheConfig struct {
+	TTL              time.Duration
+	MaxItems         int
+	BodyMethods      map[string]struct{}
+	CompressMinSize  int
+	NegativeTTL      time.Duration
+	NegativeStatuses map[int]struct{}
+	Vary             []string
+}
+
+// negative reports if the result of the backend request is a negative one to cache. The
+// errors without a status code are classified with the status received from the backend
+func (c cacheConfig) negative(resp *Response, err error, received *backendStatus) bool {
+	if c.NegativeTTL <= 0 || (resp != nil && resp.Io != nil) {
+		return false
+	}
+	status := 0
+	if err != nil {
+		var sc interface{ StatusCode() int }
+		if errors.As(err, &sc) {
+			status = sc.StatusCode()
+		} else if received != nil {
+			status = received.Get()
+		}
+	} else if resp != nil {
+		status = resp.Metadata.StatusCode
+	}
+	_, ok := c.NegativeStatuses[status]
+	return ok
+}
+
+type backendStatusKey struct{}
+
+// backendStatus records the status code of the responses received from the backend before
+// the status handler can discard it
+type backendStatus struct {
+	code int32
+}
+
+// WithContext returns a copy of the context carrying the backendStatus
+func (b *backendStatus) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backendStatusKey{}, b)
+}
+
+// Get returns the last recorded status code
+func (b *backendStatus) Get() int {
+	return int(atomic.LoadInt32(&b.code))
+}
+
+// Reset discards the recorded status code
+func (b *backendStatus) Reset() {
+	atomic.StoreInt32(&b.code, 0)
+}
+
+func recordBackendStatus(ctx context.Context, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	if b, ok := ctx.Value(backendStatusKey{}).(*backendStatus); ok {
+		atomic.StoreInt32(&b.code, int32(resp.StatusCode))
+	}
+}
+
+// key returns the cache key of the request and a flag signaling if the request is cacheable
+func (c cacheConfig) key(r *Request) (string, bool) {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var b strings.Builder
+	b.WriteString(method)
+	b.WriteByte(' ')
+	b.WriteString(r.Path)
+	if len(r.Query) > 0 {
+		b.WriteByte('?')
+		b.WriteString(r.Query.Encode())
+	}
+	writeHeadersKey(&b, r.Headers, c.Vary)
+	key := b.String()
+
+	if method == http.MethodGet || method == http.MethodHead {
+		return key, true
+	}
+	if _, ok := c.BodyMethods[method]; !ok {
+		return "", false
+	}
+
+	h := sha256.New()
+	if r.Body != nil {
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return "", false
+		}
+		h.Write(body)
+	}
+	return key + " " + hex.EncodeToString(h.Sum(nil)), true
+}
+
+// responseCache is a LRU cache storing serialized responses, so every hit returns a fresh
+// copy that can be modified by the rest of the pipe without affecting the cached version
+type responseCache struct {
+	mu       *sync.Mutex
+	maxItems int
+	items    map[string]*list.Element
+	order    *list.List
+	// compressMinSize is the minimum size of the serialized responses to store them gzipped.
+	// Zero disables the compression
+	compressMinSize int
+}
+
+type cacheEntry struct {
+	key        string
+	data       []byte
+	compressed bool
+	incomplete bool
+	headers    map[string][]string
+	statusCode int
+	err        error
+	expiration time.Time
+}
+
+func newResponseCache(maxItems int) *responseCache {
+	return &responseCache{
+		mu:       new(sync.Mutex),
+		maxItems: maxItems,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Get returns a copy of the response stored under the key, if it is not expired
+func (c *responseCache) Get(key string, now time.Time) (*Response, bool) {
+	entry, ok := c.lookup(key, now)
+	if !ok {
+		return nil, false
+	}
+	resp, ok := entry.response()
+	return resp, ok && resp != nil
+}
+
+// lookup returns the entry stored under the key, if it is not expired
+func (c *responseCache) lookup(key string, now time.Time) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if !now.Before(entry.expiration) {
+		c.order.Remove(e)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return entry, true
+}
+
+// response returns a copy of the response stored in the entry. The response is nil if the
+// entry only stores an error
+func (entry *cacheEntry) response() (*Response, bool) {
+	if entry.data == nil {
+		return nil, true
+	}
+	var r io.Reader = bytes.NewReader(entry.data)
+	if entry.compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, false
+		}
+		r = gz
+	}
+
+	// the numbers are decoded like the live responses (see encoding.NewJSONDecoder), so
+	// the cached ones keep their types and precision
+	data := map[string]interface{}{}
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	if err := d.Decode(&data); err != nil {
+		return nil, false
+	}
+
+	headers := make(map[string][]string, len(entry.headers))
+	for k, vs := range entry.headers {
+		headers[k] = append([]string{}, vs...)
+	}
+
+	return &Response{
+		Data:       data,
+		IsComplete: !entry.incomplete,
+		Metadata: Metadata{
+			Headers:    headers,
+			StatusCode: entry.statusCode,
+		},
+	}, true
+}
+
+// Set stores a serialized copy of the response under the key until the expiration time,
+// evicting the least recently used entry if the cache is full
+func (c *responseCache) Set(key string, resp *Response, expiration time.Time) error {
+	return c.SetResult(key, resp, nil, expiration)
+}
+
+// SetResult stores a serialized copy of the response (if any) along with the error returned
+// by the backend under the key until the expiration time, so negative results can be replayed
+func (c *responseCache) SetResult(key string, resp *Response, respErr error, expiration time.Time) error {
+	entry := &cacheEntry{
+		key:        key,
+		err:        respErr,
+		expiration: expiration,
+	}
+	if resp != nil {
+		data, err := json.Marshal(resp.Data)
+		if err != nil {
+			return err
+		}
+		compressed := c.compressMinSize > 0 && len(data) >= c.compressMinSize
+		if compressed {
+			buf := new(bytes.Buffer)
+			w := gzip.NewWriter(buf)
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+		}
+		headers := make(map[string][]string, len(resp.Metadata.Headers))
+		for k, vs := range resp.Metadata.Headers {
+			headers[k] = append([]string{}, vs...)
+		}
+		entry.data = data
+		entry.compressed = compressed
+		entry.incomplete = !resp.IsComplete
+		entry.headers = headers
+		entry.statusCode = resp.Metadata.StatusCode
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxItems {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(*cacheEntry).key)
+	}
+	return nil
+}
+
+// Flush removes all the entries of the cache and returns the number of removed entries
+func (c *responseCache) Flush() int {
+	c.mu.Lock()
+	n := c.order.Len()
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+	c.mu.Unlock()
+	return n
+}
+
+var (
+	responseCachesMu = &sync.Mutex{}
+	responseCaches   = map[string]*responseCache{}
+)
+
+// registerResponseCache registers the cache of the backend, replacing the one created by a
+// previous build of the same backend, so the registry does not grow with every rebuild of
+// the proxies
+func registerResponseCache(remote *config.Backend, c *responseCache) {
+	key := fmt.Sprintf("%s %s -> %s %v", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, remote.Host)
+	responseCachesMu.Lock()
+	responseCaches[key] = c
+	responseCachesMu.Unlock()
+}
+
+// FlushBackendCaches removes the entries of all the caches created by the
+// NewBackendCacheMiddleware and returns the number of removed entries
+func FlushBackendCaches() int {
+	responseCachesMu.Lock()
+	defer responseCachesMu.Unlock()
+
+	n := 0
+	for _, c := range responseCaches {
+		n += c.Flush()
+	}
+	return n
+}
+
+func getCacheConfig(extra config.ExtraConfig) (cacheConfig, bool) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return cacheConfig{}, ok
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return cacheConfig{}, ok
+	}
+	tmp, ok := e[cacheKey].(map[string]interface{})
+	if !ok {
+		return cacheConfig{}, ok
+	}
+	ttlStr, ok := tmp["ttl"].(string)
+	if !ok {
+		return cacheConfig{}, false
+	}
+	ttl, err := time.ParseDuration(ttlStr)
+	if err != nil || ttl <= 0 {
+		return cacheConfig{}, false
+	}
+
+	cfg := cacheConfig{
+		TTL:         ttl,
+		MaxItems:    defaultCacheMaxItems,
+		BodyMethods: map[string]struct{}{},
+	}
+	if v, ok := tmp["max_items"].(float64); ok && v > 0 {
+		cfg.MaxItems = int(v)
+	}
+	if v, ok := tmp["compress_min_size"].(float64); ok && v > 0 {
+		cfg.CompressMinSize = int(v)
+	}
+	if negative, ok := tmp["negative"].(map[string]interface{}); ok {
+		ttl, _ := negative["ttl"].(string)
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			cfg.NegativeTTL = d
+			cfg.NegativeStatuses = map[int]struct{}{}
+			statuses, _ := negative["statuses"].([]interface{})
+			for _, v := range statuses {
+				if status, ok := v.(float64); ok {
+					cfg.NegativeStatuses[int(status)] = struct{}{}
+				}
+			}
+			if len(cfg.NegativeStatuses) == 0 {
+				cfg.NegativeStatuses[http.StatusNotFound] = struct{}{}
+			}
+		}
+	}
+	cfg.Vary = varyHeaders(tmp["vary"])
+	if methods, ok := tmp["body_methods"].([]interface{}); ok {
+		for _, m := range methods {
+			if method, ok := m.(string); ok {
+				cfg.BodyMethods[strings.ToUpper(method)] = struct{}{}
+			}
+		}
+	}
+	return cfg, true
+}
@@ -0,0 +1,501 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+func TestNewBackendCacheMiddleware_bodyKey(t *testing.T) {
+	calls := 0
+	mw := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{
+					"ttl":          "1m",
+					"body_methods": []interface{}{"post"},
+				},
+			},
+		},
+	})
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		calls++
+		b, _ := io.ReadAll(req.Body)
+		return &Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"body": string(b)},
+			Metadata:   Metadata{StatusCode: 200, Headers: map[string][]string{"X-Foo": {"bar"}}},
+		}, nil
+	})
+
+	for i, tc := range []struct {
+		body          string
+		expectedCalls int
+	}{
+		{body: `{"query":"a"}`, expectedCalls: 1},
+		{body: `{"query":"a"}`, expectedCalls: 1},
+		{body: `{"query":"b"}`, expectedCalls: 2},
+		{body: `{"query":"a"}`, expectedCalls: 2},
+	} {
+		resp, err := p(context.Background(), &Request{
+			Method: "POST",
+			Path:   "/search",
+			Body:   io.NopCloser(strings.NewReader(tc.body)),
+		})
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %s", i, err.Error())
+			return
+		}
+		if calls != tc.expectedCalls {
+			t.Errorf("#%d: unexpected number of calls to the backend. have: %d, want: %d", i, calls, tc.expectedCalls)
+		}
+		if v, ok := resp.Data["body"].(string); !ok || v != tc.body {
+			t.Errorf("#%d: unexpected response: %v", i, resp.Data)
+		}
+		if h := resp.Metadata.Headers["X-Foo"]; len(h) != 1 || h[0] != "bar" {
+			t.Errorf("#%d: unexpected headers: %v", i, resp.Metadata.Headers)
+		}
+	}
+}
+
+func TestNewBackendCacheMiddleware_methods(t *testing.T) {
+	calls := 0
+	mw := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{
+					"ttl": "1m",
+				},
+			},
+		},
+	})
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		calls++
+		return &Response{IsComplete: true, Data: map[string]interface{}{"foo": "bar"}}, nil
+	})
+
+	for i, tc := range []struct {
+		method        string
+		query         url.Values
+		expectedCalls int
+	}{
+		{method: "GET", expectedCalls: 1},
+		{method: "GET", expectedCalls: 1},
+		{method: "GET", query: url.Values{"a": {"1"}}, expectedCalls: 2},
+		{method: "GET", query: url.Values{"a": {"1"}}, expectedCalls: 2},
+		{method: "POST", expectedCalls: 3},
+		{method: "POST", expectedCalls: 4},
+	} {
+		resp, err := p(context.Background(), &Request{
+			Method: tc.method,
+			Path:   "/foo",
+			Query:  tc.query,
+			Body:   io.NopCloser(strings.NewReader("{}")),
+		})
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %s", i, err.Error())
+			return
+		}
+		if calls != tc.expectedCalls {
+			t.Errorf("#%d: unexpected number of calls to the backend. have: %d, want: %d", i, calls, tc.expectedCalls)
+		}
+		// the cached copies are not affected by the changes in the returned responses
+		resp.Data["foo"] = "modified"
+	}
+
+	resp, _ := p(context.Background(), &Request{Method: "GET", Path: "/foo"})
+	if v := resp.Data["foo"]; v != "bar" {
+		t.Errorf("unexpected cached value: %v", v)
+	}
+}
+
+func TestNewBackendCacheMiddleware_incomplete(t *testing.T) {
+	calls := 0
+	mw := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{
+					"ttl": "1m",
+				},
+			},
+		},
+	})
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		calls++
+		return &Response{IsComplete: false, Data: map[string]interface{}{"foo": "bar"}}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		p(context.Background(), &Request{Method: "GET", Path: "/foo"})
+	}
+	if calls != 3 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	c := newResponseCache(2)
+	now := time.Now()
+
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, &Response{Data: map[string]interface{}{"key": k}}, now.Add(time.Minute))
+	}
+
+	if _, ok := c.Get("a", now); ok {
+		t.Error("the oldest entry should be evicted")
+	}
+	if _, ok := c.Get("b", now); !ok {
+		t.Error("the entry b should be cached")
+	}
+	if _, ok := c.Get("c", now.Add(time.Minute)); ok {
+		t.Error("the entry c should be expired")
+	}
+	if _, ok := c.Get("c", now); ok {
+		t.Error("the expired entry c should be removed")
+	}
+}
+
+func TestResponseCache_numbers(t *testing.T) {
+	c := newResponseCache(10)
+	c.compressMinSize = 1024
+	now := time.Now()
+
+	padding := strings.Repeat("a", 2048)
+	c.Set("plain", &Response{Data: map[string]interface{}{"id": json.Number("12345678901234567890")}}, now.Add(time.Minute))
+	c.Set("compressed", &Response{Data: map[string]interface{}{"id": json.Number("12345678901234567890"), "pad": padding}}, now.Add(time.Minute))
+
+	for _, key := range []string{"plain", "compressed"} {
+		resp, ok := c.Get(key, now)
+		if !ok {
+			t.Errorf("%s: the entry should be cached", key)
+			continue
+		}
+		if n, ok := resp.Data["id"].(json.Number); !ok || n.String() != "12345678901234567890" {
+			t.Errorf("%s: the numbers should keep their type and precision: %#v", key, resp.Data["id"])
+		}
+	}
+}
+
+func TestResponseCache_compressed(t *testing.T) {
+	c := newResponseCache(10)
+	c.compressMinSize = 1024
+	now := time.Now()
+
+	large := strings.Repeat("lorem ipsum ", 1000)
+	c.Set("large", &Response{
+		Data:     map[string]interface{}{"text": large},
+		Metadata: Metadata{StatusCode: 200, Headers: map[string][]string{"X-Foo": {"bar"}}},
+	}, now.Add(time.Minute))
+	c.Set("small", &Response{Data: map[string]interface{}{"text": "lorem ipsum"}}, now.Add(time.Minute))
+
+	entry := c.items["large"].Value.(*cacheEntry)
+	if !entry.compressed {
+		t.Error("the large entry should be stored compressed")
+	}
+	if len(entry.data) >= len(large) {
+		t.Errorf("the compressed entry should be smaller than the response: %d", len(entry.data))
+	}
+	if c.items["small"].Value.(*cacheEntry).compressed {
+		t.Error("the entries under the threshold should not be compressed")
+	}
+
+	resp, ok := c.Get("large", now)
+	if !ok {
+		t.Error("the large entry should be cached")
+		return
+	}
+	if resp.Data["text"] != large {
+		t.Error("unexpected content of the decompressed entry")
+	}
+	if resp.Metadata.StatusCode != 200 || resp.Metadata.Headers["X-Foo"][0] != "bar" {
+		t.Errorf("unexpected metadata: %+v", resp.Metadata)
+	}
+
+	resp, ok = c.Get("small", now)
+	if !ok || resp.Data["text"] != "lorem ipsum" {
+		t.Errorf("unexpected small entry: %v", resp)
+	}
+
+	cfg, _ := getCacheConfig(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"cache": map[string]interface{}{"ttl": "1m", "compress_min_size": 1024.0},
+		},
+	})
+	if cfg.CompressMinSize != 1024 {
+		t.Errorf("unexpected compression threshold: %d", cfg.CompressMinSize)
+	}
+}
+
+func TestFlushBackendCaches(t *testing.T) {
+	calls := 0
+	mw := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{
+					"ttl": "1m",
+				},
+			},
+		},
+	})
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		calls++
+		return &Response{IsComplete: true, Data: map[string]interface{}{}}, nil
+	})
+
+	p(context.Background(), &Request{Method: "GET", Path: "/foo"})
+	p(context.Background(), &Request{Method: "GET", Path: "/foo"})
+	if calls != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+
+	if n := FlushBackendCaches(); n < 1 {
+		t.Errorf("unexpected number of flushed entries: %d", n)
+	}
+
+	p(context.Background(), &Request{Method: "GET", Path: "/foo"})
+	if calls != 2 {
+		t.Errorf("the request after the flush should reach the backend. calls: %d", calls)
+	}
+}
+
+func TestNewBackendCacheMiddleware_noConfig(t *testing.T) {
+	calls := 0
+	p := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{})(func(_ context.Context, req *Request) (*Response, error) {
+		calls++
+		return &Response{IsComplete: true, Data: map[string]interface{}{}}, nil
+	})
+	for i := 0; i < 3; i++ {
+		p(context.Background(), &Request{Method: "GET", Path: "/foo"})
+	}
+	if calls != 3 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewBackendCacheMiddleware_negative(t *testing.T) {
+	calls := map[string]int{}
+	mw := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{
+					"ttl":      "1m",
+					"negative": map[string]interface{}{"ttl": "50ms"},
+				},
+			},
+		},
+	})
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		calls[req.Path]++
+		switch req.Path {
+		case "/missing":
+			return nil, client.HTTPResponseError{Code: http.StatusNotFound, Msg: "not found"}
+		case "/broken":
+			return nil, client.HTTPResponseError{Code: http.StatusInternalServerError, Msg: "boom"}
+		case "/gone":
+			return &Response{Data: map[string]interface{}{"gone": true}, Metadata: Metadata{StatusCode: http.StatusNotFound}}, nil
+		}
+		return &Response{IsComplete: true, Data: map[string]interface{}{"foo": "bar"}}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		resp, err := p(context.Background(), &Request{Method: "GET", Path: "/missing"})
+		if resp != nil {
+			t.Errorf("#%d: unexpected response: %v", i, resp)
+		}
+		if e, ok := err.(client.HTTPResponseError); !ok || e.Code != http.StatusNotFound || e.Msg != "not found" {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+
+		resp, err = p(context.Background(), &Request{Method: "GET", Path: "/gone"})
+		if err != nil || resp == nil || resp.IsComplete || resp.Metadata.StatusCode != http.StatusNotFound || resp.Data["gone"] != true {
+			t.Errorf("#%d: unexpected result: %v, %v", i, resp, err)
+		}
+
+		p(context.Background(), &Request{Method: "GET", Path: "/broken"})
+	}
+	if calls["/missing"] != 1 || calls["/gone"] != 1 {
+		t.Errorf("the negative responses should be served from the cache: %v", calls)
+	}
+	if calls["/broken"] != 3 {
+		t.Errorf("the errors with other statuses should not be cached: %v", calls)
+	}
+
+	<-time.After(60 * time.Millisecond)
+
+	p(context.Background(), &Request{Method: "GET", Path: "/missing"})
+	if calls["/missing"] != 2 {
+		t.Errorf("the negative responses should expire after their own TTL: %v", calls)
+	}
+}
+
+func TestNewBackendCacheMiddleware_negativeDisabled(t *testing.T) {
+	calls := 0
+	mw := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{"ttl": "1m"},
+			},
+		},
+	})
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return nil, client.HTTPResponseError{Code: http.StatusNotFound}
+	})
+	for i := 0; i < 2; i++ {
+		p(context.Background(), &Request{Method: "GET", Path: "/missing"})
+	}
+	if calls != 2 {
+		t.Errorf("the negative responses should not be cached by default: %d", calls)
+	}
+}
+
+func TestNewBackendCacheMiddleware_negativeDefaultStatusHandler(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{
+					"ttl":      "1m",
+					"negative": map[string]interface{}{"ttl": "1m"},
+				},
+			},
+		},
+	}
+	calls := map[string]int{}
+	re := func(_ context.Context, req *http.Request) (*http.Response, error) {
+		calls[req.URL.Path]++
+		status := http.StatusNotFound
+		if req.URL.Path == "/broken" {
+			status = http.StatusInternalServerError
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	}
+	p := NewBackendCacheMiddleware(logging.NoOp, remote)(
+		NewHTTPProxyDetailed(remote, re, client.DefaultHTTPStatusHandler, DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{
+			Decoder:         encoding.JSONDecoder,
+			EntityFormatter: NewEntityFormatter(remote),
+		})),
+	)
+
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/missing", "/broken"} {
+			u, _ := url.Parse("http://example.com" + path)
+			resp, err := p(context.Background(), &Request{Method: "GET", Path: path, URL: u})
+			if resp != nil || err != client.ErrInvalidStatusCode {
+				t.Errorf("#%d %s: unexpected result: %v, %v", i, path, resp, err)
+			}
+		}
+	}
+	if calls["/missing"] != 1 {
+		t.Errorf("the 404 responses should be served from the cache: %v", calls)
+	}
+	if calls["/broken"] != 3 {
+		t.Errorf("the errors with other statuses should not be cached: %v", calls)
+	}
+}
+
+func TestNewBackendCacheMiddleware_headersKey(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		vary          []interface{}
+		headers       []map[string][]string
+		expectedCalls int
+	}{
+		{
+			name: "different users",
+			headers: []map[string][]string{
+				{"Authorization": {"Bearer a"}},
+				{"Authorization": {"Bearer b"}},
+				{"Authorization": {"Bearer a"}},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "forwarded headers",
+			headers: []map[string][]string{
+				{"X-Tenant": {"a"}},
+				{"X-Tenant": {"b"}},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "vary",
+			vary: []interface{}{"x-tenant"},
+			headers: []map[string][]string{
+				{"X-Tenant": {"a"}, "X-Request-Id": {"1"}},
+				{"X-Tenant": {"a"}, "X-Request-Id": {"2"}},
+				{"X-Tenant": {"b"}, "X-Request-Id": {"3"}},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "vary with cookies",
+			vary: []interface{}{"x-tenant"},
+			headers: []map[string][]string{
+				{"X-Tenant": {"a"}, "Cookie": {"session=1"}},
+				{"X-Tenant": {"a"}, "Cookie": {"session=2"}},
+			},
+			expectedCalls: 2,
+		},
+	} {
+		cfg := map[string]interface{}{"ttl": "1m"}
+		if tc.vary != nil {
+			cfg["vary"] = tc.vary
+		}
+		calls := 0
+		p := NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"cache": cfg}},
+		})(func(_ context.Context, req *Request) (*Response, error) {
+			calls++
+			return &Response{IsComplete: true, Data: map[string]interface{}{"user": req.Headers["Authorization"]}}, nil
+		})
+
+		for _, h := range tc.headers {
+			if _, err := p(context.Background(), &Request{Method: "GET", Path: "/me", Headers: h}); err != nil {
+				t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			}
+		}
+		if calls != tc.expectedCalls {
+			t.Errorf("%s: unexpected number of calls to the backend. have: %d, want: %d", tc.name, calls, tc.expectedCalls)
+		}
+	}
+}
+
+func TestNewBackendCacheMiddleware_rebuild(t *testing.T) {
+	newBackend := func() *config.Backend {
+		return &config.Backend{
+			URLPattern:           "/rebuilt",
+			ParentEndpoint:       "/rebuilt",
+			ParentEndpointMethod: "GET",
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{"cache": map[string]interface{}{"ttl": "1m"}},
+			},
+		}
+	}
+
+	responseCachesMu.Lock()
+	before := len(responseCaches)
+	responseCachesMu.Unlock()
+
+	for i := 0; i < 5; i++ {
+		NewBackendCacheMiddleware(logging.NoOp, newBackend())
+	}
+
+	responseCachesMu.Lock()
+	after := len(responseCaches)
+	responseCachesMu.Unlock()
+	if after != before+1 {
+		t.Errorf("the rebuilt caches should replace the previous ones. have %d caches, want %d", after, before+1)
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const clampKey = "clamp"
+
+// NewClampMiddleware creates a proxy middleware limiting the numeric fields of the response
+// to the configured ranges, so a backend returning an unexpected value can not leak it to
+// the clients:
+//
+//	"clamp": [
+//		{ "field": "discount", "min": 0, "max": 0.5 },
+//		{ "field": "items.quantity", "min": 1 }
+//	]
+//
+// A path crossing an array, like items.quantity above, clamps the field in every item (see
+// transformPath). Both limits are optional and the values that are not numbers are left
+// untouched.
+func NewClampMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	clamps, err := getClamps(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Clamp] %s", endpointConfig.Endpoint, err.Error()))
+		return emptyMiddlewareFallback(logger)
+	}
+	if len(clamps) == 0 {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	for _, c := range clamps {
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Clamp] Clamping '%s' into [%v, %v]",
+			endpointConfig.Endpoint, strings.Join(c.Path, "."), c.Min, c.Max))
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewClampMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			for _, c := range clamps {
+				c.Apply(resp.Data)
+			}
+			return resp, err
+		}
+	}
+}
+
+type clamp struct {
+	Path []string
+	Min  float64
+	Max  float64
+}
+
+// Apply limits the numeric values found at the configured path
+func (c clamp) Apply(data map[string]interface{}) {
+	transformPath(data, c.Path, func(v interface{}) (interface{}, error) {
+		f, ok := numericValue(v)
+		if !ok {
+			return v, nil
+		}
+		if f < c.Min {
+			return c.Min, nil
+		}
+		if f > c.Max {
+			return c.Max, nil
+		}
+		return v, nil
+	})
+}
+
+func getClamps(extra config.ExtraConfig) ([]clamp, error) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	vs, ok := e[clampKey].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	clamps := make([]clamp, 0, len(vs))
+	for i, raw := range vs {
+		c, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid clamp #%d", i)
+		}
+		field, _ := c["field"].(string)
+		if field == "" {
+			return nil, fmt.Errorf("the clamp #%d has no field", i)
+		}
+		cl := clamp{Path: splitPath(field), Min: math.Inf(-1), Max: math.Inf(1)}
+		if m, ok := c["min"].(float64); ok {
+			cl.Min = m
+		}
+		if m, ok := c["max"].(float64); ok {
+			cl.Max = m
+		}
+		if cl.Min > cl.Max {
+			return nil, fmt.Errorf("the clamp of the field '%s' has a min greater than its max", field)
+		}
+		clamps = append(clamps, cl)
+	}
+	return clamps, nil
+}
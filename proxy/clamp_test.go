@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewClampMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/offers",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				clampKey: []interface{}{
+					map[string]interface{}{"field": "discount", "min": 0.0, "max": 0.5},
+					map[string]interface{}{"field": "items.quantity", "min": 1.0},
+				},
+			},
+		},
+	}
+
+	p := NewClampMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{
+		IsComplete: true,
+		Data: map[string]interface{}{
+			"discount": 0.9,
+			"items": []interface{}{
+				map[string]interface{}{"quantity": json.Number("-3")},
+				map[string]interface{}{"quantity": 42},
+				map[string]interface{}{"quantity": "many"},
+			},
+		},
+	}))
+
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	if resp.Data["discount"] != 0.5 {
+		t.Errorf("the discount should be clamped to the max: %v", resp.Data["discount"])
+	}
+
+	items := resp.Data["items"].([]interface{})
+	if v := items[0].(map[string]interface{})["quantity"]; v != 1.0 {
+		t.Errorf("the quantity should be clamped to the min: %v", v)
+	}
+	if v := items[1].(map[string]interface{})["quantity"]; v != 42 {
+		t.Errorf("the values inside the range should not be modified: %v", v)
+	}
+	if v := items[2].(map[string]interface{})["quantity"]; v != "many" {
+		t.Errorf("the non numeric values should not be modified: %v", v)
+	}
+}
+
+func TestNewClampMiddleware_invalid(t *testing.T) {
+	for _, clamps := range []interface{}{
+		[]interface{}{"discount"},
+		[]interface{}{map[string]interface{}{"max": 0.5}},
+		[]interface{}{map[string]interface{}{"field": "discount", "min": 1.0, "max": 0.5}},
+	} {
+		endpoint := &config.EndpointConfig{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{clampKey: clamps},
+			},
+		}
+		p := NewClampMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"discount": 0.9},
+		}))
+		resp, _ := p(context.Background(), &Request{})
+		if resp.Data["discount"] != 0.9 {
+			t.Errorf("%v: the response should not be modified: %v", clamps, resp.Data)
+		}
+	}
+}
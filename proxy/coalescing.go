@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	coalescingKey = "coalescing"
+
+	// CoalescingLeaderCounter is the name of the counter tracking the requests executed by
+	// the coalescing middleware on behalf of all the identical requests in flight
+	CoalescingLeaderCounter = "proxy.coalescing.leader"
+	// CoalescedRequestsCounter is the name of the counter tracking the requests served with
+	// the response of an identical request already in flight
+	CoalescedRequestsCounter = "proxy.coalescing.coalesced"
+)
+
+// NewCoalescingMiddleware creates a proxy middleware executing only once the identical GET and
+// HEAD requests received while the first of them is in flight. The rest of requests wait for
+// it and get a copy of its response. Two requests are identical if they share the path, the
+// query string and the values of the forwarded headers. The key can be limited to the headers
+// listed in the configuration, but the Authorization and the Cookie headers are always part
+// of it:
+//
+//	"coalescing": { "headers": [ "X-Tenant" ] }
+//
+// The shared request is not canceled when the client that started it goes away, since the
+// rest of requests are waiting for it. Instead, it gets the timeout of the endpoint.
+//
+// The requests executed and the ones coalesced are recorded with the default events
+// recorder, labeled with the endpoint.
+func NewCoalescingMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	headers, ok := getCoalescingConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	if headers == nil {
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Coalescing] Coalescing the identical requests", endpointConfig.Endpoint))
+	} else {
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Coalescing] Coalescing the identical requests (headers: %v)", endpointConfig.Endpoint, headers))
+	}
+	labels := map[string]string{"endpoint": endpointConfig.Endpoint}
+	timeout := endpointConfig.Timeout
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewCoalescingMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		group := &singleflight.Group{}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if request.Method != "GET" && request.Method != "HEAD" {
+				return next[0](ctx, request)
+			}
+
+			key := coalescingRequestKey(request, headers)
+			done := make(chan coalescingResult, 1)
+			go func() {
+				leader := false
+				v, err, shared := group.Do(key, func() (interface{}, error) {
+					leader = true
+					sharedCtx, cancel := detachContext(ctx, timeout)
+					resp, err := next[0](sharedCtx, request)
+					if resp == nil || resp.Io == nil {
+						// the streamed responses are bound to the context until they are consumed
+						cancel()
+					}
+					return resp, err
+				})
+
+				recorder := events.DefaultRecorder()
+				if leader {
+					recorder.Counter(CoalescingLeaderCounter, 1, labels)
+				} else {
+					recorder.Counter(CoalescedRequestsCounter, 1, labels)
+				}
+				resp, _ := v.(*Response)
+				done <- coalescingResult{resp: resp, err: err, leader: leader, shared: shared}
+			}()
+
+			var res coalescingResult
+			select {
+			case res = <-done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			if !res.shared || res.resp == nil {
+				return res.resp, res.err
+			}
+			if res.resp.Io != nil {
+				// streamed responses can not be shared, so only the leader gets it
+				if res.leader {
+					return res.resp, res.err
+				}
+				return next[0](ctx, request)
+			}
+			return copyResponse(res.resp), res.err
+		}
+	}
+}
+
+type coalescingResult struct {
+	resp   *Response
+	err    error
+	leader bool
+	shared bool
+}
+
+// detachContext returns a context keeping the values of the received one but not its
+// cancellation, with the received timeout (if any)
+func detachContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return newContextWrapperWithTimeout(ctx, timeout)
+	}
+	c, cancel := context.WithCancel(context.Background())
+	return contextWrapper{Context: c, data: ctx}, cancel
+}
+
+func coalescingRequestKey(r *Request, headers []string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.Path)
+	b.WriteByte('?')
+	b.WriteString(r.Query.Encode())
+	writeHeadersKey(&b, r.Headers, headers)
+	return b.String()
+}
+
+// copyResponse returns a deep copy of the data and the metadata of the response, so the
+// requests sharing it can modify their responses without races
+func copyResponse(r *Response) *Response {
+	res := &Response{
+		IsComplete: r.IsComplete,
+		Metadata:   Metadata{StatusCode: r.Metadata.StatusCode},
+	}
+	if r.Data != nil {
+		res.Data = copyJSONValue(r.Data).(map[string]interface{})
+	}
+	if r.Metadata.Headers != nil {
+		res.Metadata.Headers = make(map[string][]string, len(r.Metadata.Headers))
+		for k, vs := range r.Metadata.Headers {
+			res.Metadata.Headers[k] = append([]string{}, vs...)
+		}
+	}
+	return res
+}
+
+func getCoalescingConfig(extra config.ExtraConfig) ([]string, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	cfg, ok := e[coalescingKey].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return varyHeaders(cfg["headers"]), true
+}
@@ -0,0 +1,326 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type coalescingRecorder struct {
+	mu       *sync.Mutex
+	counters map[string]int64
+}
+
+func (r *coalescingRecorder) Counter(name string, delta int64, labels map[string]string) {
+	r.mu.Lock()
+	r.counters[labels["endpoint"]+" "+name] += delta
+	r.mu.Unlock()
+}
+
+func (*coalescingRecorder) Gauge(_ string, _ int64, _ map[string]string) {}
+
+func (r *coalescingRecorder) get(name string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters["/products "+name]
+}
+
+func TestNewCoalescingMiddleware(t *testing.T) {
+	recorder := &coalescingRecorder{mu: new(sync.Mutex), counters: map[string]int64{}}
+	events.SetDefaultRecorder(recorder)
+	defer events.SetDefaultRecorder(nil)
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/products",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				coalescingKey: map[string]interface{}{"headers": []interface{}{"authorization"}},
+			},
+		},
+	}
+
+	var calls uint64
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	p := NewCoalescingMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddUint64(&calls, 1)
+		entered <- struct{}{}
+		<-release
+		return &Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"items": []interface{}{"a", "b"}},
+			Metadata:   Metadata{StatusCode: 200, Headers: map[string][]string{"X-Foo": {"bar"}}},
+		}, nil
+	})
+
+	newRequest := func() *Request {
+		return &Request{
+			Method:  "GET",
+			Path:    "/products",
+			Query:   map[string][]string{"page": {"1"}},
+			Headers: map[string][]string{"Authorization": {"Bearer 1234"}},
+		}
+	}
+
+	total := 10
+	responses := make([]*Response, total)
+	wg := &sync.WaitGroup{}
+	wg.Add(total)
+	request := func(i int) {
+		resp, err := p(context.Background(), newRequest())
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+		responses[i] = resp
+		wg.Done()
+	}
+
+	go request(0)
+	<-entered
+	for i := 1; i < total; i++ {
+		go request(i)
+	}
+	// let the burst reach the middleware while the first request is in flight
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if c := atomic.LoadUint64(&calls); c != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", c)
+	}
+	if v := recorder.get(CoalescingLeaderCounter); v != 1 {
+		t.Errorf("unexpected number of leader calls: %d", v)
+	}
+	if v := recorder.get(CoalescedRequestsCounter); v != int64(total-1) {
+		t.Errorf("unexpected number of coalesced requests: %d", v)
+	}
+
+	for i, resp := range responses {
+		if resp == nil || !resp.IsComplete || resp.Metadata.StatusCode != 200 || resp.Metadata.Headers["X-Foo"][0] != "bar" {
+			t.Errorf("#%d: unexpected response: %+v", i, resp)
+			return
+		}
+	}
+	responses[0].Data["items"].([]interface{})[0] = "changed"
+	responses[0].Metadata.Headers["X-Foo"][0] = "changed"
+	for i, resp := range responses[1:] {
+		if resp.Data["items"].([]interface{})[0] != "a" || resp.Metadata.Headers["X-Foo"][0] != "bar" {
+			t.Errorf("#%d: the responses should not be shared: %+v", i+1, resp)
+		}
+	}
+
+	// the requests with different headers are not coalesced
+	r := newRequest()
+	r.Headers["Authorization"] = []string{"Bearer 5678"}
+	if _, err := p(context.Background(), r); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if c := atomic.LoadUint64(&calls); c != 2 {
+		t.Errorf("unexpected number of calls to the backend: %d", c)
+	}
+	if v := recorder.get(CoalescingLeaderCounter); v != 2 {
+		t.Errorf("unexpected number of leader calls: %d", v)
+	}
+
+	// the requests with other methods are not coalesced nor recorded
+	r = newRequest()
+	r.Method = "POST"
+	if _, err := p(context.Background(), r); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if c := atomic.LoadUint64(&calls); c != 3 {
+		t.Errorf("unexpected number of calls to the backend: %d", c)
+	}
+	if v := recorder.get(CoalescingLeaderCounter) + recorder.get(CoalescedRequestsCounter); v != int64(total+1) {
+		t.Errorf("unexpected number of recorded requests: %d", v)
+	}
+}
+
+func TestNewCoalescingMiddleware_canceledLeader(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/products",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{coalescingKey: map[string]interface{}{}},
+		},
+	}
+
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	p := NewCoalescingMiddleware(logging.NoOp, endpoint)(func(ctx context.Context, _ *Request) (*Response, error) {
+		entered <- struct{}{}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return &Response{IsComplete: true, Data: map[string]interface{}{"foo": "bar"}}, nil
+	})
+
+	newRequest := func() *Request {
+		return &Request{Method: "GET", Path: "/products", Headers: map[string][]string{}}
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := p(leaderCtx, newRequest())
+		leaderErr <- err
+	}()
+	<-entered
+
+	followerResp := make(chan *Response, 1)
+	go func() {
+		resp, err := p(context.Background(), newRequest())
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+		followerResp <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	close(release)
+
+	resp := <-followerResp
+	if resp == nil || resp.Data["foo"] != "bar" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(entered) != 0 {
+		t.Error("the follower should not call the backend")
+	}
+}
+
+func TestCoalescingRequestKey(t *testing.T) {
+	newRequest := func(headers map[string][]string) *Request {
+		return &Request{Method: "GET", Path: "/products", Headers: headers}
+	}
+
+	// by default, all the forwarded headers are part of the key
+	k1 := coalescingRequestKey(newRequest(map[string][]string{"Authorization": {"a"}, "X-Tenant": {"1"}}), nil)
+	k2 := coalescingRequestKey(newRequest(map[string][]string{"Authorization": {"a"}, "X-Tenant": {"2"}}), nil)
+	if k1 == k2 {
+		t.Error("the requests with different headers should not share the key")
+	}
+	if k1 != coalescingRequestKey(newRequest(map[string][]string{"X-Tenant": {"1"}, "Authorization": {"a"}}), nil) {
+		t.Error("the key should not depend on the order of the headers")
+	}
+
+	// the identity headers are always part of the configured key
+	headers, _ := getCoalescingConfig(config.ExtraConfig{
+		Namespace: map[string]interface{}{coalescingKey: map[string]interface{}{"headers": []interface{}{"x-tenant"}}},
+	})
+	for _, h := range []string{"Authorization", "Cookie", "X-Tenant"} {
+		r1 := newRequest(map[string][]string{h: {"1"}, "X-Request-Id": {"a"}})
+		r2 := newRequest(map[string][]string{h: {"2"}, "X-Request-Id": {"b"}})
+		if coalescingRequestKey(r1, headers) == coalescingRequestKey(r2, headers) {
+			t.Errorf("the requests with different %s headers should not share the key", h)
+		}
+	}
+	r1 := newRequest(map[string][]string{"X-Request-Id": {"a"}})
+	r2 := newRequest(map[string][]string{"X-Request-Id": {"b"}})
+	if coalescingRequestKey(r1, headers) != coalescingRequestKey(r2, headers) {
+		t.Error("the headers not configured should not be part of the key")
+	}
+}
+
+func TestNewDefaultFactory_coalescingExperiments(t *testing.T) {
+	endpoint := experimentEndpoint()
+	endpoint.Timeout = time.Second
+	endpoint.Backend = []*config.Backend{{URLPattern: "/checkout", Host: []string{"http://127.0.0.1"}}}
+	endpoint.ExtraConfig[Namespace].(map[string]interface{})[coalescingKey] = map[string]interface{}{
+		"headers": []interface{}{"X-Tenant"},
+	}
+
+	var calls uint64
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	factory := NewDefaultFactory(func(_ *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			atomic.AddUint64(&calls, 1)
+			entered <- struct{}{}
+			<-release
+			return &Response{IsComplete: true, Data: map[string]interface{}{"a": 1, "b": 2, "c": 3}}, nil
+		}
+	}, logging.NoOp)
+	p, err := factory.New(endpoint)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// find two users assigned to different variants
+	assign := NewExperimentMiddleware(logging.NoOp, experimentEndpoint())(experimentProxy())
+	variants := map[string]string{}
+	for i := 0; len(variants) < 2; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		resp, _ := assign(context.Background(), experimentRequest(user))
+		variant := resp.Metadata.Headers[ExperimentHeaderName][0]
+		if _, ok := variants[variant]; !ok {
+			variants[variant] = user
+		}
+	}
+
+	newRequest := func(user string) *Request {
+		return &Request{
+			Method:  "GET",
+			Path:    "/checkout",
+			Params:  map[string]string{},
+			Query:   map[string][]string{},
+			Headers: map[string][]string{"X-User-Id": {user}, "X-Tenant": {"acme"}},
+		}
+	}
+
+	type result struct {
+		variant string
+		resp    *Response
+	}
+	results := make(chan result, len(variants))
+	first := true
+	for variant, user := range variants {
+		go func(variant, user string) {
+			resp, err := p(context.Background(), newRequest(user))
+			if err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+			results <- result{variant: variant, resp: resp}
+		}(variant, user)
+		if first {
+			select {
+			case <-entered:
+			case <-time.After(time.Second):
+				t.Error("the backend has not been called")
+				return
+			}
+			first = false
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for range variants {
+		res := <-results
+		if res.resp == nil {
+			t.Errorf("%s: no response", res.variant)
+			continue
+		}
+		if h := res.resp.Metadata.Headers[ExperimentHeaderName]; len(h) != 1 || h[0] != res.variant {
+			t.Errorf("%s: unexpected variant %v", res.variant, h)
+		}
+	}
+	if c := atomic.LoadUint64(&calls); c != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", c)
+	}
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const coerceKey = "coerce"
+
+var (
+	truthyValues = map[string]struct{}{"true": {}, "t": {}, "yes": {}, "y": {}, "on": {}, "1": {}}
+	falsyValues  = map[string]struct{}{"false": {}, "f": {}, "no": {}, "n": {}, "off": {}, "0": {}}
+)
+
+// NewCoerceMiddleware creates a proxy middleware normalizing the configured fields of the
+// JSON request body before forwarding it:
+//
+//	"coerce": {
+//		"booleans": [ "active", "settings.notify" ],
+//		"nulls": [ "nickname" ]
+//	}
+//
+// The 'booleans' fields are replaced by true or false when they contain one of the usual
+// representations of a boolean: the numbers 1 and 0 and the strings "true", "t", "yes",
+// "y", "on" and "1" (or their negative versions), regardless of their case. The empty
+// strings found at the 'nulls' fields are replaced by null. Since the request bodies often
+// carry lists, the paths reaching into arrays coerce the field of every element (see
+// transformPath). The unrecognized values are left untouched.
+func NewCoerceMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := getCoerceConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][Coerce] Coercing the fields %v to booleans and the fields %v to null",
+			endpointConfig.Endpoint,
+			cfg.Booleans,
+			cfg.Nulls,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewCoerceMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			err := rewriteJSONBody(request, func(data map[string]interface{}) error {
+				cfg.Apply(data)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			return next[0](ctx, request)
+		}
+	}
+}
+
+type coerceConfig struct {
+	Booleans []string
+	Nulls    []string
+}
+
+// Apply normalizes the configured fields of the data
+func (c coerceConfig) Apply(data map[string]interface{}) {
+	for _, field := range c.Nulls {
+		transformPath(data, splitPath(field), func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok && s == "" {
+				return nil, nil
+			}
+			return v, nil
+		})
+	}
+	for _, field := range c.Booleans {
+		transformPath(data, splitPath(field), func(v interface{}) (interface{}, error) {
+			if b, ok := coerceBoolean(v); ok {
+				return b, nil
+			}
+			return v, nil
+		})
+	}
+}
+
+func coerceBoolean(v interface{}) (bool, bool) {
+	var s string
+	switch t := v.(type) {
+	case bool:
+		return t, true
+	case string:
+		s = strings.ToLower(strings.TrimSpace(t))
+	case json.Number:
+		s = t.String()
+	case float64:
+		s = fmt.Sprintf("%v", t)
+	default:
+		return false, false
+	}
+	if _, ok := truthyValues[s]; ok {
+		return true, true
+	}
+	if _, ok := falsyValues[s]; ok {
+		return false, true
+	}
+	return false, false
+}
+
+func getCoerceConfig(extra config.ExtraConfig) (coerceConfig, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return coerceConfig{}, false
+	}
+	tmp, ok := e[coerceKey].(map[string]interface{})
+	if !ok {
+		return coerceConfig{}, false
+	}
+	cfg := coerceConfig{
+		Booleans: stringList(tmp["booleans"]),
+		Nulls:    stringList(tmp["nulls"]),
+	}
+	return cfg, len(cfg.Booleans) > 0 || len(cfg.Nulls) > 0
+}
+
+func stringList(v interface{}) []string {
+	vs, _ := v.([]interface{})
+	res := []string{}
+	for _, s := range vs {
+		if s, ok := s.(string); ok && s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
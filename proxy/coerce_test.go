@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewCoerceMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				coerceKey: map[string]interface{}{
+					"booleans": []interface{}{"active", "settings.notify", "items.enabled"},
+					"nulls":    []interface{}{"nickname", "settings.notify"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected map[string]interface{}
+	}{
+		{name: "true", body: `{"active":true}`, expected: map[string]interface{}{"active": true}},
+		{name: "string true", body: `{"active":"true"}`, expected: map[string]interface{}{"active": true}},
+		{name: "capitalized true", body: `{"active":"TRUE"}`, expected: map[string]interface{}{"active": true}},
+		{name: "yes", body: `{"active":"yes"}`, expected: map[string]interface{}{"active": true}},
+		{name: "y", body: `{"active":" Y "}`, expected: map[string]interface{}{"active": true}},
+		{name: "on", body: `{"active":"on"}`, expected: map[string]interface{}{"active": true}},
+		{name: "number 1", body: `{"active":1}`, expected: map[string]interface{}{"active": true}},
+		{name: "string 1", body: `{"active":"1"}`, expected: map[string]interface{}{"active": true}},
+		{name: "no", body: `{"active":"no"}`, expected: map[string]interface{}{"active": false}},
+		{name: "number 0", body: `{"active":0}`, expected: map[string]interface{}{"active": false}},
+		{name: "unknown", body: `{"active":"maybe"}`, expected: map[string]interface{}{"active": "maybe"}},
+		{name: "number 2", body: `{"active":2}`, expected: map[string]interface{}{"active": json.Number("2")}},
+		{
+			name:     "nested and arrays",
+			body:     `{"settings":{"notify":"off"},"items":[{"enabled":"yes"},{"enabled":"0"}]}`,
+			expected: map[string]interface{}{"settings": map[string]interface{}{"notify": false}, "items": []interface{}{map[string]interface{}{"enabled": true}, map[string]interface{}{"enabled": false}}},
+		},
+		{
+			name:     "nulls",
+			body:     `{"nickname":"","settings":{"notify":""},"name":""}`,
+			expected: map[string]interface{}{"nickname": nil, "settings": map[string]interface{}{"notify": nil}, "name": ""},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var body []byte
+			var contentLength string
+			p := NewCoerceMiddleware(logging.NoOp, endpoint)(func(_ context.Context, r *Request) (*Response, error) {
+				body, _ = io.ReadAll(r.Body)
+				contentLength = r.Headers["Content-Length"][0]
+				return &Response{IsComplete: true}, nil
+			})
+
+			if _, err := p(context.Background(), &Request{
+				Body:    io.NopCloser(strings.NewReader(tc.body)),
+				Headers: map[string][]string{"Content-Length": {strconv.Itoa(len(tc.body))}},
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+
+			var data map[string]interface{}
+			d := json.NewDecoder(strings.NewReader(string(body)))
+			d.UseNumber()
+			if err := d.Decode(&data); err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(data, tc.expected) {
+				t.Errorf("unexpected body. have: %s, want: %v", body, tc.expected)
+			}
+			if contentLength != strconv.Itoa(len(body)) {
+				t.Errorf("unexpected content length: %s", contentLength)
+			}
+		})
+	}
+}
+
+func TestNewCoerceMiddleware_nonJSON(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				coerceKey: map[string]interface{}{"booleans": []interface{}{"active"}},
+			},
+		},
+	}
+	var body []byte
+	p := NewCoerceMiddleware(logging.NoOp, endpoint)(func(_ context.Context, r *Request) (*Response, error) {
+		body, _ = io.ReadAll(r.Body)
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), &Request{Body: io.NopCloser(strings.NewReader("active=yes"))}); err != nil {
+		t.Error(err)
+	}
+	if string(body) != "active=yes" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	columnarKey = "columnar"
+
+	columnarFillPolicy  = "fill"
+	columnarOmitPolicy  = "omit"
+	columnarSkipPolicy  = "skip"
+	columnarErrorPolicy = "error"
+)
+
+// NewColumnarMiddleware creates a proxy middleware expanding the columnar data of the response
+// into an array of objects, so
+//
+//	{"cols": ["a", "b"], "rows": [[1, 2], [3, 4]]}
+//
+// becomes
+//
+//	{"rows": [{"a": 1, "b": 2}, {"a": 3, "b": 4}]}
+//
+// The object holding the columns and the rows is located using a dot separated path (the
+// root of the response by default) and the names of both keys are configurable. The
+// expanded array replaces the rows and the columns are removed.
+//
+// When a row does not have a value for every column, the ragged policy decides what to do:
+// fill the missing cells with nulls (default), omit the missing fields, skip the row or fail
+// with an error. The values exceeding the number of columns are always discarded.
+func NewColumnarMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := getColumnarConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][Columnar] Expanding the rows at '%s' (ragged policy: %s)",
+			endpointConfig.Endpoint,
+			cfg.location(cfg.Rows),
+			cfg.Ragged,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewColumnarMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if err != nil || resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			if expErr := cfg.Apply(resp.Data); expErr != nil {
+				logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Columnar] %s", endpointConfig.Endpoint, expErr.Error()))
+				resp.IsComplete = false
+				return resp, expErr
+			}
+			return resp, nil
+		}
+	}
+}
+
+type columnarConfig struct {
+	Path    []string
+	Columns string
+	Rows    string
+	Ragged  string
+}
+
+// Apply replaces the columnar data found at the configured path with the array of objects
+func (c columnarConfig) Apply(data map[string]interface{}) error {
+	return transformObjects(data, c.Path, func(parent map[string]interface{}) error {
+		rawColumns, ok := parent[c.Columns].([]interface{})
+		if !ok {
+			return nil
+		}
+		rows, ok := parent[c.Rows].([]interface{})
+		if !ok {
+			return nil
+		}
+
+		columns := make([]string, len(rawColumns))
+		for i, col := range rawColumns {
+			name, ok := col.(string)
+			if !ok {
+				return fmt.Errorf("invalid column #%d at '%s'", i, c.location(c.Columns))
+			}
+			columns[i] = name
+		}
+
+		res := make([]interface{}, 0, len(rows))
+		for i, raw := range rows {
+			row, ok := raw.([]interface{})
+			if !ok {
+				continue
+			}
+			if len(row) < len(columns) {
+				switch c.Ragged {
+				case columnarSkipPolicy:
+					continue
+				case columnarErrorPolicy:
+					return fmt.Errorf("the row #%d at '%s' has %d values for %d columns",
+						i, c.location(c.Rows), len(row), len(columns))
+				}
+			}
+
+			obj := make(map[string]interface{}, len(columns))
+			for j, col := range columns {
+				if j < len(row) {
+					obj[col] = row[j]
+				} else if c.Ragged == columnarFillPolicy {
+					obj[col] = nil
+				}
+			}
+			res = append(res, obj)
+		}
+
+		delete(parent, c.Columns)
+		parent[c.Rows] = res
+		return nil
+	})
+}
+
+// location returns the dot separated path of the key of the columnar object
+func (c columnarConfig) location(key string) string {
+	if len(c.Path) == 0 {
+		return key
+	}
+	return strings.Join(c.Path, ".") + "." + key
+}
+
+func getColumnarConfig(extra config.ExtraConfig) (columnarConfig, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return columnarConfig{}, false
+	}
+	tmp, ok := e[columnarKey].(map[string]interface{})
+	if !ok {
+		return columnarConfig{}, false
+	}
+
+	cfg := columnarConfig{
+		Columns: "cols",
+		Rows:    "rows",
+		Ragged:  columnarFillPolicy,
+	}
+	if path, ok := tmp["path"].(string); ok {
+		cfg.Path = splitPath(path)
+	}
+	if cols, ok := tmp["columns"].(string); ok && cols != "" {
+		cfg.Columns = cols
+	}
+	if rows, ok := tmp["rows"].(string); ok && rows != "" {
+		cfg.Rows = rows
+	}
+	if policy, ok := tmp["ragged"].(string); ok {
+		switch policy {
+		case columnarFillPolicy, columnarOmitPolicy, columnarSkipPolicy, columnarErrorPolicy:
+			cfg.Ragged = policy
+		}
+	}
+	return cfg, true
+}
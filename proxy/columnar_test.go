@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewColumnarMiddleware(t *testing.T) {
+	newData := func() map[string]interface{} {
+		return map[string]interface{}{
+			"meta": "foo",
+			"result": map[string]interface{}{
+				"columns": []interface{}{"name", "age"},
+				"values": []interface{}{
+					[]interface{}{"alice", json.Number("31")},
+					[]interface{}{"bob"},
+					[]interface{}{"carol", json.Number("27"), "extra"},
+					"not a row",
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		ragged   string
+		expected []interface{}
+		err      bool
+	}{
+		{
+			name: "fill",
+			expected: []interface{}{
+				map[string]interface{}{"name": "alice", "age": json.Number("31")},
+				map[string]interface{}{"name": "bob", "age": nil},
+				map[string]interface{}{"name": "carol", "age": json.Number("27")},
+			},
+		},
+		{
+			name:   "omit",
+			ragged: "omit",
+			expected: []interface{}{
+				map[string]interface{}{"name": "alice", "age": json.Number("31")},
+				map[string]interface{}{"name": "bob"},
+				map[string]interface{}{"name": "carol", "age": json.Number("27")},
+			},
+		},
+		{
+			name:   "skip",
+			ragged: "skip",
+			expected: []interface{}{
+				map[string]interface{}{"name": "alice", "age": json.Number("31")},
+				map[string]interface{}{"name": "carol", "age": json.Number("27")},
+			},
+		},
+		{
+			name:   "error",
+			ragged: "error",
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := map[string]interface{}{"path": "result", "columns": "columns", "rows": "values"}
+			if tc.ragged != "" {
+				cfg["ragged"] = tc.ragged
+			}
+			mw := NewColumnarMiddleware(logging.NoOp, &config.EndpointConfig{
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{columnarKey: cfg},
+				},
+			})
+			p := mw(dummyProxy(&Response{Data: newData(), IsComplete: true}))
+
+			resp, err := p(context.Background(), &Request{})
+			if tc.err {
+				if err == nil {
+					t.Error("expecting an error")
+				}
+				if resp == nil || resp.IsComplete {
+					t.Errorf("the response should be incomplete: %+v", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+				return
+			}
+
+			result := resp.Data["result"].(map[string]interface{})
+			if _, ok := result["columns"]; ok {
+				t.Errorf("the columns should be removed: %v", result)
+			}
+			if !reflect.DeepEqual(result["values"], tc.expected) {
+				t.Errorf("unexpected result: %v", result["values"])
+			}
+			if resp.Data["meta"] != "foo" {
+				t.Errorf("the rest of the response should be preserved: %v", resp.Data)
+			}
+		})
+	}
+}
+
+func TestNewColumnarMiddleware_root(t *testing.T) {
+	mw := NewColumnarMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{columnarKey: map[string]interface{}{}},
+		},
+	})
+	p := mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data: map[string]interface{}{
+			"cols": []interface{}{"a", "b"},
+			"rows": []interface{}{[]interface{}{1, 2}, []interface{}{3, 4}},
+		},
+	}))
+
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	expected := map[string]interface{}{
+		"rows": []interface{}{
+			map[string]interface{}{"a": 1, "b": 2},
+			map[string]interface{}{"a": 3, "b": 4},
+		},
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Errorf("unexpected result: %v", resp.Data)
+	}
+
+	resp, err = mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data:       map[string]interface{}{"cols": []interface{}{"a", 1}, "rows": []interface{}{}},
+	}))(context.Background(), &Request{})
+	if err == nil || resp.IsComplete {
+		t.Errorf("the invalid columns should be rejected: %v %+v", err, resp)
+	}
+}
+
+func TestNewColumnarMiddleware_arrayInPath(t *testing.T) {
+	mw := NewColumnarMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{columnarKey: map[string]interface{}{"path": "series"}},
+		},
+	})
+	series := map[string]interface{}{"cols": []interface{}{"t"}, "rows": []interface{}{[]interface{}{1}}}
+	resp, err := mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data:       map[string]interface{}{"series": []interface{}{series}},
+	}))(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"rows": []interface{}{map[string]interface{}{"t": 1}}}
+	if s := resp.Data["series"].([]interface{})[0]; !reflect.DeepEqual(s, expected) {
+		t.Errorf("unexpected series: %v", s)
+	}
+}
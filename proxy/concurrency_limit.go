@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const concurrencyLimitKey = "concurrency_limit"
+
+var errConcurrencyLimitMax = errors.New("the max number of concurrent requests must be a number greater than 0")
+
+// ConcurrencyLimitConfigError is the error returned by the endpoints with an invalid
+// concurrency limit, so a typo in the config does not remove the protection silently
+type ConcurrencyLimitConfigError struct {
+	Endpoint string
+	Err      error
+}
+
+// Error returns a string representation of the ConcurrencyLimitConfigError
+func (c ConcurrencyLimitConfigError) Error() string {
+	return fmt.Sprintf("invalid concurrency limit for the endpoint %s: %s", c.Endpoint, c.Err.Error())
+}
+
+// Unwrap returns the error invalidating the concurrency limit config
+func (c ConcurrencyLimitConfigError) Unwrap() error {
+	return c.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (ConcurrencyLimitConfigError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// NewConcurrencyLimiterMiddleware creates a proxy middleware limiting the number of requests
+// being processed concurrently by the endpoint. The requests over the limit wait until a slot
+// is released or their context is canceled.
+//
+// The waiting requests are grouped by client (identified by the configured request attribute,
+// see NewAttributeExtractor) and the released slots are allocated round-robin across the
+// clients with pending requests, so a single noisy client can not starve the rest of them.
+//
+// If the max is not a positive number or the client spec is invalid, all the requests are
+// rejected with a ConcurrencyLimitConfigError.
+func NewConcurrencyLimiterMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok, err := getConcurrencyLimitConfig(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][ConcurrencyLimiter] %s. All the requests will be rejected", endpointConfig.Endpoint, err.Error()))
+		return rejectingConcurrencyLimiterMiddleware(logger, ConcurrencyLimitConfigError{Endpoint: endpointConfig.Endpoint, Err: err})
+	}
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][ConcurrencyLimiter] Processing up to %d concurrent requests (client: %s)",
+			endpointConfig.Endpoint,
+			cfg.Max,
+			cfg.ClientSpec,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewConcurrencyLimiterMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		limiter := newFairLimiter(cfg.Max)
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			client, _ := cfg.Client(request)
+			if err := limiter.Acquire(ctx, client); err != nil {
+				return nil, err
+			}
+			defer limiter.Release()
+			return next[0](ctx, request)
+		}
+	}
+}
+
+type concurrencyLimitConfig struct {
+	Max        int
+	ClientSpec string
+	Client     AttributeExtractor
+}
+
+func rejectingConcurrencyLimiterMiddleware(logger logging.Logger, err error) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewConcurrencyLimiterMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return nil, err
+		}
+	}
+}
+
+func getConcurrencyLimitConfig(extra config.ExtraConfig) (concurrencyLimitConfig, bool, error) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return concurrencyLimitConfig{}, false, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return concurrencyLimitConfig{}, false, nil
+	}
+	tmp, ok := e[concurrencyLimitKey].(map[string]interface{})
+	if !ok {
+		return concurrencyLimitConfig{}, false, nil
+	}
+	max, ok := tmp["max"].(float64)
+	if !ok || max < 1 {
+		return concurrencyLimitConfig{}, false, errConcurrencyLimitMax
+	}
+
+	cfg := concurrencyLimitConfig{
+		Max:        int(max),
+		ClientSpec: "header:X-Forwarded-For",
+	}
+	if spec, ok := tmp["client"].(string); ok && spec != "" {
+		cfg.ClientSpec = spec
+	}
+	client, err := NewAttributeExtractor(cfg.ClientSpec)
+	if err != nil {
+		return concurrencyLimitConfig{}, false, err
+	}
+	cfg.Client = client
+	return cfg, true, nil
+}
+
+// fairLimiter is a counting semaphore with a FIFO queue per client. The released slots
+// are handed to the clients with waiting requests in round-robin order.
+type fairLimiter struct {
+	mu      *sync.Mutex
+	free    int
+	queues  map[string][]*fairWaiter
+	clients []string
+	next    int
+}
+
+type fairWaiter struct {
+	ch      chan struct{}
+	granted bool
+}
+
+func newFairLimiter(size int) *fairLimiter {
+	return &fairLimiter{
+		mu:     new(sync.Mutex),
+		free:   size,
+		queues: map[string][]*fairWaiter{},
+	}
+}
+
+// Acquire blocks until a slot is allocated to the client or the context is canceled
+func (l *fairLimiter) Acquire(ctx context.Context, client string) error {
+	l.mu.Lock()
+	if l.free > 0 && len(l.clients) == 0 {
+		l.free--
+		l.mu.Unlock()
+		return nil
+	}
+
+	w := &fairWaiter{ch: make(chan struct{})}
+	if _, ok := l.queues[client]; !ok {
+		l.clients = append(l.clients, client)
+	}
+	l.queues[client] = append(l.queues[client], w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ch:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	if w.granted {
+		l.mu.Unlock()
+		l.Release()
+		return ctx.Err()
+	}
+	l.remove(client, w)
+	l.mu.Unlock()
+	return ctx.Err()
+}
+
+// Release frees a slot, handing it to the next client with waiting requests
+func (l *fairLimiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.clients) == 0 {
+		l.free++
+		return
+	}
+
+	if l.next >= len(l.clients) {
+		l.next = 0
+	}
+	client := l.clients[l.next]
+	queue := l.queues[client]
+	w := queue[0]
+	w.granted = true
+	close(w.ch)
+
+	if len(queue) == 1 {
+		l.dropClient(l.next)
+		return
+	}
+	l.queues[client] = queue[1:]
+	l.next++
+}
+
+func (l *fairLimiter) remove(client string, w *fairWaiter) {
+	queue := l.queues[client]
+	for i, qw := range queue {
+		if qw != w {
+			continue
+		}
+		if len(queue) > 1 {
+			l.queues[client] = append(queue[:i], queue[i+1:]...)
+			return
+		}
+		for j, c := range l.clients {
+			if c == client {
+				l.dropClient(j)
+				return
+			}
+		}
+	}
+}
+
+// dropClient removes the client at the given position of the round-robin ring, keeping the
+// position of the next client to serve
+func (l *fairLimiter) dropClient(i int) {
+	delete(l.queues, l.clients[i])
+	l.clients = append(l.clients[:i], l.clients[i+1:]...)
+	if i < l.next {
+		l.next--
+	}
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestFairLimiter_roundRobin(t *testing.T) {
+	l := newFairLimiter(1)
+	if err := l.Acquire(context.Background(), "holder"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	served := make(chan string, 10)
+	enqueue := func(client string, waiting int) {
+		go func() {
+			if err := l.Acquire(context.Background(), client); err != nil {
+				t.Error(err)
+				return
+			}
+			served <- client
+		}()
+		waitForWaiters(t, l, waiting)
+	}
+
+	// the noisy client enqueues all its requests before the quiet one
+	for i := 1; i <= 5; i++ {
+		enqueue("noisy", i)
+	}
+	enqueue("quiet", 6)
+
+	order := []string{}
+	for i := 0; i < 6; i++ {
+		l.Release()
+		select {
+		case c := <-served:
+			order = append(order, c)
+		case <-time.After(time.Second):
+			t.Errorf("timeout waiting for the request #%d", i)
+			return
+		}
+	}
+
+	if order[0] != "noisy" || order[1] != "quiet" {
+		t.Errorf("the quiet client was starved: %v", order)
+	}
+	for _, c := range order[2:] {
+		if c != "noisy" {
+			t.Errorf("unexpected order: %v", order)
+		}
+	}
+
+	l.Release()
+	if l.free != 1 {
+		t.Errorf("unexpected number of free slots: %d", l.free)
+	}
+}
+
+func TestFairLimiter_cancel(t *testing.T) {
+	l := newFairLimiter(1)
+	l.Acquire(context.Background(), "a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx, "b"); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	l.mu.Lock()
+	if len(l.clients) != 0 || len(l.queues) != 0 {
+		t.Errorf("the canceled request is still queued: %v", l.clients)
+	}
+	l.mu.Unlock()
+
+	l.Release()
+	if err := l.Acquire(context.Background(), "c"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewConcurrencyLimiterMiddleware(t *testing.T) {
+	mw := NewConcurrencyLimiterMiddleware(logging.NoOp, &config.EndpointConfig{
+		Endpoint: "/foo",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"concurrency_limit": map[string]interface{}{
+					"max":    2.0,
+					"client": "header:x-client-id",
+				},
+			},
+		},
+	})
+
+	mu := new(sync.Mutex)
+	current, max := 0, 0
+	perClient := map[string]int{}
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		mu.Lock()
+		current++
+		if current > max {
+			max = current
+		}
+		perClient[req.Headers["X-Client-Id"][0]]++
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+		return &Response{IsComplete: true}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+	defer cancel()
+
+	wg := new(sync.WaitGroup)
+	for _, c := range []struct {
+		client string
+		calls  int
+	}{
+		{client: "noisy", calls: 100},
+		{client: "quiet", calls: 5},
+	} {
+		for i := 0; i < c.calls; i++ {
+			wg.Add(1)
+			go func(client string) {
+				defer wg.Done()
+				p(ctx, &Request{Headers: map[string][]string{"X-Client-Id": {client}}})
+			}(c.client)
+		}
+	}
+	wg.Wait()
+
+	if max > 2 {
+		t.Errorf("too many concurrent requests: %d", max)
+	}
+	if perClient["quiet"] != 5 {
+		t.Errorf("the quiet client was starved: %v", perClient)
+	}
+}
+
+func waitForWaiters(t *testing.T, l *fairLimiter, expected int) {
+	for i := 0; i < 100; i++ {
+		l.mu.Lock()
+		total := 0
+		for _, q := range l.queues {
+			total += len(q)
+		}
+		l.mu.Unlock()
+		if total == expected {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("timeout waiting for %d queued requests", expected)
+}
+
+func TestNewConcurrencyLimiterMiddleware_invalid(t *testing.T) {
+	for i, limit := range []map[string]interface{}{
+		{"max": 0.0},
+		{"max": "10"},
+		{"client": "header:X-Client"},
+		{"max": 2.0, "client": "cookie:session"},
+	} {
+		calls := 0
+		p := NewConcurrencyLimiterMiddleware(logging.NoOp, &config.EndpointConfig{
+			Endpoint: "/foo",
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{concurrencyLimitKey: limit},
+			},
+		})(func(_ context.Context, _ *Request) (*Response, error) {
+			calls++
+			return &Response{IsComplete: true}, nil
+		})
+
+		resp, err := p(context.Background(), &Request{})
+		if resp != nil {
+			t.Errorf("#%d: unexpected response: %+v", i, resp)
+		}
+		cErr, ok := err.(ConcurrencyLimitConfigError)
+		if !ok {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if cErr.StatusCode() != http.StatusInternalServerError || cErr.Endpoint != "/foo" {
+			t.Errorf("#%d: unexpected error: %+v", i, cErr)
+		}
+		if calls != 0 {
+			t.Errorf("#%d: the backend should not be called", i)
+		}
+	}
+}
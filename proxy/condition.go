@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const conditionKey = "condition"
+
+// Condition evaluates a predicate over the request data
+type Condition func(*Request) bool
+
+// NewCondition compiles the received expression into a Condition. The expression is a set of
+// comparisons over request attributes (see NewAttributeExtractor) joined by '&&' and '||',
+// with the usual precedence ('&&' binds tighter):
+//
+//	header:X-User-Tier == premium
+//	query:region != 'eu' && param:id
+//	header:X-Beta == true || query:beta == true
+//
+// A term without operator holds if the attribute is present and it is not empty nor 'false'.
+// The values can be quoted with single or double quotes.
+func NewCondition(expr string) (Condition, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("empty condition")
+	}
+
+	var alternatives [][]Condition
+	for _, alternative := range strings.Split(expr, "||") {
+		var terms []Condition
+		for _, term := range strings.Split(alternative, "&&") {
+			c, err := newConditionTerm(strings.TrimSpace(term))
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition '%s': %s", expr, err.Error())
+			}
+			terms = append(terms, c)
+		}
+		alternatives = append(alternatives, terms)
+	}
+
+	return func(r *Request) bool {
+		for _, terms := range alternatives {
+			holds := true
+			for _, term := range terms {
+				if !term(r) {
+					holds = false
+					break
+				}
+			}
+			if holds {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func newConditionTerm(term string) (Condition, error) {
+	if term == "" {
+		return nil, fmt.Errorf("empty term")
+	}
+
+	for _, op := range []string{"!=", "=="} {
+		idx := strings.Index(term, op)
+		if idx < 0 {
+			continue
+		}
+		extractor, err := NewAttributeExtractor(strings.TrimSpace(term[:idx]))
+		if err != nil {
+			return nil, err
+		}
+		expected := unquoteConditionValue(strings.TrimSpace(term[idx+len(op):]))
+		if op == "==" {
+			return func(r *Request) bool {
+				v, ok := extractor(r)
+				return ok && v == expected
+			}, nil
+		}
+		return func(r *Request) bool {
+			v, ok := extractor(r)
+			return !ok || v != expected
+		}, nil
+	}
+
+	extractor, err := NewAttributeExtractor(term)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *Request) bool {
+		v, ok := extractor(r)
+		return ok && v != "" && v != "false"
+	}, nil
+}
+
+func unquoteConditionValue(v string) string {
+	if len(v) > 1 && (v[0] == '\'' || v[0] == '"') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// NewConditionalMiddleware creates a proxy middleware skipping the backend when the condition
+// defined in its extra config does not hold for the request. The skipped backends return an
+// empty and complete response, so they contribute nothing to the merged response. A backend
+// with an invalid condition is always skipped.
+func NewConditionalMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	expr, ok := getConditionExpression(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Condition]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	condition, err := NewCondition(expr)
+	if err != nil {
+		logger.Error(logPrefix, err.Error(), "The backend will never be called")
+		condition = func(_ *Request) bool { return false }
+	} else {
+		logger.Debug(fmt.Sprintf("%s Calling the backend only if '%s'", logPrefix, expr))
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewConditionalMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if !condition(request) {
+				logger.Debug(logPrefix, "Skipping the backend")
+				return &Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+			}
+			return next[0](ctx, request)
+		}
+	}
+}
+
+func getConditionExpression(extra config.ExtraConfig) (string, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	expr, ok := e[conditionKey].(string)
+	return expr, ok && expr != ""
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewCondition(t *testing.T) {
+	request := &Request{
+		Headers: map[string][]string{"X-User-Tier": {"premium"}, "X-Beta": {"false"}},
+		Params:  map[string]string{"Id": "42"},
+		Query:   map[string][]string{"region": {"eu"}},
+	}
+
+	for _, tc := range []struct {
+		expr     string
+		expected bool
+	}{
+		{expr: "header:X-User-Tier == premium", expected: true},
+		{expr: "header:x-user-tier == 'premium'", expected: true},
+		{expr: "header:X-User-Tier == free", expected: false},
+		{expr: "header:X-User-Tier != free", expected: true},
+		{expr: "header:X-Unknown != free", expected: true},
+		{expr: "header:X-Unknown == \"\"", expected: false},
+		{expr: "param:id", expected: true},
+		{expr: "header:X-Beta", expected: false},
+		{expr: "query:missing", expected: false},
+		{expr: "param:id && query:region == eu", expected: true},
+		{expr: "param:id && query:region != eu", expected: false},
+		{expr: "header:X-Beta || query:region == eu", expected: true},
+		{expr: "header:X-Beta || query:region == us && param:id", expected: false},
+	} {
+		c, err := NewCondition(tc.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.expr, err.Error())
+			continue
+		}
+		if res := c(request); res != tc.expected {
+			t.Errorf("%s: unexpected result. have: %v, want: %v", tc.expr, res, tc.expected)
+		}
+	}
+}
+
+func TestNewCondition_ko(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"header:X-User-Tier == premium &&",
+		"cookie:session",
+		"premium == header:X-User-Tier",
+	} {
+		if _, err := NewCondition(expr); err == nil {
+			t.Errorf("%s: expecting an error", expr)
+		}
+	}
+}
+
+func TestNewConditionalMiddleware_merge(t *testing.T) {
+	var premiumCalls uint64
+	backendFactory := func(remote *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			if remote.URLPattern == "/premium" {
+				atomic.AddUint64(&premiumCalls, 1)
+				return &Response{IsComplete: true, Data: map[string]interface{}{"offers": []interface{}{"a"}}}, nil
+			}
+			return &Response{IsComplete: true, Data: map[string]interface{}{"name": "foo"}}, nil
+		}
+	}
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/user",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{URLPattern: "/user", Host: []string{"http://example.com"}},
+			{
+				URLPattern: "/premium",
+				Host:       []string{"http://example.com"},
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{
+						"condition": "header:X-User-Tier == premium",
+					},
+				},
+			},
+		},
+	}
+
+	p, err := NewDefaultFactory(backendFactory, logging.NoOp).New(endpoint)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	resp, err := p(context.Background(), &Request{
+		Path:    "/user",
+		Headers: map[string][]string{"X-User-Tier": {"free"}},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if atomic.LoadUint64(&premiumCalls) != 0 {
+		t.Error("the premium backend should be skipped")
+	}
+	if !resp.IsComplete || len(resp.Data) != 1 || resp.Data["name"] != "foo" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	resp, err = p(context.Background(), &Request{
+		Path:    "/user",
+		Headers: map[string][]string{"X-User-Tier": {"premium"}},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if atomic.LoadUint64(&premiumCalls) != 1 {
+		t.Error("the premium backend should be called")
+	}
+	if !resp.IsComplete || len(resp.Data) != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestNewConditionalMiddleware_invalid(t *testing.T) {
+	mw := NewConditionalMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"condition": "cookie:session"},
+		},
+	})
+	calls := 0
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{IsComplete: true, Data: map[string]interface{}{"secret": true}}, nil
+	})
+	resp, err := p(context.Background(), &Request{Headers: map[string][]string{"Cookie": {"session=1"}}})
+	if err != nil || calls != 0 {
+		t.Errorf("the backends with an invalid condition should be skipped. err: %v, calls: %d", err, calls)
+		return
+	}
+	if !resp.IsComplete || len(resp.Data) != 0 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	expectedContentTypeKey = "expected_content_type"
+
+	contentTypeErrorPolicy    = "error"
+	contentTypeTolerantPolicy = "tolerant"
+
+	defaultContentTypePreviewSize = 128
+)
+
+// UnexpectedContentTypeError is the error returned when the Content-Type of the backend
+// response does not match the expected ones
+type UnexpectedContentTypeError struct {
+	ContentType string
+	Expected    []string
+	// Preview contains the first bytes of the response body. It is meant to be logged and
+	// it is not included in the error message, so it never reaches the clients.
+	Preview []byte
+}
+
+// Error returns a string representation of the UnexpectedContentTypeError
+func (u UnexpectedContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type '%s' (expected: %s)", u.ContentType, strings.Join(u.Expected, ", "))
+}
+
+// StatusCode returns the status code to send to the client
+func (UnexpectedContentTypeError) StatusCode() int {
+	return http.StatusBadGateway
+}
+
+// NewContentTypeCheckingParser wraps the received HTTPResponseParser, checking the
+// Content-Type of the responses against the expected types configured for the backend.
+// The expected types accept wildcards (see encoding.MatchContentType).
+//
+// The responses with unexpected types are short-circuited with an UnexpectedContentTypeError
+// or, if the 'tolerant' policy is configured, decoded with the encoding.SafeJSONDecoder, so
+// the mislabeled JSON bodies are still accepted. The previews of the rejected bodies are
+// logged by the middleware returned by NewContentTypeLoggerMiddleware.
+func NewContentTypeCheckingParser(remote *config.Backend, rp HTTPResponseParser) HTTPResponseParser {
+	cfg, ok := getExpectedContentTypeConfig(remote.ExtraConfig)
+	if !ok {
+		return rp
+	}
+
+	tolerant := DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{
+		Decoder:         encoding.SafeJSONDecoder,
+		EntityFormatter: NewEntityFormatter(remote),
+	})
+
+	return func(ctx context.Context, resp *http.Response) (*Response, error) {
+		contentType := resp.Header.Get("Content-Type")
+		if encoding.MatchAnyContentType(cfg.Types, contentType) {
+			return rp(ctx, resp)
+		}
+
+		if cfg.Policy == contentTypeTolerantPolicy {
+			return tolerant(ctx, resp)
+		}
+
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, int64(cfg.PreviewSize)))
+		resp.Body.Close()
+		return nil, UnexpectedContentTypeError{
+			ContentType: contentType,
+			Expected:    cfg.Types,
+			Preview:     preview,
+		}
+	}
+}
+
+// NewContentTypeLoggerMiddleware creates a proxy middleware logging the body previews of the
+// UnexpectedContentTypeErrors returned by the backends with a configured content type
+// expectation, since they are never sent to the clients
+func NewContentTypeLoggerMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	if _, ok := getExpectedContentTypeConfig(remote.ExtraConfig); !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][ContentType]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewContentTypeLoggerMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			var ctErr UnexpectedContentTypeError
+			if errors.As(err, &ctErr) {
+				logger.Warning(logPrefix, fmt.Sprintf("%s. Body preview: %q", ctErr.Error(), ctErr.Preview))
+			}
+			return resp, err
+		}
+	}
+}
+
+type expectedContentTypeConfig struct {
+	Types       []string
+	Policy      string
+	PreviewSize int
+}
+
+func getExpectedContentTypeConfig(extra config.ExtraConfig) (expectedContentTypeConfig, bool) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return expectedContentTypeConfig{}, ok
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return expectedContentTypeConfig{}, ok
+	}
+	tmp, ok := e[expectedContentTypeKey].(map[string]interface{})
+	if !ok {
+		return expectedContentTypeConfig{}, ok
+	}
+
+	cfg := expectedContentTypeConfig{
+		Policy:      contentTypeErrorPolicy,
+		PreviewSize: defaultContentTypePreviewSize,
+	}
+	types, _ := tmp["types"].([]interface{})
+	for _, t := range types {
+		if s, ok := t.(string); ok && s != "" {
+			cfg.Types = append(cfg.Types, s)
+		}
+	}
+	if len(cfg.Types) == 0 {
+		return expectedContentTypeConfig{}, false
+	}
+	if policy, ok := tmp["on_mismatch"].(string); ok && policy == contentTypeTolerantPolicy {
+		cfg.Policy = policy
+	}
+	if size, ok := tmp["preview_size"].(float64); ok && size >= 0 {
+		cfg.PreviewSize = int(size)
+	}
+	return cfg, true
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewContentTypeCheckingParser(t *testing.T) {
+	htmlBody := "<html><body>" + strings.Repeat("please log in ", 20) + "</body></html>"
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"foo":"bar"}`))
+		case "/hal":
+			w.Header().Set("Content-Type", "application/hal+json")
+			w.Write([]byte(`{"foo":"hal"}`))
+		case "/mislabeled":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(`{"foo":"plain"}`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(htmlBody))
+		}
+	}))
+	defer backendServer.Close()
+
+	newBackend := func(policy string) *config.Backend {
+		return &config.Backend{
+			Decoder: encoding.JSONDecoder,
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{
+					"expected_content_type": map[string]interface{}{
+						"types":        []interface{}{"application/json", "application/*+json"},
+						"on_mismatch":  policy,
+						"preview_size": 12.0,
+					},
+				},
+			},
+		}
+	}
+	call := func(backend *config.Backend, path string) (*Response, error) {
+		u, _ := url.Parse(backendServer.URL + path)
+		return HTTPProxyFactory(http.DefaultClient)(backend)(context.Background(), &Request{
+			Method:  "GET",
+			Path:    path,
+			URL:     u,
+			Headers: map[string][]string{},
+		})
+	}
+
+	for path, expected := range map[string]string{"/json": "bar", "/hal": "hal"} {
+		resp, err := call(newBackend("error"), path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", path, err.Error())
+			continue
+		}
+		if v := resp.Data["foo"]; v != expected {
+			t.Errorf("%s: unexpected response: %v", path, resp.Data)
+		}
+	}
+
+	_, err := call(newBackend("error"), "/login")
+	ctErr, ok := err.(UnexpectedContentTypeError)
+	if !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if ctErr.ContentType != "text/html" {
+		t.Errorf("unexpected content type: %s", ctErr.ContentType)
+	}
+	if string(ctErr.Preview) != "<html><body>" {
+		t.Errorf("unexpected preview: %s", string(ctErr.Preview))
+	}
+	if ctErr.StatusCode() != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", ctErr.StatusCode())
+	}
+	if strings.Contains(ctErr.Error(), "please log in") {
+		t.Errorf("the error message should not contain the body: %s", ctErr.Error())
+	}
+
+	resp, err := call(newBackend("tolerant"), "/mislabeled")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if v := resp.Data["foo"]; v != "plain" {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+
+	if _, err := call(newBackend("tolerant"), "/login"); err == nil {
+		t.Error("the html bodies should not be decoded in tolerant mode")
+	}
+}
+
+func TestNewContentTypeLoggerMiddleware(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger, _ := logging.NewLogger("WARNING", buf, "")
+	backend := &config.Backend{
+		URLPattern: "/login",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"expected_content_type": map[string]interface{}{"types": []interface{}{"application/json"}},
+			},
+		},
+	}
+	p := NewContentTypeLoggerMiddleware(logger, backend)(func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, UnexpectedContentTypeError{ContentType: "text/html", Expected: []string{"application/json"}, Preview: []byte("<html>")}
+	})
+	if _, err := p(context.Background(), &Request{}); err == nil {
+		t.Error("expecting an error")
+	}
+	if out := buf.String(); !strings.Contains(out, "WARNING") || !strings.Contains(out, `Body preview: "<html>"`) {
+		t.Errorf("unexpected log: %s", out)
+	}
+}
+
+func TestNewContentTypeCheckingParser_noConfig(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`{"foo":"bar"}`))
+	}))
+	defer backendServer.Close()
+
+	u, _ := url.Parse(backendServer.URL)
+	resp, err := HTTPProxyFactory(http.DefaultClient)(&config.Backend{Decoder: encoding.JSONDecoder})(context.Background(), &Request{
+		Method:  "GET",
+		URL:     u,
+		Headers: map[string][]string{},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if v := resp.Data["foo"]; v != "bar" {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const dependsOnKey = "depends_on"
+
+func init() {
+	config.RegisterEndpointValidator(ValidateDependencyGraph)
+}
+
+// DependencyCycleError is the error returned by the configuration init process when the
+// dependencies declared by the backends of an endpoint contain a cycle
+type DependencyCycleError struct {
+	Endpoint string
+	Method   string
+	Backends []int
+}
+
+// Error returns a string representation of the DependencyCycleError
+func (d DependencyCycleError) Error() string {
+	return fmt.Sprintf("the backends %v of the endpoint %s %s have cyclic dependencies", d.Backends, d.Method, d.Endpoint)
+}
+
+// DependencyError is the error returned for the backends not executed because one of their
+// dependencies failed or returned an incomplete response
+type DependencyError struct {
+	Backend    int
+	Dependency int
+}
+
+// Error returns a string representation of the DependencyError
+func (d DependencyError) Error() string {
+	return fmt.Sprintf("backend %d not executed: its dependency %d failed", d.Backend, d.Dependency)
+}
+
+// ValidateDependencyGraph checks that the dependencies declared by the backends of the
+// endpoint (with the 'depends_on' list of backend indexes) reference existing backends and
+// do not contain cycles
+func ValidateDependencyGraph(cfg *config.EndpointConfig) error {
+	deps, ok, err := getBackendDependencies(cfg)
+	if err != nil || !ok {
+		return err
+	}
+	if _, err := topologicalOrder(deps); err != nil {
+		if cycle, ok := err.(DependencyCycleError); ok {
+			cycle.Endpoint = cfg.Endpoint
+			cycle.Method = cfg.Method
+			return cycle
+		}
+		return err
+	}
+	return nil
+}
+
+// getBackendDependencies returns the indexes of the backends every backend of the endpoint
+// depends on. A backend depends on the backends listed at its 'depends_on' key and on the
+// ones referenced by the {{.RespN_field}} placeholders of its URL pattern. The flag is false
+// if no backend declares its dependencies.
+func getBackendDependencies(cfg *config.EndpointConfig) ([][]int, bool, error) {
+	declared := false
+	deps := make([][]int, len(cfg.Backend))
+	for i, b := range cfg.Backend {
+		e, ok := b.ExtraConfig[Namespace].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		vs, ok := e[dependsOnKey].([]interface{})
+		if !ok {
+			continue
+		}
+		declared = true
+		for _, v := range vs {
+			idx, ok := v.(float64)
+			if !ok || idx != float64(int(idx)) {
+				return nil, false, fmt.Errorf("invalid dependency %v for the backend %d of the endpoint %s", v, i, cfg.Endpoint)
+			}
+			deps[i] = appendDependency(deps[i], int(idx))
+		}
+	}
+	if !declared {
+		return nil, false, nil
+	}
+
+	for i, b := range cfg.Backend {
+		for _, match := range reMergeKey.FindAllStringSubmatch(b.URLPattern, -1) {
+			if idx, err := strconv.Atoi(match[1]); err == nil {
+				deps[i] = appendDependency(deps[i], idx)
+			}
+		}
+		for _, d := range deps[i] {
+			if d < 0 || d >= len(cfg.Backend) || d == i {
+				return nil, false, fmt.Errorf("invalid dependency %d for the backend %d of the endpoint %s", d, i, cfg.Endpoint)
+			}
+		}
+	}
+	return deps, true, nil
+}
+
+func appendDependency(deps []int, d int) []int {
+	for _, v := range deps {
+		if v == d {
+			return deps
+		}
+	}
+	return append(deps, d)
+}
+
+// topologicalOrder returns the indexes of the backends sorted so every backend is placed
+// after its dependencies
+func topologicalOrder(deps [][]int) ([]int, error) {
+	pending := make([]int, len(deps))
+	dependants := make([][]int, len(deps))
+	for i, ds := range deps {
+		pending[i] = len(ds)
+		for _, d := range ds {
+			dependants[d] = append(dependants[d], i)
+		}
+	}
+
+	order := make([]int, 0, len(deps))
+	for i := range deps {
+		if pending[i] == 0 {
+			order = append(order, i)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for _, d := range dependants[order[i]] {
+			pending[d]--
+			if pending[d] == 0 {
+				order = append(order, d)
+			}
+		}
+	}
+
+	if len(order) != len(deps) {
+		var cycle []int
+		for i, p := range pending {
+			if p > 0 {
+				cycle = append(cycle, i)
+			}
+		}
+		return nil, DependencyCycleError{Backends: cycle}
+	}
+	return order, nil
+}
+
+type dependencyGraphResult struct {
+	idx  int
+	resp *Response
+	err  error
+}
+
+// dependencyGraphMerge executes every backend as soon as all its dependencies have returned a
+// complete response, so the independent backends run in parallel. The values of the responses
+// of the dependencies are available for the URL pattern of the backend as {{.RespN_field}}
+// placeholders. The backends depending on a failed or incomplete response are not executed.
+func dependencyGraphMerge(reqCloner func(*Request) *Request, patterns []string, deps [][]int, timeout time.Duration, rc ResponseCombiner, next ...Proxy) Proxy {
+	dependants := make([][]int, len(deps))
+	for i, ds := range deps {
+		for _, d := range ds {
+			dependants[d] = append(dependants[d], i)
+		}
+	}
+
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		localCtx, cancel := context.WithTimeout(ctx, timeout)
+
+		total := len(next)
+		parts := make([]*Response, total)
+		pending := make([]int, total)
+		skipped := make([]bool, total)
+		results := make(chan dependencyGraphResult, total)
+
+		start := func(i int) {
+			r := reqCloner(request)
+			r.Params = CloneRequestParams(r.Params)
+			setResponseParams(r.Params, patterns[i], parts, total)
+			go func() {
+				resp, err := next[i](localCtx, r)
+				results <- dependencyGraphResult{idx: i, resp: resp, err: err}
+			}()
+		}
+
+		for i := range next {
+			pending[i] = len(deps[i])
+			if pending[i] == 0 {
+				start(i)
+			}
+		}
+
+		acc := newIncrementalMergeAccumulator(total, rc)
+		remaining := total
+
+		var skip func(i, failed int)
+		skip = func(i, failed int) {
+			for _, d := range dependants[i] {
+				if skipped[d] {
+					continue
+				}
+				skipped[d] = true
+				remaining--
+				acc.Merge(nil, DependencyError{Backend: d, Dependency: failed})
+				skip(d, d)
+			}
+		}
+
+		for remaining > 0 {
+			var res dependencyGraphResult
+			select {
+			case res = <-results:
+			case <-localCtx.Done():
+				res = dependencyGraphResult{idx: -1, err: localCtx.Err()}
+			}
+			if res.idx < 0 {
+				for ; remaining > 0; remaining-- {
+					acc.Merge(nil, res.err)
+				}
+				break
+			}
+			remaining--
+
+			if res.err == nil && res.resp == nil {
+				res.err = errNullResult
+			}
+			if res.err != nil {
+				acc.Merge(nil, res.err)
+				skip(res.idx, res.idx)
+				continue
+			}
+			if !res.resp.IsComplete {
+				acc.Merge(res.resp, nil)
+				skip(res.idx, res.idx)
+				continue
+			}
+			// the combiner can merge other responses into this one, so the dependants get
+			// a snapshot of its top level fields
+			data := make(map[string]interface{}, len(res.resp.Data))
+			for k, v := range res.resp.Data {
+				data[k] = v
+			}
+			parts[res.idx] = &Response{Data: data, IsComplete: true}
+			acc.Merge(res.resp, nil)
+
+			for _, d := range dependants[res.idx] {
+				pending[d]--
+				if pending[d] == 0 && !skipped[d] {
+					start(d)
+				}
+			}
+		}
+
+		result, err := acc.Result()
+		cancel()
+		return result, err
+	}
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func diamondEndpoint() *config.EndpointConfig {
+	dependsOn := func(deps ...interface{}) config.ExtraConfig {
+		return config.ExtraConfig{Namespace: map[string]interface{}{"depends_on": deps}}
+	}
+	return &config.EndpointConfig{
+		Endpoint: "/user/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{URLPattern: "/users/{{.Id}}"},
+			{URLPattern: "/orders/{{.Resp0_id}}", ExtraConfig: dependsOn(0.0)},
+			{URLPattern: "/levels/{{.Resp0_tier}}", ExtraConfig: dependsOn(0.0)},
+			{URLPattern: "/summary/{{.Resp1_total}}/{{.Resp2_level}}", ExtraConfig: dependsOn(1.0)},
+		},
+	}
+}
+
+func TestNewMergeDataMiddleware_dependencyGraph(t *testing.T) {
+	// the backends 1 and 2 depend on the 0 and the backend 3 depends on both of them
+	endpoint := diamondEndpoint()
+
+	barrier := &sync.WaitGroup{}
+	barrier.Add(2)
+	waitForSibling := func() {
+		barrier.Done()
+		done := make(chan struct{})
+		go func() {
+			barrier.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(500 * time.Millisecond):
+			t.Error("the independent backends should be executed in parallel")
+		}
+	}
+
+	mu := &sync.Mutex{}
+	var calls []int
+	var summaryParams map[string]string
+	record := func(i int) {
+		mu.Lock()
+		calls = append(calls, i)
+		mu.Unlock()
+	}
+
+	p := NewMergeDataMiddleware(logging.NoOp, endpoint)(
+		func(_ context.Context, r *Request) (*Response, error) {
+			record(0)
+			if r.Params["Id"] != "42" {
+				t.Errorf("unexpected params: %v", r.Params)
+			}
+			return &Response{IsComplete: true, Data: map[string]interface{}{"id": "u42", "tier": "gold"}}, nil
+		},
+		func(_ context.Context, r *Request) (*Response, error) {
+			record(1)
+			if r.Params["Resp0_id"] != "u42" {
+				t.Errorf("unexpected params: %v", r.Params)
+			}
+			waitForSibling()
+			return &Response{IsComplete: true, Data: map[string]interface{}{"total": "3"}}, nil
+		},
+		func(_ context.Context, r *Request) (*Response, error) {
+			record(2)
+			if r.Params["Resp0_tier"] != "gold" {
+				t.Errorf("unexpected params: %v", r.Params)
+			}
+			waitForSibling()
+			return &Response{IsComplete: true, Data: map[string]interface{}{"level": "7"}}, nil
+		},
+		func(_ context.Context, r *Request) (*Response, error) {
+			record(3)
+			mu.Lock()
+			summaryParams = r.Params
+			mu.Unlock()
+			return &Response{IsComplete: true, Data: map[string]interface{}{"summary": true}}, nil
+		},
+	)
+
+	request := &Request{Params: map[string]string{"Id": "42"}}
+	resp, err := p(context.Background(), request)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if !resp.IsComplete || len(resp.Data) != 5 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	if len(calls) != 4 || calls[0] != 0 || calls[3] != 3 {
+		t.Errorf("unexpected execution order: %v", calls)
+	}
+	if summaryParams["Resp1_total"] != "3" || summaryParams["Resp2_level"] != "7" {
+		t.Errorf("the outputs of the dependencies should be injected: %v", summaryParams)
+	}
+	if len(request.Params) != 1 {
+		t.Errorf("the params of the received request should not be modified: %v", request.Params)
+	}
+}
+
+func TestNewMergeDataMiddleware_dependencyGraphFailure(t *testing.T) {
+	endpoint := diamondEndpoint()
+	errBackend := errors.New("orders not available")
+
+	summaryCalled := false
+	p := NewMergeDataMiddleware(logging.NoOp, endpoint)(
+		dummyProxy(&Response{IsComplete: true, Data: map[string]interface{}{"id": "u42", "tier": "gold"}}),
+		func(_ context.Context, _ *Request) (*Response, error) {
+			return nil, errBackend
+		},
+		dummyProxy(&Response{IsComplete: true, Data: map[string]interface{}{"level": "7"}}),
+		func(_ context.Context, _ *Request) (*Response, error) {
+			summaryCalled = true
+			return &Response{IsComplete: true, Data: map[string]interface{}{"summary": true}}, nil
+		},
+	)
+
+	resp, err := p(context.Background(), &Request{Params: map[string]string{"Id": "42"}})
+	if summaryCalled {
+		t.Error("the backends depending on a failed one should not be executed")
+	}
+	mErr, ok := err.(mergeError)
+	if !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if len(mErr.errs) != 2 || mErr.errs[0] != errBackend {
+		t.Errorf("unexpected errors: %v", mErr.errs)
+		return
+	}
+	if depErr, ok := mErr.errs[1].(DependencyError); !ok || depErr.Backend != 3 || depErr.Dependency != 1 {
+		t.Errorf("unexpected error: %v", mErr.errs[1])
+	}
+	if resp == nil || resp.IsComplete || len(resp.Data) != 3 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestValidateDependencyGraph(t *testing.T) {
+	dependsOn := func(deps ...interface{}) config.ExtraConfig {
+		return config.ExtraConfig{Namespace: map[string]interface{}{"depends_on": deps}}
+	}
+
+	if err := ValidateDependencyGraph(diamondEndpoint()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateDependencyGraph(&config.EndpointConfig{Backend: []*config.Backend{{}, {}}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		backends []*config.Backend
+	}{
+		{
+			name:     "self",
+			backends: []*config.Backend{{ExtraConfig: dependsOn(0.0)}, {}},
+		},
+		{
+			name:     "out of range",
+			backends: []*config.Backend{{}, {ExtraConfig: dependsOn(2.0)}},
+		},
+		{
+			name:     "invalid",
+			backends: []*config.Backend{{}, {ExtraConfig: dependsOn("0")}},
+		},
+	} {
+		if err := ValidateDependencyGraph(&config.EndpointConfig{Backend: tc.backends}); err == nil {
+			t.Errorf("%s: expecting an error", tc.name)
+		}
+	}
+}
+
+func TestValidateDependencyGraph_cycle(t *testing.T) {
+	subject := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/cycle",
+				Method:   "GET",
+				Backend: []*config.Backend{
+					{URLPattern: "/a"},
+					{
+						URLPattern: "/b/{resp2_id}",
+						ExtraConfig: config.ExtraConfig{
+							Namespace: map[string]interface{}{"depends_on": []interface{}{0.0}},
+						},
+					},
+					{
+						URLPattern: "/c/{resp1_id}",
+						ExtraConfig: config.ExtraConfig{
+							Namespace: map[string]interface{}{"depends_on": []interface{}{0.0}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := subject.Init()
+	cycleErr, ok := err.(DependencyCycleError)
+	if !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if cycleErr.Endpoint != "/cycle" || len(cycleErr.Backends) != 2 || cycleErr.Backends[0] != 1 || cycleErr.Backends[1] != 2 {
+		t.Errorf("unexpected error: %+v", cycleErr)
+	}
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const dynamicHostKey = "dynamic_host"
+
+// DisallowedHostError is the error returned when the host generated from the request data
+// is not included in the allowlist of the backend
+type DisallowedHostError struct {
+	Host string
+}
+
+// Error returns a string representation of the DisallowedHostError
+func (d DisallowedHostError) Error() string {
+	return fmt.Sprintf("the host '%s' is not allowed", d.Host)
+}
+
+// StatusCode returns the status code to send to the client
+func (DisallowedHostError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// NewDynamicHostMiddleware creates a proxy middleware replacing the host selected by the load
+// balancer with a host generated from the request data. The template of the host contains
+// request attributes between curly braces (see NewAttributeExtractor), like
+//
+//	https://{header:X-Tenant}.internal:8080
+//
+// The generated host must be included in the allowlist of the backend, so the clients can
+// not route the requests to arbitrary upstreams.
+func NewDynamicHostMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	tmpl, ok, err := getDynamicHostConfig(remote.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[BACKEND: %s %s -> %s][DynamicHost] %s",
+			remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, err.Error()))
+		return emptyMiddlewareFallback(logger)
+	}
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[BACKEND: %s %s -> %s][DynamicHost] Generating the host from '%s' (%d allowed hosts)",
+			remote.ParentEndpointMethod,
+			remote.ParentEndpoint,
+			remote.URLPattern,
+			tmpl.Pattern,
+			len(tmpl.Allowed),
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewDynamicHostMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			scheme, host, err := tmpl.Execute(request)
+			if err != nil {
+				return nil, err
+			}
+			if request.URL == nil {
+				request.URL = &url.URL{Path: request.Path}
+			}
+			u := *request.URL
+			if scheme != "" {
+				u.Scheme = scheme
+			}
+			u.Host = host
+			request.URL = &u
+			return next[0](ctx, request)
+		}
+	}
+}
+
+type dynamicHost struct {
+	Pattern string
+	Scheme  string
+	Allowed map[string]struct{}
+	parts   []string
+	sources []AttributeExtractor
+}
+
+// Execute returns the scheme and the host generated for the request
+func (d dynamicHost) Execute(r *Request) (string, string, error) {
+	var sb strings.Builder
+	for i, part := range d.parts {
+		sb.WriteString(part)
+		if i >= len(d.sources) {
+			continue
+		}
+		v, ok := d.sources[i](r)
+		if !ok || v == "" || !isHostLabel(v) {
+			return "", "", DisallowedHostError{Host: d.Pattern}
+		}
+		sb.WriteString(v)
+	}
+
+	host := strings.ToLower(sb.String())
+	if _, ok := d.Allowed[host]; !ok {
+		return "", "", DisallowedHostError{Host: host}
+	}
+	return d.Scheme, host, nil
+}
+
+// isHostLabel checks the value just contains the chars allowed in a hostname
+func isHostLabel(v string) bool {
+	for _, c := range v {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func getDynamicHostConfig(extra config.ExtraConfig) (dynamicHost, bool, error) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return dynamicHost{}, false, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return dynamicHost{}, false, nil
+	}
+	tmp, ok := e[dynamicHostKey].(map[string]interface{})
+	if !ok {
+		return dynamicHost{}, false, nil
+	}
+	pattern, ok := tmp["host"].(string)
+	if !ok || pattern == "" {
+		return dynamicHost{}, false, nil
+	}
+
+	d := dynamicHost{
+		Pattern: pattern,
+		Allowed: map[string]struct{}{},
+	}
+
+	hostPattern := pattern
+	if i := strings.Index(pattern, "://"); i >= 0 {
+		d.Scheme = pattern[:i]
+		hostPattern = pattern[i+3:]
+	}
+
+	for {
+		start := strings.Index(hostPattern, "{")
+		if start < 0 {
+			d.parts = append(d.parts, hostPattern)
+			break
+		}
+		end := strings.Index(hostPattern[start:], "}")
+		if end < 0 {
+			return dynamicHost{}, false, fmt.Errorf("unclosed placeholder in the host '%s'", pattern)
+		}
+		extractor, err := NewAttributeExtractor(hostPattern[start+1 : start+end])
+		if err != nil {
+			return dynamicHost{}, false, err
+		}
+		d.parts = append(d.parts, hostPattern[:start])
+		d.sources = append(d.sources, extractor)
+		hostPattern = hostPattern[start+end+1:]
+	}
+
+	allowed, _ := tmp["allowed_hosts"].([]interface{})
+	for _, a := range allowed {
+		h, ok := a.(string)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(h, "://"); i >= 0 {
+			h = h[i+3:]
+		}
+		d.Allowed[strings.ToLower(strings.TrimRight(h, "/"))] = struct{}{}
+	}
+	if len(d.Allowed) == 0 {
+		return dynamicHost{}, false, fmt.Errorf("the host '%s' requires a list of allowed hosts", pattern)
+	}
+	return d, true, nil
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewDynamicHostMiddleware_header(t *testing.T) {
+	mw := NewDynamicHostMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"dynamic_host": map[string]interface{}{
+					"host":          "https://{header:X-Tenant}.internal:8443",
+					"allowed_hosts": []interface{}{"a.internal:8443", "https://B.internal:8443"},
+				},
+			},
+		},
+	})
+
+	var backendURL *url.URL
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		backendURL = req.URL
+		return &Response{IsComplete: true}, nil
+	})
+
+	for _, tc := range []struct {
+		tenant      string
+		expectedURL string
+		expectedErr bool
+	}{
+		{tenant: "a", expectedURL: "https://a.internal:8443/foo?a=1"},
+		{tenant: "B", expectedURL: "https://b.internal:8443/foo?a=1"},
+		{tenant: "c", expectedErr: true},
+		{tenant: "evil.com/a", expectedErr: true},
+		{tenant: "a.internal:8443@evil.com#", expectedErr: true},
+		{tenant: "", expectedErr: true},
+	} {
+		backendURL = nil
+		lbURL, _ := url.Parse("http://default.internal/foo?a=1")
+		_, err := p(context.Background(), &Request{
+			Path:    "/foo",
+			URL:     lbURL,
+			Headers: map[string][]string{"X-Tenant": {tc.tenant}},
+		})
+		if tc.expectedErr {
+			if _, ok := err.(DisallowedHostError); !ok {
+				t.Errorf("%s: unexpected error: %v", tc.tenant, err)
+			}
+			if backendURL != nil {
+				t.Errorf("%s: the backend should not be called", tc.tenant)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.tenant, err.Error())
+			continue
+		}
+		if backendURL.String() != tc.expectedURL {
+			t.Errorf("%s: unexpected url: %s", tc.tenant, backendURL.String())
+		}
+	}
+}
+
+func TestNewDynamicHostMiddleware_param(t *testing.T) {
+	mw := NewDynamicHostMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"dynamic_host": map[string]interface{}{
+					"host":          "{param:region}.{query:tenant}.internal",
+					"allowed_hosts": []interface{}{"eu.a.internal"},
+				},
+			},
+		},
+	})
+
+	p := mw(func(_ context.Context, req *Request) (*Response, error) {
+		if req.URL.String() != "http://eu.a.internal/foo" {
+			t.Errorf("unexpected url: %s", req.URL.String())
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	lbURL, _ := url.Parse("http://default.internal/foo")
+	if _, err := p(context.Background(), &Request{
+		URL:    lbURL,
+		Params: map[string]string{"Region": "eu"},
+		Query:  url.Values{"tenant": {"a"}},
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewDynamicHostMiddleware_invalidConfig(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"host": "https://{header:X-Tenant}.internal"},
+		{"host": "https://{header:X-Tenant.internal", "allowed_hosts": []interface{}{"a.internal"}},
+		{"host": "https://{cookie:tenant}.internal", "allowed_hosts": []interface{}{"a.internal"}},
+	} {
+		mw := NewDynamicHostMiddleware(logging.NoOp, &config.Backend{
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"dynamic_host": cfg}},
+		})
+		lbURL, _ := url.Parse("http://default.internal/foo")
+		p := mw(func(_ context.Context, req *Request) (*Response, error) {
+			if req.URL != lbURL {
+				t.Errorf("%v: the url should not be modified: %s", cfg, req.URL.String())
+			}
+			return &Response{IsComplete: true}, nil
+		})
+		p(context.Background(), &Request{URL: lbURL, Headers: map[string][]string{"X-Tenant": {"a"}}})
+	}
+}
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/feature"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	experimentsKey = "experiments"
+
+	// ExperimentHeaderName is the default name of the response header exposing the variants
+	// assigned to the request, as a list of 'experiment=variant' pairs
+	ExperimentHeaderName = "X-Experiments"
+	// ExperimentAssignmentsCounter is the name of the counter tracking the variants assigned
+	ExperimentAssignmentsCounter = "proxy.experiments.assigned"
+	// ExperimentFeatureFlagPrefix is the prefix of the feature flags acting as kill switches
+	// of the experiments. See ExperimentFeatureFlag.
+	ExperimentFeatureFlagPrefix = "experiment:"
+
+	experimentBuckets = 10000
+)
+
+// ExperimentFeatureFlag returns the name of the feature flag controlling the named
+// experiment. While the flag is disabled, all the requests get the control variant.
+func ExperimentFeatureFlag(name string) string {
+	return ExperimentFeatureFlagPrefix + name
+}
+
+// ExperimentConfig defines an experiment comparing several shapes of the endpoint response
+type ExperimentConfig struct {
+	// Name identifies the experiment in the response header, the events and the feature flag
+	Name string `json:"name"`
+	// Attribute is the spec of the request attribute used for the sticky assignment of the
+	// variants (see NewAttributeExtractor)
+	Attribute string `json:"attribute"`
+	// Header is the name of the response header exposing the variant. Defaults to
+	// ExperimentHeaderName
+	Header string `json:"header"`
+	// Variants are the candidate shapes of the response. The first one is the control
+	// variant: it gets the traffic not assigned to the others, the requests without the
+	// attribute and all of them while the experiment is disabled.
+	Variants []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant is a named formatter configuration receiving a percentage of the traffic
+type ExperimentVariant struct {
+	Name       string            `json:"name"`
+	Percentage float64           `json:"percentage"`
+	AllowList  []string          `json:"allow"`
+	DenyList   []string          `json:"deny"`
+	Mapping    map[string]string `json:"mapping"`
+	Target     string            `json:"target"`
+	Group      string            `json:"group"`
+}
+
+// NewExperimentMiddleware creates a proxy middleware formatting the responses of the endpoint
+// with one of the variants of every experiment defined in its extra config. The variant is
+// selected with a stable hash of the configured request attribute, so the same user always
+// gets the same shape of the response. The assignments are exposed in a response header and
+// recorded with the default events recorder.
+//
+// The experiments can be reverted to their control variant at runtime by disabling their
+// feature flag (see ExperimentFeatureFlag), through the admin endpoints, for instance.
+func NewExperimentMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfgs, err := getExperimentsConfig(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Experiments] %s", endpointConfig.Endpoint, err.Error()))
+		return emptyMiddlewareFallback(logger)
+	}
+	if len(cfgs) == 0 {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	experiments := make([]experiment, len(cfgs))
+	for i, cfg := range cfgs {
+		e, err := newExperiment(endpointConfig.Endpoint, cfg)
+		if err != nil {
+			logger.Error(fmt.Sprintf("[ENDPOINT: %s][Experiments] %s", endpointConfig.Endpoint, err.Error()))
+			return emptyMiddlewareFallback(logger)
+		}
+		experiments[i] = e
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Experiments] Running the experiment '%s' with %d variants",
+			endpointConfig.Endpoint, cfg.Name, len(cfg.Variants)))
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewExperimentMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil {
+				return resp, err
+			}
+
+			recorder := events.DefaultRecorder()
+			for _, e := range experiments {
+				v := e.variants[e.assign(request)]
+				if resp.Data != nil {
+					*resp = v.formatter.Format(*resp)
+				}
+				if resp.Metadata.Headers == nil {
+					resp.Metadata.Headers = map[string][]string{}
+				}
+				resp.Metadata.Headers[e.header] = append(resp.Metadata.Headers[e.header], v.headerValue)
+				recorder.Counter(ExperimentAssignmentsCounter, 1, v.labels)
+			}
+			return resp, err
+		}
+	}
+}
+
+type experiment struct {
+	name      string
+	flag      string
+	header    string
+	extractor AttributeExtractor
+	variants  []experimentVariant
+}
+
+type experimentVariant struct {
+	limit       uint32
+	formatter   EntityFormatter
+	headerValue string
+	labels      map[string]string
+}
+
+func newExperiment(endpoint string, cfg ExperimentConfig) (experiment, error) {
+	if cfg.Name == "" {
+		return experiment{}, fmt.Errorf("the experiments require a name")
+	}
+	if len(cfg.Variants) == 0 {
+		return experiment{}, fmt.Errorf("the experiment '%s' has no variants", cfg.Name)
+	}
+	extractor, err := NewAttributeExtractor(cfg.Attribute)
+	if err != nil {
+		return experiment{}, fmt.Errorf("experiment '%s': %s", cfg.Name, err.Error())
+	}
+
+	e := experiment{
+		name:      cfg.Name,
+		flag:      ExperimentFeatureFlag(cfg.Name),
+		header:    cfg.Header,
+		extractor: extractor,
+		variants:  make([]experimentVariant, len(cfg.Variants)),
+	}
+	if e.header == "" {
+		e.header = ExperimentHeaderName
+	}
+
+	// the control variant takes the buckets not assigned to the rest of variants, so it
+	// is placed at the end of the ranges
+	var assigned float64
+	for i, v := range cfg.Variants {
+		if v.Name == "" {
+			return experiment{}, fmt.Errorf("the variant #%d of the experiment '%s' has no name", i, cfg.Name)
+		}
+		if v.Percentage < 0 {
+			return experiment{}, fmt.Errorf("the variant '%s' of the experiment '%s' has a negative percentage", v.Name, cfg.Name)
+		}
+		if i > 0 {
+			assigned += v.Percentage
+		}
+		e.variants[i] = experimentVariant{
+			limit: uint32(assigned * experimentBuckets / 100),
+			formatter: NewEntityFormatter(&config.Backend{
+				AllowList: v.AllowList,
+				DenyList:  v.DenyList,
+				Mapping:   v.Mapping,
+				Target:    v.Target,
+				Group:     v.Group,
+			}),
+			headerValue: cfg.Name + "=" + v.Name,
+			labels: map[string]string{
+				"endpoint":   endpoint,
+				"experiment": cfg.Name,
+				"variant":    v.Name,
+			},
+		}
+	}
+	if assigned > 100 {
+		return experiment{}, fmt.Errorf("the variants of the experiment '%s' get more than the 100%% of the traffic", cfg.Name)
+	}
+	return e, nil
+}
+
+// assign returns the index of the variant for the request
+func (e experiment) assign(r *Request) int {
+	if !feature.Enabled(e.flag, true) {
+		return 0
+	}
+	v, ok := e.extractor(r)
+	if !ok || v == "" {
+		return 0
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(e.name))
+	h.Write([]byte{0})
+	h.Write([]byte(v))
+	bucket := h.Sum32() % experimentBuckets
+
+	for i := 1; i < len(e.variants); i++ {
+		if bucket < e.variants[i].limit {
+			return i
+		}
+	}
+	return 0
+}
+
+func getExperimentsConfig(extra config.ExtraConfig) ([]ExperimentConfig, error) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	tmp, ok := e[experimentsKey]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []ExperimentConfig
+	if err := json.Unmarshal(b, &cfgs); err != nil {
+		return nil, fmt.Errorf("invalid experiments: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return cfgs, nil
+}
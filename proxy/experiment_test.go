@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/feature"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type experimentRecorder struct {
+	mu       *sync.Mutex
+	counters map[string]int64
+}
+
+func (r *experimentRecorder) Counter(name string, delta int64, labels map[string]string) {
+	r.mu.Lock()
+	r.counters[name+":"+labels["experiment"]+"="+labels["variant"]] += delta
+	r.mu.Unlock()
+}
+
+func (*experimentRecorder) Gauge(_ string, _ int64, _ map[string]string) {}
+
+func experimentEndpoint() *config.EndpointConfig {
+	return &config.EndpointConfig{
+		Endpoint: "/checkout",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				experimentsKey: []interface{}{
+					map[string]interface{}{
+						"name":      "shape",
+						"attribute": "header:X-User-Id",
+						"variants": []interface{}{
+							map[string]interface{}{
+								"name":  "control",
+								"allow": []interface{}{"a", "b"},
+							},
+							map[string]interface{}{
+								"name":       "flat",
+								"percentage": 50,
+								"deny":       []interface{}{"b"},
+								"mapping":    map[string]interface{}{"a": "alpha"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func experimentProxy() Proxy {
+	return func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"a": 1, "b": 2, "c": 3},
+		}, nil
+	}
+}
+
+func experimentRequest(user string) *Request {
+	return &Request{Headers: map[string][]string{"X-User-Id": {user}}}
+}
+
+func TestNewExperimentMiddleware(t *testing.T) {
+	recorder := &experimentRecorder{mu: new(sync.Mutex), counters: map[string]int64{}}
+	events.SetDefaultRecorder(recorder)
+	defer events.SetDefaultRecorder(nil)
+
+	p := NewExperimentMiddleware(logging.NoOp, experimentEndpoint())(experimentProxy())
+
+	assignments := map[string]string{}
+	total := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		resp, err := p(context.Background(), experimentRequest(user))
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		h := resp.Metadata.Headers[ExperimentHeaderName]
+		if len(h) != 1 {
+			t.Errorf("unexpected header: %v", h)
+			return
+		}
+		assignments[user] = h[0]
+		total[h[0]]++
+
+		switch h[0] {
+		case "shape=control":
+			if len(resp.Data) != 2 || resp.Data["a"] != 1 || resp.Data["b"] != 2 {
+				t.Errorf("unexpected control response: %v", resp.Data)
+				return
+			}
+		case "shape=flat":
+			if len(resp.Data) != 2 || resp.Data["alpha"] != 1 || resp.Data["c"] != 3 {
+				t.Errorf("unexpected variant response: %v", resp.Data)
+				return
+			}
+		default:
+			t.Errorf("unexpected variant: %s", h[0])
+			return
+		}
+	}
+
+	if total["shape=control"] < 400 || total["shape=flat"] < 400 {
+		t.Errorf("unexpected distribution: %v", total)
+	}
+	if recorder.counters[ExperimentAssignmentsCounter+":shape=control"] != int64(total["shape=control"]) ||
+		recorder.counters[ExperimentAssignmentsCounter+":shape=flat"] != int64(total["shape=flat"]) {
+		t.Errorf("unexpected events: %v", recorder.counters)
+	}
+
+	// the assignment is sticky
+	for user, variant := range assignments {
+		resp, _ := p(context.Background(), experimentRequest(user))
+		if h := resp.Metadata.Headers[ExperimentHeaderName]; h[0] != variant {
+			t.Errorf("%s: the variant changed from %s to %s", user, variant, h[0])
+			return
+		}
+	}
+
+	// the requests without the attribute get the control variant
+	resp, _ := p(context.Background(), &Request{})
+	if h := resp.Metadata.Headers[ExperimentHeaderName]; h[0] != "shape=control" || len(resp.Data) != 2 {
+		t.Errorf("unexpected response: %v %v", h, resp.Data)
+	}
+}
+
+func TestNewExperimentMiddleware_killSwitch(t *testing.T) {
+	p := NewExperimentMiddleware(logging.NoOp, experimentEndpoint())(experimentProxy())
+
+	var users []string
+	for i := 0; len(users) < 10; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		resp, _ := p(context.Background(), experimentRequest(user))
+		if resp.Metadata.Headers[ExperimentHeaderName][0] == "shape=flat" {
+			users = append(users, user)
+		}
+	}
+
+	feature.Set(ExperimentFeatureFlag("shape"), false)
+	for _, user := range users {
+		resp, _ := p(context.Background(), experimentRequest(user))
+		if h := resp.Metadata.Headers[ExperimentHeaderName]; h[0] != "shape=control" || resp.Data["a"] != 1 {
+			t.Errorf("%s: the experiment should be disabled: %v %v", user, h, resp.Data)
+		}
+	}
+
+	feature.Unset(ExperimentFeatureFlag("shape"))
+	for _, user := range users {
+		resp, _ := p(context.Background(), experimentRequest(user))
+		if h := resp.Metadata.Headers[ExperimentHeaderName]; h[0] != "shape=flat" || resp.Data["alpha"] != 1 {
+			t.Errorf("%s: the user should get its variant again: %v %v", user, h, resp.Data)
+		}
+	}
+}
+
+func TestNewExperimentMiddleware_invalid(t *testing.T) {
+	for _, experiments := range []interface{}{
+		"shape",
+		[]interface{}{map[string]interface{}{"name": "shape", "attribute": "header:X-User-Id"}},
+		[]interface{}{map[string]interface{}{
+			"name":      "shape",
+			"attribute": "cookie:user",
+			"variants":  []interface{}{map[string]interface{}{"name": "control"}},
+		}},
+		[]interface{}{map[string]interface{}{
+			"name":      "shape",
+			"attribute": "header:X-User-Id",
+			"variants": []interface{}{
+				map[string]interface{}{"name": "control"},
+				map[string]interface{}{"name": "a", "percentage": 60},
+				map[string]interface{}{"name": "b", "percentage": 60},
+			},
+		}},
+	} {
+		endpoint := &config.EndpointConfig{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{experimentsKey: experiments},
+			},
+		}
+		p := NewExperimentMiddleware(logging.NoOp, endpoint)(experimentProxy())
+		resp, _ := p(context.Background(), experimentRequest("user-1"))
+		if len(resp.Data) != 3 || resp.Metadata.Headers != nil {
+			t.Errorf("%v: the response should not be modified: %+v", experiments, resp)
+		}
+	}
+}
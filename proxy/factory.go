@@ -3,8 +3,12 @@
 package proxy
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/random"
 	"github.com/luraproject/lura/v2/sd"
 )
 
@@ -45,13 +49,22 @@ func NewDefaultFactory(backendFactory BackendFactory, logger logging.Logger) Fac
 // NewDefaultFactoryWithSubscriber returns a default proxy factory with the injected proxy builder,
 // logger and subscriber factory
 func NewDefaultFactoryWithSubscriber(backendFactory BackendFactory, logger logging.Logger, sF sd.SubscriberFactory) Factory {
-	return defaultFactory{backendFactory, logger, sF}
+	return defaultFactory{backendFactory: backendFactory, logger: logger, subscriberFactory: sF}
+}
+
+// NewDefaultFactoryWithSource returns a default proxy factory with the injected proxy builder,
+// logger, subscriber factory and source of the random decisions taken by the balancers and the
+// jittered retries of the http executors. If the source is a random.NewDecisionLog, the draws
+// are labelled with the backend and recorded in the random.Decisions of the request context.
+func NewDefaultFactoryWithSource(backendFactory BackendFactory, logger logging.Logger, sF sd.SubscriberFactory, src random.Source) Factory {
+	return defaultFactory{backendFactory: backendFactory, logger: logger, subscriberFactory: sF, source: src}
 }
 
 type defaultFactory struct {
 	backendFactory    BackendFactory
 	logger            logging.Logger
 	subscriberFactory sd.SubscriberFactory
+	source            random.Source
 }
 
 // New implements the Factory interface
@@ -68,8 +81,25 @@ func (pf defaultFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 		return
 	}
 
+	p = NewCoerceMiddleware(pf.logger, cfg)(p)
+	p = NewBodyDecryptionMiddleware(pf.logger, cfg)(p)
+	p = NewObjectMergeMiddleware(pf.logger, cfg)(p)
+	p = NewUnflattenMiddleware(pf.logger, cfg)(p)
+	p = NewColumnarMiddleware(pf.logger, cfg)(p)
+	p = NewArrayToObjectMiddleware(pf.logger, cfg)(p)
+	p = NewUnitConversionMiddleware(pf.logger, cfg)(p)
+	p = NewClampMiddleware(pf.logger, cfg)(p)
+	p = NewNormalizeMiddleware(pf.logger, cfg)(p)
+	p = NewJSONPatchMiddleware(pf.logger, cfg)(p)
+	p = NewCoalescingMiddleware(pf.logger, cfg)(p)
+	p = NewExperimentMiddleware(pf.logger, cfg)(p)
+	p = NewTokenizeMiddleware(pf.logger, cfg)(p)
 	p = NewPluginMiddleware(pf.logger, cfg)(p)
 	p = NewStaticMiddleware(pf.logger, cfg)(p)
+	p = NewConcurrencyLimiterMiddleware(pf.logger, cfg)(p)
+	p = NewRateLimitMiddleware(pf.logger, cfg)(p)
+	p = NewSizeQuotaMiddleware(pf.logger, cfg)(p)
+	p = NewSLAMiddleware(pf.logger, cfg)(p)
 	return
 }
 
@@ -89,14 +119,41 @@ func (pf defaultFactory) newSingle(cfg *config.EndpointConfig) (Proxy, error) {
 
 func (pf defaultFactory) newStack(backend *config.Backend) (p Proxy) {
 	p = pf.backendFactory(backend)
+	var src random.Source
+	if pf.source != nil {
+		prefix := fmt.Sprintf("[BACKEND: %s %s -> %s]", backend.ParentEndpointMethod, backend.ParentEndpoint, backend.URLPattern)
+		p = withRandomSource(random.WithName(pf.source, prefix+"[Retry]"), p)
+		src = random.WithName(pf.source, prefix+"[Balancer]")
+	}
+	p = NewContentTypeLoggerMiddleware(pf.logger, backend)(p)
+	p = NewSecretHeadersMiddleware(pf.logger, backend)(p)
 	p = NewBackendPluginMiddleware(pf.logger, backend)(p)
 	p = NewGraphQLMiddleware(pf.logger, backend)(p)
 	p = NewFilterHeadersMiddleware(pf.logger, backend)(p)
 	p = NewFilterQueryStringsMiddleware(pf.logger, backend)(p)
-	p = NewLoadBalancedMiddlewareWithSubscriberAndLogger(pf.logger, pf.subscriberFactory(backend))(p)
+	p = NewDynamicHostMiddleware(pf.logger, backend)(p)
+	if adaptive, ok := getAdaptiveBalancingConfig(backend.ExtraConfig); ok {
+		p = NewAdaptiveLoadBalancedMiddleware(pf.logger, pf.subscriberFactory(backend), src, adaptive)(p)
+	} else if src != nil {
+		p = NewLoadBalancedMiddlewareWithSource(pf.logger, pf.subscriberFactory(backend), src)(p)
+	} else {
+		p = NewLoadBalancedMiddlewareWithSubscriberAndLogger(pf.logger, pf.subscriberFactory(backend))(p)
+	}
+	p = NewBackendQueueMiddleware(pf.logger, backend)(p)
 	if backend.ConcurrentCalls > 1 {
 		p = NewConcurrentMiddlewareWithLogger(pf.logger, backend)(p)
 	}
+	p = NewURLRewriteMiddleware(pf.logger, backend)(p)
+	p = NewBackendCacheMiddleware(pf.logger, backend)(p)
 	p = NewRequestBuilderMiddlewareWithLogger(pf.logger, backend)(p)
+	p = NewConditionalMiddleware(pf.logger, backend)(p)
 	return
 }
+
+// withRandomSource makes the source available to the stages of the backend proxy drawing
+// from the request context, like the jittered retries of the http executors
+func withRandomSource(src random.Source, next Proxy) Proxy {
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		return next(random.NewContext(ctx, src), request)
+	}
+}
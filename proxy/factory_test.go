@@ -9,12 +9,14 @@ import (
 	"bytes"
 	"context"
 	"net/url"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/random"
 	"github.com/luraproject/lura/v2/sd"
 )
 
@@ -145,3 +147,56 @@ func TestNewDefaultFactory_ok(t *testing.T) {
 		t.Errorf("The proxy middleware propagated an unexpected error: %v\n", response)
 	}
 }
+
+func TestNewDefaultFactoryWithSource(t *testing.T) {
+	trace := NewRequestTrace()
+
+	selectedHosts := func(src random.Source) []string {
+		var hosts []string
+		backendFactory := func(_ *config.Backend) Proxy {
+			return func(ctx context.Context, r *Request) (*Response, error) {
+				if random.FromContext(ctx, nil) == nil {
+					t.Error("the backend proxy should receive the source in its context")
+				}
+				hosts = append(hosts, r.URL.Host)
+				return &Response{IsComplete: true}, nil
+			}
+		}
+		p, err := NewDefaultFactoryWithSource(backendFactory, logging.NoOp, sd.FixedSubscriberFactory, src).New(&config.EndpointConfig{
+			Endpoint: "/foo",
+			Method:   "GET",
+			Backend: []*config.Backend{
+				{
+					Host:                 []string{"http://a", "http://b", "http://c", "http://d"},
+					URLPattern:           "/bar",
+					ParentEndpoint:       "/foo",
+					ParentEndpointMethod: "GET",
+				},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		p(trace.WithContext(context.Background()), &Request{Method: "GET", Path: "/bar"})
+		for i := 1; i < 20; i++ {
+			p(context.Background(), &Request{Method: "GET", Path: "/bar"})
+		}
+		return hosts
+	}
+
+	a := selectedHosts(random.NewDecisionLog(random.NewSource(42)))
+	b := selectedHosts(random.NewSource(42))
+	if len(a) != 20 || strings.Join(a, ",") != strings.Join(b, ",") {
+		t.Errorf("the factories with the same seed should select the same hosts:\n%v\n%v", a, b)
+	}
+
+	if runtime.GOMAXPROCS(-1) == 1 {
+		// the round robin balancer only draws its starting position
+		return
+	}
+	decisions := trace.Decisions()
+	if len(decisions) != 1 || !strings.HasPrefix(decisions[0], "[BACKEND: GET /foo -> /bar][Balancer] Intn(4) = ") {
+		t.Errorf("only the draws of the traced request should be recorded: %v", decisions)
+	}
+}
@@ -98,11 +98,16 @@ func NewGraphQLMiddleware(logger logging.Logger, remote *config.Backend) Middlew
 				// header to be safe if the server side checks it:
 				req.Headers["Content-Type"] = []string{"application/json"}
 				if req.Query != nil {
+					// the query values can be shared with the rest of backends, so they are
+					// copied before adding the graphql ones
+					query := make(url.Values, len(req.Query)+len(q))
+					for k, vs := range req.Query {
+						query[k] = vs
+					}
 					for k, vs := range q {
-						for _, v := range vs {
-							req.Query.Add(k, v)
-						}
+						query[k] = append(query[k][:len(query[k]):len(query[k])], vs...)
 					}
+					req.Query = query
 				} else {
 					req.Query = q
 				}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
@@ -135,3 +136,34 @@ func TestNewGraphQLMiddleware_query(t *testing.T) {
 		t.Errorf("unexpected response: %v", resp)
 	}
 }
+
+func TestNewGraphQLMiddleware_sharedQuery(t *testing.T) {
+	mw := NewGraphQLMiddleware(
+		logging.NoOp,
+		&config.Backend{
+			ExtraConfig: config.ExtraConfig{
+				graphql.Namespace: map[string]interface{}{
+					"method": "get",
+					"type":   "query",
+					"query":  "{ q }",
+				},
+			},
+		},
+	)
+
+	query := url.Values{"page": {"2"}}
+	prxy := mw(func(_ context.Context, req *Request) (*Response, error) {
+		if req.Query.Get("page") != "2" || req.Query.Get("query") != "{ q }" {
+			t.Errorf("unexpected query: %v", req.Query)
+		}
+		return &Response{}, nil
+	})
+
+	if _, err := prxy(context.Background(), &Request{Query: query, Headers: map[string][]string{}}); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(query) != 1 {
+		t.Errorf("the query of the request has been modified: %v", query)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
@@ -29,9 +30,10 @@ func CustomHTTPProxyFactory(cf client.HTTPClientFactory) BackendFactory {
 	}
 }
 
-// NewHTTPProxy creates a http proxy with the injected configuration, HTTPClientFactory and Decoder
+// NewHTTPProxy creates a http proxy with the injected configuration, HTTPClientFactory and Decoder.
+// The backends enabling HTTP/2 are reached with their own executor instead of the clients of the factory.
 func NewHTTPProxy(remote *config.Backend, cf client.HTTPClientFactory, decode encoding.Decoder) Proxy {
-	return NewHTTPProxyWithHTTPExecutor(remote, client.DefaultHTTPRequestExecutor(cf), decode)
+	return NewHTTPProxyWithHTTPExecutor(remote, client.NewHTTP2HTTPRequestExecutor(remote, cf), decode)
 }
 
 // NewHTTPProxyWithHTTPExecutor creates a http proxy with the injected configuration, HTTPRequestExecutor and Decoder
@@ -41,23 +43,48 @@ func NewHTTPProxyWithHTTPExecutor(remote *config.Backend, re client.HTTPRequestE
 	}
 
 	ef := NewEntityFormatter(remote)
-	rp := DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{dec, ef})
+	var rp HTTPResponseParser
+	if remote.Encoding == encoding.AUTO {
+		rp = NewContentNegotiatingHTTPResponseParser(remote, HTTPResponseParserConfig{dec, ef})
+	} else {
+		rp = DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{dec, ef})
+	}
+	rp = NewContentTypeCheckingParser(remote, rp)
 	return NewHTTPProxyDetailed(remote, re, client.GetHTTPStatusHandler(remote), rp)
 }
 
+// readOnlyHeadersNamespaces are the namespaces of the backend extra config whose components
+// never write the headers of the requests sent to the backend. Any other namespace (executor
+// plugins, martian modifiers, scripting...) may install a stage writing them.
+var readOnlyHeadersNamespaces = map[string]struct{}{
+	Namespace:        {},
+	client.Namespace: {},
+}
+
 // NewHTTPProxyDetailed creates a http proxy with the injected configuration, HTTPRequestExecutor,
-// Decoder and HTTPResponseParser
-func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch client.HTTPStatusHandler, rp HTTPResponseParser) Proxy {
+// Decoder and HTTPResponseParser.
+//
+// The headers of the request are shared with the request sent to the backend only when the
+// backend is the single one of its endpoint and its extra config only declares components
+// known to leave them untouched. Otherwise, every request to the backend gets its own copy,
+// since the merged backends receive the same headers map and, with the '*' header forwarding,
+// the map is the one of the incoming request. The executors injected here must not write the
+// headers of the shared requests.
+//
+// The executor is wrapped with the body checksum and the retries defined by the backend, if any.
+func NewHTTPProxyDetailed(remote *config.Backend, re client.HTTPRequestExecutor, ch client.HTTPStatusHandler, rp HTTPResponseParser) Proxy {
+	re = client.NewBodyChecksumHTTPRequestExecutor(remote, re)
+	re = client.NewRetryHTTPRequestExecutor(remote, re)
+	copyHeaders := !sharesRequestHeaders(remote)
 	return func(ctx context.Context, request *Request) (*Response, error) {
 		requestToBackend, err := http.NewRequest(strings.ToTitle(request.Method), request.URL.String(), request.Body)
 		if err != nil {
 			return nil, err
 		}
-		requestToBackend.Header = make(map[string][]string, len(request.Headers))
-		for k, vs := range request.Headers {
-			tmp := make([]string, len(vs))
-			copy(tmp, vs)
-			requestToBackend.Header[k] = tmp
+		if copyHeaders {
+			requestToBackend.Header = CloneRequestHeaders(request.Headers)
+		} else if request.Headers != nil {
+			requestToBackend.Header = request.Headers
 		}
 		if request.Body != nil {
 			if v, ok := request.Headers["Content-Length"]; ok && len(v) == 1 && v[0] != "chunked" {
@@ -67,7 +94,9 @@ func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch c
 			}
 		}
 
+		start := time.Now()
 		resp, err := re(ctx, requestToBackend)
+		traceBackendRequest(ctx, requestToBackend, resp, err, start)
 		if requestToBackend.Body != nil {
 			requestToBackend.Body.Close()
 		}
@@ -80,8 +109,19 @@ func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch c
 		if err != nil {
 			return nil, err
 		}
+		collectResponseHeaders(ctx, resp)
+		recordBackendStatus(ctx, resp)
 
 		resp, err = ch(ctx, resp)
+		if t, ok := err.(client.PassthroughStatusError); ok && resp != nil {
+			r, perr := rp(ctx, resp)
+			if perr != nil || r == nil {
+				return nil, t
+			}
+			r.IsComplete = false
+			r.Metadata.StatusCode = t.Code
+			return r, t
+		}
 		if err != nil {
 			if t, ok := err.(responseError); ok {
 				return &Response{
@@ -98,6 +138,18 @@ func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch c
 	}
 }
 
+func sharesRequestHeaders(remote *config.Backend) bool {
+	if remote == nil || remote.ParentEndpointBackends != 1 {
+		return false
+	}
+	for ns := range remote.ExtraConfig {
+		if _, ok := readOnlyHeadersNamespaces[ns]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // NewRequestBuilderMiddleware creates a proxy middleware that parses the request params received
 // from the outer layer and generates the path to the backend endpoints
 var NewRequestBuilderMiddleware = func(remote *config.Backend) Middleware {
@@ -109,6 +161,7 @@ func NewRequestBuilderMiddlewareWithLogger(logger logging.Logger, remote *config
 }
 
 func newRequestBuilderMiddleware(l logging.Logger, remote *config.Backend) Middleware {
+	pagination, paginated := getPaginationConfig(remote.ExtraConfig)
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
 			l.Fatal("too many proxies for this %s %s -> %s proxy middleware: newRequestBuilderMiddleware only accepts 1 proxy, got %d", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
@@ -117,6 +170,9 @@ func newRequestBuilderMiddleware(l logging.Logger, remote *config.Backend) Middl
 		return func(ctx context.Context, r *Request) (*Response, error) {
 			r.GeneratePath(remote.URLPattern)
 			r.Method = remote.Method
+			if paginated {
+				r.Query = pagination.apply(r.Query)
+			}
 			return next[0](ctx, r)
 		}
 	}
@@ -3,10 +3,17 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/transport/http/client"
+	clientplugin "github.com/luraproject/lura/v2/transport/http/client/plugin"
 )
 
 func BenchmarkNewRequestBuilderMiddleware(b *testing.B) {
@@ -22,3 +29,37 @@ func BenchmarkNewRequestBuilderMiddleware(b *testing.B) {
 		proxy(context.Background(), &Request{})
 	}
 }
+
+func BenchmarkNewHTTPProxyDetailed_headers(b *testing.B) {
+	headers := make(map[string][]string, 30)
+	for i := 0; i < 30; i++ {
+		headers[fmt.Sprintf("X-Header-%d", i)] = []string{"some value"}
+	}
+	rpURL, _ := url.Parse("http://example.com/foo")
+	body := []byte("{}")
+	re := func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+	}
+
+	for _, testCase := range []struct {
+		name    string
+		backend *config.Backend
+	}{
+		{name: "shared", backend: &config.Backend{ParentEndpointBackends: 1}},
+		{
+			name: "copied",
+			backend: &config.Backend{
+				ParentEndpointBackends: 1,
+				ExtraConfig:            config.ExtraConfig{clientplugin.Namespace: map[string]interface{}{}},
+			},
+		},
+	} {
+		p := NewHTTPProxyDetailed(testCase.backend, re, client.DefaultHTTPStatusHandler, NoOpHTTPResponseParser)
+		b.Run(testCase.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p(context.Background(), &Request{Method: "GET", URL: rpURL, Headers: headers})
+			}
+		})
+	}
+}
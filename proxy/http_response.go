@@ -7,7 +7,9 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"strings"
 
+	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 )
 
@@ -54,6 +56,34 @@ func DefaultHTTPResponseParserFactory(cfg HTTPResponseParserConfig) HTTPResponse
 	}
 }
 
+const fallbackEncodingKey = "fallback_encoding"
+
+// NewContentNegotiatingHTTPResponseParser returns a HTTPResponseParser selecting the decoder
+// of every response by its content type, using the content types associated with the decoders
+// of the encoding register (see encoding.DecoderRegister.RegisterContentType). The responses
+// without a known content type are decoded with the encoding defined at the 'fallback_encoding'
+// key of the backend extra config or, if it is not defined, with the decoder of the config.
+func NewContentNegotiatingHTTPResponseParser(remote *config.Backend, cfg HTTPResponseParserConfig) HTTPResponseParser {
+	register := encoding.GetRegister()
+	if e, ok := remote.ExtraConfig[Namespace].(map[string]interface{}); ok {
+		if name, ok := e[fallbackEncodingKey].(string); ok && name != "" {
+			cfg.Decoder = register.Get(strings.ToLower(name))(remote.IsCollection)
+		}
+	}
+	fallback := DefaultHTTPResponseParserFactory(cfg)
+
+	return func(ctx context.Context, resp *http.Response) (*Response, error) {
+		dec, ok := register.GetByContentType(resp.Header.Get("Content-Type"))
+		if !ok {
+			return fallback(ctx, resp)
+		}
+		return DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{
+			Decoder:         dec(remote.IsCollection),
+			EntityFormatter: cfg.EntityFormatter,
+		})(ctx, resp)
+	}
+}
+
 // NoOpHTTPResponseParser is a HTTPResponseParser implementation that just copies the
 // http response body into the proxy response IO
 func NoOpHTTPResponseParser(ctx context.Context, resp *http.Response) (*Response, error) {
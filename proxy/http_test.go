@@ -6,18 +6,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/transport/http/client"
+	clientplugin "github.com/luraproject/lura/v2/transport/http/client/plugin"
 )
 
 func TestNewHTTPProxy_ok(t *testing.T) {
@@ -487,3 +491,357 @@ func TestNewHTTPProxy_noopDecoder(t *testing.T) {
 		t.Error("unexpected content:", content)
 	}
 }
+
+func TestNewHTTPProxy_registeredStatusHandler(t *testing.T) {
+	client.RegisterHTTPStatusHandler("test_accept_conflict", func(_ *config.Backend) client.HTTPStatusHandler {
+		return func(ctx context.Context, resp *http.Response) (*http.Response, error) {
+			if resp.StatusCode == http.StatusConflict {
+				return resp, nil
+			}
+			return client.DefaultHTTPStatusHandler(ctx, resp)
+		}
+	})
+
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, `{"status":"already exists"}`)
+	}))
+	defer backendServer.Close()
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	newRequest := func() *Request {
+		return &Request{Method: "GET", Path: "/", URL: rpURL, Headers: map[string][]string{}}
+	}
+
+	backend := &config.Backend{
+		Decoder: encoding.JSONDecoder,
+		ExtraConfig: config.ExtraConfig{
+			client.Namespace: map[string]interface{}{"status_handler": "test_accept_conflict"},
+		},
+	}
+	result, err := HTTPProxyFactory(http.DefaultClient)(backend)(context.Background(), newRequest())
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if !result.IsComplete {
+		t.Error("the response should be complete")
+	}
+	if v, ok := result.Data["status"]; !ok || v != "already exists" {
+		t.Errorf("unexpected response: %v", result.Data)
+	}
+
+	backend.ExtraConfig = config.ExtraConfig{}
+	if _, err := HTTPProxyFactory(http.DefaultClient)(backend)(context.Background(), newRequest()); err != client.ErrInvalidStatusCode {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewHTTPProxy_statusErrors(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, `{"status":"already exists"}`)
+	}))
+	defer backendServer.Close()
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	backend := &config.Backend{
+		Decoder: encoding.JSONDecoder,
+		StatusErrors: map[int]config.StatusError{
+			http.StatusConflict: {Error: "conflict"},
+		},
+	}
+	_, err := HTTPProxyFactory(http.DefaultClient)(backend)(context.Background(), &Request{
+		Method:  "GET",
+		Path:    "/",
+		URL:     rpURL,
+		Headers: map[string][]string{},
+	})
+	mappedErr, ok := err.(client.MappedStatusError)
+	if !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if mappedErr.StatusCode() != http.StatusConflict || mappedErr.Error() != "conflict" {
+		t.Errorf("unexpected error: %+v", mappedErr)
+	}
+}
+
+func TestNewHTTPProxy_passthroughStatuses(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/conflict" {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, `{"error":"already exists","id":42,"fields":["name"]}`)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"error":"boom"}`)
+	}))
+	defer backendServer.Close()
+
+	backend := &config.Backend{
+		Decoder: encoding.JSONDecoder,
+		ExtraConfig: config.ExtraConfig{
+			client.Namespace: map[string]interface{}{
+				"passthrough_statuses": []interface{}{404.0, 409.0},
+			},
+		},
+	}
+	p := HTTPProxyFactory(http.DefaultClient)(backend)
+
+	rpURL, _ := url.Parse(backendServer.URL + "/conflict")
+	resp, err := p(context.Background(), &Request{
+		Method:  "GET",
+		Path:    "/conflict",
+		URL:     rpURL,
+		Headers: map[string][]string{},
+	})
+	if passthroughErr, ok := err.(client.PassthroughStatusError); !ok || passthroughErr.StatusCode() != http.StatusConflict {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if resp == nil {
+		t.Error("the decoded response should be returned")
+		return
+	}
+	if resp.Metadata.StatusCode != http.StatusConflict || resp.IsComplete {
+		t.Errorf("unexpected response metadata: %+v", resp)
+	}
+	if resp.Data["error"] != "already exists" || fmt.Sprint(resp.Data["id"]) != "42" {
+		t.Errorf("unexpected response data: %v", resp.Data)
+	}
+	if fields, ok := resp.Data["fields"].([]interface{}); !ok || len(fields) != 1 || fields[0] != "name" {
+		t.Errorf("unexpected response data: %v", resp.Data)
+	}
+
+	rpURL, _ = url.Parse(backendServer.URL + "/error")
+	resp, err = p(context.Background(), &Request{
+		Method:  "GET",
+		Path:    "/error",
+		URL:     rpURL,
+		Headers: map[string][]string{},
+	})
+	if err != client.ErrInvalidStatusCode {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if resp != nil {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestNewHTTPProxyDetailed_headers(t *testing.T) {
+	rpURL, _ := url.Parse("http://example.com/foo")
+	newRequest := func() *Request {
+		return &Request{
+			Method: "GET",
+			URL:    rpURL,
+			Headers: map[string][]string{
+				"X-Tenant": {"a"},
+				"Accept":   {"application/json"},
+			},
+		}
+	}
+
+	var backendHeaders http.Header
+	re := func(_ context.Context, req *http.Request) (*http.Response, error) {
+		backendHeaders = req.Header
+		req.Header.Set("X-Tenant", "mutated")
+		req.Header.Add("Accept", "text/plain")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString("{}")),
+		}, nil
+	}
+
+	// a backend enabling the executor plugins gets its own copy of the headers
+	request := newRequest()
+	p := NewHTTPProxyDetailed(&config.Backend{
+		ParentEndpointBackends: 1,
+		ExtraConfig: config.ExtraConfig{
+			clientplugin.Namespace: map[string]interface{}{"name": "mutator"},
+		},
+	}, re, client.DefaultHTTPStatusHandler, NoOpHTTPResponseParser)
+	if _, err := p(context.Background(), request); err != nil {
+		t.Error(err)
+		return
+	}
+	if v := request.Headers["X-Tenant"]; len(v) != 1 || v[0] != "a" {
+		t.Errorf("the request headers have been modified: %v", request.Headers)
+	}
+	if v := request.Headers["Accept"]; len(v) != 1 || v[0] != "application/json" {
+		t.Errorf("the request headers have been modified: %v", request.Headers)
+	}
+	if backendHeaders.Get("X-Tenant") != "mutated" || len(backendHeaders["Accept"]) != 2 {
+		t.Errorf("unexpected backend headers: %v", backendHeaders)
+	}
+
+	// so does a backend declaring a namespace unknown to the proxy
+	request = newRequest()
+	p = NewHTTPProxyDetailed(&config.Backend{
+		ParentEndpointBackends: 1,
+		ExtraConfig: config.ExtraConfig{
+			"github.com/devopsfaith/krakend-martian": map[string]interface{}{},
+			Namespace:                                map[string]interface{}{},
+		},
+	}, re, client.DefaultHTTPStatusHandler, NoOpHTTPResponseParser)
+	if _, err := p(context.Background(), request); err != nil {
+		t.Error(err)
+		return
+	}
+	if v := request.Headers["X-Tenant"]; len(v) != 1 || v[0] != "a" {
+		t.Errorf("the request headers have been modified: %v", request.Headers)
+	}
+
+	// the single backends of their endpoints share the headers of the request
+	request = newRequest()
+	p = NewHTTPProxyDetailed(&config.Backend{
+		ParentEndpointBackends: 1,
+		ExtraConfig:            config.ExtraConfig{client.Namespace: map[string]interface{}{}},
+	}, func(_ context.Context, req *http.Request) (*http.Response, error) {
+		backendHeaders = req.Header
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString("{}")),
+		}, nil
+	}, client.DefaultHTTPStatusHandler, NoOpHTTPResponseParser)
+	if _, err := p(context.Background(), request); err != nil {
+		t.Error(err)
+		return
+	}
+	if reflect.ValueOf(backendHeaders).Pointer() != reflect.ValueOf(request.Headers).Pointer() {
+		t.Error("the headers should be shared")
+	}
+}
+
+func TestNewHTTPProxy_autoEncoding(t *testing.T) {
+	encoding.GetRegister().Register(encoding.XML, func(_ bool) func(io.Reader, *map[string]interface{}) error {
+		return func(r io.Reader, v *map[string]interface{}) error {
+			var doc struct {
+				Fields []struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				} `xml:",any"`
+			}
+			if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+				return err
+			}
+			res := map[string]interface{}{}
+			for _, f := range doc.Fields {
+				res[f.XMLName.Local] = f.Value
+			}
+			*v = res
+			return nil
+		}
+	})
+
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/xml":
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			fmt.Fprint(w, "<user><id>42</id><name>foo</name></user>")
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":42,"name":"foo"}`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<p>foo</p>")
+		}
+	}))
+	defer backendServer.Close()
+
+	backend := &config.Backend{
+		Encoding: encoding.AUTO,
+		Decoder:  encoding.GetRegister().Get(encoding.AUTO)(false),
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{fallbackEncodingKey: encoding.STRING},
+		},
+	}
+	p := NewHTTPProxyWithHTTPExecutor(backend, client.DefaultHTTPRequestExecutor(client.NewHTTPClient), backend.Decoder)
+
+	for _, tc := range []struct {
+		path     string
+		expected map[string]interface{}
+	}{
+		{path: "/xml", expected: map[string]interface{}{"id": "42", "name": "foo"}},
+		{path: "/json", expected: map[string]interface{}{"id": "42", "name": "foo"}},
+		{path: "/html", expected: map[string]interface{}{"content": "<p>foo</p>"}},
+	} {
+		u, _ := url.Parse(backendServer.URL + tc.path)
+		resp, err := p(context.Background(), &Request{Method: "GET", Path: tc.path, URL: u})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.path, err.Error())
+			continue
+		}
+		if !resp.IsComplete || len(resp.Data) != len(tc.expected) {
+			t.Errorf("%s: unexpected response: %+v", tc.path, resp)
+			continue
+		}
+		for k, v := range tc.expected {
+			if fmt.Sprint(resp.Data[k]) != v {
+				t.Errorf("%s: unexpected value for %s: %v", tc.path, k, resp.Data[k])
+			}
+		}
+	}
+}
+
+func TestNewHTTPProxyWithHTTPExecutor_mergedBackendsHeaders(t *testing.T) {
+	re := func(_ context.Context, req *http.Request) (*http.Response, error) {
+		for i := 0; i < 100; i++ {
+			req.Header.Set("X-Backend", req.URL.Path)
+			req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(fmt.Sprintf(`{%q:true}`, req.URL.Path))),
+		}, nil
+	}
+	endpointConfig := &config.EndpointConfig{
+		Endpoint: "/merged",
+		Method:   "GET",
+		Backend: []*config.Backend{
+			{URLPattern: "/a", Encoding: encoding.JSON},
+			{URLPattern: "/b", Encoding: encoding.JSON},
+		},
+	}
+	serviceConfig := config.ServiceConfig{
+		Version:   config.ConfigVersion,
+		Endpoints: []*config.EndpointConfig{endpointConfig},
+		Timeout:   time.Second,
+		Host:      []string{"http://127.0.0.1"},
+	}
+	if err := serviceConfig.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	factory := NewDefaultFactory(func(remote *config.Backend) Proxy {
+		return NewHTTPProxyWithHTTPExecutor(remote, re, remote.Decoder)
+	}, logging.NoOp)
+	p, err := factory.New(endpointConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	headers := map[string][]string{"Accept": {"application/json"}}
+	resp, err := p(context.Background(), &Request{
+		Method:  "GET",
+		Path:    "/merged",
+		Headers: headers,
+		Params:  map[string]string{},
+		Query:   url.Values{},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !resp.IsComplete || len(resp.Data) != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(headers) != 1 {
+		t.Errorf("the request headers have been modified: %v", headers)
+	}
+}
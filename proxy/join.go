@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const joinKey = "join"
+
+type joinFactory struct {
+	f      Factory
+	logger logging.Logger
+}
+
+// New checks the Backends for an ExtraConfig with the "join" definition and implements the
+// Factory interface. The backends defining it are excluded from the regular pipe and they are
+// used to fetch the objects referenced by the items returned by the rest of backends.
+func (j joinFactory) New(cfg *config.EndpointConfig) (Proxy, error) {
+	backends := cfg.Backend
+	var joins []joinConfig
+	var joined []*config.Backend
+	var regular []*config.Backend
+	for _, b := range cfg.Backend {
+		if jc, ok := getJoinConfig(b); ok {
+			joins = append(joins, jc)
+			joined = append(joined, b)
+			continue
+		}
+		regular = append(regular, b)
+	}
+	if len(joins) == 0 {
+		return j.f.New(cfg)
+	}
+
+	cfg.Backend = regular
+	p, err := j.f.New(cfg)
+	cfg.Backend = backends
+	if err != nil {
+		return p, err
+	}
+
+	for i, jc := range joins {
+		joinCfg := *cfg
+		joinCfg.ExtraConfig = config.ExtraConfig{}
+		joinCfg.Backend = []*config.Backend{joined[i]}
+		pJoin, err := j.f.New(&joinCfg)
+		if err != nil {
+			return nil, err
+		}
+		j.logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Join] Inlining the '%s' of the items at '%s' as '%s'",
+			cfg.Endpoint, jc.SourceField, strings.Join(jc.Items, "."), jc.Key))
+		p = newJoinProxy(jc, p, pJoin)
+	}
+	return p, nil
+}
+
+// NewJoinFactory creates a new joinFactory using the provided Factory
+func NewJoinFactory(f Factory) Factory {
+	return NewJoinFactoryWithLogger(logging.NoOp, f)
+}
+
+// NewJoinFactoryWithLogger creates a new joinFactory using the provided Factory and logger.
+//
+// The backends with the join definition receive a single request per response, with the
+// deduplicated list of ids referenced by the items sent in the configured query string
+// param. The objects returned by them (under the 'collection' key, by default) are inlined
+// in the items referencing them.
+func NewJoinFactoryWithLogger(logger logging.Logger, f Factory) Factory {
+	return joinFactory{f: f, logger: logger}
+}
+
+type joinConfig struct {
+	Items       []string
+	SourceField string
+	Key         string
+	Param       string
+	Separator   string
+	IDField     string
+	Results     []string
+}
+
+func newJoinProxy(cfg joinConfig, next, join Proxy) Proxy {
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		resp, err := next(ctx, request)
+		if err != nil || resp == nil || resp.Data == nil {
+			return resp, err
+		}
+
+		items := joinItems(resp.Data, cfg.Items)
+		ids := make([]string, 0, len(items))
+		seen := make(map[string]struct{}, len(items))
+		for _, item := range items {
+			id, ok := joinID(item[cfg.SourceField])
+			if !ok {
+				continue
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			return resp, nil
+		}
+
+		joinRequest := CloneRequest(request)
+		joinRequest.Body = nil
+		joinRequest.Method = "GET"
+		joinRequest.Query = url.Values{}
+		if cfg.Separator != "" {
+			joinRequest.Query.Set(cfg.Param, strings.Join(ids, cfg.Separator))
+		} else {
+			joinRequest.Query[cfg.Param] = ids
+		}
+
+		joinResp, err := join(ctx, joinRequest)
+		if err != nil || joinResp == nil {
+			resp.IsComplete = false
+			return resp, err
+		}
+
+		objects := make(map[string]interface{}, len(ids))
+		for _, obj := range joinItems(joinResp.Data, cfg.Results) {
+			if id, ok := joinID(obj[cfg.IDField]); ok {
+				objects[id] = obj
+			}
+		}
+
+		for _, item := range items {
+			id, ok := joinID(item[cfg.SourceField])
+			if !ok {
+				continue
+			}
+			obj, ok := objects[id]
+			if !ok {
+				continue
+			}
+			item[cfg.Key] = copyJSONValue(obj)
+		}
+		resp.IsComplete = resp.IsComplete && joinResp.IsComplete
+		return resp, nil
+	}
+}
+
+// joinItems returns the objects found at the path. The value at the path can be an object
+// or an array of objects.
+func joinItems(data map[string]interface{}, path []string) []map[string]interface{} {
+	var current interface{} = data
+	for _, token := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[token]
+	}
+
+	switch v := current.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		res := make([]map[string]interface{}, 0, len(v))
+		for _, e := range v {
+			if m, ok := e.(map[string]interface{}); ok {
+				res = append(res, m)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func joinID(v interface{}) (string, bool) {
+	switch id := v.(type) {
+	case string:
+		return id, id != ""
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+func getJoinConfig(remote *config.Backend) (joinConfig, bool) {
+	v, ok := remote.ExtraConfig[Namespace]
+	if !ok {
+		return joinConfig{}, false
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return joinConfig{}, false
+	}
+	tmp, ok := e[joinKey].(map[string]interface{})
+	if !ok {
+		return joinConfig{}, false
+	}
+
+	cfg := joinConfig{
+		IDField: "id",
+		Results: []string{"collection"},
+	}
+	cfg.SourceField, _ = tmp["source_field"].(string)
+	cfg.Key, _ = tmp["key"].(string)
+	cfg.Param, _ = tmp["param"].(string)
+	if cfg.SourceField == "" || cfg.Key == "" || cfg.Param == "" {
+		return joinConfig{}, false
+	}
+	if items, ok := tmp["items"].(string); ok {
+		cfg.Items = splitPath(items)
+	}
+	if separator, ok := tmp["separator"].(string); ok {
+		cfg.Separator = separator
+	}
+	if id, ok := tmp["id_field"].(string); ok && id != "" {
+		cfg.IDField = id
+	}
+	if results, ok := tmp["results"].(string); ok {
+		cfg.Results = splitPath(results)
+	}
+	return cfg, true
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewJoinFactory(t *testing.T) {
+	var authorRequests []*Request
+	posts := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{
+			IsComplete: true,
+			Data: map[string]interface{}{
+				"collection": []interface{}{
+					map[string]interface{}{"id": 1.0, "title": "a", "author_id": 10.0},
+					map[string]interface{}{"id": 2.0, "title": "b", "author_id": 20.0},
+					map[string]interface{}{"id": 3.0, "title": "c", "author_id": 10.0},
+					map[string]interface{}{"id": 4.0, "title": "d", "author_id": 30.0},
+					map[string]interface{}{"id": 5.0, "title": "e"},
+				},
+			},
+		}, nil
+	}
+	authors := func(_ context.Context, r *Request) (*Response, error) {
+		authorRequests = append(authorRequests, r)
+		return &Response{
+			IsComplete: true,
+			Data: map[string]interface{}{
+				"collection": []interface{}{
+					map[string]interface{}{"id": 10.0, "name": "alice"},
+					map[string]interface{}{"id": 20.0, "name": "bob"},
+				},
+			},
+		}, nil
+	}
+
+	f := NewJoinFactory(FactoryFunc(func(cfg *config.EndpointConfig) (Proxy, error) {
+		if len(cfg.Backend) != 1 {
+			return nil, fmt.Errorf("unexpected number of backends: %d", len(cfg.Backend))
+		}
+		if cfg.Backend[0].URLPattern == "/authors" {
+			if len(cfg.ExtraConfig) != 0 {
+				return nil, fmt.Errorf("the endpoint config should not be applied to the joined backends")
+			}
+			return authors, nil
+		}
+		return posts, nil
+	}))
+
+	p, err := f.New(&config.EndpointConfig{
+		Endpoint: "/posts",
+		ExtraConfig: config.ExtraConfig{
+			"foo": "bar",
+		},
+		Backend: []*config.Backend{
+			{URLPattern: "/posts"},
+			{
+				URLPattern: "/authors",
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{
+						joinKey: map[string]interface{}{
+							"items":        "collection",
+							"source_field": "author_id",
+							"key":          "author",
+							"param":        "id",
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	resp, err := p(context.Background(), &Request{Method: "GET", Headers: map[string][]string{}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(authorRequests) != 1 {
+		t.Errorf("the authors should be fetched with a single request. have: %d", len(authorRequests))
+		return
+	}
+	if ids := authorRequests[0].Query["id"]; strings.Join(ids, ",") != "10,20,30" {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+
+	b, _ := json.Marshal(resp.Data)
+	expected := `{"collection":[` +
+		`{"author":{"id":10,"name":"alice"},"author_id":10,"id":1,"title":"a"},` +
+		`{"author":{"id":20,"name":"bob"},"author_id":20,"id":2,"title":"b"},` +
+		`{"author":{"id":10,"name":"alice"},"author_id":10,"id":3,"title":"c"},` +
+		`{"author_id":30,"id":4,"title":"d"},` +
+		`{"id":5,"title":"e"}]}`
+	if string(b) != expected {
+		t.Errorf("unexpected response:\nhave: %s\nwant: %s", string(b), expected)
+	}
+	if !resp.IsComplete {
+		t.Error("the response should be complete")
+	}
+}
+
+func TestNewJoinFactory_httpBackends(t *testing.T) {
+	var authorQueries []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/posts":
+			fmt.Fprint(w, `[{"title":"a","author_id":"u1"},{"title":"b","author_id":"u2"},{"title":"c","author_id":"u1"}]`)
+		case "/authors":
+			authorQueries = append(authorQueries, r.URL.RawQuery)
+			fmt.Fprint(w, `{"data":[{"uid":"u1","name":"alice"},{"uid":"u2","name":"bob"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/posts",
+		Method:   "GET",
+		Backend: []*config.Backend{
+			{
+				Host:         []string{s.URL},
+				URLPattern:   "/posts",
+				Method:       "GET",
+				IsCollection: true,
+				Decoder:      encoding.NewJSONDecoder(true),
+			},
+			{
+				Host:       []string{s.URL},
+				URLPattern: "/authors",
+				Method:     "GET",
+				Decoder:    encoding.NewJSONDecoder(false),
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{
+						joinKey: map[string]interface{}{
+							"source_field": "author_id",
+							"key":          "author",
+							"param":        "uids",
+							"separator":    ",",
+							"id_field":     "uid",
+							"results":      "data",
+							"items":        "collection",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	p, err := NewJoinFactory(NewDefaultFactory(httpProxy, logging.NoOp)).New(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp, err := p(context.Background(), &Request{Method: "GET", Headers: map[string][]string{}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(authorQueries) != 1 || authorQueries[0] != "uids=u1%2Cu2" {
+		t.Errorf("unexpected requests to the authors backend: %v", authorQueries)
+	}
+	col, _ := resp.Data["collection"].([]interface{})
+	if len(col) != 3 {
+		t.Errorf("unexpected response: %v", resp.Data)
+		return
+	}
+	for i, name := range []string{"alice", "bob", "alice"} {
+		author, _ := col[i].(map[string]interface{})["author"].(map[string]interface{})
+		if author["name"] != name {
+			t.Errorf("#%d: unexpected author: %v", i, author)
+		}
+	}
+}
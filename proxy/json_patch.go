@@ -0,0 +1,341 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	jsonPatchKey = "json_patch"
+
+	jsonPatchSkipPolicy  = "skip"
+	jsonPatchErrorPolicy = "error"
+)
+
+// NewJSONPatchMiddleware creates a proxy middleware applying the configured JSON Patch
+// (RFC 6902) document to the response data. The add, remove, replace and move operations
+// are supported.
+//
+// When an operation can not be applied (because its target does not exist, for instance),
+// the error policy decides what to do: skip the operation and continue with the rest of the
+// patch (default) or mark the response as incomplete and return an error. In the second case
+// the response data is not modified by any of the operations.
+func NewJSONPatchMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	patch, ok, err := getJSONPatchConfig(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][JSONPatch] %s", endpointConfig.Endpoint, err.Error()))
+		return emptyMiddlewareFallback(logger)
+	}
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][JSONPatch] Applying %d operations (error policy: %s)",
+			endpointConfig.Endpoint,
+			len(patch.Operations),
+			patch.ErrorPolicy,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewJSONPatchMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if err != nil || resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			// with the error policy, the patch is applied to a copy of the data, so the
+			// response is not altered by the operations preceding the failing one
+			doc := resp.Data
+			if patch.ErrorPolicy == jsonPatchErrorPolicy {
+				doc = copyJSONValue(resp.Data).(map[string]interface{})
+			}
+
+			for _, op := range patch.Operations {
+				opErr := op.Apply(doc)
+				if opErr == nil {
+					continue
+				}
+				logger.Debug(fmt.Sprintf("[ENDPOINT: %s][JSONPatch] %s", endpointConfig.Endpoint, opErr.Error()))
+				if patch.ErrorPolicy == jsonPatchErrorPolicy {
+					resp.IsComplete = false
+					return resp, opErr
+				}
+			}
+			resp.Data = doc
+			return resp, nil
+		}
+	}
+}
+
+type jsonPatch struct {
+	Operations  []jsonPatchOperation
+	ErrorPolicy string
+}
+
+type jsonPatchOperation struct {
+	Op    string
+	Path  []string
+	From  []string
+	Value interface{}
+}
+
+// Apply executes the operation over the received document
+func (o jsonPatchOperation) Apply(doc map[string]interface{}) error {
+	var err error
+	switch o.Op {
+	case "add":
+		err = jsonPatchAdd(doc, o.Path, copyJSONValue(o.Value))
+	case "remove":
+		_, err = jsonPatchRemove(doc, o.Path)
+	case "replace":
+		if _, err = jsonPatchRemove(doc, o.Path); err == nil {
+			err = jsonPatchAdd(doc, o.Path, copyJSONValue(o.Value))
+		}
+	case "move":
+		if err = jsonPatchCheckTarget(doc, o.Path); err != nil {
+			break
+		}
+		var v interface{}
+		if v, err = jsonPatchRemove(doc, o.From); err != nil {
+			break
+		}
+		if err = jsonPatchAdd(doc, o.Path, v); err != nil {
+			// the removal of the source can shift the indexes of the target array
+			jsonPatchAdd(doc, o.From, v)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to apply the %s operation to '%s': %s", o.Op, jsonPointer(o.Path), err.Error())
+	}
+	return nil
+}
+
+// jsonPatchParent returns the container holding the last token of the path and a function
+// replacing that container in its own parent (required for updating arrays)
+func jsonPatchParent(doc map[string]interface{}, path []string) (interface{}, func(interface{}), error) {
+	var current interface{} = doc
+	set := func(interface{}) {}
+
+	for _, token := range path[:len(path)-1] {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, nil, fmt.Errorf("the key '%s' does not exist", token)
+			}
+			k := token
+			current, set = v, func(v interface{}) { c[k] = v }
+		case []interface{}:
+			i, err := jsonPatchIndex(token, len(c)-1)
+			if err != nil {
+				return nil, nil, err
+			}
+			current, set = c[i], func(v interface{}) { c[i] = v }
+		default:
+			return nil, nil, fmt.Errorf("the value at '%s' is not a container", token)
+		}
+	}
+	return current, set, nil
+}
+
+// jsonPatchCheckTarget checks if a value can be added at the path of the document
+func jsonPatchCheckTarget(doc map[string]interface{}, path []string) error {
+	parent, _, err := jsonPatchParent(doc, path)
+	if err != nil {
+		return err
+	}
+	token := path[len(path)-1]
+
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		return nil
+	case []interface{}:
+		if token == "-" {
+			return nil
+		}
+		_, err := jsonPatchIndex(token, len(c))
+		return err
+	}
+	return fmt.Errorf("the parent of '%s' is not a container", token)
+}
+
+func jsonPatchAdd(doc map[string]interface{}, path []string, value interface{}) error {
+	parent, set, err := jsonPatchParent(doc, path)
+	if err != nil {
+		return err
+	}
+	token := path[len(path)-1]
+
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		c[token] = value
+		return nil
+	case []interface{}:
+		if token == "-" {
+			set(append(c, value))
+			return nil
+		}
+		i, err := jsonPatchIndex(token, len(c))
+		if err != nil {
+			return err
+		}
+		res := make([]interface{}, 0, len(c)+1)
+		res = append(res, c[:i]...)
+		res = append(res, value)
+		set(append(res, c[i:]...))
+		return nil
+	}
+	return fmt.Errorf("the parent of '%s' is not a container", token)
+}
+
+func jsonPatchRemove(doc map[string]interface{}, path []string) (interface{}, error) {
+	parent, set, err := jsonPatchParent(doc, path)
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		v, ok := c[token]
+		if !ok {
+			return nil, fmt.Errorf("the key '%s' does not exist", token)
+		}
+		delete(c, token)
+		return v, nil
+	case []interface{}:
+		i, err := jsonPatchIndex(token, len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		v := c[i]
+		res := make([]interface{}, 0, len(c)-1)
+		res = append(res, c[:i]...)
+		set(append(res, c[i+1:]...))
+		return v, nil
+	}
+	return nil, fmt.Errorf("the parent of '%s' is not a container", token)
+}
+
+func jsonPatchIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+	return i, nil
+}
+
+// copyJSONValue returns a deep copy of the value, so the patched responses do not share
+// the objects and arrays defined in the configuration
+func copyJSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			res[k] = copyJSONValue(v)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, v := range t {
+			res[i] = copyJSONValue(v)
+		}
+		return res
+	}
+	return v
+}
+
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" || pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func jsonPointer(tokens []string) string {
+	res := ""
+	for _, t := range tokens {
+		res += "/" + strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1")
+	}
+	return res
+}
+
+func getJSONPatchConfig(extra config.ExtraConfig) (jsonPatch, bool, error) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return jsonPatch{}, false, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return jsonPatch{}, false, nil
+	}
+	tmp, ok := e[jsonPatchKey].(map[string]interface{})
+	if !ok {
+		return jsonPatch{}, false, nil
+	}
+	ops, ok := tmp["operations"].([]interface{})
+	if !ok || len(ops) == 0 {
+		return jsonPatch{}, false, nil
+	}
+
+	patch := jsonPatch{
+		Operations:  make([]jsonPatchOperation, 0, len(ops)),
+		ErrorPolicy: jsonPatchSkipPolicy,
+	}
+	if policy, ok := tmp["on_error"].(string); ok && policy == jsonPatchErrorPolicy {
+		patch.ErrorPolicy = policy
+	}
+
+	for i, raw := range ops {
+		o, ok := raw.(map[string]interface{})
+		if !ok {
+			return jsonPatch{}, false, fmt.Errorf("invalid operation #%d", i)
+		}
+		op := jsonPatchOperation{Value: o["value"]}
+		op.Op, _ = o["op"].(string)
+
+		path, _ := o["path"].(string)
+		var err error
+		if op.Path, err = parseJSONPointer(path); err != nil {
+			return jsonPatch{}, false, fmt.Errorf("operation #%d: %s", i, err.Error())
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if _, ok := o["value"]; !ok {
+				return jsonPatch{}, false, fmt.Errorf("operation #%d: the %s operation requires a value", i, op.Op)
+			}
+		case "remove":
+		case "move":
+			from, _ := o["from"].(string)
+			if op.From, err = parseJSONPointer(from); err != nil {
+				return jsonPatch{}, false, fmt.Errorf("operation #%d: %s", i, err.Error())
+			}
+			if path != from && strings.HasPrefix(path+"/", from+"/") {
+				return jsonPatch{}, false, fmt.Errorf("operation #%d: a value can not be moved into one of its children", i)
+			}
+		default:
+			return jsonPatch{}, false, fmt.Errorf("operation #%d: unsupported operation '%s'", i, op.Op)
+		}
+		patch.Operations = append(patch.Operations, op)
+	}
+	return patch, true, nil
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewJSONPatchMiddleware_addAndRemove(t *testing.T) {
+	mw := NewJSONPatchMiddleware(logging.NoOp, newJSONPatchEndpoint(`{
+		"operations": [
+			{"op": "add", "path": "/meta", "value": {"source": "gateway"}},
+			{"op": "add", "path": "/items/1", "value": "b"},
+			{"op": "add", "path": "/items/-", "value": "z"},
+			{"op": "remove", "path": "/secret"},
+			{"op": "remove", "path": "/user/a~1b"}
+		]
+	}`))
+
+	for i := 0; i < 2; i++ {
+		p := mw(dummyProxy(&Response{
+			IsComplete: true,
+			Data: map[string]interface{}{
+				"items":  []interface{}{"a", "c"},
+				"secret": "xxx",
+				"user":   map[string]interface{}{"a/b": 1, "name": "foo"},
+			},
+		}))
+		resp, err := p(context.Background(), &Request{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, _ := json.Marshal(resp.Data)
+		if expected := `{"items":["a","b","c","z"],"meta":{"source":"gateway"},"user":{"name":"foo"}}`; string(b) != expected {
+			t.Errorf("#%d: unexpected response: %s", i, string(b))
+		}
+		resp.Data["meta"].(map[string]interface{})["source"] = "modified"
+	}
+}
+
+func TestNewJSONPatchMiddleware_replaceAndMove(t *testing.T) {
+	mw := NewJSONPatchMiddleware(logging.NoOp, newJSONPatchEndpoint(`{
+		"operations": [
+			{"op": "replace", "path": "/items/0", "value": "x"},
+			{"op": "replace", "path": "/name", "value": "bar"},
+			{"op": "move", "from": "/items/1", "path": "/last"},
+			{"op": "move", "from": "/user/id", "path": "/id"}
+		]
+	}`))
+	p := mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data: map[string]interface{}{
+			"items": []interface{}{"a", "b"},
+			"name":  "foo",
+			"user":  map[string]interface{}{"id": 42},
+		},
+	}))
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, _ := json.Marshal(resp.Data)
+	if expected := `{"id":42,"items":["x"],"last":"b","name":"bar","user":{}}`; string(b) != expected {
+		t.Errorf("unexpected response: %s", string(b))
+	}
+}
+
+func TestNewJSONPatchMiddleware_errorPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy       string
+		expectedErr  bool
+		expectedBody string
+	}{
+		{
+			policy:       "skip",
+			expectedBody: `{"a":1,"c":3}`,
+		},
+		{
+			policy:       "error",
+			expectedErr:  true,
+			expectedBody: `{"a":1}`,
+		},
+	} {
+		mw := NewJSONPatchMiddleware(logging.NoOp, newJSONPatchEndpoint(`{
+			"on_error": "`+tc.policy+`",
+			"operations": [
+				{"op": "remove", "path": "/b"},
+				{"op": "add", "path": "/c", "value": 3}
+			]
+		}`))
+		p := mw(dummyProxy(&Response{IsComplete: true, Data: map[string]interface{}{"a": 1}}))
+		resp, err := p(context.Background(), &Request{})
+		if tc.expectedErr != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", tc.policy, err)
+		}
+		if resp.IsComplete == tc.expectedErr {
+			t.Errorf("%s: unexpected completion flag", tc.policy)
+		}
+		b, _ := json.Marshal(resp.Data)
+		if string(b) != tc.expectedBody {
+			t.Errorf("%s: unexpected response: %s", tc.policy, string(b))
+		}
+	}
+}
+
+func TestNewJSONPatchMiddleware_invalidConfig(t *testing.T) {
+	for _, cfg := range []string{
+		`{"operations": [{"op": "copy", "path": "/a", "from": "/b"}]}`,
+		`{"operations": [{"op": "add", "path": "a", "value": 1}]}`,
+		`{"operations": [{"op": "add", "path": "/a"}]}`,
+		`{"operations": [{"op": "move", "path": "/a/b", "from": "/a"}]}`,
+	} {
+		resp := &Response{Data: map[string]interface{}{"a": 1}}
+		p := NewJSONPatchMiddleware(logging.NoOp, newJSONPatchEndpoint(cfg))(dummyProxy(resp))
+		r, err := p(context.Background(), &Request{})
+		if err != nil || r != resp || len(r.Data) != 1 {
+			t.Errorf("%s: the response should not be modified: %v", cfg, r.Data)
+		}
+	}
+}
+
+func newJSONPatchEndpoint(patch string) *config.EndpointConfig {
+	var v interface{}
+	json.Unmarshal([]byte(patch), &v)
+	return &config.EndpointConfig{
+		Endpoint: "/foo",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"json_patch": v,
+			},
+		},
+	}
+}
+
+func TestNewJSONPatchMiddleware_errorPolicyAtomic(t *testing.T) {
+	mw := NewJSONPatchMiddleware(logging.NoOp, newJSONPatchEndpoint(`{
+		"on_error": "error",
+		"operations": [
+			{"op": "add", "path": "/c", "value": 3},
+			{"op": "remove", "path": "/a/x"},
+			{"op": "remove", "path": "/b"}
+		]
+	}`))
+	p := mw(dummyProxy(&Response{IsComplete: true, Data: map[string]interface{}{"a": map[string]interface{}{"x": 1}}}))
+	resp, err := p(context.Background(), &Request{})
+	if err == nil || resp.IsComplete {
+		t.Errorf("unexpected result: %v %v", resp.IsComplete, err)
+	}
+	b, _ := json.Marshal(resp.Data)
+	if expected := `{"a":{"x":1}}`; string(b) != expected {
+		t.Errorf("the failed patch should not modify the response: %s", string(b))
+	}
+}
+
+func TestNewJSONPatchMiddleware_moveInvalidTarget(t *testing.T) {
+	mw := NewJSONPatchMiddleware(logging.NoOp, newJSONPatchEndpoint(`{
+		"operations": [
+			{"op": "move", "from": "/a", "path": "/missing/a"},
+			{"op": "move", "from": "/items/0", "path": "/items/2"},
+			{"op": "move", "from": "/name", "path": "/items/9"}
+		]
+	}`))
+	p := mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data: map[string]interface{}{
+			"a":     1,
+			"items": []interface{}{"x", "y"},
+			"name":  "foo",
+		},
+	}))
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, _ := json.Marshal(resp.Data)
+	if expected := `{"a":1,"items":["x","y"],"name":"foo"}`; string(b) != expected {
+		t.Errorf("the failed moves should keep their sources: %s", string(b))
+	}
+}
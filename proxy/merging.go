@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/textproto"
 	"regexp"
 	"strconv"
 	"strings"
@@ -28,6 +29,7 @@ func NewMergeDataMiddleware(logger logging.Logger, endpointConfig *config.Endpoi
 	serviceTimeout := time.Duration(85*endpointConfig.Timeout.Nanoseconds()/100) * time.Nanosecond
 	combiner := getResponseCombiner(endpointConfig.ExtraConfig)
 	isSequential := shouldRunSequentialMerger(endpointConfig)
+	propagatedHeaders := getSequentialPropagatedHeaders(endpointConfig.ExtraConfig)
 
 	logger.Debug(
 		fmt.Sprintf(
@@ -38,6 +40,15 @@ func NewMergeDataMiddleware(logger logging.Logger, endpointConfig *config.Endpoi
 			getResponseCombinerName(endpointConfig.ExtraConfig),
 		),
 	)
+	if isSequential && len(propagatedHeaders) > 0 {
+		logger.Debug(
+			fmt.Sprintf(
+				"[ENDPOINT: %s][Merge] Propagating the response headers %v to the next sequential steps",
+				endpointConfig.Endpoint,
+				propagatedHeaders,
+			),
+		)
+	}
 
 	return func(next ...Proxy) Proxy {
 		if len(next) != totalBackends {
@@ -53,16 +64,52 @@ func NewMergeDataMiddleware(logger logging.Logger, endpointConfig *config.Endpoi
 			reqClone = CloneRequest
 		}
 
+		patterns := make([]string, len(endpointConfig.Backend))
+		for i, b := range endpointConfig.Backend {
+			patterns[i] = b.URLPattern
+		}
+
+		if deps, ok, err := getBackendDependencies(endpointConfig); err != nil {
+			logger.Error(fmt.Sprintf("[ENDPOINT: %s][Merge] %s", endpointConfig.Endpoint, err.Error()))
+		} else if ok {
+			if _, err := topologicalOrder(deps); err != nil {
+				logger.Fatal("[ENDPOINT: %s][Merge] %s", endpointConfig.Endpoint, err.Error())
+				return nil
+			}
+			logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Merge] Executing the backends following the dependency graph %v", endpointConfig.Endpoint, deps))
+			return dependencyGraphMerge(reqClone, patterns, deps, serviceTimeout, combiner, next...)
+		}
+
 		if !isSequential {
 			return parallelMerge(reqClone, serviceTimeout, combiner, next...)
 		}
 
-		patterns := make([]string, len(endpointConfig.Backend))
-		for i, b := range endpointConfig.Backend {
-			patterns[i] = b.URLPattern
+		return sequentialMerge(reqClone, patterns, propagatedHeaders, serviceTimeout, combiner, next...)
+	}
+}
+
+// getSequentialPropagatedHeaders returns the canonical names of the response headers to copy from
+// the response of every sequential step into the requests of the following ones
+func getSequentialPropagatedHeaders(extra config.ExtraConfig) []string {
+	v, ok := extra[Namespace]
+	if !ok {
+		return nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	vs, ok := e[sequentialPropagatedHeadersKey].([]interface{})
+	if !ok {
+		return nil
+	}
+	headers := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if h, ok := v.(string); ok && h != "" {
+			headers = append(headers, textproto.CanonicalMIMEHeaderKey(h))
 		}
-		return sequentialMerge(reqClone, patterns, serviceTimeout, combiner, next...)
 	}
+	return headers
 }
 
 func shouldRunSequentialMerger(cfg *config.EndpointConfig) bool {
@@ -120,10 +167,16 @@ func parallelMerge(reqCloner func(*Request) *Request, timeout time.Duration, rc
 
 var reMergeKey = regexp.MustCompile(`\{\{\.Resp(\d+)_([\w-\.]+)\}\}`)
 
-func sequentialMerge(reqCloner func(*Request) *Request, patterns []string, timeout time.Duration, rc ResponseCombiner, next ...Proxy) Proxy {
+func sequentialMerge(reqCloner func(*Request) *Request, patterns, propagatedHeaders []string, timeout time.Duration, rc ResponseCombiner, next ...Proxy) Proxy {
 	return func(ctx context.Context, request *Request) (*Response, error) {
 		localCtx, cancel := context.WithTimeout(ctx, timeout)
 
+		if len(propagatedHeaders) > 0 {
+			// the propagated headers are added to the request, so we do not want
+			// to modify the headers received from the outer layer
+			request.Headers = CloneRequestHeaders(request.Headers)
+		}
+
 		parts := make([]*Response, len(next))
 		out := make(chan *Response, 1)
 		errCh := make(chan error, 1)
@@ -132,65 +185,23 @@ func sequentialMerge(reqCloner func(*Request) *Request, patterns []string, timeo
 	TxLoop:
 		for i, n := range next {
 			if i > 0 {
-				for _, match := range reMergeKey.FindAllStringSubmatch(patterns[i], -1) {
-					if len(match) > 1 {
-						rNum, err := strconv.Atoi(match[1])
-						if err != nil || rNum >= i || parts[rNum] == nil {
-							continue
-						}
-						key := "Resp" + match[1] + "_" + match[2]
-
-						var v interface{}
-						var ok bool
-
-						data := parts[rNum].Data
-						keys := strings.Split(match[2], ".")
-						if len(keys) > 1 {
-							for _, k := range keys[:len(keys)-1] {
-								v, ok = data[k]
-								if !ok {
-									break
-								}
-								clean, ok := v.(map[string]interface{})
-								if !ok {
-									break
-								}
-								data = clean
-							}
-						}
-
-						v, ok = data[keys[len(keys)-1]]
-						if !ok {
-							continue
-						}
-						switch clean := v.(type) {
-						case []interface{}:
-							if len(clean) == 0 {
-								request.Params[key] = ""
-								continue
-							}
-							var b strings.Builder
-							for i := 0; i < len(clean)-1; i++ {
-								fmt.Fprintf(&b, "%v,", clean[i])
-							}
-							fmt.Fprintf(&b, "%v", clean[len(clean)-1])
-							request.Params[key] = b.String()
-						case string:
-							request.Params[key] = clean
-						case int:
-							request.Params[key] = strconv.Itoa(clean)
-						case float64:
-							request.Params[key] = strconv.FormatFloat(clean, 'E', -1, 32)
-						case bool:
-							request.Params[key] = strconv.FormatBool(clean)
-						default:
-							request.Params[key] = fmt.Sprintf("%v", v)
-						}
-					}
-				}
+				setResponseParams(request.Params, patterns[i], parts, i)
 			}
 
-			sequentialRequestPart(localCtx, n, reqCloner(request), out, errCh)
+			stepCtx := localCtx
+			var collector *responseHeadersCollector
+			if len(propagatedHeaders) > 0 && i < len(next)-1 {
+				collector = newResponseHeadersCollector(propagatedHeaders)
+				stepCtx = collector.WithContext(localCtx)
+			}
+
+			sequentialRequestPart(stepCtx, n, reqCloner(request), out, errCh)
+
+			if collector != nil {
+				for k, vs := range collector.Headers() {
+					request.Headers[k] = vs
+				}
+			}
 
 			select {
 			case err := <-errCh:
@@ -215,6 +226,68 @@ func sequentialMerge(reqCloner func(*Request) *Request, patterns []string, timeo
 	}
 }
 
+// setResponseParams adds to the params the values of the responses referenced by the
+// {{.RespN_field}} placeholders of the pattern. Only the responses with an index lower than
+// limit are considered.
+func setResponseParams(params map[string]string, pattern string, parts []*Response, limit int) {
+	for _, match := range reMergeKey.FindAllStringSubmatch(pattern, -1) {
+		if len(match) > 1 {
+			rNum, err := strconv.Atoi(match[1])
+			if err != nil || rNum >= limit || parts[rNum] == nil {
+				continue
+			}
+			key := "Resp" + match[1] + "_" + match[2]
+
+			var v interface{}
+			var ok bool
+
+			data := parts[rNum].Data
+			keys := strings.Split(match[2], ".")
+			if len(keys) > 1 {
+				for _, k := range keys[:len(keys)-1] {
+					v, ok = data[k]
+					if !ok {
+						break
+					}
+					clean, ok := v.(map[string]interface{})
+					if !ok {
+						break
+					}
+					data = clean
+				}
+			}
+
+			v, ok = data[keys[len(keys)-1]]
+			if !ok {
+				continue
+			}
+			switch clean := v.(type) {
+			case []interface{}:
+				if len(clean) == 0 {
+					params[key] = ""
+					continue
+				}
+				var b strings.Builder
+				for i := 0; i < len(clean)-1; i++ {
+					fmt.Fprintf(&b, "%v,", clean[i])
+				}
+				fmt.Fprintf(&b, "%v", clean[len(clean)-1])
+				params[key] = b.String()
+			case string:
+				params[key] = clean
+			case int:
+				params[key] = strconv.Itoa(clean)
+			case float64:
+				params[key] = strconv.FormatFloat(clean, 'E', -1, 32)
+			case bool:
+				params[key] = strconv.FormatBool(clean)
+			default:
+				params[key] = fmt.Sprintf("%v", v)
+			}
+		}
+	}
+}
+
 type incrementalMergeAccumulator struct {
 	pending  int
 	data     *Response
@@ -342,9 +415,10 @@ func RegisterResponseCombiner(name string, f ResponseCombiner) {
 }
 
 const (
-	mergeKey            = "combiner"
-	isSequentialKey     = "sequential"
-	defaultCombinerName = "default"
+	mergeKey                       = "combiner"
+	isSequentialKey                = "sequential"
+	sequentialPropagatedHeadersKey = "sequential_propagated_headers"
+	defaultCombinerName            = "default"
 )
 
 var responseCombiners = initResponseCombiners()
@@ -6,11 +6,14 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/logging"
 )
 
@@ -677,3 +680,71 @@ func Test_incrementalMergeAccumulator_incompleteResponse(t *testing.T) {
 		t.Error("response should not be completed")
 	}
 }
+
+func TestNewMergeDataMiddleware_sequentialPropagatedHeaders(t *testing.T) {
+	first := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("X-Lsn", "0/16B3748")
+		rw.Header().Set("X-Other", "ignored")
+		rw.Write([]byte(`{"id":42}`))
+	}))
+	defer first.Close()
+
+	var received http.Header
+	second := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"name":"foo"}`))
+	}))
+	defer second.Close()
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/sequential",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{URLPattern: "/write", Method: "POST", Host: []string{first.URL}, Decoder: encoding.JSONDecoder},
+			{URLPattern: "/read/{{.Resp0_id}}", Method: "GET", Host: []string{second.URL}, Decoder: encoding.JSONDecoder},
+		},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				isSequentialKey:                true,
+				sequentialPropagatedHeadersKey: []interface{}{"x-lsn"},
+			},
+		},
+	}
+
+	p, err := DefaultFactory(logging.NoOp).New(endpoint)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	headers := map[string][]string{"X-Foo": {"bar"}}
+	resp, err := p(context.Background(), &Request{
+		Method:  "GET",
+		Params:  map[string]string{},
+		Headers: headers,
+		Body:    io.NopCloser(strings.NewReader("")),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !resp.IsComplete {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	if v := received.Get("X-Lsn"); v != "0/16B3748" {
+		t.Errorf("unexpected propagated header: '%s'", v)
+	}
+	if v := received.Get("X-Other"); v != "" {
+		t.Errorf("unexpected header: '%s'", v)
+	}
+	if v := received.Get("X-Foo"); v != "bar" {
+		t.Errorf("unexpected header: '%s'", v)
+	}
+	if _, ok := headers["X-Lsn"]; ok {
+		t.Error("the headers of the original request have been modified")
+	}
+}
@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	normalizeKey = "normalize"
+
+	normalizeKeepPolicy  = "keep"
+	normalizeBlankPolicy = "blank"
+	normalizeErrorPolicy = "error"
+)
+
+// Normalizer returns the canonical form of the value and a flag signaling if it was valid
+type Normalizer func(string) (string, bool)
+
+var normalizers = map[string]func(map[string]interface{}) Normalizer{
+	"email": func(_ map[string]interface{}) Normalizer { return NormalizeEmail },
+	"phone": func(cfg map[string]interface{}) Normalizer {
+		cc, _ := cfg["country_code"].(string)
+		return NewPhoneNormalizer(cc)
+	},
+}
+
+// NormalizeEmail trims and lowercases the email address
+func NormalizeEmail(v string) (string, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	at := strings.LastIndex(v, "@")
+	if at < 1 || at == len(v)-1 || strings.ContainsAny(v, " \t\r\n") {
+		return v, false
+	}
+	domain := v[at+1:]
+	if strings.Contains(v[:at], "@") || !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return v, false
+	}
+	return v, true
+}
+
+// NewPhoneNormalizer returns a Normalizer formatting the phone numbers in the E.164 format.
+// The spaces, dots, dashes and parentheses are removed and the international prefix 00 is
+// replaced with a '+'. The numbers without an international prefix get the received country
+// code, if any.
+func NewPhoneNormalizer(countryCode string) Normalizer {
+	countryCode = strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+	return func(v string) (string, bool) {
+		var digits strings.Builder
+		international := false
+		for i, r := range strings.TrimSpace(v) {
+			switch {
+			case r >= '0' && r <= '9':
+				digits.WriteRune(r)
+			case r == '+' && i == 0:
+				international = true
+			case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			default:
+				return v, false
+			}
+		}
+
+		number := digits.String()
+		switch {
+		case international:
+		case strings.HasPrefix(number, "00"):
+			number = number[2:]
+		case countryCode != "":
+			number = countryCode + strings.TrimPrefix(number, "0")
+		default:
+			return v, false
+		}
+		if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+			return v, false
+		}
+		return "+" + number, true
+	}
+}
+
+// InvalidValueError is the error returned when a field can not be normalized and its policy
+// requires to fail
+type InvalidValueError struct {
+	Field string
+	Type  string
+}
+
+// Error returns a string representation of the InvalidValueError
+func (i InvalidValueError) Error() string {
+	return fmt.Sprintf("invalid %s at '%s'", i.Type, i.Field)
+}
+
+// NewNormalizeMiddleware creates a proxy middleware normalizing the string fields of the
+// response with the configured normalizers (email and phone):
+//
+//	"normalize": [
+//		{ "field": "user.email", "type": "email" },
+//		{ "field": "user.phone", "type": "phone", "country_code": "34", "on_invalid": "blank" }
+//	]
+//
+// Only the strings are normalized, including the ones held by the arrays the path reaches (see
+// transformPath). The values that are not valid are kept as they are (default), replaced with
+// an empty string or make the response fail with an InvalidValueError, depending on the
+// on_invalid policy.
+func NewNormalizeMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	fields, err := getNormalizeConfig(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Normalize] %s", endpointConfig.Endpoint, err.Error()))
+		return emptyMiddlewareFallback(logger)
+	}
+	if len(fields) == 0 {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	for _, f := range fields {
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Normalize] Normalizing the %s at '%s' (invalid values policy: %s)",
+			endpointConfig.Endpoint, f.Type, f.Field, f.OnInvalid))
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewNormalizeMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if err != nil || resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			for _, f := range fields {
+				if nErr := f.Apply(resp.Data); nErr != nil {
+					logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Normalize] %s", endpointConfig.Endpoint, nErr.Error()))
+					resp.IsComplete = false
+					return resp, nErr
+				}
+			}
+			return resp, nil
+		}
+	}
+}
+
+type normalizedField struct {
+	Field      string
+	Path       []string
+	Type       string
+	OnInvalid  string
+	Normalizer Normalizer
+}
+
+// Apply normalizes the string values found at the path of the field
+func (n normalizedField) Apply(data map[string]interface{}) error {
+	return transformPath(data, n.Path, func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return v, nil
+		}
+		res, ok := n.Normalizer(s)
+		if ok {
+			return res, nil
+		}
+		switch n.OnInvalid {
+		case normalizeBlankPolicy:
+			return "", nil
+		case normalizeErrorPolicy:
+			return v, InvalidValueError{Field: n.Field, Type: n.Type}
+		}
+		return v, nil
+	})
+}
+
+func getNormalizeConfig(extra config.ExtraConfig) ([]normalizedField, error) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	vs, ok := e[normalizeKey].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	fields := make([]normalizedField, 0, len(vs))
+	for i, raw := range vs {
+		cfg, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid normalizer #%d", i)
+		}
+		f := normalizedField{OnInvalid: normalizeKeepPolicy}
+		f.Field, _ = cfg["field"].(string)
+		if f.Field == "" {
+			return nil, fmt.Errorf("the normalizer #%d has no field", i)
+		}
+		f.Path = splitPath(f.Field)
+		f.Type, _ = cfg["type"].(string)
+		factory, ok := normalizers[f.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown normalizer '%s' for the field '%s'", f.Type, f.Field)
+		}
+		f.Normalizer = factory(cfg)
+		if policy, ok := cfg["on_invalid"].(string); ok {
+			switch policy {
+			case normalizeKeepPolicy, normalizeBlankPolicy, normalizeErrorPolicy:
+				f.OnInvalid = policy
+			default:
+				return nil, fmt.Errorf("unknown invalid values policy '%s' for the field '%s'", policy, f.Field)
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
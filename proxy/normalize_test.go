@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected string
+		valid    bool
+	}{
+		{in: "  John.Doe@Example.COM ", expected: "john.doe@example.com", valid: true},
+		{in: "a@b.io", expected: "a@b.io", valid: true},
+		{in: "not an email", valid: false},
+		{in: "@example.com", valid: false},
+		{in: "john@", valid: false},
+		{in: "john@localhost", valid: false},
+		{in: "john@doe@example.com", valid: false},
+	} {
+		res, ok := NormalizeEmail(tc.in)
+		if ok != tc.valid {
+			t.Errorf("%s: unexpected validity: %v", tc.in, ok)
+			continue
+		}
+		if ok && res != tc.expected {
+			t.Errorf("%s: unexpected result: %s", tc.in, res)
+		}
+	}
+}
+
+func TestNewPhoneNormalizer(t *testing.T) {
+	n := NewPhoneNormalizer("+34")
+	for _, tc := range []struct {
+		in       string
+		expected string
+		valid    bool
+	}{
+		{in: "+1 (415) 555-2671", expected: "+14155552671", valid: true},
+		{in: "0044 20 7946 0958", expected: "+442079460958", valid: true},
+		{in: "612.34.56.78", expected: "+34612345678", valid: true},
+		{in: "0612 345 678", expected: "+34612345678", valid: true},
+		{in: "call me maybe", valid: false},
+		{in: "+1 555", valid: false},
+		{in: "+1234567890123456", valid: false},
+		{in: "1+234567890", valid: false},
+	} {
+		res, ok := n(tc.in)
+		if ok != tc.valid {
+			t.Errorf("%s: unexpected validity: %v (%s)", tc.in, ok, res)
+			continue
+		}
+		if ok && res != tc.expected {
+			t.Errorf("%s: unexpected result: %s", tc.in, res)
+		}
+	}
+
+	if _, ok := NewPhoneNormalizer("")("612 345 678"); ok {
+		t.Error("the numbers without country code should be invalid if there is no default one")
+	}
+}
+
+func TestNewNormalizeMiddleware(t *testing.T) {
+	newResponse := func() *Response {
+		return &Response{
+			IsComplete: true,
+			Data: map[string]interface{}{
+				"user": map[string]interface{}{
+					"email": "John.DOE@Example.com",
+					"phone": " (0034) 612-34-56-78 ",
+				},
+				"contacts": []interface{}{
+					map[string]interface{}{"email": "ALICE@EXAMPLE.ORG", "phone": "not a phone"},
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		policy  string
+		invalid interface{}
+		err     bool
+	}{
+		{policy: "", invalid: "not a phone"},
+		{policy: "keep", invalid: "not a phone"},
+		{policy: "blank", invalid: ""},
+		{policy: "error", err: true},
+	} {
+		phone := map[string]interface{}{"field": "contacts.phone", "type": "phone", "country_code": "34"}
+		if tc.policy != "" {
+			phone["on_invalid"] = tc.policy
+		}
+		endpoint := &config.EndpointConfig{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{
+					normalizeKey: []interface{}{
+						map[string]interface{}{"field": "user.email", "type": "email"},
+						map[string]interface{}{"field": "user.phone", "type": "phone"},
+						map[string]interface{}{"field": "contacts.email", "type": "email"},
+						phone,
+					},
+				},
+			},
+		}
+
+		resp, err := NewNormalizeMiddleware(logging.NoOp, endpoint)(dummyProxy(newResponse()))(context.Background(), &Request{})
+		if tc.err {
+			if _, ok := err.(InvalidValueError); !ok {
+				t.Errorf("%s: unexpected error: %v", tc.policy, err)
+			}
+			if resp == nil || resp.IsComplete {
+				t.Errorf("%s: the response should be incomplete: %+v", tc.policy, resp)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.policy, err.Error())
+			continue
+		}
+
+		user := resp.Data["user"].(map[string]interface{})
+		if user["email"] != "john.doe@example.com" {
+			t.Errorf("%s: unexpected email: %v", tc.policy, user["email"])
+		}
+		if user["phone"] != "+34612345678" {
+			t.Errorf("%s: unexpected phone: %v", tc.policy, user["phone"])
+		}
+		contact := resp.Data["contacts"].([]interface{})[0].(map[string]interface{})
+		if contact["email"] != "alice@example.org" {
+			t.Errorf("%s: unexpected email: %v", tc.policy, contact["email"])
+		}
+		if contact["phone"] != tc.invalid {
+			t.Errorf("%s: unexpected phone: %v", tc.policy, contact["phone"])
+		}
+	}
+}
+
+func TestNewNormalizeMiddleware_invalidConfig(t *testing.T) {
+	for _, cfg := range []interface{}{
+		[]interface{}{map[string]interface{}{"type": "email"}},
+		[]interface{}{map[string]interface{}{"field": "email", "type": "zip"}},
+		[]interface{}{map[string]interface{}{"field": "email", "type": "email", "on_invalid": "ignore"}},
+	} {
+		endpoint := &config.EndpointConfig{
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{normalizeKey: cfg}},
+		}
+		resp, _ := NewNormalizeMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"email": "FOO@EXAMPLE.COM"},
+		}))(context.Background(), &Request{})
+		if resp.Data["email"] != "FOO@EXAMPLE.COM" {
+			t.Errorf("%v: the response should not be modified: %v", cfg, resp.Data)
+		}
+	}
+}
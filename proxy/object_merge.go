@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	objectMergeKey = "merge_objects"
+
+	objectMergeLastPolicy  = "last"
+	objectMergeFirstPolicy = "first"
+	objectMergeErrorPolicy = "error"
+)
+
+// NewObjectMergeMiddleware creates a proxy middleware that merges all the objects contained
+// in an array of the response into a single object.
+//
+// The array is located using a dot separated path and the merge can be shallow (only the
+// first level keys are considered) or deep (nested objects are merged recursively). When
+// more than one object defines the same key, the conflict policy decides what to do:
+// keep the last value (default), keep the first one or fail with an error.
+func NewObjectMergeMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := getObjectMergeConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][ObjectMerge] Merging the objects at '%s' (deep: %t, conflict policy: %s)",
+			endpointConfig.Endpoint,
+			strings.Join(cfg.Path, "."),
+			cfg.Deep,
+			cfg.Conflict,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewObjectMergeMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if err != nil || resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			if mergeErr := cfg.Apply(resp.Data); mergeErr != nil {
+				logger.Debug(fmt.Sprintf("[ENDPOINT: %s][ObjectMerge] %s", endpointConfig.Endpoint, mergeErr.Error()))
+				resp.IsComplete = false
+				return resp, mergeErr
+			}
+			return resp, nil
+		}
+	}
+}
+
+type objectMergeConfig struct {
+	Path     []string
+	Deep     bool
+	Conflict string
+	ToRoot   bool
+}
+
+// Apply merges the objects found at the configured path, replacing the array with the result.
+// If the ToRoot flag is set, the keys of the merged object are moved to the root of the data
+// and the array is removed.
+func (o objectMergeConfig) Apply(data map[string]interface{}) error {
+	last := o.Path[len(o.Path)-1]
+	var toRoot []map[string]interface{}
+
+	err := transformObjects(data, o.Path[:len(o.Path)-1], func(parent map[string]interface{}) error {
+		items, ok := parent[last].([]interface{})
+		if !ok {
+			return nil
+		}
+
+		merged := map[string]interface{}{}
+		for _, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if err := o.merge(merged, obj, ""); err != nil {
+				return err
+			}
+		}
+
+		if !o.ToRoot {
+			parent[last] = merged
+			return nil
+		}
+		delete(parent, last)
+		toRoot = append(toRoot, merged)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, merged := range toRoot {
+		if err := o.merge(data, merged, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o objectMergeConfig) merge(dst, src map[string]interface{}, prefix string) error {
+	for k, v := range src {
+		current, exists := dst[k]
+		if !exists {
+			dst[k] = v
+			continue
+		}
+		if o.Deep {
+			dstObj, okDst := current.(map[string]interface{})
+			srcObj, okSrc := v.(map[string]interface{})
+			if okDst && okSrc {
+				if err := o.merge(dstObj, srcObj, prefix+k+"."); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		switch o.Conflict {
+		case objectMergeFirstPolicy:
+		case objectMergeErrorPolicy:
+			return fmt.Errorf("conflict merging objects: the key '%s' is defined more than once", prefix+k)
+		default:
+			dst[k] = v
+		}
+	}
+	return nil
+}
+
+func getObjectMergeConfig(extra config.ExtraConfig) (objectMergeConfig, bool) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return objectMergeConfig{}, ok
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return objectMergeConfig{}, ok
+	}
+	tmp, ok := e[objectMergeKey].(map[string]interface{})
+	if !ok {
+		return objectMergeConfig{}, ok
+	}
+	path, ok := tmp["path"].(string)
+	if !ok || path == "" {
+		return objectMergeConfig{}, false
+	}
+
+	cfg := objectMergeConfig{
+		Path:     strings.Split(path, "."),
+		Conflict: objectMergeLastPolicy,
+	}
+	cfg.Deep, _ = tmp["deep"].(bool)
+	cfg.ToRoot, _ = tmp["to_root"].(bool)
+
+	if policy, ok := tmp["conflict"].(string); ok {
+		switch policy {
+		case objectMergeFirstPolicy, objectMergeErrorPolicy, objectMergeLastPolicy:
+			cfg.Conflict = policy
+		}
+	}
+	return cfg, true
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewObjectMergeMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      map[string]interface{}
+		expected map[string]interface{}
+		err      bool
+	}{
+		{
+			name: "shallow",
+			cfg:  map[string]interface{}{"path": "collection"},
+			expected: map[string]interface{}{
+				"collection": map[string]interface{}{
+					"a": 1,
+					"b": 2,
+					"c": map[string]interface{}{"y": 2},
+				},
+			},
+		},
+		{
+			name: "shallow_first",
+			cfg:  map[string]interface{}{"path": "collection", "conflict": "first"},
+			expected: map[string]interface{}{
+				"collection": map[string]interface{}{
+					"a": 1,
+					"b": 2,
+					"c": map[string]interface{}{"x": 1},
+				},
+			},
+		},
+		{
+			name: "deep_to_root",
+			cfg:  map[string]interface{}{"path": "collection", "deep": true, "to_root": true},
+			expected: map[string]interface{}{
+				"a": 1,
+				"b": 2,
+				"c": map[string]interface{}{"x": 1, "y": 2},
+			},
+		},
+		{
+			name: "shallow_error",
+			cfg:  map[string]interface{}{"path": "collection", "conflict": "error"},
+			expected: map[string]interface{}{
+				"collection": []interface{}{
+					map[string]interface{}{"a": 1, "c": map[string]interface{}{"x": 1}},
+					map[string]interface{}{"b": 2, "c": map[string]interface{}{"y": 2}},
+				},
+			},
+			err: true,
+		},
+		{
+			name: "deep_error_without_conflicts",
+			cfg:  map[string]interface{}{"path": "collection", "deep": true, "conflict": "error"},
+			expected: map[string]interface{}{
+				"collection": map[string]interface{}{
+					"a": 1,
+					"b": 2,
+					"c": map[string]interface{}{"x": 1, "y": 2},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mw := NewObjectMergeMiddleware(logging.NoOp, &config.EndpointConfig{
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{objectMergeKey: tc.cfg},
+				},
+			})
+			p := mw(dummyProxy(&Response{
+				IsComplete: true,
+				Data: map[string]interface{}{
+					"collection": []interface{}{
+						map[string]interface{}{"a": 1, "c": map[string]interface{}{"x": 1}},
+						map[string]interface{}{"b": 2, "c": map[string]interface{}{"y": 2}},
+					},
+				},
+			}))
+
+			resp, err := p(context.Background(), &Request{})
+			if tc.err != (err != nil) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if resp == nil {
+				t.Error("nil response")
+				return
+			}
+			if resp.IsComplete == tc.err {
+				t.Errorf("unexpected completion flag: %v", resp.IsComplete)
+			}
+			if !reflect.DeepEqual(resp.Data, tc.expected) {
+				t.Errorf("unexpected response: %v", resp.Data)
+			}
+		})
+	}
+}
+
+func TestNewObjectMergeMiddleware_nestedPath(t *testing.T) {
+	mw := NewObjectMergeMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				objectMergeKey: map[string]interface{}{"path": "data.items"},
+			},
+		},
+	})
+	p := mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data: map[string]interface{}{
+			"data": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"a": 1},
+					"not an object",
+					map[string]interface{}{"b": 2},
+				},
+			},
+		},
+	}))
+
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{
+			"items": map[string]interface{}{"a": 1, "b": 2},
+		},
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+}
+
+func TestNewObjectMergeMiddleware_arrayInPath(t *testing.T) {
+	mw := NewObjectMergeMiddleware(logging.NoOp, &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{objectMergeKey: map[string]interface{}{"path": "orders.items"}},
+		},
+	})
+	items := []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"b": 2}}
+	resp, err := mw(dummyProxy(&Response{
+		IsComplete: true,
+		Data:       map[string]interface{}{"orders": []interface{}{map[string]interface{}{"items": items}}},
+	}))(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := resp.Data["orders"].([]interface{})[0].(map[string]interface{})
+	if !reflect.DeepEqual(order["items"], map[string]interface{}{"a": 1, "b": 2}) {
+		t.Errorf("unexpected order: %v", order)
+	}
+}
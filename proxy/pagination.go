@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const paginationKey = "pagination"
+
+// paginationConfig defines the pagination params the request builder sends to the backend.
+// The backends declaring the 'pagination' key in their extra config get the default page
+// and page size injected when the client does not send them, and the page size requested
+// by the client capped to the max one:
+//
+//	"pagination": { "page_param": "page", "size_param": "size", "default_size": 20, "max_size": 100 }
+//
+// Notice the backends filtering their query strings must allow the pagination params.
+type paginationConfig struct {
+	PageParam   string
+	SizeParam   string
+	DefaultPage int
+	DefaultSize int
+	MaxSize     int
+}
+
+// apply returns a copy of the query with the pagination params enforced
+func (p paginationConfig) apply(query url.Values) url.Values {
+	res := make(url.Values, len(query)+2)
+	for k, v := range query {
+		res[k] = v
+	}
+
+	if page, err := strconv.Atoi(res.Get(p.PageParam)); err != nil || page < 1 {
+		res.Set(p.PageParam, strconv.Itoa(p.DefaultPage))
+	}
+
+	size, err := strconv.Atoi(res.Get(p.SizeParam))
+	switch {
+	case err != nil || size < 1:
+		if p.DefaultSize > 0 {
+			res.Set(p.SizeParam, strconv.Itoa(p.DefaultSize))
+		} else {
+			res.Del(p.SizeParam)
+		}
+	case p.MaxSize > 0 && size > p.MaxSize:
+		res.Set(p.SizeParam, strconv.Itoa(p.MaxSize))
+	}
+	return res
+}
+
+func getPaginationConfig(extra config.ExtraConfig) (paginationConfig, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return paginationConfig{}, false
+	}
+	tmp, ok := e[paginationKey].(map[string]interface{})
+	if !ok {
+		return paginationConfig{}, false
+	}
+	cfg := paginationConfig{
+		PageParam:   "page",
+		SizeParam:   "size",
+		DefaultPage: 1,
+	}
+	if v, ok := tmp["page_param"].(string); ok && v != "" {
+		cfg.PageParam = v
+	}
+	if v, ok := tmp["size_param"].(string); ok && v != "" {
+		cfg.SizeParam = v
+	}
+	if v, ok := tmp["default_page"].(float64); ok && v >= 1 {
+		cfg.DefaultPage = int(v)
+	}
+	if v, ok := tmp["max_size"].(float64); ok && v > 0 {
+		cfg.MaxSize = int(v)
+	}
+	if v, ok := tmp["default_size"].(float64); ok && v > 0 {
+		cfg.DefaultSize = int(v)
+	}
+	if cfg.MaxSize > 0 && (cfg.DefaultSize == 0 || cfg.DefaultSize > cfg.MaxSize) {
+		cfg.DefaultSize = cfg.MaxSize
+	}
+	return cfg, true
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewRequestBuilderMiddleware_pagination(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      map[string]interface{}
+		query    url.Values
+		expected url.Values
+	}{
+		{
+			name:     "oversized",
+			cfg:      map[string]interface{}{"default_size": 20.0, "max_size": 100.0},
+			query:    url.Values{"size": {"1000"}, "page": {"3"}, "q": {"foo"}},
+			expected: url.Values{"size": {"100"}, "page": {"3"}, "q": {"foo"}},
+		},
+		{
+			name:     "defaults",
+			cfg:      map[string]interface{}{"default_size": 20.0, "max_size": 100.0},
+			query:    url.Values{},
+			expected: url.Values{"size": {"20"}, "page": {"1"}},
+		},
+		{
+			name:     "invalid",
+			cfg:      map[string]interface{}{"default_size": 20.0, "max_size": 100.0},
+			query:    url.Values{"size": {"-5"}, "page": {"first"}},
+			expected: url.Values{"size": {"20"}, "page": {"1"}},
+		},
+		{
+			name:     "valid",
+			cfg:      map[string]interface{}{"default_size": 20.0, "max_size": 100.0},
+			query:    url.Values{"size": {"50"}, "page": {"2"}},
+			expected: url.Values{"size": {"50"}, "page": {"2"}},
+		},
+		{
+			name: "custom params",
+			cfg: map[string]interface{}{
+				"page_param":   "p",
+				"size_param":   "limit",
+				"default_page": 0.0,
+				"max_size":     10.0,
+			},
+			query:    url.Values{"limit": {"1000"}},
+			expected: url.Values{"limit": {"10"}, "p": {"1"}},
+		},
+		{
+			name:     "no sizes",
+			cfg:      map[string]interface{}{"default_page": 5.0},
+			query:    url.Values{"size": {"1000"}},
+			expected: url.Values{"size": {"1000"}, "page": {"5"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var query url.Values
+			assertion := func(_ context.Context, request *Request) (*Response, error) {
+				query = request.Query
+				return &Response{IsComplete: true}, nil
+			}
+			backend := &config.Backend{
+				URLPattern: "/items",
+				Method:     "GET",
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{paginationKey: tc.cfg},
+				},
+			}
+			original := url.Values{}
+			for k, v := range tc.query {
+				original[k] = v
+			}
+
+			if _, err := NewRequestBuilderMiddleware(backend)(assertion)(context.Background(), &Request{Query: tc.query}); err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(query, tc.expected) {
+				t.Errorf("unexpected query. have: %v, want: %v", query, tc.expected)
+			}
+			if !reflect.DeepEqual(tc.query, original) {
+				t.Errorf("the query of the request should not be modified: %v", tc.query)
+			}
+		})
+	}
+}
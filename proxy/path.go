@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import "strings"
+
+// splitPath returns the steps of the dot separated path. The empty path has no steps.
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// transformPath replaces the values found at the path with the result of the transformation.
+// It is the walker shared by the middlewares locating their fields with dot separated
+// paths, like "data.items.price":
+//
+//   - every step of the path is a key of an object
+//   - when a step finds an array, the rest of the path is resolved against each one of its
+//     objects, so the path above reaches the price of every item
+//   - an array found at the last step gets the transformation applied to each element
+//   - the missing keys and the values of any other type end the walk without errors
+//
+// The first error returned by the transformation aborts the process.
+func transformPath(data map[string]interface{}, path []string, f func(interface{}) (interface{}, error)) error {
+	k := path[0]
+	v, ok := data[k]
+	if !ok {
+		return nil
+	}
+
+	if len(path) > 1 {
+		switch child := v.(type) {
+		case map[string]interface{}:
+			return transformPath(child, path[1:], f)
+		case []interface{}:
+			for _, elem := range child {
+				if m, ok := elem.(map[string]interface{}); ok {
+					if err := transformPath(m, path[1:], f); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	if vs, ok := v.([]interface{}); ok {
+		for i, elem := range vs {
+			res, err := f(elem)
+			if err != nil {
+				return err
+			}
+			vs[i] = res
+		}
+		return nil
+	}
+	res, err := f(v)
+	if err != nil {
+		return err
+	}
+	data[k] = res
+	return nil
+}
+
+// transformObjects applies the function to the objects found at the path, or to the data
+// itself if the path is empty
+func transformObjects(data map[string]interface{}, path []string, f func(map[string]interface{}) error) error {
+	if len(path) == 0 {
+		return f(data)
+	}
+	return transformPath(data, path, func(v interface{}) (interface{}, error) {
+		if obj, ok := v.(map[string]interface{}); ok {
+			return obj, f(obj)
+		}
+		return v, nil
+	})
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTransformPath(t *testing.T) {
+	double := func(v interface{}) (interface{}, error) {
+		if n, ok := v.(int); ok {
+			return 2 * n, nil
+		}
+		return v, nil
+	}
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		data     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "field",
+			path:     "a",
+			data:     map[string]interface{}{"a": 1, "b": 1},
+			expected: map[string]interface{}{"a": 2, "b": 1},
+		},
+		{
+			name:     "nested field",
+			path:     "a.b",
+			data:     map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+			expected: map[string]interface{}{"a": map[string]interface{}{"b": 2}},
+		},
+		{
+			name:     "array at the last step",
+			path:     "a",
+			data:     map[string]interface{}{"a": []interface{}{1, 2}},
+			expected: map[string]interface{}{"a": []interface{}{2, 4}},
+		},
+		{
+			name: "arrays along the path",
+			path: "a.b.c",
+			data: map[string]interface{}{"a": []interface{}{
+				map[string]interface{}{"b": []interface{}{map[string]interface{}{"c": 1}, "not an object"}},
+				map[string]interface{}{"b": map[string]interface{}{"c": 2}},
+			}},
+			expected: map[string]interface{}{"a": []interface{}{
+				map[string]interface{}{"b": []interface{}{map[string]interface{}{"c": 2}, "not an object"}},
+				map[string]interface{}{"b": map[string]interface{}{"c": 4}},
+			}},
+		},
+		{
+			name:     "missing key",
+			path:     "a.b",
+			data:     map[string]interface{}{"a": map[string]interface{}{"c": 1}},
+			expected: map[string]interface{}{"a": map[string]interface{}{"c": 1}},
+		},
+		{
+			name:     "scalar along the path",
+			path:     "a.b",
+			data:     map[string]interface{}{"a": 1},
+			expected: map[string]interface{}{"a": 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := transformPath(tc.data, splitPath(tc.path), double); err != nil {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(tc.data, tc.expected) {
+				t.Errorf("unexpected data: %v", tc.data)
+			}
+		})
+	}
+}
+
+func TestTransformPath_error(t *testing.T) {
+	expected := errors.New("boom")
+	calls := 0
+	data := map[string]interface{}{"a": []interface{}{1, 2, 3}}
+	err := transformPath(data, splitPath("a"), func(v interface{}) (interface{}, error) {
+		calls++
+		return v, expected
+	})
+	if err != expected {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("the first error should abort the process. calls: %d", calls)
+	}
+}
+
+func TestTransformObjects(t *testing.T) {
+	mark := func(obj map[string]interface{}) error {
+		obj["seen"] = true
+		return nil
+	}
+
+	data := map[string]interface{}{"a": 1}
+	if err := transformObjects(data, splitPath(""), mark); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(data, map[string]interface{}{"a": 1, "seen": true}) {
+		t.Errorf("the empty path should transform the data itself: %v", data)
+	}
+
+	data = map[string]interface{}{"a": []interface{}{map[string]interface{}{}, "not an object"}}
+	if err := transformObjects(data, splitPath("a"), mark); err != nil {
+		t.Error(err)
+	}
+	expected := map[string]interface{}{"a": []interface{}{map[string]interface{}{"seen": true}, "not an object"}}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
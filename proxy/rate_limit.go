@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	rateLimitKey = "rate_limit"
+
+	// MemoryTokenBucketStoreName is the name of the default token bucket store
+	MemoryTokenBucketStoreName = "memory"
+)
+
+// RateLimitError is the error returned when the request exceeds the rate limit of the endpoint
+type RateLimitError struct {
+	Endpoint string
+}
+
+// Error returns a string representation of the RateLimitError
+func (r RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for the endpoint %s", r.Endpoint)
+}
+
+// StatusCode returns the status code to send to the client
+func (RateLimitError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+var errRateLimitMaxRate = errors.New("the max rate must be a number greater than 0")
+
+// RateLimitConfigError is the error returned by the endpoints with an invalid rate limit
+// config, so the endpoint is not left without limits because of a typo
+type RateLimitConfigError struct {
+	Endpoint string
+	Err      error
+}
+
+// Error returns a string representation of the RateLimitConfigError
+func (r RateLimitConfigError) Error() string {
+	return fmt.Sprintf("invalid rate limit for the endpoint %s: %s", r.Endpoint, r.Err.Error())
+}
+
+// Unwrap returns the error invalidating the rate limit config
+func (r RateLimitConfigError) Unwrap() error {
+	return r.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (RateLimitConfigError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// TokenBucketStore keeps the state of the token buckets used by the rate limiters. The
+// default store keeps them in memory, so every instance of the service applies its own
+// limits. A store shared by several instances (backed by Redis, for instance) coordinates
+// the limits across all of them.
+type TokenBucketStore interface {
+	// Take tries to remove a token from the bucket identified by the key. The bucket holds
+	// up to capacity tokens and it is refilled at the rate of tokens per second.
+	Take(ctx context.Context, key string, rate float64, capacity int64) (bool, error)
+}
+
+var tokenBucketStores = register.NewUntyped()
+
+func init() {
+	RegisterTokenBucketStore(MemoryTokenBucketStoreName, NewMemoryTokenBucketStore())
+}
+
+// RegisterTokenBucketStore registers the store under the received name, so the endpoints
+// can select it with the 'store' attribute of their rate limit config
+func RegisterTokenBucketStore(name string, s TokenBucketStore) {
+	tokenBucketStores.Register(name, s)
+}
+
+// GetTokenBucketStore returns the store registered under the received name
+func GetTokenBucketStore(name string) (TokenBucketStore, bool) {
+	v, ok := tokenBucketStores.Get(name)
+	if !ok {
+		return nil, false
+	}
+	s, ok := v.(TokenBucketStore)
+	return s, ok
+}
+
+// NewRateLimitMiddleware creates a proxy middleware limiting the rate of requests accepted
+// by the endpoint with a token bucket per client (identified by the optional request
+// attribute, see NewAttributeExtractor). The requests without tokens available are rejected
+// with a RateLimitError.
+//
+// The buckets are kept in the registered store selected by the config (the in-memory one,
+// by default). If the store fails, the request is accepted. If the max rate, the client spec
+// or the store of the config are invalid, all the requests are rejected with a
+// RateLimitConfigError.
+func NewRateLimitMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	return NewRateLimitMiddlewareWithStore(logger, endpointConfig, nil)
+}
+
+// NewRateLimitMiddlewareWithStore is like NewRateLimitMiddleware, but it uses the received
+// store instead of the one defined in the config. A nil store falls back to the config.
+func NewRateLimitMiddlewareWithStore(logger logging.Logger, endpointConfig *config.EndpointConfig, store TokenBucketStore) Middleware {
+	cfg, ok, err := getRateLimitConfig(endpointConfig.ExtraConfig)
+	if err == nil && ok && store == nil {
+		if store, ok = GetTokenBucketStore(cfg.Store); !ok {
+			err = fmt.Errorf("unknown token bucket store '%s'", cfg.Store)
+		}
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][RateLimit] %s. All the requests will be rejected", endpointConfig.Endpoint, err.Error()))
+		return rejectingRateLimitMiddleware(logger, RateLimitConfigError{Endpoint: endpointConfig.Endpoint, Err: err})
+	}
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][RateLimit] Accepting %.2f requests per second (capacity: %d, client: '%s', store: %s)",
+			endpointConfig.Endpoint,
+			cfg.Rate,
+			cfg.Capacity,
+			cfg.ClientSpec,
+			cfg.Store,
+		),
+	)
+
+	prefix := endpointConfig.Method + " " + endpointConfig.Endpoint
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewRateLimitMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			key := prefix
+			if cfg.Client != nil {
+				client, _ := cfg.Client(request)
+				key += " " + client
+			}
+			ok, err := store.Take(ctx, key, cfg.Rate, cfg.Capacity)
+			if err != nil {
+				logger.Error(fmt.Sprintf("[ENDPOINT: %s][RateLimit] %s", endpointConfig.Endpoint, err.Error()))
+				return next[0](ctx, request)
+			}
+			if !ok {
+				return nil, RateLimitError{Endpoint: endpointConfig.Endpoint}
+			}
+			return next[0](ctx, request)
+		}
+	}
+}
+
+func rejectingRateLimitMiddleware(logger logging.Logger, err error) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewRateLimitMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return nil, err
+		}
+	}
+}
+
+type rateLimitConfig struct {
+	Rate       float64
+	Capacity   int64
+	Store      string
+	ClientSpec string
+	Client     AttributeExtractor
+}
+
+func getRateLimitConfig(extra config.ExtraConfig) (rateLimitConfig, bool, error) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return rateLimitConfig{}, false, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return rateLimitConfig{}, false, nil
+	}
+	tmp, ok := e[rateLimitKey].(map[string]interface{})
+	if !ok {
+		return rateLimitConfig{}, false, nil
+	}
+	rate, ok := tmp["max_rate"].(float64)
+	if !ok || rate <= 0 {
+		return rateLimitConfig{}, false, errRateLimitMaxRate
+	}
+
+	cfg := rateLimitConfig{
+		Rate:     rate,
+		Capacity: int64(math.Ceil(rate)),
+		Store:    MemoryTokenBucketStoreName,
+	}
+	if capacity, ok := tmp["capacity"].(float64); ok && capacity >= 1 {
+		cfg.Capacity = int64(capacity)
+	}
+	if store, ok := tmp["store"].(string); ok && store != "" {
+		cfg.Store = store
+	}
+	if spec, ok := tmp["client"].(string); ok && spec != "" {
+		client, err := NewAttributeExtractor(spec)
+		if err != nil {
+			return rateLimitConfig{}, false, err
+		}
+		cfg.ClientSpec = spec
+		cfg.Client = client
+	}
+	return cfg, true, nil
+}
+
+// tokenBucketSweepInterval is the minimum time between two sweeps of the idle buckets
+const tokenBucketSweepInterval = time.Minute
+
+// NewMemoryTokenBucketStore returns a TokenBucketStore keeping the buckets in memory
+func NewMemoryTokenBucketStore() *MemoryTokenBucketStore {
+	return &MemoryTokenBucketStore{
+		mu:      &sync.Mutex{},
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+}
+
+// MemoryTokenBucketStore is the default TokenBucketStore. The buckets are local to the
+// service instance. The buckets idle long enough to be full again are removed periodically,
+// since they are equivalent to the ones created for the unknown keys, so the keys extracted
+// from the requests do not grow the store without bound.
+type MemoryTokenBucketStore struct {
+	mu        *sync.Mutex
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is the moment the bucket gets all its tokens back
+	full time.Time
+}
+
+// Take implements the TokenBucketStore interface
+func (m *MemoryTokenBucketStore) Take(_ context.Context, key string, rate float64, capacity int64) (bool, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= tokenBucketSweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(capacity), last: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(capacity), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, nil
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(capacity) - b.tokens) / rate * float64(time.Second)))
+	return true, nil
+}
+
+// sweep removes the buckets already full. It must be called with the lock held
+func (m *MemoryTokenBucketStore) sweep(now time.Time) {
+	for k, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, k)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// fakeDistributedStore emulates a store shared by several instances of the service,
+// keeping a single set of buckets behind a network-like boundary
+type fakeDistributedStore struct {
+	mu      *sync.Mutex
+	backend *MemoryTokenBucketStore
+	calls   map[string]int
+	err     error
+}
+
+func (f *fakeDistributedStore) Take(ctx context.Context, key string, rate float64, capacity int64) (bool, error) {
+	f.mu.Lock()
+	f.calls[key]++
+	f.mu.Unlock()
+	if f.err != nil {
+		return false, f.err
+	}
+	return f.backend.Take(ctx, key, rate, capacity)
+}
+
+func rateLimitEndpoint(extra map[string]interface{}) *config.EndpointConfig {
+	return &config.EndpointConfig{
+		Endpoint: "/foo",
+		Method:   "GET",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{rateLimitKey: extra},
+		},
+	}
+}
+
+func TestNewRateLimitMiddlewareWithStore_distributed(t *testing.T) {
+	now := time.Now()
+	backend := NewMemoryTokenBucketStore()
+	backend.now = func() time.Time { return now }
+	store := &fakeDistributedStore{mu: &sync.Mutex{}, backend: backend, calls: map[string]int{}}
+
+	cfg := rateLimitEndpoint(map[string]interface{}{
+		"max_rate": 1.0,
+		"capacity": 4.0,
+		"client":   "header:X-Api-Key",
+	})
+	next := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true}, nil
+	}
+	instances := []Proxy{
+		NewRateLimitMiddlewareWithStore(logging.NoOp, cfg, store)(next),
+		NewRateLimitMiddlewareWithStore(logging.NoOp, cfg, store)(next),
+	}
+
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		_, err := instances[i%2](context.Background(), &Request{
+			Headers: map[string][]string{"X-Api-Key": {"a"}},
+		})
+		if err == nil {
+			accepted++
+			continue
+		}
+		if rlErr, ok := err.(RateLimitError); !ok || rlErr.StatusCode() != http.StatusTooManyRequests {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if accepted != 4 {
+		t.Errorf("the instances should share the bucket. accepted: %d", accepted)
+	}
+	if calls := store.calls["GET /foo a"]; calls != 10 {
+		t.Errorf("unexpected number of calls to the store: %v", store.calls)
+	}
+
+	if _, err := instances[0](context.Background(), &Request{
+		Headers: map[string][]string{"X-Api-Key": {"b"}},
+	}); err != nil {
+		t.Errorf("the clients should not share buckets: %v", err)
+	}
+
+	now = now.Add(2 * time.Second)
+	accepted = 0
+	for i := 0; i < 4; i++ {
+		if _, err := instances[i%2](context.Background(), &Request{
+			Headers: map[string][]string{"X-Api-Key": {"a"}},
+		}); err == nil {
+			accepted++
+		}
+	}
+	if accepted != 2 {
+		t.Errorf("the bucket should be refilled with 2 tokens. accepted: %d", accepted)
+	}
+}
+
+func TestNewRateLimitMiddlewareWithStore_storeError(t *testing.T) {
+	store := &fakeDistributedStore{
+		mu:      &sync.Mutex{},
+		backend: NewMemoryTokenBucketStore(),
+		calls:   map[string]int{},
+		err:     errors.New("connection refused"),
+	}
+	p := NewRateLimitMiddlewareWithStore(logging.NoOp, rateLimitEndpoint(map[string]interface{}{
+		"max_rate": 1.0,
+	}), store)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := p(context.Background(), &Request{}); err != nil {
+			t.Errorf("the requests should be accepted when the store fails: %v", err)
+		}
+	}
+}
+
+func TestNewRateLimitMiddleware_registeredStore(t *testing.T) {
+	store := &fakeDistributedStore{mu: &sync.Mutex{}, backend: NewMemoryTokenBucketStore(), calls: map[string]int{}}
+	RegisterTokenBucketStore("fake", store)
+
+	p := NewRateLimitMiddleware(logging.NoOp, rateLimitEndpoint(map[string]interface{}{
+		"max_rate": 2.0,
+		"store":    "fake",
+	}))(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true}, nil
+	})
+
+	accepted := 0
+	for i := 0; i < 5; i++ {
+		if _, err := p(context.Background(), &Request{}); err == nil {
+			accepted++
+		}
+	}
+	if accepted != 2 {
+		t.Errorf("unexpected number of accepted requests: %d", accepted)
+	}
+	if calls := store.calls["GET /foo"]; calls != 5 {
+		t.Errorf("unexpected number of calls to the store: %v", store.calls)
+	}
+}
+
+func TestNewRateLimitMiddleware_invalid(t *testing.T) {
+	for i, limit := range []map[string]interface{}{
+		{"max_rate": 1.0, "store": "unknown"},
+		{"max_rate": 0.0},
+		{"max_rate": "10"},
+		{"capacity": 10.0},
+		{"max_rate": 1.0, "client": "cookie:session"},
+	} {
+		calls := 0
+		p := NewRateLimitMiddleware(logging.NoOp, rateLimitEndpoint(limit))(func(_ context.Context, _ *Request) (*Response, error) {
+			calls++
+			return &Response{IsComplete: true}, nil
+		})
+
+		resp, err := p(context.Background(), &Request{})
+		if resp != nil {
+			t.Errorf("#%d: unexpected response: %+v", i, resp)
+		}
+		rErr, ok := err.(RateLimitConfigError)
+		if !ok {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if rErr.StatusCode() != http.StatusInternalServerError || rErr.Endpoint != "/foo" {
+			t.Errorf("#%d: unexpected error: %+v", i, rErr)
+		}
+		if calls != 0 {
+			t.Errorf("#%d: the backend should not be called", i)
+		}
+	}
+}
+
+func TestMemoryTokenBucketStore_sweep(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryTokenBucketStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		store.Take(ctx, fmt.Sprintf("client-%d", i), 1, 10)
+	}
+	// drain the bucket of a client, so it needs 10s to be full again
+	for i := 0; i < 10; i++ {
+		store.Take(ctx, "busy", 1, 10)
+	}
+	if len(store.buckets) != 101 {
+		t.Errorf("unexpected number of buckets: %d", len(store.buckets))
+	}
+
+	// the buckets are not swept before the interval
+	now = now.Add(5 * time.Second)
+	store.Take(ctx, "other", 1, 10)
+	if len(store.buckets) != 102 {
+		t.Errorf("unexpected number of buckets: %d", len(store.buckets))
+	}
+
+	now = now.Add(tokenBucketSweepInterval)
+	if ok, _ := store.Take(ctx, "busy", 1, 10); !ok {
+		t.Error("the bucket should have been refilled")
+	}
+	if len(store.buckets) != 1 {
+		t.Errorf("the full buckets should be swept: %d", len(store.buckets))
+	}
+
+	// the buckets still refilling are kept
+	for i := 0; i < 10; i++ {
+		store.Take(ctx, "slow", 0.01, 10)
+	}
+	now = now.Add(tokenBucketSweepInterval)
+	store.Take(ctx, "other", 1, 10)
+	if _, ok := store.buckets["slow"]; !ok {
+		t.Error("the bucket should be kept")
+	}
+	if ok, _ := store.Take(ctx, "slow", 0.01, 10); ok {
+		t.Error("the bucket should still be empty")
+	}
+}
@@ -5,7 +5,10 @@ package proxy
 import (
 	"bytes"
 	"io"
+	"net/textproto"
 	"net/url"
+	"sort"
+	"strings"
 )
 
 // Request contains the data to send to the backend
@@ -44,7 +47,8 @@ func (r *Request) GeneratePath(URLPattern string) {
 func (r *Request) Clone() Request {
 	var clonedURL *url.URL
 	if r.URL != nil {
-		clonedURL, _ = url.Parse(r.URL.String())
+		u := *r.URL
+		clonedURL = &u
 	}
 	return Request{
 		Method:  r.Method,
@@ -76,13 +80,25 @@ func CloneRequest(r *Request) *Request {
 	return &clone
 }
 
-// CloneRequestHeaders returns a copy of the received request headers
+// CloneRequestHeaders returns a copy of the received request headers. All the values are
+// copied into a single backing slice, so the cost of the copy does not depend on the number
+// of headers.
 func CloneRequestHeaders(headers map[string][]string) map[string][]string {
 	m := make(map[string][]string, len(headers))
+	total := 0
+	for _, vs := range headers {
+		total += len(vs)
+	}
+	values := make([]string, total)
 	for k, vs := range headers {
-		tmp := make([]string, len(vs))
-		copy(tmp, vs)
-		m[k] = tmp
+		if vs == nil {
+			m[k] = nil
+			continue
+		}
+		n := copy(values, vs)
+		// limit the capacity, so appending to a header does not overwrite the next one
+		m[k] = values[:n:n]
+		values = values[n:]
 	}
 	return m
 }
@@ -95,3 +111,41 @@ func CloneRequestParams(params map[string]string) map[string]string {
 	}
 	return m
 }
+
+// identityHeaders are the request headers identifying the user. The keys of the components
+// sharing the responses between requests always include them
+var identityHeaders = []string{"Authorization", "Cookie"}
+
+// varyHeaders returns the canonical names of the listed headers along with the identity
+// headers. It returns nil if there are no listed headers, so the keys include all of them.
+func varyHeaders(v interface{}) []string {
+	vs, ok := v.([]interface{})
+	if !ok || len(vs) == 0 {
+		return nil
+	}
+	headers := append([]string{}, identityHeaders...)
+	for _, v := range vs {
+		if h, ok := v.(string); ok && h != "" {
+			headers = append(headers, textproto.CanonicalMIMEHeaderKey(h))
+		}
+	}
+	return headers
+}
+
+// writeHeadersKey writes the values of the named headers to the key. If names is nil, all
+// the headers are written, sorted by name.
+func writeHeadersKey(b *strings.Builder, headers map[string][]string, names []string) {
+	if names == nil {
+		names = make([]string, 0, len(headers))
+		for h := range headers {
+			names = append(names, h)
+		}
+		sort.Strings(names)
+	}
+	for _, h := range names {
+		b.WriteByte('\n')
+		b.WriteString(h)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(headers[h], ","))
+	}
+}
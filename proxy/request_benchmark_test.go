@@ -2,7 +2,10 @@
 
 package proxy
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func BenchmarkRequestGeneratePath(b *testing.B) {
 	r := Request{
@@ -29,3 +32,15 @@ func BenchmarkRequestGeneratePath(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkCloneRequestHeaders(b *testing.B) {
+	headers := make(map[string][]string, 30)
+	for i := 0; i < 30; i++ {
+		headers[fmt.Sprintf("X-Header-%d", i)] = []string{"some value"}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CloneRequestHeaders(headers)
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// rewriteJSONBody decodes the body of the request as a JSON object, applies the modifier
+// and replaces the body (and its Content-Length header) with the encoded result.
+// Requests without body or with a body not containing a JSON object are left untouched.
+// The headers are cloned before updating them, so the outer layers are not affected.
+func rewriteJSONBody(r *Request, modifier func(map[string]interface{}) error) error {
+	if r.Body == nil {
+		return nil
+	}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var data map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&data); err != nil || data == nil {
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		return nil
+	}
+
+	if err := modifier(data); err != nil {
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		return err
+	}
+
+	if b, err = json.Marshal(data); err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.Headers = CloneRequestHeaders(r.Headers)
+	r.Headers["Content-Length"] = []string{strconv.Itoa(len(b))}
+	return nil
+}
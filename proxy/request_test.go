@@ -5,6 +5,7 @@ package proxy
 import (
 	"bytes"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -147,3 +148,40 @@ func TestCloneRequest(t *testing.T) {
 		t.Errorf("unexpected bodies. original: %s, returned: %s", string(rb), string(cb))
 	}
 }
+
+func TestCloneRequestHeaders(t *testing.T) {
+	headers := map[string][]string{
+		"Accept":       {"application/json", "text/plain"},
+		"Content-Type": {"application/json"},
+		"X-Empty":      {},
+		"X-Nil":        nil,
+	}
+	clone := CloneRequestHeaders(headers)
+
+	if len(clone) != len(headers) {
+		t.Errorf("wrong num of headers. have: %d, want: %d", len(clone), len(headers))
+		return
+	}
+	if !reflect.DeepEqual(clone, headers) {
+		t.Errorf("unexpected clone: %v", clone)
+	}
+
+	clone["Accept"][0] = "application/xml"
+	clone["Accept"] = append(clone["Accept"], "text/html")
+	clone["X-Empty"] = append(clone["X-Empty"], "foo")
+	if headers["Accept"][0] != "application/json" || len(headers["Accept"]) != 2 {
+		t.Errorf("the original header has been modified: %v", headers["Accept"])
+	}
+	if len(headers["X-Empty"]) != 0 {
+		t.Errorf("the original header has been modified: %v", headers["X-Empty"])
+	}
+	for k, expected := range map[string][]string{
+		"Accept":       {"application/xml", "text/plain", "text/html"},
+		"Content-Type": {"application/json"},
+		"X-Empty":      {"foo"},
+	} {
+		if !reflect.DeepEqual(clone[k], expected) {
+			t.Errorf("unexpected header %s. have: %v, want: %v", k, clone[k], expected)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/random"
+)
+
+type requestTraceKey struct{}
+
+// BackendTrace is the record of a request sent to a backend
+type BackendTrace struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Duration   time.Duration
+	Error      string
+}
+
+// RequestTrace keeps a record of the requests sent to the backends reached with a context
+// containing it and the random decisions taken for them, so the routers can expose them
+// when debugging a request
+type RequestTrace struct {
+	backends  []BackendTrace
+	decisions *random.Decisions
+	mu        *sync.Mutex
+}
+
+// NewRequestTrace returns an empty RequestTrace
+func NewRequestTrace() *RequestTrace {
+	return &RequestTrace{decisions: random.NewDecisions(), mu: new(sync.Mutex)}
+}
+
+// WithContext returns a copy of the context containing the trace and its random decisions
+func (t *RequestTrace) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(t.decisions.WithContext(ctx), requestTraceKey{}, t)
+}
+
+// Add stores the record of a request sent to a backend
+func (t *RequestTrace) Add(b BackendTrace) {
+	t.mu.Lock()
+	t.backends = append(t.backends, b)
+	t.mu.Unlock()
+}
+
+// Backends returns the records of the requests sent to the backends
+func (t *RequestTrace) Backends() []BackendTrace {
+	t.mu.Lock()
+	res := make([]BackendTrace, len(t.backends))
+	copy(res, t.backends)
+	t.mu.Unlock()
+	return res
+}
+
+// Decisions returns the records of the random draws taken with a random.NewDecisionLog
+func (t *RequestTrace) Decisions() []string {
+	return t.decisions.Entries()
+}
+
+func traceBackendRequest(ctx context.Context, req *http.Request, resp *http.Response, err error, start time.Time) {
+	t, ok := ctx.Value(requestTraceKey{}).(*RequestTrace)
+	if !ok {
+		return
+	}
+	b := BackendTrace{
+		Method:   req.Method,
+		URL:      req.URL.Redacted(),
+		Duration: time.Since(start),
+	}
+	if resp != nil {
+		b.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		b.Error = err.Error()
+	}
+	t.Add(b)
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type responseHeadersCollectorKey struct{}
+
+// responseHeadersCollector keeps a copy of a set of headers returned by the backends
+// reached with a context containing it. It allows the upper layers to consume response
+// headers even when the response parser drops them.
+type responseHeadersCollector struct {
+	names   []string
+	headers map[string][]string
+	mu      *sync.Mutex
+}
+
+func newResponseHeadersCollector(names []string) *responseHeadersCollector {
+	return &responseHeadersCollector{
+		names:   names,
+		headers: map[string][]string{},
+		mu:      new(sync.Mutex),
+	}
+}
+
+// WithContext returns a copy of the context containing the collector
+func (c *responseHeadersCollector) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, responseHeadersCollectorKey{}, c)
+}
+
+// Collect stores a copy of the values of the tracked headers
+func (c *responseHeadersCollector) Collect(h http.Header) {
+	c.mu.Lock()
+	for _, name := range c.names {
+		vs, ok := h[name]
+		if !ok {
+			continue
+		}
+		tmp := make([]string, len(vs))
+		copy(tmp, vs)
+		c.headers[name] = tmp
+	}
+	c.mu.Unlock()
+}
+
+// Headers returns the collected headers
+func (c *responseHeadersCollector) Headers() map[string][]string {
+	c.mu.Lock()
+	res := make(map[string][]string, len(c.headers))
+	for k, vs := range c.headers {
+		res[k] = vs
+	}
+	c.mu.Unlock()
+	return res
+}
+
+func collectResponseHeaders(ctx context.Context, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	if c, ok := ctx.Value(responseHeadersCollectorKey{}).(*responseHeadersCollector); ok {
+		c.Collect(resp.Header)
+	}
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	secretHeadersKey = "secret_headers"
+
+	// EnvSecretsProvider is the name of the provider reading the secrets from the environment
+	EnvSecretsProvider = "env"
+	// FileSecretsProvider is the name of the provider reading the secrets from the files at
+	// the paths used as names
+	FileSecretsProvider = "file"
+
+	defaultSecretsRefresh = time.Minute
+	minSecretsRetryDelay  = 100 * time.Millisecond
+)
+
+// SecretsProvider returns the current value of the named secrets
+type SecretsProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretsProviderFunc is a function implementing the SecretsProvider interface
+type SecretsProviderFunc func(context.Context, string) (string, error)
+
+// Secret implements the SecretsProvider interface
+func (f SecretsProviderFunc) Secret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+var secretsProviders = register.NewUntyped()
+
+func init() {
+	RegisterSecretsProvider(EnvSecretsProvider, SecretsProviderFunc(envSecret))
+	RegisterSecretsProvider(FileSecretsProvider, SecretsProviderFunc(fileSecret))
+}
+
+// RegisterSecretsProvider makes the provider available for the backends declaring secret
+// headers. Registering a provider under an existing name replaces it.
+func RegisterSecretsProvider(name string, p SecretsProvider) {
+	secretsProviders.Register(name, p)
+}
+
+func getSecretsProvider(name string) (SecretsProvider, bool) {
+	v, ok := secretsProviders.Get(name)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(SecretsProvider)
+	return p, ok
+}
+
+func envSecret(_ context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("the environment variable %s is not defined", name)
+	}
+	return v, nil
+}
+
+func fileSecret(_ context.Context, name string) (string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// NewSecretHeadersMiddleware creates a proxy middleware adding to the requests sent to the
+// backend the headers defined in its extra config, with the values of the secrets returned
+// by the selected provider:
+//
+//	"secret_headers": {
+//		"provider": "file",
+//		"refresh": "5m",
+//		"headers": { "X-Api-Key": "/run/secrets/api_key" }
+//	}
+//
+// The secrets are loaded in the background as soon as the backend is built and refreshed
+// every refresh interval (one minute by default), so the requests never pay for the calls
+// to the provider and the rotated values are picked up within an interval. If a secret can
+// not be refreshed, its last value is kept. The secrets never loaded are retried sooner.
+func NewSecretHeadersMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	cfg, ok := getSecretHeadersConfig(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][SecretHeaders]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	provider, ok := getSecretsProvider(cfg.Provider)
+	if !ok {
+		logger.Error(logPrefix, fmt.Sprintf("unknown secrets provider '%s'", cfg.Provider))
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(fmt.Sprintf("%s Adding %d headers from the '%s' secrets every %s", logPrefix, len(cfg.Headers), cfg.Provider, cfg.Refresh))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewSecretHeadersMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		s := &secretHeaders{
+			provider: provider,
+			headers:  cfg.Headers,
+			refresh:  cfg.Refresh,
+			logger:   logger,
+			prefix:   logPrefix,
+			ready:    make(chan struct{}),
+		}
+		s.start()
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			values := s.Values(ctx)
+			if len(values) == 0 {
+				return next[0](ctx, request)
+			}
+
+			r := request.Clone()
+			r.Headers = make(map[string][]string, len(request.Headers)+len(values))
+			for k, vs := range request.Headers {
+				r.Headers[k] = vs
+			}
+			for k, v := range values {
+				r.Headers[k] = []string{v}
+			}
+			return next[0](ctx, &r)
+		}
+	}
+}
+
+type secretHeadersConfig struct {
+	Provider string
+	Refresh  time.Duration
+	Headers  map[string]string
+}
+
+type secretHeaders struct {
+	provider SecretsProvider
+	headers  map[string]string
+	refresh  time.Duration
+	logger   logging.Logger
+	prefix   string
+	values   atomic.Value
+	ready    chan struct{}
+}
+
+// start loads the secrets in the background right away and then every refresh interval.
+// While any of them has never been loaded, the next attempt comes sooner: the delay starts
+// at minSecretsRetryDelay and doubles up to the refresh interval.
+func (s *secretHeaders) start() {
+	go func() {
+		retry := minSecretsRetryDelay
+		for first := true; ; first = false {
+			missing := s.load()
+			if first {
+				close(s.ready)
+			}
+			delay := s.refresh
+			if missing && retry < delay {
+				delay = retry
+				retry *= 2
+			}
+			time.Sleep(delay)
+		}
+	}()
+}
+
+// Values returns the current values of the headers. The requests arriving before the end of
+// the first load wait for it, as long as their context allows.
+func (s *secretHeaders) Values(ctx context.Context) map[string]string {
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+	}
+	values, _ := s.values.Load().(map[string]string)
+	return values
+}
+
+// load fetches all the secrets with its own context, bounded by the refresh interval, and
+// keeps the last value of the ones failing. It reports if any header is left without value.
+func (s *secretHeaders) load() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), s.refresh)
+	defer cancel()
+
+	previous, _ := s.values.Load().(map[string]string)
+	values := make(map[string]string, len(s.headers))
+	for header, name := range s.headers {
+		v, err := s.provider.Secret(ctx, name)
+		if err != nil {
+			s.logger.Error(s.prefix, fmt.Sprintf("unable to get the secret for the header %s: %s", header, err.Error()))
+			if old, ok := previous[header]; ok {
+				values[header] = old
+			}
+			continue
+		}
+		values[header] = v
+	}
+	s.values.Store(values)
+	return len(values) < len(s.headers)
+}
+
+func getSecretHeadersConfig(extra config.ExtraConfig) (secretHeadersConfig, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return secretHeadersConfig{}, false
+	}
+	tmp, ok := e[secretHeadersKey].(map[string]interface{})
+	if !ok {
+		return secretHeadersConfig{}, false
+	}
+	headers, ok := tmp["headers"].(map[string]interface{})
+	if !ok || len(headers) == 0 {
+		return secretHeadersConfig{}, false
+	}
+
+	cfg := secretHeadersConfig{
+		Provider: EnvSecretsProvider,
+		Refresh:  defaultSecretsRefresh,
+		Headers:  make(map[string]string, len(headers)),
+	}
+	if p, ok := tmp["provider"].(string); ok && p != "" {
+		cfg.Provider = p
+	}
+	if r, ok := tmp["refresh"].(string); ok {
+		if d, err := time.ParseDuration(r); err == nil && d > 0 {
+			cfg.Refresh = d
+		}
+	}
+	for k, v := range headers {
+		if name, ok := v.(string); ok && name != "" {
+			cfg.Headers[textproto.CanonicalMIMEHeaderKey(k)] = name
+		}
+	}
+	return cfg, len(cfg.Headers) > 0
+}
@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type rotatingSecretsProvider struct {
+	mu    *sync.Mutex
+	value string
+	err   error
+	calls int
+}
+
+func (p *rotatingSecretsProvider) Secret(_ context.Context, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if name != "api-key" {
+		return "", errors.New("unknown secret")
+	}
+	return p.value, p.err
+}
+
+func (p *rotatingSecretsProvider) set(value string, err error) {
+	p.mu.Lock()
+	p.value, p.err = value, err
+	p.mu.Unlock()
+}
+
+func TestNewSecretHeadersMiddleware(t *testing.T) {
+	provider := &rotatingSecretsProvider{mu: new(sync.Mutex), value: "first"}
+	RegisterSecretsProvider("rotating", provider)
+
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				secretHeadersKey: map[string]interface{}{
+					"provider": "rotating",
+					"refresh":  "10ms",
+					"headers":  map[string]interface{}{"x-api-key": "api-key"},
+				},
+			},
+		},
+	}
+
+	received := make(chan string, 100)
+	p := NewSecretHeadersMiddleware(logging.NoOp, remote)(func(_ context.Context, r *Request) (*Response, error) {
+		if r.Headers["X-Original"][0] != "foo" {
+			t.Errorf("the headers of the request should be preserved: %v", r.Headers)
+		}
+		received <- r.Headers["X-Api-Key"][0]
+		return &Response{IsComplete: true}, nil
+	})
+
+	request := &Request{Headers: map[string][]string{"X-Original": {"foo"}}}
+	call := func() string {
+		if _, err := p(context.Background(), request); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+		return <-received
+	}
+
+	if v := call(); v != "first" {
+		t.Errorf("unexpected header value: %s", v)
+	}
+	if v := call(); v != "first" {
+		t.Errorf("unexpected header value: %s", v)
+	}
+	if _, ok := request.Headers["X-Api-Key"]; ok {
+		t.Error("the headers of the received request should not be modified")
+	}
+
+	provider.set("second", nil)
+	if !waitForSecret(call, "second") {
+		t.Error("the rotated secret should be injected")
+	}
+
+	// the last value is kept if the secret can not be refreshed
+	provider.set("", errors.New("provider not available"))
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if v := call(); v != "second" {
+			t.Errorf("the last value should be kept: %s", v)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	provider.set("third", nil)
+	if !waitForSecret(call, "third") {
+		t.Error("the rotated secret should be injected")
+	}
+}
+
+func waitForSecret(call func() string, expected string) bool {
+	for i := 0; i < 100; i++ {
+		if call() == expected {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestNewSecretHeadersMiddleware_defaultProviders(t *testing.T) {
+	os.Setenv("LURA_TEST_SECRET", "from-env")
+	defer os.Unsetenv("LURA_TEST_SECRET")
+
+	secretFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, tc := range []struct {
+		provider string
+		name     string
+		expected string
+	}{
+		{provider: "", name: "LURA_TEST_SECRET", expected: "from-env"},
+		{provider: EnvSecretsProvider, name: "LURA_TEST_SECRET", expected: "from-env"},
+		{provider: FileSecretsProvider, name: secretFile, expected: "from-file"},
+	} {
+		remote := &config.Backend{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{
+					secretHeadersKey: map[string]interface{}{
+						"provider": tc.provider,
+						"headers":  map[string]interface{}{"Authorization": tc.name},
+					},
+				},
+			},
+		}
+		var header string
+		p := NewSecretHeadersMiddleware(logging.NoOp, remote)(func(_ context.Context, r *Request) (*Response, error) {
+			header = r.Headers["Authorization"][0]
+			return &Response{IsComplete: true}, nil
+		})
+		if _, err := p(context.Background(), &Request{}); err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.provider, err.Error())
+		}
+		if header != tc.expected {
+			t.Errorf("%s: unexpected header value: %s", tc.provider, header)
+		}
+	}
+}
+
+func TestNewSecretHeadersMiddleware_missingSecret(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				secretHeadersKey: map[string]interface{}{
+					"headers": map[string]interface{}{"Authorization": "LURA_TEST_UNDEFINED_SECRET"},
+				},
+			},
+		},
+	}
+	p := NewSecretHeadersMiddleware(logging.NoOp, remote)(func(_ context.Context, r *Request) (*Response, error) {
+		if _, ok := r.Headers["Authorization"]; ok {
+			t.Errorf("unexpected header: %v", r.Headers)
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestNewSecretHeadersMiddleware_retryFirstLoad(t *testing.T) {
+	provider := &rotatingSecretsProvider{mu: new(sync.Mutex), err: errors.New("provider not ready")}
+	RegisterSecretsProvider("not-ready", provider)
+
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				secretHeadersKey: map[string]interface{}{
+					"provider": "not-ready",
+					"refresh":  "1h",
+					"headers":  map[string]interface{}{"X-Api-Key": "api-key"},
+				},
+			},
+		},
+	}
+
+	received := make(chan string, 100)
+	p := NewSecretHeadersMiddleware(logging.NoOp, remote)(func(_ context.Context, r *Request) (*Response, error) {
+		v := ""
+		if vs := r.Headers["X-Api-Key"]; len(vs) > 0 {
+			v = vs[0]
+		}
+		received <- v
+		return &Response{IsComplete: true}, nil
+	})
+	call := func() string {
+		p(context.Background(), &Request{})
+		return <-received
+	}
+
+	if v := call(); v != "" {
+		t.Errorf("unexpected header value: %s", v)
+	}
+	provider.set("recovered", nil)
+	for i := 0; i < 100; i++ {
+		if call() == "recovered" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the failed first load should be retried before the refresh interval")
+}
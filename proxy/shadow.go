@@ -7,21 +7,28 @@ import (
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/feature"
 	"github.com/luraproject/lura/v2/logging"
 )
 
 const (
 	shadowKey        = "shadow"
 	shadowTimeoutKey = "shadow_timeout"
+
+	// ShadowFeatureFlag is the name of the feature flag controlling the shadow traffic.
+	// The shadow backends receive requests unless the flag is disabled.
+	ShadowFeatureFlag = "shadow"
 )
 
 type shadowFactory struct {
-	f Factory
+	f      Factory
+	logger logging.Logger
 }
 
 // New check the Backends for an ExtraConfig with the "shadow" param to true
 // implements the Factory interface. Sets the "shadow_timeout" defined in the
-// config; uses the backend timeout as fallback.
+// config; uses the backend timeout as fallback. The responses of the shadow backends
+// are compared with the primary ones if any of them enables the "shadow_compare" mode.
 func (s shadowFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 	if len(cfg.Backend) == 0 {
 		err = ErrNoBackends
@@ -48,7 +55,11 @@ func (s shadowFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 	if len(shadow) > 0 {
 		cfg.Backend = shadow
 		pShadow, _ := s.f.New(cfg)
-		p = ShadowMiddlewareWithTimeout(maxTimeout, p, pShadow)
+		if ignore, ok := getShadowCompareConfig(shadow); ok {
+			p = NewShadowCompareProxy(s.logger, cfg.Endpoint, maxTimeout, ignore, p, pShadow)
+		} else {
+			p = ShadowMiddlewareWithTimeout(maxTimeout, p, pShadow)
+		}
 	}
 
 	return
@@ -56,7 +67,13 @@ func (s shadowFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 
 // NewShadowFactory creates a new shadowFactory using the provided Factory
 func NewShadowFactory(f Factory) Factory {
-	return shadowFactory{f}
+	return NewShadowFactoryWithLogger(logging.NoOp, f)
+}
+
+// NewShadowFactoryWithLogger creates a new shadowFactory using the provided Factory and
+// logging the differences found by the shadow compare mode with the injected logger
+func NewShadowFactoryWithLogger(logger logging.Logger, f Factory) Factory {
+	return shadowFactory{f: f, logger: logger}
 }
 
 // ShadowMiddlewareWithLogger is a Middleware that creates a shadowProxy
@@ -108,9 +125,13 @@ func NewShadowProxy(p1, p2 Proxy) Proxy {
 }
 
 // NewShadowProxyWithTimeout returns a Proxy that sends requests to p1 and p2 but ignores
-// the response of p2. Sets a timeout in the context.
+// the response of p2. Sets a timeout in the context. The requests are not sent to p2
+// while the ShadowFeatureFlag is disabled.
 func NewShadowProxyWithTimeout(timeout time.Duration, p1, p2 Proxy) Proxy {
 	return func(ctx context.Context, request *Request) (*Response, error) {
+		if !feature.Enabled(ShadowFeatureFlag, true) {
+			return p1(ctx, request)
+		}
 		shadowCtx, cancel := newContextWrapperWithTimeout(ctx, timeout)
 		shadowRequest := CloneRequest(request)
 		go func() {
@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/feature"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	shadowCompareKey = "shadow_compare"
+
+	// ShadowComparisonsCounter is the name of the counter tracking the comparisons between the
+	// primary and the shadow responses, labeled with their result (match or mismatch)
+	ShadowComparisonsCounter = "proxy.shadow.compared"
+	// ShadowMismatchesCounter is the name of the counter tracking the fields differing between
+	// the primary and the shadow responses
+	ShadowMismatchesCounter = "proxy.shadow.mismatches"
+
+	// maxShadowMismatchFields is the number of distinct fields an endpoint reports in the
+	// field label of the mismatches counter. The rest of them are reported as 'other'.
+	maxShadowMismatchFields = 100
+)
+
+// NewShadowCompareProxy returns a Proxy that sends requests to p1 and p2, returning the
+// response of p1. Once both responses are available, they are compared in the background
+// and the differences are logged and reported to the default events recorder, so the
+// shadow backends can be validated before migrating the traffic to them.
+//
+// The differences are reported as the dot separated paths of the fields, without the
+// indexes of the arrays, and the fields matching (or nested into) the ignored paths are
+// skipped. The numbers are compared with all their digits, so the large identifiers do not
+// match just because they round to the same float. The status codes and the errors of both
+// responses are compared too. Every endpoint reports up to maxShadowMismatchFields distinct
+// fields to the mismatches counter, so the responses keyed by ids can not blow up the
+// cardinality of its labels. The shadow backends enable this mode with the 'shadow_compare'
+// key of their extra config:
+//
+//	"shadow": true,
+//	"shadow_compare": { "ignore": [ "updated_at", "items.request_id" ] }
+func NewShadowCompareProxy(logger logging.Logger, endpoint string, timeout time.Duration, ignore []string, p1, p2 Proxy) Proxy {
+	ignored := make(map[string]struct{}, len(ignore))
+	for _, path := range ignore {
+		ignored[path] = struct{}{}
+	}
+	fields := &shadowFields{mu: new(sync.Mutex), seen: map[string]struct{}{}}
+
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		if !feature.Enabled(ShadowFeatureFlag, true) {
+			return p1(ctx, request)
+		}
+		shadowCtx, cancel := newContextWrapperWithTimeout(ctx, timeout)
+		shadowRequest := CloneRequest(request)
+		primary := make(chan shadowResult, 1)
+		go func() {
+			resp, err := p2(shadowCtx, shadowRequest)
+			shadow := newShadowResult(resp, err)
+			cancel()
+
+			diff := diffShadowResults(<-primary, shadow, ignored)
+			reportShadowComparison(logger, endpoint, fields, diff)
+		}()
+
+		resp, err := p1(ctx, request)
+		// the snapshot is taken before the outer layers can modify the response
+		primary <- newShadowResult(resp, err)
+		return resp, err
+	}
+}
+
+// shadowResult is a snapshot of the result of a proxy, normalized for its comparison
+type shadowResult struct {
+	data       interface{}
+	statusCode int
+	err        string
+}
+
+func newShadowResult(resp *Response, err error) shadowResult {
+	res := shadowResult{}
+	if err != nil {
+		res.err = err.Error()
+	}
+	if resp == nil {
+		return res
+	}
+	res.statusCode = resp.Metadata.StatusCode
+	// encoding the data normalizes the types of the values to the ones of a decoded JSON,
+	// keeping the numbers as they are written
+	if b, err := json.Marshal(resp.Data); err == nil {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		d.Decode(&res.data)
+	}
+	return res
+}
+
+// shadowFields keeps the fields already reported by an endpoint, bounding the values of the
+// field label of the mismatches counter
+type shadowFields struct {
+	mu   *sync.Mutex
+	seen map[string]struct{}
+}
+
+// label returns the field itself while the endpoint has room for new fields, and 'other'
+// after that
+func (s *shadowFields) label(field string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[field]; ok {
+		return field
+	}
+	if len(s.seen) >= maxShadowMismatchFields {
+		return "other"
+	}
+	s.seen[field] = struct{}{}
+	return field
+}
+
+// diffShadowResults returns the sorted list of the paths differing between both results. The
+// data is not compared if only one of them failed
+func diffShadowResults(primary, shadow shadowResult, ignored map[string]struct{}) []string {
+	if (primary.err == "") != (shadow.err == "") {
+		return []string{"error"}
+	}
+	diff := map[string]struct{}{}
+	if primary.statusCode != shadow.statusCode {
+		diff["status"] = struct{}{}
+	}
+	diffValues(primary.data, shadow.data, nil, ignored, diff)
+
+	res := make([]string, 0, len(diff))
+	for path := range diff {
+		res = append(res, path)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func diffValues(a, b interface{}, path []string, ignored, diff map[string]struct{}) {
+	p := strings.Join(path, ".")
+	if isIgnoredPath(path, ignored) {
+		return
+	}
+
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for k, v := range va {
+			child := append(path[:len(path):len(path)], k)
+			w, ok := vb[k]
+			if !ok {
+				if !isIgnoredPath(child, ignored) {
+					diff[strings.Join(child, ".")] = struct{}{}
+				}
+				continue
+			}
+			diffValues(v, w, child, ignored, diff)
+		}
+		for k := range vb {
+			if _, ok := va[k]; ok {
+				continue
+			}
+			child := append(path[:len(path):len(path)], k)
+			if !isIgnoredPath(child, ignored) {
+				diff[strings.Join(child, ".")] = struct{}{}
+			}
+		}
+		return
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			break
+		}
+		for i := range va {
+			diffValues(va[i], vb[i], path, ignored, diff)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		if p == "" {
+			p = "."
+		}
+		diff[p] = struct{}{}
+	}
+}
+
+// isIgnoredPath reports if the path, or any of its parents, is ignored
+func isIgnoredPath(path []string, ignored map[string]struct{}) bool {
+	for i := 1; i <= len(path); i++ {
+		if _, ok := ignored[strings.Join(path[:i], ".")]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func reportShadowComparison(logger logging.Logger, endpoint string, fields *shadowFields, diff []string) {
+	recorder := events.DefaultRecorder()
+	if len(diff) == 0 {
+		recorder.Counter(ShadowComparisonsCounter, 1, map[string]string{"endpoint": endpoint, "result": "match"})
+		return
+	}
+	for _, field := range diff {
+		recorder.Counter(ShadowMismatchesCounter, 1, map[string]string{"endpoint": endpoint, "field": fields.label(field)})
+	}
+	recorder.Counter(ShadowComparisonsCounter, 1, map[string]string{"endpoint": endpoint, "result": "mismatch"})
+	logger.Warning(fmt.Sprintf("[ENDPOINT: %s][ShadowCompare] The shadow response differs at %v", endpoint, diff))
+}
+
+// getShadowCompareConfig returns the paths to ignore when comparing the responses, and a
+// flag signaling if any of the shadow backends enables the compare mode
+func getShadowCompareConfig(backends []*config.Backend) ([]string, bool) {
+	var ignore []string
+	enabled := false
+	for _, b := range backends {
+		e, ok := b.ExtraConfig[Namespace].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch v := e[shadowCompareKey].(type) {
+		case bool:
+			enabled = enabled || v
+		case map[string]interface{}:
+			enabled = true
+			ignore = append(ignore, stringList(v["ignore"])...)
+		}
+	}
+	return ignore, enabled
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type shadowRecorder struct {
+	mu         *sync.Mutex
+	results    []string
+	mismatches []string
+	done       chan struct{}
+}
+
+func (r *shadowRecorder) Counter(name string, _ int64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch name {
+	case ShadowMismatchesCounter:
+		r.mismatches = append(r.mismatches, labels["field"])
+	case ShadowComparisonsCounter:
+		r.results = append(r.results, labels["endpoint"]+" "+labels["result"])
+		r.done <- struct{}{}
+	}
+}
+
+func (*shadowRecorder) Gauge(_ string, _ int64, _ map[string]string) {}
+
+func TestNewShadowCompareProxy(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		primary    map[string]interface{}
+		shadow     map[string]interface{}
+		shadowErr  error
+		status     int
+		result     string
+		mismatches []string
+	}{
+		{
+			name:    "match",
+			primary: map[string]interface{}{"id": 42, "name": "foo", "items": []interface{}{map[string]interface{}{"id": 1.0, "request_id": "a"}}},
+			shadow:  map[string]interface{}{"id": 42.0, "name": "foo", "items": []interface{}{map[string]interface{}{"id": 1, "request_id": "b"}}},
+			result:  "/users match",
+		},
+		{
+			name:    "ignored",
+			primary: map[string]interface{}{"id": 42, "updated_at": "yesterday", "meta": map[string]interface{}{"host": "a"}},
+			shadow:  map[string]interface{}{"id": 42, "meta": map[string]interface{}{"host": "b", "region": "eu"}},
+			result:  "/users match",
+		},
+		{
+			name:       "mismatch",
+			primary:    map[string]interface{}{"id": 42, "name": "foo", "age": 20, "tags": []interface{}{"a", "b"}},
+			shadow:     map[string]interface{}{"id": 42, "name": "bar", "email": "x@example.com", "tags": []interface{}{"a"}},
+			status:     404,
+			result:     "/users mismatch",
+			mismatches: []string{"age", "email", "name", "status", "tags"},
+		},
+		{
+			name:       "nested mismatch",
+			primary:    map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}}},
+			shadow:     map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 3}}},
+			result:     "/users mismatch",
+			mismatches: []string{"items.id"},
+		},
+		{
+			name:       "large numbers",
+			primary:    map[string]interface{}{"id": int64(9007199254740993)},
+			shadow:     map[string]interface{}{"id": int64(9007199254740992)},
+			result:     "/users mismatch",
+			mismatches: []string{"id"},
+		},
+		{
+			name:       "errored shadow",
+			primary:    map[string]interface{}{"id": 42},
+			shadowErr:  errors.New("boom"),
+			result:     "/users mismatch",
+			mismatches: []string{"error"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := &shadowRecorder{mu: new(sync.Mutex), done: make(chan struct{}, 1)}
+			events.SetDefaultRecorder(recorder)
+			defer events.SetDefaultRecorder(nil)
+
+			buff := new(bytes.Buffer)
+			logger, _ := logging.NewLogger("WARNING", buff, "")
+			primary := func(_ context.Context, _ *Request) (*Response, error) {
+				return &Response{IsComplete: true, Data: tc.primary, Metadata: Metadata{StatusCode: 200}}, nil
+			}
+			shadow := func(_ context.Context, _ *Request) (*Response, error) {
+				if tc.shadowErr != nil {
+					return nil, tc.shadowErr
+				}
+				status := 200
+				if tc.status != 0 {
+					status = tc.status
+				}
+				return &Response{IsComplete: true, Data: tc.shadow, Metadata: Metadata{StatusCode: status}}, nil
+			}
+			p := NewShadowCompareProxy(logger, "/users", time.Second, []string{"updated_at", "meta", "items.request_id"}, primary, shadow)
+
+			resp, err := p(context.Background(), &Request{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(resp.Data, tc.primary) {
+				t.Errorf("unexpected response: %v", resp.Data)
+			}
+			// the outer layers can modify the returned response
+			resp.Data["id"] = "modified"
+
+			select {
+			case <-recorder.done:
+			case <-time.After(time.Second):
+				t.Error("the comparison was not reported")
+				return
+			}
+
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			if !reflect.DeepEqual(recorder.results, []string{tc.result}) {
+				t.Errorf("unexpected results: %v", recorder.results)
+			}
+			if !reflect.DeepEqual(recorder.mismatches, tc.mismatches) {
+				t.Errorf("unexpected mismatches: %v", recorder.mismatches)
+			}
+		})
+	}
+}
+
+func TestShadowFields_label(t *testing.T) {
+	fields := &shadowFields{mu: new(sync.Mutex), seen: map[string]struct{}{}}
+	for i := 0; i < maxShadowMismatchFields; i++ {
+		if field := fmt.Sprintf("users.%d", i); fields.label(field) != field {
+			t.Errorf("#%d: the field should be reported", i)
+		}
+	}
+	if label := fields.label("users.extra"); label != "other" {
+		t.Errorf("unexpected label: %s", label)
+	}
+	if label := fields.label("users.0"); label != "users.0" {
+		t.Errorf("the fields already reported should keep their label: %s", label)
+	}
+}
+
+func TestNewShadowFactory_compare(t *testing.T) {
+	recorder := &shadowRecorder{mu: new(sync.Mutex), done: make(chan struct{}, 1)}
+	events.SetDefaultRecorder(recorder)
+	defer events.SetDefaultRecorder(nil)
+
+	buff := new(bytes.Buffer)
+	logger, _ := logging.NewLogger("WARNING", buff, "")
+
+	shadowBackend := &config.Backend{
+		URLPattern: "/shadow",
+		Host:       []string{"http://shadow.example.com"},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"shadow":         true,
+				"shadow_compare": map[string]interface{}{"ignore": []interface{}{"host"}},
+			},
+		},
+	}
+	endpointConfig := &config.EndpointConfig{
+		Endpoint: "/users",
+		Timeout:  time.Second,
+		Backend:  []*config.Backend{shadowBackend, {URLPattern: "/primary", Host: []string{"http://primary.example.com"}}},
+	}
+	factory := NewDefaultFactory(func(b *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return &Response{IsComplete: true, Data: map[string]interface{}{"id": 42, "host": b.URLPattern}}, nil
+		}
+	}, logger)
+
+	p, err := NewShadowFactoryWithLogger(logger, factory).New(endpointConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.Data["host"] != "/primary" {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+
+	select {
+	case <-recorder.done:
+	case <-time.After(time.Second):
+		t.Error("the comparison was not reported as a match")
+	}
+	if strings.Contains(buff.String(), "ShadowCompare") {
+		t.Errorf("unexpected log: %s", buff.String())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/feature"
 	"github.com/luraproject/lura/v2/logging"
 )
 
@@ -68,6 +69,28 @@ func TestShadowMiddleware(t *testing.T) {
 	}
 }
 
+func TestShadowMiddleware_featureFlag(t *testing.T) {
+	defer feature.Unset(ShadowFeatureFlag)
+
+	var counter uint64
+	assertProxy := newAssertionProxy(&counter)
+	p := ShadowMiddleware(assertProxy, assertProxy)
+
+	feature.Set(ShadowFeatureFlag, false)
+	p(context.Background(), &Request{})
+	time.Sleep(100 * time.Millisecond)
+	if v := atomic.LoadUint64(&counter); v != 1 {
+		t.Errorf("The shadow proxy should not be called while the flag is disabled. Calls: %d", v)
+	}
+
+	feature.Set(ShadowFeatureFlag, true)
+	p(context.Background(), &Request{})
+	time.Sleep(100 * time.Millisecond)
+	if v := atomic.LoadUint64(&counter); v != 3 {
+		t.Errorf("The shadow proxy should have been called again. Calls: %d", v)
+	}
+}
+
 func TestShadowFactory_noBackends(t *testing.T) {
 	buff := bytes.NewBuffer(make([]byte, 1024))
 	logger, err := logging.NewLogger("ERROR", buff, "pref")
@@ -0,0 +1,319 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	sizeQuotaKey = "size_quota"
+
+	// SizeQuotaRejectedCounter is the name of the counter tracking the requests rejected
+	// because the request or the response exceeded the size quota of the key
+	SizeQuotaRejectedCounter = "proxy.size_quota.rejected"
+)
+
+// SizeQuota defines the maximum sizes (in bytes) of the requests and the responses of a key.
+// Zero means no limit.
+type SizeQuota struct {
+	MaxRequestBytes  int64 `json:"max_request_bytes"`
+	MaxResponseBytes int64 `json:"max_response_bytes"`
+}
+
+// SizeQuotaProvider returns the size quota of the key, if it has one
+type SizeQuotaProvider interface {
+	SizeQuota(ctx context.Context, key string) (SizeQuota, bool, error)
+}
+
+// SizeQuotaProviderFunc is a function implementing the SizeQuotaProvider interface
+type SizeQuotaProviderFunc func(context.Context, string) (SizeQuota, bool, error)
+
+// SizeQuota implements the SizeQuotaProvider interface
+func (f SizeQuotaProviderFunc) SizeQuota(ctx context.Context, key string) (SizeQuota, bool, error) {
+	return f(ctx, key)
+}
+
+// SizeQuotaAccountant is implemented by the providers keeping their own record of the
+// rejections of every key. The middleware reports them the rejections of the keys they
+// returned a quota for, since the keys of a provider are not used as labels of the counter.
+type SizeQuotaAccountant interface {
+	SizeQuotaExceeded(ctx context.Context, key, direction string)
+}
+
+// StaticSizeQuotas is a SizeQuotaProvider with a fixed set of quotas
+type StaticSizeQuotas map[string]SizeQuota
+
+// SizeQuota implements the SizeQuotaProvider interface
+func (s StaticSizeQuotas) SizeQuota(_ context.Context, key string) (SizeQuota, bool, error) {
+	q, ok := s[key]
+	return q, ok, nil
+}
+
+var sizeQuotaProviders = register.NewUntyped()
+
+// RegisterSizeQuotaProvider makes the provider available for the endpoints declaring size
+// quotas. Registering a provider under an existing name replaces it.
+func RegisterSizeQuotaProvider(name string, p SizeQuotaProvider) {
+	sizeQuotaProviders.Register(name, p)
+}
+
+func getSizeQuotaProvider(name string) (SizeQuotaProvider, bool) {
+	v, ok := sizeQuotaProviders.Get(name)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(SizeQuotaProvider)
+	return p, ok
+}
+
+// SizeQuotaExceededError is the error returned when the request or the response exceeds the
+// size quota of the key
+type SizeQuotaExceededError struct {
+	Endpoint string
+	// Direction is "request" or "response"
+	Direction string
+	Limit     int64
+}
+
+// Error returns a string representation of the SizeQuotaExceededError
+func (s SizeQuotaExceededError) Error() string {
+	return fmt.Sprintf("the %s exceeds the size quota of %d bytes for the endpoint %s", s.Direction, s.Limit, s.Endpoint)
+}
+
+// StatusCode returns the status code to send to the client
+func (SizeQuotaExceededError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// SizeQuotaError is the error returned when the size quota of the key can not be checked
+type SizeQuotaError struct {
+	Endpoint string
+	Err      error
+}
+
+// Error returns a string representation of the SizeQuotaError
+func (s SizeQuotaError) Error() string {
+	return fmt.Sprintf("unable to check the size quota for the endpoint %s: %s", s.Endpoint, s.Err.Error())
+}
+
+// Unwrap returns the error preventing the check of the size quota
+func (s SizeQuotaError) Unwrap() error {
+	return s.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (SizeQuotaError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// NewSizeQuotaMiddleware creates a proxy middleware rejecting the requests and the responses
+// exceeding the size quota of the API key (extracted with the spec of the 'key' attribute,
+// see NewAttributeExtractor). The quotas are returned by the registered provider selected in
+// the config or, if none, by the ones listed in the config itself:
+//
+//	"size_quota": {
+//		"key": "header:X-Api-Key",
+//		"quotas": {
+//			"free-key": { "max_request_bytes": 1024, "max_response_bytes": 4096 }
+//		},
+//		"default": { "max_request_bytes": 512 }
+//	}
+//
+// The keys without a quota get the default one, if any. The size of the requests is checked
+// before reaching the backends and the size of the responses is the one of their serialized
+// data or, for the streamed ones, their Content-Length. The rejections are counted with the
+// default events recorder, labeled with the endpoint, the direction and the quota: the key
+// itself for the quotas listed in the config, 'default' or 'provider', so the cardinality is
+// bounded by the config. The providers implementing SizeQuotaAccountant get the rejections
+// of their keys.
+//
+// The quotas are a protection control, so the requests are rejected with a SizeQuotaError if
+// the config is not valid, the provider is not registered or it fails.
+func NewSizeQuotaMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	logPrefix := fmt.Sprintf("[ENDPOINT: %s][SizeQuota]", endpointConfig.Endpoint)
+	cfg, ok, err := getSizeQuotaConfig(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(logPrefix, err.Error(), "All the requests will be rejected")
+		return rejectingSizeQuotaMiddleware(logger, SizeQuotaError{Endpoint: endpointConfig.Endpoint, Err: err})
+	}
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	provider := SizeQuotaProvider(cfg.Quotas)
+	if cfg.Provider != "" {
+		p, ok := getSizeQuotaProvider(cfg.Provider)
+		if !ok {
+			err := fmt.Errorf("unknown size quota provider '%s'", cfg.Provider)
+			logger.Error(logPrefix, err.Error(), "All the requests will be rejected")
+			return rejectingSizeQuotaMiddleware(logger, SizeQuotaError{Endpoint: endpointConfig.Endpoint, Err: err})
+		}
+		provider = p
+	}
+	logger.Debug(fmt.Sprintf("%s Enforcing the size quotas of the key '%s'", logPrefix, cfg.KeySpec))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewSizeQuotaMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			key, _ := cfg.Key(request)
+			quota, ok, err := provider.SizeQuota(ctx, key)
+			if err != nil {
+				logger.Error(logPrefix, err.Error())
+				return nil, SizeQuotaError{Endpoint: endpointConfig.Endpoint, Err: err}
+			}
+			label := key
+			accountant, _ := provider.(SizeQuotaAccountant)
+			switch {
+			case !ok && cfg.Default == nil:
+				return next[0](ctx, request)
+			case !ok:
+				quota, label, accountant = *cfg.Default, "default", nil
+			case cfg.Provider != "":
+				label = "provider"
+			}
+			reject := func(direction string, limit int64) error {
+				events.DefaultRecorder().Counter(SizeQuotaRejectedCounter, 1, map[string]string{
+					"endpoint":  endpointConfig.Endpoint,
+					"direction": direction,
+					"quota":     label,
+				})
+				if accountant != nil {
+					accountant.SizeQuotaExceeded(ctx, key, direction)
+				}
+				return SizeQuotaExceededError{Endpoint: endpointConfig.Endpoint, Direction: direction, Limit: limit}
+			}
+
+			if quota.MaxRequestBytes > 0 {
+				exceeded, err := requestExceeds(request, quota.MaxRequestBytes)
+				if err != nil {
+					return nil, err
+				}
+				if exceeded {
+					return nil, reject("request", quota.MaxRequestBytes)
+				}
+			}
+
+			resp, err := next[0](ctx, request)
+			if resp == nil || quota.MaxResponseBytes <= 0 || !responseExceeds(resp, quota.MaxResponseBytes) {
+				return resp, err
+			}
+			if resp.Io != nil {
+				if c, ok := resp.Io.(io.Closer); ok {
+					c.Close()
+				}
+			}
+			return nil, reject("response", quota.MaxResponseBytes)
+		}
+	}
+}
+
+func rejectingSizeQuotaMiddleware(logger logging.Logger, err error) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewSizeQuotaMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return nil, err
+		}
+	}
+}
+
+// requestExceeds reports if the body of the request is larger than the limit. The body is
+// read up to the limit and restored, so it can be consumed by the next layers.
+func requestExceeds(r *Request, limit int64) (bool, error) {
+	if vs := r.Headers["Content-Length"]; len(vs) == 1 {
+		if size, err := strconv.ParseInt(vs[0], 10, 64); err == nil && size > limit {
+			return true, nil
+		}
+	}
+	if r.Body == nil {
+		return false, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		r.Body.Close()
+		return false, err
+	}
+	if int64(len(b)) > limit {
+		r.Body.Close()
+		return true, nil
+	}
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(b), r.Body))
+	return false, nil
+}
+
+func responseExceeds(resp *Response, limit int64) bool {
+	if resp.Io != nil {
+		for k, vs := range resp.Metadata.Headers {
+			if http.CanonicalHeaderKey(k) != "Content-Length" || len(vs) != 1 {
+				continue
+			}
+			size, err := strconv.ParseInt(vs[0], 10, 64)
+			return err == nil && size > limit
+		}
+		return false
+	}
+	if resp.Data == nil {
+		return false
+	}
+	b, err := json.Marshal(resp.Data)
+	return err == nil && int64(len(b)) > limit
+}
+
+type sizeQuotaConfig struct {
+	KeySpec  string
+	Key      AttributeExtractor
+	Provider string
+	Quotas   StaticSizeQuotas
+	Default  *SizeQuota
+}
+
+func getSizeQuotaConfig(extra config.ExtraConfig) (sizeQuotaConfig, bool, error) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return sizeQuotaConfig{}, false, nil
+	}
+	tmp, ok := e[sizeQuotaKey]
+	if !ok {
+		return sizeQuotaConfig{}, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return sizeQuotaConfig{}, false, err
+	}
+	raw := struct {
+		Key      string               `json:"key"`
+		Provider string               `json:"provider"`
+		Quotas   map[string]SizeQuota `json:"quotas"`
+		Default  *SizeQuota           `json:"default"`
+	}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return sizeQuotaConfig{}, false, err
+	}
+	key, err := NewAttributeExtractor(raw.Key)
+	if err != nil {
+		return sizeQuotaConfig{}, false, err
+	}
+	return sizeQuotaConfig{
+		KeySpec:  raw.Key,
+		Key:      key,
+		Provider: raw.Provider,
+		Quotas:   StaticSizeQuotas(raw.Quotas),
+		Default:  raw.Default,
+	}, true, nil
+}
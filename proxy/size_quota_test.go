@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type sizeQuotaRecorder struct {
+	counters map[string]int64
+}
+
+func (r *sizeQuotaRecorder) Counter(name string, delta int64, labels map[string]string) {
+	if _, ok := labels["key"]; ok {
+		r.counters["unexpected key label"]++
+	}
+	r.counters[name+" "+labels["direction"]] += delta
+	r.counters["quota "+labels["quota"]] += delta
+}
+
+func (*sizeQuotaRecorder) Gauge(_ string, _ int64, _ map[string]string) {}
+
+func TestNewSizeQuotaMiddleware(t *testing.T) {
+	recorder := &sizeQuotaRecorder{counters: map[string]int64{}}
+	events.SetDefaultRecorder(recorder)
+	defer events.SetDefaultRecorder(nil)
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/upload",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				sizeQuotaKey: map[string]interface{}{
+					"key": "header:X-Api-Key",
+					"quotas": map[string]interface{}{
+						"free":    map[string]interface{}{"max_request_bytes": 10, "max_response_bytes": 20},
+						"premium": map[string]interface{}{"max_request_bytes": 100, "max_response_bytes": 200},
+					},
+				},
+			},
+		},
+	}
+
+	var received string
+	p := NewSizeQuotaMiddleware(logging.NoOp, endpoint)(func(_ context.Context, r *Request) (*Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+		return &Response{IsComplete: true, Data: map[string]interface{}{"echo": received}}, nil
+	})
+
+	for _, tc := range []struct {
+		key       string
+		body      string
+		direction string
+	}{
+		{key: "free", body: "tiny"},
+		{key: "free", body: strings.Repeat("a", 11), direction: "request"},
+		{key: "free", body: strings.Repeat("a", 10), direction: "response"},
+		{key: "premium", body: strings.Repeat("a", 50)},
+		{key: "premium", body: strings.Repeat("a", 101), direction: "request"},
+		{key: "unknown", body: strings.Repeat("a", 1000)},
+	} {
+		received = ""
+		resp, err := p(context.Background(), &Request{
+			Headers: map[string][]string{"X-Api-Key": {tc.key}},
+			Body:    io.NopCloser(strings.NewReader(tc.body)),
+		})
+
+		if tc.direction == "" {
+			if err != nil {
+				t.Errorf("%s (%d bytes): unexpected error: %s", tc.key, len(tc.body), err.Error())
+				continue
+			}
+			if resp.Data["echo"] != tc.body {
+				t.Errorf("%s (%d bytes): the body should reach the backend: %v", tc.key, len(tc.body), resp.Data)
+			}
+			continue
+		}
+
+		var quotaErr SizeQuotaExceededError
+		if !errors.As(err, &quotaErr) || quotaErr.Direction != tc.direction {
+			t.Errorf("%s (%d bytes): unexpected error: %v", tc.key, len(tc.body), err)
+			continue
+		}
+		if quotaErr.StatusCode() != 413 {
+			t.Errorf("%s: unexpected status code: %d", tc.key, quotaErr.StatusCode())
+		}
+		if resp != nil {
+			t.Errorf("%s: unexpected response: %v", tc.key, resp)
+		}
+		if tc.direction == "request" && received != "" {
+			t.Errorf("%s: the request should not reach the backend", tc.key)
+		}
+	}
+
+	for name, expected := range map[string]int64{
+		SizeQuotaRejectedCounter + " request":  2,
+		SizeQuotaRejectedCounter + " response": 1,
+		"quota free":                           2,
+		"quota premium":                        1,
+		"unexpected key label":                 0,
+	} {
+		if v := recorder.counters[name]; v != expected {
+			t.Errorf("unexpected value for %s: %d", name, v)
+		}
+	}
+}
+
+type accountingSizeQuotas struct {
+	rejected map[string]int
+}
+
+func (*accountingSizeQuotas) SizeQuota(_ context.Context, key string) (SizeQuota, bool, error) {
+	if key == "" {
+		return SizeQuota{}, false, nil
+	}
+	return SizeQuota{MaxRequestBytes: int64(len(key))}, true, nil
+}
+
+func (a *accountingSizeQuotas) SizeQuotaExceeded(_ context.Context, key, direction string) {
+	a.rejected[key+" "+direction]++
+}
+
+func TestNewSizeQuotaMiddleware_provider(t *testing.T) {
+	recorder := &sizeQuotaRecorder{counters: map[string]int64{}}
+	events.SetDefaultRecorder(recorder)
+	defer events.SetDefaultRecorder(nil)
+
+	provider := &accountingSizeQuotas{rejected: map[string]int{}}
+	RegisterSizeQuotaProvider("test", provider)
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/upload",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				sizeQuotaKey: map[string]interface{}{
+					"key":      "query:key",
+					"provider": "test",
+					"default":  map[string]interface{}{"max_request_bytes": 1},
+				},
+			},
+		},
+	}
+	p := NewSizeQuotaMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{IsComplete: true}))
+
+	for _, tc := range []struct {
+		query    map[string][]string
+		length   string
+		rejected bool
+	}{
+		{query: map[string][]string{"key": {"abcd"}}, length: "4"},
+		{query: map[string][]string{"key": {"abcd"}}, length: "5", rejected: true},
+		{length: "1"},
+		{length: "2", rejected: true},
+	} {
+		_, err := p(context.Background(), &Request{
+			Query:   tc.query,
+			Headers: map[string][]string{"Content-Length": {tc.length}},
+		})
+		if (err != nil) != tc.rejected {
+			t.Errorf("%v (%s bytes): unexpected error: %v", tc.query, tc.length, err)
+		}
+	}
+
+	if len(provider.rejected) != 1 || provider.rejected["abcd request"] != 1 {
+		t.Errorf("unexpected rejections reported to the provider: %v", provider.rejected)
+	}
+	if recorder.counters["quota provider"] != 1 || recorder.counters["quota default"] != 1 {
+		t.Errorf("unexpected counters: %v", recorder.counters)
+	}
+}
+
+func TestNewSizeQuotaMiddleware_failClosed(t *testing.T) {
+	RegisterSizeQuotaProvider("broken", SizeQuotaProviderFunc(func(_ context.Context, _ string) (SizeQuota, bool, error) {
+		return SizeQuota{}, false, errors.New("quota store unavailable")
+	}))
+
+	for _, cfg := range []map[string]interface{}{
+		{"key": "header:X-Api-Key", "provider": "unknown"},
+		{"key": "header:X-Api-Key", "provider": "broken"},
+		{"key": "unknown:X-Api-Key"},
+	} {
+		endpoint := &config.EndpointConfig{
+			Endpoint:    "/upload",
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{sizeQuotaKey: cfg}},
+		}
+		p := NewSizeQuotaMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+			t.Errorf("%v: the request should not reach the backend", cfg)
+			return nil, nil
+		})
+
+		resp, err := p(context.Background(), &Request{Headers: map[string][]string{"X-Api-Key": {"free"}}})
+		if resp != nil {
+			t.Errorf("%v: unexpected response: %v", cfg, resp)
+		}
+		var quotaErr SizeQuotaError
+		if !errors.As(err, &quotaErr) || quotaErr.StatusCode() != http.StatusInternalServerError {
+			t.Errorf("%v: unexpected error: %v", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const slaKey = "sla"
+
+// SLAExceededError is the error returned when the recent latency of an endpoint exceeds its SLA
+type SLAExceededError struct {
+	Estimated time.Duration
+	SLA       time.Duration
+}
+
+// Error returns a string representation of the SLAExceededError
+func (e SLAExceededError) Error() string {
+	return fmt.Sprintf("the estimated latency (%s) exceeds the SLA (%s)", e.Estimated, e.SLA)
+}
+
+// StatusCode returns the status code to send to the client
+func (SLAExceededError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// NewSLAMiddleware creates a proxy middleware that fast-fails the requests when the recent
+// latency of the wrapped proxy exceeds the configured SLA, instead of adding more load to
+// the backends. The latency is estimated with the configured percentile of the samples
+// collected during the last window.
+func NewSLAMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := getSLAConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(
+		fmt.Sprintf(
+			"[ENDPOINT: %s][SLA] Rejecting requests when the p%v latency of the last %s exceeds %s",
+			endpointConfig.Endpoint,
+			cfg.Percentile,
+			cfg.Window,
+			cfg.MaxLatency,
+		),
+	)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewSLAMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		estimator := newLatencyEstimator(cfg.Window, cfg.MinSamples, cfg.MaxSamples)
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			now := time.Now()
+			if estimated, ok := estimator.Estimate(now, cfg.Percentile); ok && estimated > cfg.MaxLatency {
+				logger.Debug(fmt.Sprintf("[ENDPOINT: %s][SLA] Fast-failing the request. Estimated latency: %s",
+					endpointConfig.Endpoint, estimated))
+				return nil, SLAExceededError{Estimated: estimated, SLA: cfg.MaxLatency}
+			}
+
+			resp, err := next[0](ctx, request)
+			end := time.Now()
+			estimator.Add(end, end.Sub(now))
+			return resp, err
+		}
+	}
+}
+
+type slaConfig struct {
+	MaxLatency time.Duration
+	Percentile float64
+	Window     time.Duration
+	MinSamples int
+	MaxSamples int
+}
+
+func getSLAConfig(extra config.ExtraConfig) (slaConfig, bool) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return slaConfig{}, ok
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return slaConfig{}, ok
+	}
+	tmp, ok := e[slaKey].(map[string]interface{})
+	if !ok {
+		return slaConfig{}, ok
+	}
+	maxLatency, ok := tmp["max_latency"].(string)
+	if !ok {
+		return slaConfig{}, ok
+	}
+	cfg := slaConfig{
+		Percentile: 99,
+		Window:     10 * time.Second,
+		MinSamples: 10,
+		MaxSamples: 1000,
+	}
+	var err error
+	if cfg.MaxLatency, err = time.ParseDuration(maxLatency); err != nil || cfg.MaxLatency <= 0 {
+		return slaConfig{}, false
+	}
+	if v, ok := tmp["percentile"].(float64); ok && v > 0 && v <= 100 {
+		cfg.Percentile = v
+	}
+	if v, ok := tmp["window"].(string); ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Window = d
+		}
+	}
+	if v, ok := tmp["min_samples"].(float64); ok && v > 0 {
+		cfg.MinSamples = int(v)
+	}
+	if v, ok := tmp["max_samples"].(float64); ok && v > 0 {
+		cfg.MaxSamples = int(v)
+	}
+	if cfg.MaxSamples < cfg.MinSamples {
+		cfg.MaxSamples = cfg.MinSamples
+	}
+	return cfg, true
+}
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// latencyEstimator keeps the latency samples of a sliding time window. Once all the samples
+// expire, the estimator stops reporting estimations, so a fast-failing endpoint will accept
+// requests again after a quiet window.
+type latencyEstimator struct {
+	window     time.Duration
+	minSamples int
+	maxSamples int
+	samples    []latencySample
+	mu         *sync.Mutex
+}
+
+func newLatencyEstimator(window time.Duration, minSamples, maxSamples int) *latencyEstimator {
+	return &latencyEstimator{
+		window:     window,
+		minSamples: minSamples,
+		maxSamples: maxSamples,
+		samples:    make([]latencySample, 0, maxSamples),
+		mu:         new(sync.Mutex),
+	}
+}
+
+// Add registers a new sample, discarding the oldest one if the estimator is full
+func (l *latencyEstimator) Add(at time.Time, d time.Duration) {
+	l.mu.Lock()
+	if len(l.samples) == l.maxSamples {
+		copy(l.samples, l.samples[1:])
+		l.samples = l.samples[:len(l.samples)-1]
+	}
+	l.samples = append(l.samples, latencySample{at: at, duration: d})
+	l.mu.Unlock()
+}
+
+// Estimate returns the requested percentile of the samples of the window, if there are enough
+func (l *latencyEstimator) Estimate(now time.Time, percentile float64) (time.Duration, bool) {
+	l.mu.Lock()
+	since := now.Add(-l.window)
+	expired := 0
+	for expired < len(l.samples) && l.samples[expired].at.Before(since) {
+		expired++
+	}
+	if expired > 0 {
+		l.samples = append(l.samples[:0], l.samples[expired:]...)
+	}
+	if len(l.samples) < l.minSamples {
+		l.mu.Unlock()
+		return 0, false
+	}
+	durations := make([]time.Duration, len(l.samples))
+	for i, s := range l.samples {
+		durations[i] = s.duration
+	}
+	l.mu.Unlock()
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	idx := int(math.Ceil(percentile/100*float64(len(durations)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return durations[idx], true
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewSLAMiddleware_fastFail(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/sla",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				slaKey: map[string]interface{}{
+					"max_latency": "10ms",
+					"percentile":  99.0,
+					"window":      "100ms",
+					"min_samples": 3.0,
+				},
+			},
+		},
+	}
+	calls := 0
+	expected := &Response{IsComplete: true, Data: map[string]interface{}{"foo": "bar"}}
+	p := NewSLAMiddleware(logging.NoOp, endpoint)(func(ctx context.Context, _ *Request) (*Response, error) {
+		calls++
+		if calls <= 3 {
+			<-time.After(20 * time.Millisecond)
+		}
+		return expected, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		resp, err := p(context.Background(), &Request{})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if resp != expected {
+			t.Errorf("unexpected response: %v", resp)
+		}
+	}
+
+	resp, err := p(context.Background(), &Request{})
+	if resp != nil {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	slaErr, ok := err.(SLAExceededError)
+	if !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if slaErr.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status code: %d", slaErr.StatusCode())
+	}
+	if slaErr.Estimated < 20*time.Millisecond {
+		t.Errorf("unexpected estimation: %s", slaErr.Estimated)
+	}
+	if calls != 3 {
+		t.Errorf("the request should not reach the backends. calls: %d", calls)
+	}
+
+	<-time.After(150 * time.Millisecond)
+
+	resp, err = p(context.Background(), &Request{})
+	if err != nil {
+		t.Errorf("unexpected error after the window: %v", err)
+	}
+	if resp != expected {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestNewSLAMiddleware_withinSLA(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				slaKey: map[string]interface{}{
+					"max_latency": "1s",
+					"min_samples": 1.0,
+				},
+			},
+		},
+	}
+	expected := &Response{IsComplete: true}
+	p := NewSLAMiddleware(logging.NoOp, endpoint)(dummyProxy(expected))
+	for i := 0; i < 20; i++ {
+		resp, err := p(context.Background(), &Request{})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if resp != expected {
+			t.Errorf("unexpected response: %v", resp)
+			return
+		}
+	}
+}
+
+func TestLatencyEstimator(t *testing.T) {
+	e := newLatencyEstimator(time.Minute, 2, 4)
+	now := time.Now()
+	if _, ok := e.Estimate(now, 99); ok {
+		t.Error("the estimator should not report estimations without samples")
+	}
+	for _, d := range []time.Duration{5, 1, 3, 2, 4} {
+		e.Add(now, d*time.Millisecond)
+	}
+	if v, ok := e.Estimate(now, 50); !ok || v != 2*time.Millisecond {
+		t.Errorf("unexpected p50: %s", v)
+	}
+	if v, ok := e.Estimate(now, 99); !ok || v != 4*time.Millisecond {
+		t.Errorf("unexpected p99: %s", v)
+	}
+	if _, ok := e.Estimate(now.Add(2*time.Minute), 99); ok {
+		t.Error("the expired samples should be discarded")
+	}
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+const tokenizeKey = "tokenize"
+
+// Tokenizer returns an opaque token for the value of the field, so the sensitive values
+// can be vaulted and recovered elsewhere
+type Tokenizer interface {
+	Tokenize(ctx context.Context, field, value string) (string, error)
+}
+
+// TokenizerFunc is a function implementing the Tokenizer interface
+type TokenizerFunc func(context.Context, string, string) (string, error)
+
+// Tokenize implements the Tokenizer interface
+func (f TokenizerFunc) Tokenize(ctx context.Context, field, value string) (string, error) {
+	return f(ctx, field, value)
+}
+
+// TokenizationError is the error returned when the fields of the response can not be tokenized
+type TokenizationError struct {
+	Field string
+	Err   error
+}
+
+// Error returns a string representation of the TokenizationError
+func (t TokenizationError) Error() string {
+	if t.Field == "" {
+		return fmt.Sprintf("unable to tokenize the response: %s", t.Err.Error())
+	}
+	return fmt.Sprintf("unable to tokenize the field '%s': %s", t.Field, t.Err.Error())
+}
+
+// Unwrap returns the error returned by the tokenizer
+func (t TokenizationError) Unwrap() error {
+	return t.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (TokenizationError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+var errTokenizeNoOp = errors.New("the no-op encoded responses can not be tokenized")
+
+var tokenizers = register.NewUntyped()
+
+// RegisterTokenizer makes the tokenizer available for the endpoints declaring tokenized
+// fields. Registering a tokenizer under an existing name replaces it.
+func RegisterTokenizer(name string, t Tokenizer) {
+	tokenizers.Register(name, t)
+}
+
+func getTokenizer(name string) (Tokenizer, bool) {
+	v, ok := tokenizers.Get(name)
+	if !ok {
+		return nil, false
+	}
+	t, ok := v.(Tokenizer)
+	return t, ok
+}
+
+// NewTokenizeMiddleware creates a proxy middleware replacing the values of the configured
+// response fields with the tokens returned by the selected tokenizer:
+//
+//	"tokenize": {
+//		"provider": "vault",
+//		"fields": [ "card_number", "owner.iban" ]
+//	}
+//
+// Every value reached by the field paths is tokenized on its own, so a path ending at an
+// array of card numbers sends each one of them to the tokenizer (see transformPath). The
+// strings and the numbers are tokenized and the rest of values are left untouched. If the
+// tokenizer fails or it is not registered, the response is discarded and the proxy returns a
+// TokenizationError, so the sensitive values never reach the client. The same happens with the
+// bodies that are not decoded, so the endpoints using the no-op encoding can not be tokenized.
+func NewTokenizeMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	provider, fields, ok := getTokenizeConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logPrefix := fmt.Sprintf("[ENDPOINT: %s][Tokenize]", endpointConfig.Endpoint)
+	tokenizer, found := getTokenizer(provider)
+	var configErr error
+	switch {
+	case !found:
+		configErr = TokenizationError{Err: fmt.Errorf("unknown tokenizer '%s'", provider)}
+	case endpointConfig.OutputEncoding == encoding.NOOP:
+		configErr = TokenizationError{Err: errTokenizeNoOp}
+	}
+	if configErr != nil {
+		logger.Error(logPrefix, configErr.Error()+". All the requests will be rejected")
+	} else {
+		logger.Debug(fmt.Sprintf("%s Tokenizing %d fields with the '%s' tokenizer", logPrefix, len(fields), provider))
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewTokenizeMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		if configErr != nil {
+			return func(_ context.Context, _ *Request) (*Response, error) {
+				return nil, configErr
+			}
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil {
+				return resp, err
+			}
+			if resp.Data == nil && resp.Io != nil {
+				logger.Error(logPrefix, "Unable to tokenize a streamed response")
+				return nil, TokenizationError{Err: errTokenizeNoOp}
+			}
+
+			for _, field := range fields {
+				name := strings.Join(field, ".")
+				tErr := transformPath(resp.Data, field, func(v interface{}) (interface{}, error) {
+					s, ok := tokenizableValue(v)
+					if !ok {
+						return v, nil
+					}
+					return tokenizer.Tokenize(ctx, name, s)
+				})
+				if tErr != nil {
+					logger.Error(logPrefix, fmt.Sprintf("Unable to tokenize '%s': %s", name, tErr.Error()))
+					return nil, TokenizationError{Field: name, Err: tErr}
+				}
+			}
+			return resp, err
+		}
+	}
+}
+
+func tokenizableValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case json.Number:
+		return t.String(), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(t), true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	}
+	return "", false
+}
+
+func getTokenizeConfig(extra config.ExtraConfig) (string, [][]string, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	tmp, ok := e[tokenizeKey].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	provider, _ := tmp["provider"].(string)
+	vs, _ := tmp["fields"].([]interface{})
+	fields := make([][]string, 0, len(vs))
+	for _, v := range vs {
+		if f, ok := v.(string); ok && f != "" {
+			fields = append(fields, splitPath(f))
+		}
+	}
+	return provider, fields, len(fields) > 0
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewTokenizeMiddleware(t *testing.T) {
+	vault := map[string]string{}
+	RegisterTokenizer("fake", TokenizerFunc(func(_ context.Context, field, value string) (string, error) {
+		token := "tok_" + field + "_" + value[len(value)-4:]
+		vault[token] = value
+		return token, nil
+	}))
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/payments",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				tokenizeKey: map[string]interface{}{
+					"provider": "fake",
+					"fields":   []interface{}{"card_number", "history.card_number"},
+				},
+			},
+		},
+	}
+
+	p := NewTokenizeMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{
+		IsComplete: true,
+		Data: map[string]interface{}{
+			"card_number": "4111111111111111",
+			"amount":      42.0,
+			"history": []interface{}{
+				map[string]interface{}{"card_number": 5500000000000004.0},
+				map[string]interface{}{"card_number": nil},
+			},
+		},
+	}))
+
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	if v := resp.Data["card_number"]; v != "tok_card_number_1111" {
+		t.Errorf("unexpected token: %v", v)
+	}
+	if vault["tok_card_number_1111"] != "4111111111111111" {
+		t.Errorf("unexpected vaulted value: %v", vault)
+	}
+	if resp.Data["amount"] != 42.0 {
+		t.Errorf("the fields not configured should not be tokenized: %v", resp.Data["amount"])
+	}
+
+	history := resp.Data["history"].([]interface{})
+	if v := history[0].(map[string]interface{})["card_number"]; v != "tok_history.card_number_0004" {
+		t.Errorf("unexpected token: %v", v)
+	}
+	if v := history[1].(map[string]interface{})["card_number"]; v != nil {
+		t.Errorf("the null values should not be tokenized: %v", v)
+	}
+}
+
+func TestNewTokenizeMiddleware_error(t *testing.T) {
+	expectedErr := errors.New("vault unavailable")
+	RegisterTokenizer("failing", TokenizerFunc(func(_ context.Context, _, _ string) (string, error) {
+		return "", expectedErr
+	}))
+
+	endpoint := &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				tokenizeKey: map[string]interface{}{
+					"provider": "failing",
+					"fields":   []interface{}{"card_number"},
+				},
+			},
+		},
+	}
+
+	p := NewTokenizeMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{
+		IsComplete: true,
+		Data:       map[string]interface{}{"card_number": "4111111111111111"},
+	}))
+
+	resp, err := p(context.Background(), &Request{})
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if e, ok := err.(TokenizationError); !ok || e.Field != "card_number" || e.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if resp != nil {
+		t.Errorf("the response should be discarded: %v", resp)
+	}
+}
+
+func TestNewTokenizeMiddleware_unknownProvider(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				tokenizeKey: map[string]interface{}{
+					"provider": "unknown",
+					"fields":   []interface{}{"card_number"},
+				},
+			},
+		},
+	}
+
+	p := NewTokenizeMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the backend should not be called")
+		return nil, nil
+	})
+
+	resp, err := p(context.Background(), &Request{})
+	if resp != nil {
+		t.Errorf("the response should be discarded: %v", resp)
+	}
+	if e, ok := err.(TokenizationError); !ok || e.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewTokenizeMiddleware_noOp(t *testing.T) {
+	RegisterTokenizer("noop-test", TokenizerFunc(func(_ context.Context, _, v string) (string, error) {
+		return "tok", nil
+	}))
+	extra := config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			tokenizeKey: map[string]interface{}{
+				"provider": "noop-test",
+				"fields":   []interface{}{"card_number"},
+			},
+		},
+	}
+
+	p := NewTokenizeMiddleware(logging.NoOp, &config.EndpointConfig{
+		OutputEncoding: encoding.NOOP,
+		ExtraConfig:    extra,
+	})(func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the backend should not be called")
+		return nil, nil
+	})
+	if resp, err := p(context.Background(), &Request{}); resp != nil || !errors.Is(err, errTokenizeNoOp) {
+		t.Errorf("unexpected result. resp: %v, err: %v", resp, err)
+	}
+
+	p = NewTokenizeMiddleware(logging.NoOp, &config.EndpointConfig{
+		OutputEncoding: encoding.JSON,
+		ExtraConfig:    extra,
+	})(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true, Io: strings.NewReader(`{"card_number":"4111"}`)}, nil
+	})
+	if resp, err := p(context.Background(), &Request{}); resp != nil || !errors.Is(err, errTokenizeNoOp) {
+		t.Errorf("the streamed responses should be rejected. resp: %v, err: %v", resp, err)
+	}
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	unflattenKey = "unflatten"
+
+	defaultUnflattenSeparator = "."
+)
+
+// NewUnflattenMiddleware creates a proxy middleware building nested objects from the keys of
+// the response containing the separator, so {"a.b.c": 1} becomes {"a": {"b": {"c": 1}}}:
+//
+//	"unflatten": { "separator": ".", "arrays": true, "path": "result" }
+//
+// The separator defaults to a dot and the optional path locates the object to process
+// (the root of the response, by default). With arrays enabled, the objects built with the
+// keys 0 to n-1 become arrays, so {"a.0": 1, "a.1": 2} becomes {"a": [1, 2]}. The keys
+// clashing with an existing value (like "a" and "a.b") are left as they are.
+func NewUnflattenMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := getUnflattenConfig(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Unflatten] Unflattening the keys at '%s' (separator: '%s', arrays: %v)",
+		endpointConfig.Endpoint, strings.Join(cfg.Path, "."), cfg.Separator, cfg.Arrays))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewUnflattenMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil || resp.Data == nil {
+				return resp, err
+			}
+			cfg.Apply(resp.Data)
+			return resp, err
+		}
+	}
+}
+
+type unflattenConfig struct {
+	Path      []string
+	Separator string
+	Arrays    bool
+}
+
+// unflattenNode is an object built by the middleware, so it can be told apart from the
+// objects of the response when building the arrays
+type unflattenNode map[string]interface{}
+
+// Apply unflattens the keys of the object at the configured path
+func (u unflattenConfig) Apply(data map[string]interface{}) {
+	if len(u.Path) == 0 {
+		u.unflatten(data)
+		return
+	}
+	transformPath(data, u.Path, func(v interface{}) (interface{}, error) {
+		if m, ok := v.(map[string]interface{}); ok {
+			u.unflatten(m)
+		}
+		return v, nil
+	})
+}
+
+func (u unflattenConfig) unflatten(data map[string]interface{}) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		if strings.Contains(k, u.Separator) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		parts := strings.Split(k, u.Separator)
+		if u.set(data, parts, data[k], false) {
+			u.set(data, parts, data[k], true)
+			delete(data, k)
+		}
+	}
+	for k, v := range data {
+		data[k] = u.finalize(v)
+	}
+}
+
+// set stores the value at the path, creating the missing objects if create is true. It
+// reports if the value can be stored without overwriting an existing one.
+func (u unflattenConfig) set(data map[string]interface{}, path []string, v interface{}, create bool) bool {
+	current := data
+	for i, p := range path {
+		if p == "" {
+			return false
+		}
+		next, ok := current[p]
+		if i == len(path)-1 {
+			if ok {
+				return false
+			}
+			if create {
+				current[p] = v
+			}
+			return true
+		}
+		switch t := next.(type) {
+		case unflattenNode:
+			current = t
+		case map[string]interface{}:
+			current = t
+		case nil:
+			if ok {
+				return false
+			}
+			if !create {
+				return true
+			}
+			n := unflattenNode{}
+			current[p] = n
+			current = n
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// finalize replaces the built objects with plain ones or, if they only contain consecutive
+// indexes, with arrays
+func (u unflattenConfig) finalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = u.finalize(e)
+		}
+		return t
+	case unflattenNode:
+		for k, e := range t {
+			t[k] = u.finalize(e)
+		}
+		if u.Arrays {
+			if arr, ok := asArray(t); ok {
+				return arr
+			}
+		}
+		return map[string]interface{}(t)
+	}
+	return v
+}
+
+func asArray(n unflattenNode) ([]interface{}, bool) {
+	arr := make([]interface{}, len(n))
+	for k, v := range n {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(n) || strconv.Itoa(i) != k {
+			return nil, false
+		}
+		arr[i] = v
+	}
+	return arr, true
+}
+
+func getUnflattenConfig(extra config.ExtraConfig) (unflattenConfig, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return unflattenConfig{}, false
+	}
+	tmp, ok := e[unflattenKey].(map[string]interface{})
+	if !ok {
+		return unflattenConfig{}, false
+	}
+	cfg := unflattenConfig{Separator: defaultUnflattenSeparator}
+	if sep, ok := tmp["separator"].(string); ok && sep != "" {
+		cfg.Separator = sep
+	}
+	cfg.Arrays, _ = tmp["arrays"].(bool)
+	if path, ok := tmp["path"].(string); ok && path != "" {
+		cfg.Path = splitPath(path)
+	}
+	return cfg, true
+}
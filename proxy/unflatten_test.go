@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewUnflattenMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      map[string]interface{}
+		data     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name: "dotted keys",
+			cfg:  map[string]interface{}{},
+			data: map[string]interface{}{
+				"a.b.c":   1.0,
+				"a.b.d":   "foo",
+				"a.e":     true,
+				"x":       "untouched",
+				"items.0": "first",
+			},
+			expected: map[string]interface{}{
+				"a": map[string]interface{}{
+					"b": map[string]interface{}{"c": 1.0, "d": "foo"},
+					"e": true,
+				},
+				"x":     "untouched",
+				"items": map[string]interface{}{"0": "first"},
+			},
+		},
+		{
+			name: "arrays",
+			cfg:  map[string]interface{}{"arrays": true},
+			data: map[string]interface{}{
+				"items.0.id":   1.0,
+				"items.1.id":   2.0,
+				"items.1.name": "two",
+				"sparse.0":     "a",
+				"sparse.2":     "c",
+			},
+			expected: map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"id": 1.0},
+					map[string]interface{}{"id": 2.0, "name": "two"},
+				},
+				"sparse": map[string]interface{}{"0": "a", "2": "c"},
+			},
+		},
+		{
+			name: "custom separator and path",
+			cfg:  map[string]interface{}{"separator": "__", "path": "result"},
+			data: map[string]interface{}{
+				"result":   map[string]interface{}{"user__name": "john", "user__age": 42.0},
+				"meta__id": "root keys are not processed",
+			},
+			expected: map[string]interface{}{
+				"result":   map[string]interface{}{"user": map[string]interface{}{"name": "john", "age": 42.0}},
+				"meta__id": "root keys are not processed",
+			},
+		},
+		{
+			name: "conflicts",
+			cfg:  map[string]interface{}{},
+			data: map[string]interface{}{
+				"a":       1.0,
+				"a.b":     2.0,
+				"user":    map[string]interface{}{"id": 1.0},
+				"user.id": 2.0,
+				"user.x":  3.0,
+			},
+			expected: map[string]interface{}{
+				"a":       1.0,
+				"a.b":     2.0,
+				"user":    map[string]interface{}{"id": 1.0, "x": 3.0},
+				"user.id": 2.0,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := &config.EndpointConfig{
+				Endpoint: "/flat",
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{unflattenKey: tc.cfg},
+				},
+			}
+			p := NewUnflattenMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{IsComplete: true, Data: tc.data}))
+
+			resp, err := p(context.Background(), &Request{})
+			if err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+				return
+			}
+			if !reflect.DeepEqual(resp.Data, tc.expected) {
+				t.Errorf("unexpected response.\nhave: %#v\nwant: %#v", resp.Data, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const unitConversionKey = "unit_conversion"
+
+// NewUnitConversionMiddleware creates a proxy middleware applying the configured linear
+// conversions (value * factor + offset) to the numeric fields of the response, so a
+// temperature in Celsius can be returned in Fahrenheit with a factor of 1.8 and an offset
+// of 32, for instance.
+//
+// The fields are located using dot separated paths. The arrays found along the path are
+// traversed, so the conversion is applied to every element. The values that are not numbers
+// are left untouched.
+func NewUnitConversionMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	conversions, err := getUnitConversions(endpointConfig.ExtraConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][UnitConversion] %s", endpointConfig.Endpoint, err.Error()))
+		return emptyMiddlewareFallback(logger)
+	}
+	if len(conversions) == 0 {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	for _, c := range conversions {
+		logger.Debug(
+			fmt.Sprintf(
+				"[ENDPOINT: %s][UnitConversion] Converting '%s' (factor: %v, offset: %v)",
+				endpointConfig.Endpoint,
+				strings.Join(c.Path, "."),
+				c.Factor,
+				c.Offset,
+			),
+		)
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewUnitConversionMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			for _, c := range conversions {
+				c.Apply(resp.Data)
+			}
+			return resp, err
+		}
+	}
+}
+
+type unitConversion struct {
+	Path   []string
+	Factor float64
+	Offset float64
+}
+
+// Apply converts the values found at the configured path
+func (u unitConversion) Apply(data map[string]interface{}) {
+	transformPath(data, u.Path, func(v interface{}) (interface{}, error) {
+		if f, ok := u.convert(v); ok {
+			return f, nil
+		}
+		return v, nil
+	})
+}
+
+func (u unitConversion) convert(v interface{}) (float64, bool) {
+	f, ok := numericValue(v)
+	if !ok {
+		return 0, false
+	}
+	return f*u.Factor + u.Offset, true
+}
+
+// numericValue returns the value as a float64 if it is a number
+func numericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func getUnitConversions(extra config.ExtraConfig) ([]unitConversion, error) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	vs, ok := e[unitConversionKey].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	conversions := make([]unitConversion, 0, len(vs))
+	for i, raw := range vs {
+		c, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid conversion #%d", i)
+		}
+		field, _ := c["field"].(string)
+		if field == "" {
+			return nil, fmt.Errorf("the conversion #%d has no field", i)
+		}
+		conversion := unitConversion{Path: splitPath(field), Factor: 1}
+		if f, ok := c["factor"].(float64); ok {
+			conversion.Factor = f
+		}
+		if o, ok := c["offset"].(float64); ok {
+			conversion.Offset = o
+		}
+		conversions = append(conversions, conversion)
+	}
+	return conversions, nil
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewUnitConversionMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/weather",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				unitConversionKey: []interface{}{
+					map[string]interface{}{"field": "current.temperature", "factor": 1.8, "offset": 32.0},
+					map[string]interface{}{"field": "forecast.temperature", "factor": 1.8, "offset": 32.0},
+					map[string]interface{}{"field": "history", "factor": 1.8, "offset": 32.0},
+					map[string]interface{}{"field": "altitude", "factor": 3.28084},
+				},
+			},
+		},
+	}
+
+	p := NewUnitConversionMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{
+		IsComplete: true,
+		Data: map[string]interface{}{
+			"current": map[string]interface{}{"temperature": json.Number("20"), "city": "Barcelona"},
+			"forecast": []interface{}{
+				map[string]interface{}{"temperature": -40.0},
+				map[string]interface{}{"temperature": 100},
+				map[string]interface{}{"temperature": "unknown"},
+			},
+			"history":  []interface{}{0.0, 37.0},
+			"altitude": 100.0,
+		},
+	}))
+
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	assertFloat := func(name string, v interface{}, expected float64) {
+		f, ok := v.(float64)
+		if !ok || math.Abs(f-expected) > 1e-9 {
+			t.Errorf("%s: unexpected value. have: %v, want: %v", name, v, expected)
+		}
+	}
+
+	current := resp.Data["current"].(map[string]interface{})
+	assertFloat("current", current["temperature"], 68)
+	if current["city"] != "Barcelona" {
+		t.Errorf("unexpected city: %v", current["city"])
+	}
+
+	forecast := resp.Data["forecast"].([]interface{})
+	assertFloat("forecast #0", forecast[0].(map[string]interface{})["temperature"], -40)
+	assertFloat("forecast #1", forecast[1].(map[string]interface{})["temperature"], 212)
+	if v := forecast[2].(map[string]interface{})["temperature"]; v != "unknown" {
+		t.Errorf("the non numeric values should not be converted: %v", v)
+	}
+
+	history := resp.Data["history"].([]interface{})
+	assertFloat("history #0", history[0], 32)
+	assertFloat("history #1", history[1], 98.6)
+
+	assertFloat("altitude", resp.Data["altitude"], 328.084)
+}
+
+func TestNewUnitConversionMiddleware_invalid(t *testing.T) {
+	for _, conversions := range []interface{}{
+		[]interface{}{"current.temperature"},
+		[]interface{}{map[string]interface{}{"factor": 1.8}},
+	} {
+		endpoint := &config.EndpointConfig{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{unitConversionKey: conversions},
+			},
+		}
+		p := NewUnitConversionMiddleware(logging.NoOp, endpoint)(dummyProxy(&Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"temperature": 20.0},
+		}))
+		resp, _ := p(context.Background(), &Request{})
+		if resp.Data["temperature"] != 20.0 {
+			t.Errorf("%v: the response should not be modified: %v", conversions, resp.Data)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const urlRewriteKey = "rewrite_urls"
+
+// NewURLRewriteMiddleware creates a proxy middleware rewriting the URLs returned by the
+// backend (pagination links, for instance), so they point to the gateway instead of the
+// internal hosts of the backend.
+//
+// The values at the configured paths ("links.next", "items.*.href"...) pointing to one of the
+// hosts of the backend get the scheme and the host of the public URL, and the static prefix
+// of the backend url pattern is replaced by the static prefix of the endpoint. The query
+// string is kept as is. The values that are not absolute URLs (opaque cursors, for instance)
+// and the URLs pointing to other hosts are not modified.
+func NewURLRewriteMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	rw, ok, err := getURLRewriteConfig(remote)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[BACKEND: %s %s -> %s][URLRewrite] %s",
+			remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, err.Error()))
+		return emptyMiddlewareFallback(logger)
+	}
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][URLRewrite]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	logger.Debug(fmt.Sprintf("%s Rewriting %d fields with the public URL %s", logPrefix, len(rw.Fields), rw.Public.String()))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewURLRewriteMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil || resp.Data == nil {
+				return resp, err
+			}
+			for _, field := range rw.Fields {
+				rewriteURLField(resp.Data, field, func(v string) (string, bool) {
+					res, ok := rw.Rewrite(v)
+					if !ok {
+						logger.Debug(fmt.Sprintf("%s The value of '%s' is not a backend URL", logPrefix, strings.Join(field, ".")))
+					}
+					return res, ok
+				})
+			}
+			return resp, err
+		}
+	}
+}
+
+type urlRewriter struct {
+	Fields         [][]string
+	Public         *url.URL
+	Hosts          map[string]struct{}
+	BackendPrefix  string
+	EndpointPrefix string
+}
+
+// Rewrite returns the public version of the received backend URL
+func (u urlRewriter) Rewrite(v string) (string, bool) {
+	parsed, err := url.Parse(v)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return v, false
+	}
+	if _, ok := u.Hosts[strings.ToLower(parsed.Scheme+"://"+parsed.Host)]; !ok {
+		return v, false
+	}
+
+	// the prefixes have no trailing slash, so the rest of the path starts with one or it
+	// is empty
+	path := parsed.Path
+	if hasPathPrefix(path, u.BackendPrefix) {
+		path = u.EndpointPrefix + path[len(u.BackendPrefix):]
+	}
+
+	parsed.Scheme = u.Public.Scheme
+	parsed.Host = u.Public.Host
+	parsed.User = u.Public.User
+	parsed.Path = strings.TrimRight(u.Public.Path, "/") + path
+	parsed.RawPath = ""
+	return parsed.String(), true
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// rewriteURLField applies the rewrite function to the string values found at the path. The
+// '*' token matches all the elements of an array or an object.
+func rewriteURLField(data interface{}, path []string, rewrite func(string) (string, bool)) {
+	if len(path) == 0 {
+		return
+	}
+	token, last := path[0], len(path) == 1
+
+	switch c := data.(type) {
+	case map[string]interface{}:
+		if token == "*" {
+			for k, v := range c {
+				c[k] = rewriteURLValue(v, path, last, rewrite)
+			}
+			return
+		}
+		if v, ok := c[token]; ok {
+			c[token] = rewriteURLValue(v, path, last, rewrite)
+		}
+	case []interface{}:
+		if token != "*" {
+			return
+		}
+		for i, v := range c {
+			c[i] = rewriteURLValue(v, path, last, rewrite)
+		}
+	}
+}
+
+func rewriteURLValue(v interface{}, path []string, last bool, rewrite func(string) (string, bool)) interface{} {
+	if !last {
+		rewriteURLField(v, path[1:], rewrite)
+		return v
+	}
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	res, _ := rewrite(s)
+	return res
+}
+
+// staticPrefix returns the segments of the pattern before its first parameter, without the
+// trailing slash
+func staticPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "{:*"); i >= 0 {
+		pattern = pattern[:strings.LastIndex(pattern[:i], "/")+1]
+	}
+	return strings.TrimRight(pattern, "/")
+}
+
+func getURLRewriteConfig(remote *config.Backend) (urlRewriter, bool, error) {
+	v, ok := remote.ExtraConfig[Namespace]
+	if !ok {
+		return urlRewriter{}, false, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return urlRewriter{}, false, nil
+	}
+	tmp, ok := e[urlRewriteKey].(map[string]interface{})
+	if !ok {
+		return urlRewriter{}, false, nil
+	}
+	fields, _ := tmp["fields"].([]interface{})
+	if len(fields) == 0 {
+		return urlRewriter{}, false, nil
+	}
+
+	publicURL, _ := tmp["public_url"].(string)
+	public, err := url.Parse(publicURL)
+	if err != nil || public.Scheme == "" || public.Host == "" {
+		return urlRewriter{}, false, fmt.Errorf("invalid public URL '%s'", publicURL)
+	}
+
+	rw := urlRewriter{
+		Fields:         make([][]string, 0, len(fields)),
+		Public:         public,
+		Hosts:          make(map[string]struct{}, len(remote.Host)),
+		BackendPrefix:  staticPrefix(remote.URLPattern),
+		EndpointPrefix: staticPrefix(remote.ParentEndpoint),
+	}
+	for _, f := range fields {
+		if s, ok := f.(string); ok && s != "" {
+			rw.Fields = append(rw.Fields, strings.Split(s, "."))
+		}
+	}
+	for _, h := range remote.Host {
+		rw.Hosts[strings.ToLower(strings.TrimRight(h, "/"))] = struct{}{}
+	}
+	return rw, true, nil
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewURLRewriteMiddleware(t *testing.T) {
+	remote := &config.Backend{
+		Host:                 []string{"http://items.internal:8080"},
+		URLPattern:           "/v1/items/{id}/children",
+		ParentEndpoint:       "/items/{id}/children",
+		ParentEndpointMethod: "GET",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				urlRewriteKey: map[string]interface{}{
+					"public_url": "https://api.example.com/public",
+					"fields":     []interface{}{"links.next", "links.prev", "items.*.href", "cursor"},
+				},
+			},
+		},
+	}
+	body := `{
+		"links": {
+			"next": "http://items.internal:8080/v1/items/42/children?cursor=abc&size=10",
+			"prev": "https://partner.example.com/v1/items/42/children?cursor=xyz"
+		},
+		"items": [
+			{"href": "http://items.internal:8080/v1/items/43"},
+			{"href": "HTTP://ITEMS.INTERNAL:8080/v1/items/44#details"},
+			{"href": 44},
+			{"name": "no link"}
+		],
+		"cursor": "eyJwYWdlIjoyfQ=="
+	}`
+
+	p := NewURLRewriteMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		data := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(body), &data); err != nil {
+			return nil, err
+		}
+		return &Response{IsComplete: true, Data: data}, nil
+	})
+
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, _ := json.Marshal(resp.Data)
+	expected := `{"cursor":"eyJwYWdlIjoyfQ==","items":[` +
+		`{"href":"https://api.example.com/public/items/43"},` +
+		`{"href":"https://api.example.com/public/items/44#details"},` +
+		`{"href":44},{"name":"no link"}],` +
+		`"links":{"next":"https://api.example.com/public/items/42/children?cursor=abc\u0026size=10",` +
+		`"prev":"https://partner.example.com/v1/items/42/children?cursor=xyz"}}`
+	if string(b) != expected {
+		t.Errorf("unexpected response:\nhave: %s\nwant: %s", string(b), expected)
+	}
+}
+
+func TestNewURLRewriteMiddleware_collection(t *testing.T) {
+	remote := &config.Backend{
+		Host:           []string{"http://items.internal:8080"},
+		URLPattern:     "/v1/items",
+		ParentEndpoint: "/items",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				urlRewriteKey: map[string]interface{}{
+					"public_url": "https://api.example.com",
+					"fields":     []interface{}{"collection.*.links.self"},
+				},
+			},
+		},
+	}
+	p := NewURLRewriteMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true, Data: map[string]interface{}{
+			"collection": []interface{}{
+				map[string]interface{}{"links": map[string]interface{}{"self": "http://items.internal:8080/v1/items?page=2"}},
+				map[string]interface{}{"links": map[string]interface{}{"self": "http://items.internal:8080/v1/itemsets/1"}},
+				map[string]interface{}{"links": map[string]interface{}{"self": "opaque-cursor"}},
+			},
+		}}, nil
+	})
+
+	resp, _ := p(context.Background(), &Request{})
+	col := resp.Data["collection"].([]interface{})
+	for i, expected := range []string{
+		"https://api.example.com/items?page=2",
+		"https://api.example.com/v1/itemsets/1",
+		"opaque-cursor",
+	} {
+		v := col[i].(map[string]interface{})["links"].(map[string]interface{})["self"]
+		if v != expected {
+			t.Errorf("#%d: unexpected value. have: %v, want: %s", i, v, expected)
+		}
+	}
+}
+
+func TestNewURLRewriteMiddleware_wrongConfig(t *testing.T) {
+	for _, publicURL := range []interface{}{nil, "", "/relative", 42} {
+		_, ok, err := getURLRewriteConfig(&config.Backend{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{
+					urlRewriteKey: map[string]interface{}{
+						"public_url": publicURL,
+						"fields":     []interface{}{"next"},
+					},
+				},
+			},
+		})
+		if ok || err == nil {
+			t.Errorf("%v: the config should be rejected", publicURL)
+		}
+	}
+	if _, ok, err := getURLRewriteConfig(&config.Backend{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+}
+
+func TestURLRewriter_prefixes(t *testing.T) {
+	for _, tc := range []struct {
+		backend, endpoint, url, expected string
+	}{
+		{
+			backend:  "/internal/{x}",
+			endpoint: "/public",
+			url:      "http://backend/internal/abc?x=1",
+			expected: "https://gw.example.com/public/abc?x=1",
+		},
+		{
+			backend:  "/v1/items",
+			endpoint: "/items/{page}",
+			url:      "http://backend/v1/items/2",
+			expected: "https://gw.example.com/items/2",
+		},
+		{
+			backend:  "/v1/items/",
+			endpoint: "/items/",
+			url:      "http://backend/v1/items",
+			expected: "https://gw.example.com/items",
+		},
+		{
+			backend:  "/{x}",
+			endpoint: "/api/{x}",
+			url:      "http://backend/abc",
+			expected: "https://gw.example.com/api/abc",
+		},
+		{
+			backend:  "/v1/items",
+			endpoint: "/items",
+			url:      "http://backend/v1/itemsx",
+			expected: "https://gw.example.com/v1/itemsx",
+		},
+	} {
+		rw, _, err := getURLRewriteConfig(&config.Backend{
+			Host:           []string{"http://backend"},
+			URLPattern:     tc.backend,
+			ParentEndpoint: tc.endpoint,
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{
+					urlRewriteKey: map[string]interface{}{
+						"public_url": "https://gw.example.com",
+						"fields":     []interface{}{"next"},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if res, ok := rw.Rewrite(tc.url); !ok || res != tc.expected {
+			t.Errorf("%s -> %s: unexpected rewrite of %s. have: %s, want: %s", tc.backend, tc.endpoint, tc.url, res, tc.expected)
+		}
+	}
+}
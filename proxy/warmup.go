@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	warmUpKey = "warmup"
+
+	defaultWarmUpTimeout = 10 * time.Second
+)
+
+// WarmUpConfig defines the requests to execute against the endpoints before serving traffic,
+// so the caches of their backends are primed
+type WarmUpConfig struct {
+	// Timeout is the maximum duration of every warm-up request. Defaults to 10s
+	Timeout string `json:"timeout"`
+	// Requests is the list of sample requests
+	Requests []WarmUpRequest `json:"requests"`
+}
+
+// WarmUpRequest is a sample request for an endpoint
+type WarmUpRequest struct {
+	// Endpoint is the path pattern of the endpoint, as defined in its config
+	Endpoint string `json:"endpoint"`
+	// Method is the method of the endpoint. Defaults to GET
+	Method  string              `json:"method"`
+	Params  map[string]string   `json:"params"`
+	Query   map[string][]string `json:"query"`
+	Headers map[string][]string `json:"headers"`
+}
+
+// GetWarmUpConfig parses the warm-up block of the service extra config
+func GetWarmUpConfig(extra config.ExtraConfig) (WarmUpConfig, bool, error) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return WarmUpConfig{}, false, nil
+	}
+	tmp, ok := e[warmUpKey]
+	if !ok {
+		return WarmUpConfig{}, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return WarmUpConfig{}, false, err
+	}
+	cfg := WarmUpConfig{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return WarmUpConfig{}, false, err
+	}
+	return cfg, len(cfg.Requests) > 0, nil
+}
+
+// WarmUp executes the sample requests defined in the service extra config for the endpoint
+// against its proxy, so the routers can prime the backend caches before serving traffic.
+// The failures are logged but they do not stop the process. It returns the number of
+// successful requests.
+func WarmUp(ctx context.Context, cfg config.ServiceConfig, e *config.EndpointConfig, p Proxy, logger logging.Logger) int {
+	logPrefix := fmt.Sprintf("[ENDPOINT: %s][WarmUp]", e.Endpoint)
+	warmUpCfg, ok, err := GetWarmUpConfig(cfg.ExtraConfig)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return 0
+	}
+	if !ok {
+		return 0
+	}
+	timeout, err := time.ParseDuration(warmUpCfg.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = defaultWarmUpTimeout
+	}
+
+	primed := 0
+	for _, r := range warmUpCfg.Requests {
+		method := strings.ToUpper(r.Method)
+		if method == "" {
+			method = "GET"
+		}
+		if r.Endpoint != e.Endpoint || !strings.EqualFold(method, e.Method) {
+			continue
+		}
+
+		request := newWarmUpRequest(e.Endpoint, method, r)
+		reqCtx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := p(reqCtx, request)
+		cancel()
+
+		switch {
+		case err != nil:
+			logger.Warning(logPrefix, "Unable to warm up", request.Path, err.Error())
+		case resp == nil || !resp.IsComplete:
+			logger.Warning(logPrefix, "Incomplete response warming up", request.Path)
+		default:
+			logger.Debug(logPrefix, "Warmed up", request.Path)
+			primed++
+		}
+	}
+	return primed
+}
+
+// newWarmUpRequest builds the request the router would create for the sample one
+func newWarmUpRequest(endpoint, method string, r WarmUpRequest) *Request {
+	params := make(map[string]string, len(r.Params))
+	path := endpoint
+	for k, v := range r.Params {
+		if k == "" {
+			continue
+		}
+		params[textproto.CanonicalMIMEHeaderKey(k[:1])+k[1:]] = v
+		path = strings.ReplaceAll(path, "{"+k+"}", v)
+		path = strings.ReplaceAll(path, ":"+k, v)
+	}
+
+	headers := make(map[string][]string, len(r.Headers))
+	for k, vs := range r.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = vs
+	}
+
+	return &Request{
+		Method:  method,
+		Path:    path,
+		Query:   url.Values(r.Query),
+		Params:  params,
+		Headers: headers,
+	}
+}
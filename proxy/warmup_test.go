@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestWarmUp(t *testing.T) {
+	mu := new(sync.Mutex)
+	calls := map[string]int{}
+	backendFactory := func(_ *config.Backend) Proxy {
+		return func(_ context.Context, r *Request) (*Response, error) {
+			mu.Lock()
+			calls[r.Path]++
+			mu.Unlock()
+			if r.Path == "/users/0" {
+				return nil, errors.New("user not found")
+			}
+			return &Response{IsComplete: true, Data: map[string]interface{}{"path": r.Path, "page": r.Query.Get("page")}}, nil
+		}
+	}
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/user/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{
+				URLPattern: "/users/{{.Id}}",
+				Host:       []string{"http://example.com"},
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{cacheKey: map[string]interface{}{"ttl": "1m"}},
+				},
+			},
+		},
+	}
+	p, err := NewDefaultFactory(backendFactory, logging.NoOp).New(endpoint)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				warmUpKey: map[string]interface{}{
+					"timeout": "1s",
+					"requests": []interface{}{
+						map[string]interface{}{
+							"endpoint": "/user/{id}",
+							"params":   map[string]interface{}{"id": "42"},
+							"query":    map[string]interface{}{"page": []interface{}{"1"}},
+						},
+						map[string]interface{}{"endpoint": "/user/{id}", "params": map[string]interface{}{"id": "0"}},
+						map[string]interface{}{"endpoint": "/user/{id}", "method": "POST", "params": map[string]interface{}{"id": "1"}},
+						map[string]interface{}{"endpoint": "/other", "params": map[string]interface{}{"id": "2"}},
+					},
+				},
+			},
+		},
+	}
+
+	if n := WarmUp(context.Background(), cfg, endpoint, p, logging.NoOp); n != 1 {
+		t.Errorf("unexpected number of primed requests: %d", n)
+	}
+	if len(calls) != 2 || calls["/users/42"] != 1 || calls["/users/0"] != 1 {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+
+	resp, err := p(context.Background(), &Request{
+		Method: "GET",
+		Path:   "/user/42",
+		Params: map[string]string{"Id": "42"},
+		Query:  map[string][]string{"page": {"1"}},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if resp.Data["path"] != "/users/42" || resp.Data["page"] != "1" {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+	if calls["/users/42"] != 1 {
+		t.Error("the response should be served from the primed cache")
+	}
+}
+
+func TestWarmUp_notConfigured(t *testing.T) {
+	endpoint := &config.EndpointConfig{Endpoint: "/foo", Method: "GET"}
+	p := func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the proxy should not be called")
+		return nil, nil
+	}
+	if n := WarmUp(context.Background(), config.ServiceConfig{}, endpoint, p, logging.NoOp); n != 0 {
+		t.Errorf("unexpected number of primed requests: %d", n)
+	}
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{warmUpKey: map[string]interface{}{"requests": "/foo"}},
+		},
+	}
+	if n := WarmUp(context.Background(), cfg, endpoint, p, logging.NoOp); n != 0 {
+		t.Errorf("unexpected number of primed requests: %d", n)
+	}
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package random provides the injectable sources of pseudo random numbers used by the lura
+components taking random decisions (balancers, jittered backoffs...), so they can be seeded
+for deterministic tests and their draws recorded per request while debugging.
+*/
+package random
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Source generates pseudo random numbers. The implementations must be safe for concurrent use.
+type Source interface {
+	// Intn returns a non-negative pseudo random number in [0,n). It panics if n <= 0.
+	Intn(n int) int
+	// Float64 returns a pseudo random number in [0.0,1.0)
+	Float64() float64
+}
+
+// NewSource returns a Source generating the sequence defined by the seed
+func NewSource(seed int64) Source {
+	return &lockedSource{
+		mu: &sync.Mutex{},
+		r:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+var defaultSource = NewSource(time.Now().UnixNano())
+
+// Default returns the shared Source used by the components not receiving an explicit one
+func Default() Source {
+	return defaultSource
+}
+
+type lockedSource struct {
+	mu *sync.Mutex
+	r  *rand.Rand
+}
+
+// Intn implements the Source interface
+func (l *lockedSource) Intn(n int) int {
+	l.mu.Lock()
+	v := l.r.Intn(n)
+	l.mu.Unlock()
+	return v
+}
+
+// Float64 implements the Source interface
+func (l *lockedSource) Float64() float64 {
+	l.mu.Lock()
+	v := l.r.Float64()
+	l.mu.Unlock()
+	return v
+}
+
+type sourceKey struct{}
+
+// NewContext returns a copy of the context carrying the source, so the components built
+// without an explicit one can draw from it while processing the request
+func NewContext(ctx context.Context, src Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, src)
+}
+
+// FromContext returns the source carried by the context or, if there is none, the fallback
+func FromContext(ctx context.Context, fallback Source) Source {
+	if src, ok := ctx.Value(sourceKey{}).(Source); ok {
+		return src
+	}
+	return fallback
+}
+
+type decisionsKey struct{}
+
+// Decisions keeps a record of the random draws taken on behalf of the requests processed
+// with a context containing it, so the routers can expose them when debugging a request
+type Decisions struct {
+	entries []string
+	mu      *sync.Mutex
+}
+
+// NewDecisions returns an empty Decisions
+func NewDecisions() *Decisions {
+	return &Decisions{mu: new(sync.Mutex)}
+}
+
+// WithContext returns a copy of the context containing the decisions
+func (d *Decisions) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, decisionsKey{}, d)
+}
+
+// Add stores the record of a draw
+func (d *Decisions) Add(entry string) {
+	d.mu.Lock()
+	d.entries = append(d.entries, entry)
+	d.mu.Unlock()
+}
+
+// Entries returns the records of the draws
+func (d *Decisions) Entries() []string {
+	d.mu.Lock()
+	res := make([]string, len(d.entries))
+	copy(res, d.entries)
+	d.mu.Unlock()
+	return res
+}
+
+// NewDecisionLog wraps the source, so the draws taken with the versions of the source
+// returned by ForContext are recorded in the Decisions of the request context
+func NewDecisionLog(src Source) Source {
+	return decisionLog{src: src, name: "[RANDOM]"}
+}
+
+// WithName returns a version of the source labelling its draws with the received name, if
+// it is a decision log. Other sources are returned as they are.
+func WithName(src Source, name string) Source {
+	if d, ok := src.(decisionLog); ok {
+		d.name = name
+		return d
+	}
+	return src
+}
+
+// ForContext returns a version of the source recording its draws in the Decisions of the
+// context, if it is a decision log and the context contains them. Otherwise, the source is
+// returned as it is.
+func ForContext(ctx context.Context, src Source) Source {
+	d, ok := src.(decisionLog)
+	if !ok {
+		return src
+	}
+	if d.decisions, ok = ctx.Value(decisionsKey{}).(*Decisions); !ok {
+		return src
+	}
+	return d
+}
+
+type decisionLog struct {
+	src       Source
+	name      string
+	decisions *Decisions
+}
+
+// Intn implements the Source interface
+func (d decisionLog) Intn(n int) int {
+	v := d.src.Intn(n)
+	if d.decisions != nil {
+		d.decisions.Add(fmt.Sprintf("%s Intn(%d) = %d", d.name, n, v))
+	}
+	return v
+}
+
+// Float64 implements the Source interface
+func (d decisionLog) Float64() float64 {
+	v := d.src.Float64()
+	if d.decisions != nil {
+		d.decisions.Add(fmt.Sprintf("%s Float64() = %f", d.name, v))
+	}
+	return v
+}
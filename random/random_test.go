@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package random
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewSource(t *testing.T) {
+	a, b := NewSource(42), NewSource(42)
+	for i := 0; i < 100; i++ {
+		if x, y := a.Intn(10), b.Intn(10); x != y {
+			t.Errorf("#%d: the sources with the same seed should return the same sequence: %d != %d", i, x, y)
+		}
+		if x, y := a.Float64(), b.Float64(); x != y {
+			t.Errorf("#%d: the sources with the same seed should return the same sequence: %f != %f", i, x, y)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if v := Default().Intn(5); v < 0 || v >= 5 {
+					t.Errorf("unexpected value: %d", v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewDecisionLog(t *testing.T) {
+	src := WithName(NewDecisionLog(NewSource(1)), "[BACKEND: /foo][Balancer]")
+	expected := NewSource(1)
+
+	a, b := NewDecisions(), NewDecisions()
+	ctxA, ctxB := a.WithContext(context.Background()), b.WithContext(context.Background())
+
+	if v := ForContext(ctxA, src).Intn(3); v != expected.Intn(3) {
+		t.Errorf("unexpected value: %d", v)
+	}
+	ForContext(ctxB, src).Float64()
+	expected.Float64()
+	if v := src.Intn(3); v != expected.Intn(3) {
+		t.Errorf("unexpected value: %d", v)
+	}
+
+	if entries := a.Entries(); len(entries) != 1 || !strings.HasPrefix(entries[0], "[BACKEND: /foo][Balancer] Intn(3) = ") {
+		t.Errorf("unexpected entries of the first request: %v", entries)
+	}
+	if entries := b.Entries(); len(entries) != 1 || !strings.HasPrefix(entries[0], "[BACKEND: /foo][Balancer] Float64() = ") {
+		t.Errorf("unexpected entries of the second request: %v", entries)
+	}
+
+	if s := ForContext(context.Background(), src); s != src {
+		t.Error("the sources should not be bound to the contexts without decisions")
+	}
+	if s := WithName(expected, "foo"); s != expected {
+		t.Error("the regular sources should not be wrapped")
+	}
+	if s := ForContext(ctxA, expected); s != expected {
+		t.Error("the regular sources should not be bound")
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	src, fallback := NewSource(1), NewSource(2)
+	if FromContext(context.Background(), fallback) != fallback {
+		t.Error("the fallback should be returned when the context has no source")
+	}
+	if FromContext(NewContext(context.Background(), src), fallback) != src {
+		t.Error("the source of the context should be returned")
+	}
+}
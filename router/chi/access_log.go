@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package chi
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AccessLogger is an access log middleware logging the same information as the chi
+// middleware.Logger, adding the pattern of the matched route as a distinct field. The default
+// factory keeps the chi logger, so it has to be set in the Middlewares of the Config to
+// replace it.
+var AccessLogger = middleware.RequestLogger(NewAccessLogFormatter(log.New(os.Stdout, "", log.LstdFlags)))
+
+// NewAccessLogFormatter returns a chi LogFormatter printing the access log lines with the
+// injected logger. Every line includes the pattern of the matched route, read from the chi
+// route context once the request has been served:
+//
+//	"GET http://example.com/users/42 HTTP/1.1" from 127.0.0.1:1234 - 200 12B in 1.2ms route=/users/{id}
+func NewAccessLogFormatter(logger middleware.LoggerInterface) middleware.LogFormatter {
+	return accessLogFormatter{logger: logger}
+}
+
+type accessLogFormatter struct {
+	logger middleware.LoggerInterface
+}
+
+// NewLogEntry implements the middleware.LogFormatter interface
+func (f accessLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	e := &accessLogEntry{logger: f.logger, request: r, buf: new(bytes.Buffer)}
+
+	if reqID := middleware.GetReqID(r.Context()); reqID != "" {
+		fmt.Fprintf(e.buf, "[%s] ", reqID)
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	fmt.Fprintf(e.buf, "\"%s %s://%s%s %s\" from %s - ", r.Method, scheme, r.Host, r.RequestURI, r.Proto, r.RemoteAddr)
+	return e
+}
+
+type accessLogEntry struct {
+	logger  middleware.LoggerInterface
+	request *http.Request
+	buf     *bytes.Buffer
+}
+
+// Write implements the middleware.LogEntry interface
+func (e *accessLogEntry) Write(status, bytes int, _ http.Header, elapsed time.Duration, _ interface{}) {
+	pattern := ""
+	if rctx := chi.RouteContext(e.request.Context()); rctx != nil {
+		pattern = rctx.RoutePattern()
+	}
+	fmt.Fprintf(e.buf, "%03d %dB in %s route=%s", status, bytes, elapsed, pattern)
+	e.logger.Print(e.buf.String())
+}
+
+// Panic implements the middleware.LogEntry interface
+func (e *accessLogEntry) Panic(v interface{}, stack []byte) {
+	middleware.PrintPrettyStack(v)
+}
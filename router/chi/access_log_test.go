@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package chi
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewAccessLogFormatter(t *testing.T) {
+	buff := new(bytes.Buffer)
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"id": 42}}, nil
+	}
+
+	engine := chi.NewRouter()
+	engine.Use(middleware.RequestLogger(NewAccessLogFormatter(log.New(buff, "", 0))))
+	engine.Get("/users/{id}", NewEndpointHandler(endpoint, p))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	line := buff.String()
+	if !strings.HasPrefix(line, "\"GET http://example.com/users/42 HTTP/1.1\" from 192.0.2.1:1234 - 200 ") {
+		t.Errorf("unexpected log line: %s", line)
+	}
+	if !strings.Contains(line, "route=/users/{id}") {
+		t.Errorf("the log line should contain the route pattern: %s", line)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/luraproject/lura/v2/proxy"
 	"github.com/luraproject/lura/v2/router"
 	"github.com/luraproject/lura/v2/router/mux"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -94,7 +95,10 @@ func (r chiRouter) Run(cfg config.ServiceConfig) {
 
 	server.InitHTTPDefaultTransport(cfg)
 
-	r.registerKrakendEndpoints(cfg.Endpoints)
+	warmCtx, stopWarmPools := context.WithCancel(r.ctx)
+	warmPools := client.StartWarmPools(warmCtx, cfg, client.NewHTTPClient, r.cfg.Logger)
+
+	r.registerKrakendEndpoints(cfg)
 
 	r.cfg.Engine.NotFound(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
@@ -105,6 +109,9 @@ func (r chiRouter) Run(cfg config.ServiceConfig) {
 		r.cfg.Logger.Error(logPrefix, err.Error())
 	}
 
+	stopWarmPools()
+	warmPools.Wait()
+
 	r.cfg.Logger.Info(logPrefix, "Router execution ended")
 }
 
@@ -117,15 +124,23 @@ func (r chiRouter) registerDebugEndpoints() {
 	r.cfg.Engine.Delete(r.cfg.DebugPattern, debugHandler)
 }
 
-func (r chiRouter) registerKrakendEndpoints(endpoints []*config.EndpointConfig) {
-	for _, c := range endpoints {
+func (r chiRouter) registerKrakendEndpoints(cfg config.ServiceConfig) {
+	for _, c := range cfg.Endpoints {
 		proxyStack, err := r.cfg.ProxyFactory.New(c)
 		if err != nil {
 			r.cfg.Logger.Error(logPrefix, "calling the ProxyFactory", err.Error())
 			continue
 		}
+		proxy.WarmUp(r.ctx, cfg, c, proxyStack, r.cfg.Logger)
 
-		r.registerKrakendEndpoint(c.Method, c, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
+		h := r.cfg.HandlerFactory(c, proxyStack)
+		if allowed := server.GetAllowedContentTypes(c.ExtraConfig); len(allowed) > 0 {
+			h = server.NewAllowedContentTypesHandler(allowed, h).ServeHTTP
+		}
+		if max := server.EndpointMaxURLLength(cfg, c); max > 0 {
+			h = server.NewMaxURLLengthHandler(max, h).ServeHTTP
+		}
+		r.registerKrakendEndpoint(c.Method, c, h, len(c.Backend))
 	}
 }
 
@@ -330,3 +330,69 @@ type erroredProxyFactory struct {
 func (e erroredProxyFactory) New(_ *config.EndpointConfig) (proxy.Proxy, error) {
 	return proxy.NoopProxy, e.Error
 }
+
+func TestDefaultFactory_allowedContentTypes(t *testing.T) {
+	buff := bytes.NewBuffer(make([]byte, 1024))
+	logger, err := logging.NewLogger("ERROR", buff, "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		time.Sleep(5 * time.Millisecond)
+	}()
+
+	r := DefaultFactory(noopProxyFactory(map[string]interface{}{"supu": "tupu"}), logger).NewWithContext(ctx)
+
+	serviceCfg := config.ServiceConfig{
+		Port: 8085,
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/any",
+				Method:   "POST",
+				Timeout:  10 * time.Second,
+				Backend:  []*config.Backend{{}},
+			},
+			{
+				Endpoint: "/json",
+				Method:   "POST",
+				Timeout:  10 * time.Second,
+				Backend:  []*config.Backend{{}},
+				ExtraConfig: config.ExtraConfig{
+					server.Namespace: map[string]interface{}{
+						"allowed_content_types": []interface{}{"application/json", "application/*+json"},
+					},
+				},
+			},
+		},
+	}
+
+	go func() { r.Run(serviceCfg) }()
+
+	time.Sleep(5 * time.Millisecond)
+
+	for _, tc := range []struct {
+		path        string
+		contentType string
+		status      int
+	}{
+		{path: "/any", contentType: "application/xml", status: http.StatusOK},
+		{path: "/json", contentType: "application/json; charset=utf-8", status: http.StatusOK},
+		{path: "/json", contentType: "application/problem+json", status: http.StatusOK},
+		{path: "/json", contentType: "application/xml", status: http.StatusUnsupportedMediaType},
+		{path: "/json", contentType: "text/plain", status: http.StatusUnsupportedMediaType},
+	} {
+		resp, err := http.Post("http://127.0.0.1:8085"+tc.path, tc.contentType, strings.NewReader("{}"))
+		if err != nil {
+			t.Error("Making the request:", err.Error())
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s (%s): unexpected status code. have: %d, want: %d", tc.path, tc.contentType, resp.StatusCode, tc.status)
+		}
+	}
+}
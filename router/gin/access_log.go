@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoutePatternKey is the key of the gin context where the endpoint handlers store the pattern
+// of the matched route
+const RoutePatternKey = "lura.route_pattern"
+
+// AccessLogFormatter is the formatter of the access log enabled with the
+// 'access_log_route_pattern' option. It prints the same line as the gin default formatter,
+// adding the pattern of the matched route as a distinct field:
+//
+//	[GIN] 2006/01/02 - 15:04:05 | 200 |   1.2ms |   127.0.0.1 | GET     "/users/42" route="/users/{id}"
+func AccessLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	pattern, _ := param.Keys[RoutePatternKey].(string)
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v route=%#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		param.Path,
+		pattern,
+		param.ErrorMessage,
+	)
+}
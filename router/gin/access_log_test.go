@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestAccessLogFormatter(t *testing.T) {
+	buff := new(bytes.Buffer)
+	engine := NewEngine(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"access_log_route_pattern": true},
+		},
+	}, EngineOptions{Writer: buff})
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"id": 42}}, nil
+	}
+	engine.GET("/users/:id", EndpointHandler(endpoint, p))
+	engine.GET("/other", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	line := buff.String()
+	if !strings.Contains(line, `"/users/42" route="/users/{id}"`) {
+		t.Errorf("the log line should contain the route pattern: %s", line)
+	}
+
+	buff.Reset()
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/other", nil))
+	if line := buff.String(); !strings.Contains(line, `"/other" route=""`) {
+		t.Errorf("unexpected log line: %s", line)
+	}
+}
+
+func TestNewEngine_defaultAccessLog(t *testing.T) {
+	buff := new(bytes.Buffer)
+	engine := NewEngine(config.ServiceConfig{}, EngineOptions{Writer: buff})
+	engine.GET("/other", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/other", nil))
+	if line := buff.String(); !strings.Contains(line, `"/other"`) || strings.Contains(line, "route=") {
+		t.Errorf("the default access log should keep the gin format: %s", line)
+	}
+}
@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// NewAllowedContentTypesMiddleware returns a gin middleware aborting the requests with a body
+// of a content type not in the allowed list with a 415 status code
+func NewAllowedContentTypesMiddleware(allowed []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !server.ContentTypeAllowed(c.Request, allowed) {
+			c.Header(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
+			c.AbortWithStatus(http.StatusUnsupportedMediaType)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewAllowedContentTypesMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/foo", withMiddleware(NewAllowedContentTypesMiddleware([]string{"application/*"}), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	}))
+
+	for contentType, status := range map[string]int{
+		"application/json":       http.StatusOK,
+		"application/x-protobuf": http.StatusOK,
+		"text/plain":             http.StatusUnsupportedMediaType,
+	} {
+		req := httptest.NewRequest("POST", "/foo", strings.NewReader("body"))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%s: unexpected status code. have: %d, want: %d", contentType, w.Code, status)
+		}
+	}
+}
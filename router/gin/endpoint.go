@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"net/textproto"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/luraproject/lura/v2/core"
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -40,14 +42,38 @@ func CustomErrorEndpointHandler(logger logging.Logger, errF server.ToHTTPError)
 		isCacheEnabled := configuration.CacheTTL.Seconds() != 0
 		requestGenerator := NewRequest(configuration.HeadersToPass)
 		render := getRender(configuration)
+		debugTrace := server.EndpointDebugTrace(configuration)
+		signature := server.EndpointResponseSignature(configuration)
 		logPrefix := "[ENDPOINT: " + configuration.Endpoint + "]"
+		securityHeaders, err := server.EndpointSecurityHeaders(configuration)
+		if err != nil {
+			logger.Error(logPrefix, err.Error(), "All the requests will be rejected")
+			prxy = func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+				return nil, err
+			}
+		}
 
 		return func(c *gin.Context) {
+			c.Set(RoutePatternKey, configuration.Endpoint)
 			requestCtx, cancel := context.WithTimeout(c, configuration.Timeout)
 
 			c.Header(core.KrakendHeaderName, core.KrakendHeaderValue)
+			securityHeaders.Apply(c.Writer, c.Request)
+
+			if signature != nil {
+				w := newSignedResponseWriter(c.Writer, signature)
+				c.Writer = w
+				defer func() {
+					if err := w.close(); err != nil {
+						logger.Error(logPrefix, "Signing the response:", err.Error())
+					}
+				}()
+			}
 
+			requestCtx, trace := debugTrace.Start(requestCtx, c.Request)
+			start := time.Now()
 			response, err := prxy(requestCtx, requestGenerator(c, configuration.QueryString))
+			debugTrace.Apply(c.Writer, trace, time.Since(start))
 
 			select {
 			case <-requestCtx.Done():
@@ -103,6 +129,10 @@ func CustomErrorEndpointHandler(logger logging.Logger, errF server.ToHTTPError)
 					cancel()
 					return
 				}
+
+				if t, ok := err.(client.PassthroughStatusError); ok {
+					c.Status(t.StatusCode())
+				}
 			}
 
 			render(c, response)
@@ -116,6 +146,15 @@ func NewRequest(headersToSend []string) func(*gin.Context, []string) *proxy.Requ
 	if len(headersToSend) == 0 {
 		headersToSend = server.HeadersToSend
 	}
+	passAllHeaders := false
+	canonicalHeaders := make([]string, len(headersToSend))
+	for i, k := range headersToSend {
+		if k == requestParamsAsterisk {
+			passAllHeaders = true
+			break
+		}
+		canonicalHeaders[i] = textproto.CanonicalMIMEHeaderKey(k)
+	}
 
 	return func(c *gin.Context, queryString []string) *proxy.Request {
 		params := make(map[string]string, len(c.Params))
@@ -123,17 +162,15 @@ func NewRequest(headersToSend []string) func(*gin.Context, []string) *proxy.Requ
 			params[textproto.CanonicalMIMEHeaderKey(param.Key[:1])+param.Key[1:]] = param.Value
 		}
 
-		headers := make(map[string][]string, 3+len(headersToSend))
-
-		for _, k := range headersToSend {
-			if k == requestParamsAsterisk {
-				headers = c.Request.Header
-
-				break
-			}
-
-			if h, ok := c.Request.Header[textproto.CanonicalMIMEHeaderKey(k)]; ok {
-				headers[k] = h
+		var headers map[string][]string
+		if passAllHeaders {
+			headers = c.Request.Header
+		} else {
+			headers = make(map[string][]string, 3+len(headersToSend))
+			for i, k := range headersToSend {
+				if h, ok := c.Request.Header[canonicalHeaders[i]]; ok {
+					headers[k] = h
+				}
 			}
 		}
 
@@ -151,7 +188,7 @@ func NewRequest(headersToSend []string) func(*gin.Context, []string) *proxy.Requ
 		queryValues := c.Request.URL.Query()
 		for i := range queryString {
 			if queryString[i] == requestParamsAsterisk {
-				query = c.Request.URL.Query()
+				query = queryValues
 
 				break
 			}
@@ -128,3 +128,22 @@ func BenchmarkEndpointHandler_ok_Parallel(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkNewRequest_headers(b *testing.B) {
+	headersToSend := make([]string, 30)
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_gin_endpoint/a?b=1", http.NoBody)
+	for i := range headersToSend {
+		headersToSend[i] = fmt.Sprintf("x-header-%d", i)
+		req.Header.Set(headersToSend[i], "some value")
+	}
+	requestGenerator := NewRequest(headersToSend)
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		requestGenerator(c, []string{"b"})
+	}
+}
@@ -5,6 +5,9 @@ package gin
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"io"
@@ -19,6 +22,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -182,6 +186,26 @@ func TestEndpointHandler_errored_encodedResponseError(t *testing.T) {
 	returnErrorMsg = false
 }
 
+func TestEndpointHandler_errored_passthrough(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: false,
+			Data:       map[string]interface{}{"error": "conflict", "id": 3},
+		}, client.PassthroughStatusError{Code: http.StatusConflict}
+	}
+	endpointHandlerTestCase{
+		timeout:            10,
+		proxy:              p,
+		method:             "GET",
+		expectedBody:       `{"error":"conflict","id":3}`,
+		expectedCache:      "",
+		expectedContent:    "application/json; charset=utf-8",
+		expectedStatusCode: http.StatusConflict,
+		completed:          false,
+	}.test(t)
+	time.Sleep(5 * time.Millisecond)
+}
+
 type dummyResponseError struct {
 	err    string
 	status int
@@ -404,3 +428,35 @@ func ctxMiddleware(c *gin.Context) {
 		c.Set(k, v)
 	}
 }
+
+func TestEndpointHandler_responseSignature(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Method:  "GET",
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{
+			server.Namespace: map[string]interface{}{
+				"response_signature": map[string]interface{}{"key": "s3cr3t", "header": "X-Body-Signature"},
+			},
+		},
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"supu": "tupu"}}, nil
+	}
+	s := startGinServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_gin_endpoint/a", http.NoBody)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if w.Body.String() != `{"supu":"tupu"}` {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(`{"supu":"tupu"}`))
+	if sig := w.Header().Get("X-Body-Signature"); sig != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected signature: %s", sig)
+	}
+}
@@ -90,11 +90,15 @@ func NewEngine(cfg config.ServiceConfig, opt EngineOptions) *gin.Engine {
 	})
 
 	if !ginOptions.DisableAccessLog {
+		formatter := opt.Formatter
+		if formatter == nil && ginOptions.AccessLogRoutePattern {
+			formatter = AccessLogFormatter
+		}
 		engine.Use(
 			gin.LoggerWithConfig(gin.LoggerConfig{
 				Output:    opt.Writer,
 				SkipPaths: paths,
-				Formatter: opt.Formatter,
+				Formatter: formatter,
 			}),
 		)
 	}
@@ -231,6 +235,10 @@ type engineConfiguration struct {
 	// DisableAccessLog blocks the injection of the router logger
 	DisableAccessLog bool `json:"disable_access_log"`
 
+	// AccessLogRoutePattern replaces the default format of the access log with the
+	// AccessLogFormatter, adding the pattern of the matched route to every line
+	AccessLogRoutePattern bool `json:"access_log_route_pattern"`
+
 	// DisablePathDecoding disables automatic validation of the url params looking for url encoded ones.
 	// For example if /foo/..%252Fbar is requested and this flag is set to false, the router will
 	// reject the request with http status code 400.
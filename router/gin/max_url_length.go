@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// NewMaxURLLengthMiddleware returns a gin middleware aborting the requests with a URL longer
+// than max with a 414 status code
+func NewMaxURLLengthMiddleware(max int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if server.URLLength(c.Request) > max {
+			c.Header(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
+			c.AbortWithStatus(http.StatusRequestURITooLong)
+			return
+		}
+		c.Next()
+	}
+}
@@ -5,12 +5,12 @@ package gin
 import (
 	"io"
 	"net/http"
-	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router"
 )
 
 // Render defines the signature of the functions to be use for the final response
@@ -22,62 +22,79 @@ const NEGOTIATE = "negotiate"
 const XML = "xml"
 const YAML = "yaml"
 
-var (
-	mutex          = &sync.RWMutex{}
-	renderRegister = map[string]Render{
-		encoding.STRING:   stringRender,
-		encoding.JSON:     jsonRender,
-		encoding.NOOP:     noopRender,
-		"json-collection": jsonCollectionRender,
-		XML:               xmlRender,
-		YAML:              yamlRender,
-	}
-)
+var builtinRenders = map[string]Render{
+	encoding.STRING:       stringRender,
+	encoding.JSON:         jsonRender,
+	encoding.NOOP:         noopRender,
+	router.JSONCollection: jsonCollectionRender,
+}
 
 func init() {
-	// the negotiated render must be registered at the init function in order
-	// to avoid a cyclical dependency
-	renderRegister[NEGOTIATE] = negotiatedRender
+	// the gin specific renders are registered at the init function in order to avoid a
+	// cyclical dependency with the negotiated render
+	RegisterRender(NEGOTIATE, negotiatedRender)
+	RegisterRender(XML, xmlRender)
+	RegisterRender(YAML, yamlRender)
 }
 
-// RegisterRender allows clients to register their custom renders
+// RegisterRender allows clients to register their custom renders. The renders are stored
+// in the shared registry of the router package. When they are selected by a router not
+// based on gin, the response is rendered with the default JSON renderer.
 func RegisterRender(name string, r Render) {
-	mutex.Lock()
-	renderRegister[name] = r
-	mutex.Unlock()
+	router.RegisterRenderer(name, ginRenderer{render: r})
 }
 
+// ginRenderer adapts the renders registered by this package to the shared registry
+type ginRenderer struct {
+	render Render
+}
+
+// Render implements the router.Renderer interface
+func (g ginRenderer) Render(w http.ResponseWriter, resp *proxy.Response, cfg *config.EndpointConfig) {
+	router.JSONRenderer().Render(w, resp, cfg)
+}
+
+// getRender returns the render for the endpoint, resolved with the shared registry of the
+// router package. The default renderers of the registry are replaced by their gin version.
 func getRender(cfg *config.EndpointConfig) Render {
 	fallback := jsonRender
 	if len(cfg.Backend) == 1 {
-		fallback = getWithFallback(cfg.Backend[0].Encoding, fallback)
+		fallback = getWithFallback(cfg, cfg.Backend[0].Encoding, fallback)
 	}
 
 	if cfg.OutputEncoding == "" {
 		return fallback
 	}
 
-	return getWithFallback(cfg.OutputEncoding, fallback)
+	return getWithFallback(cfg, cfg.OutputEncoding, fallback)
 }
 
-func getWithFallback(key string, fallback Render) Render {
-	mutex.RLock()
-	r, ok := renderRegister[key]
-	mutex.RUnlock()
+func getWithFallback(cfg *config.EndpointConfig, key string, fallback Render) Render {
+	r, ok := router.GetRenderer(key)
 	if !ok {
 		return fallback
 	}
-	return r
+	if g, ok := r.(ginRenderer); ok {
+		return g.render
+	}
+	if router.IsBuiltinRenderer(r) {
+		if b, ok := builtinRenders[key]; ok {
+			return b
+		}
+	}
+	return func(c *gin.Context, response *proxy.Response) {
+		r.Render(c.Writer, response, cfg)
+	}
 }
 
 func negotiatedRender(c *gin.Context, response *proxy.Response) {
 	switch c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain, gin.MIMEXML) {
 	case gin.MIMEXML:
-		getWithFallback(XML, jsonRender)(c, response)
+		getWithFallback(nil, XML, jsonRender)(c, response)
 	case gin.MIMEPlain:
-		getWithFallback(YAML, jsonRender)(c, response)
+		getWithFallback(nil, YAML, jsonRender)(c, response)
 	default:
-		getWithFallback(encoding.JSON, jsonRender)(c, response)
+		getWithFallback(nil, encoding.JSON, jsonRender)(c, response)
 	}
 }
 
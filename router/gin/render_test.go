@@ -17,6 +17,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router"
 )
 
 func TestRender_Negotiated_ok(t *testing.T) {
@@ -534,3 +535,65 @@ func TestRender_noop_nilResponse(t *testing.T) {
 		t.Error("Unexpected status code:", w.Result().StatusCode)
 	}
 }
+
+func TestRender_sharedRegistry(t *testing.T) {
+	router.RegisterRenderer("gin-csv", router.RendererFunc(func(w http.ResponseWriter, resp *proxy.Response, cfg *config.EndpointConfig) {
+		w.Header().Set("Content-Type", "text/csv")
+		fmt.Fprintf(w, "%s,%v", cfg.Endpoint, resp.Data["a"])
+	}))
+
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"a": "b"}}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Endpoint:       "/_gin_endpoint",
+		Timeout:        time.Second,
+		OutputEncoding: "gin-csv",
+	}
+
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.GET("/_gin_endpoint", EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_gin_endpoint", http.NoBody)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if ct := w.Result().Header.Get("Content-Type"); ct != "text/csv" {
+		t.Error("Unexpected Content-Type:", ct)
+	}
+	if w.Result().StatusCode != http.StatusOK {
+		t.Error("Unexpected status code:", w.Result().StatusCode)
+	}
+	if body := w.Body.String(); body != "/_gin_endpoint,b" {
+		t.Error("Unexpected body:", body)
+	}
+}
+
+func TestRegisterRender_overrideJSON(t *testing.T) {
+	defer router.RegisterRenderer(encoding.JSON, router.JSONRenderer())
+	RegisterRender(encoding.JSON, func(c *gin.Context, _ *proxy.Response) {
+		c.String(http.StatusTeapot, "custom")
+	})
+
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"a": "b"}}, nil
+	}
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.GET("/_gin_endpoint", EndpointHandler(&config.EndpointConfig{Timeout: time.Second, OutputEncoding: NEGOTIATE}, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_gin_endpoint", http.NoBody)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusTeapot || w.Body.String() != "custom" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	shared, _ := router.GetRenderer(encoding.JSON)
+	w = httptest.NewRecorder()
+	shared.Render(w, &proxy.Response{Data: map[string]interface{}{"a": "b"}}, &config.EndpointConfig{})
+	if body := w.Body.String(); body != `{"a":"b"}` {
+		t.Errorf("the gin renders should fall back to the default JSON renderer out of gin: %s", body)
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// signedResponseWriter buffers the response body so the signature header can be added
+// before sending it
+type signedResponseWriter struct {
+	gin.ResponseWriter
+	signature *server.ResponseSignature
+	buf       *bytes.Buffer
+}
+
+func newSignedResponseWriter(w gin.ResponseWriter, s *server.ResponseSignature) *signedResponseWriter {
+	return &signedResponseWriter{ResponseWriter: w, signature: s, buf: new(bytes.Buffer)}
+}
+
+func (w *signedResponseWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func (w *signedResponseWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+func (*signedResponseWriter) WriteHeaderNow() {}
+
+// close signs the buffered body and sends it along with the signature header. If the
+// signature fails, it sends an internal server error instead.
+func (w *signedResponseWriter) close() error {
+	sig, err := w.signature.Sign(w.buf.Bytes())
+	if err != nil {
+		w.Header().Del("Content-Type")
+		w.Header().Del(server.CompleteResponseHeaderName)
+		w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		w.ResponseWriter.WriteString(server.ErrInternalError.Error())
+		return err
+	}
+	w.Header().Set(w.signature.Header, sig)
+	w.ResponseWriter.WriteHeaderNow()
+	_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
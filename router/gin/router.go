@@ -19,6 +19,7 @@ import (
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
 	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -100,6 +101,9 @@ func (r ginRouter) Run(cfg config.ServiceConfig) {
 
 	server.InitHTTPDefaultTransport(cfg)
 
+	warmCtx, stopWarmPools := context.WithCancel(r.ctx)
+	warmPools := client.StartWarmPools(warmCtx, cfg, client.NewHTTPClient, r.cfg.Logger)
+
 	r.registerEndpointsAndMiddlewares(cfg)
 
 	r.cfg.Logger.Info("[SERVICE: Gin] Listening on port:", cfg.Port)
@@ -107,6 +111,9 @@ func (r ginRouter) Run(cfg config.ServiceConfig) {
 		r.cfg.Logger.Error(logPrefix, err.Error())
 	}
 
+	stopWarmPools()
+	warmPools.Wait()
+
 	r.cfg.Logger.Info(logPrefix, "Router execution ended")
 }
 
@@ -140,7 +147,25 @@ func (r ginRouter) registerKrakendEndpoints(rg *gin.RouterGroup, cfg config.Serv
 			r.cfg.Logger.Error(logPrefix, "Calling the ProxyFactory", err.Error())
 			continue
 		}
-		r.registerKrakendEndpoint(rg, c.Method, c, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
+		proxy.WarmUp(r.ctx, cfg, c, proxyStack, r.cfg.Logger)
+		h := r.cfg.HandlerFactory(c, proxyStack)
+		if allowed := server.GetAllowedContentTypes(c.ExtraConfig); len(allowed) > 0 {
+			h = withMiddleware(NewAllowedContentTypesMiddleware(allowed), h)
+		}
+		if max := server.EndpointMaxURLLength(cfg, c); max > 0 {
+			h = withMiddleware(NewMaxURLLengthMiddleware(max), h)
+		}
+		r.registerKrakendEndpoint(rg, c.Method, c, h, len(c.Backend))
+	}
+}
+
+func withMiddleware(mw, h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mw(c)
+		if c.IsAborted() {
+			return
+		}
+		h(c)
 	}
 }
 
@@ -247,6 +247,75 @@ func TestDefaultFactory_proxyFactoryCrash(t *testing.T) {
 	}
 }
 
+func TestDefaultFactory_maxURLLength(t *testing.T) {
+	buff := bytes.NewBuffer(make([]byte, 1024))
+	logger, err := logging.NewLogger("ERROR", buff, "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		time.Sleep(5 * time.Millisecond)
+	}()
+
+	r := DefaultFactory(noopProxyFactory(map[string]interface{}{"supu": "tupu"}), logger).NewWithContext(ctx)
+
+	serviceCfg := config.ServiceConfig{
+		Port: 8075,
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/short",
+				Method:   "GET",
+				Timeout:  10 * time.Second,
+				Backend:  []*config.Backend{{}},
+			},
+			{
+				Endpoint: "/long",
+				Method:   "GET",
+				Timeout:  10 * time.Second,
+				Backend:  []*config.Backend{{}},
+				ExtraConfig: config.ExtraConfig{
+					server.Namespace: map[string]interface{}{"max_url_length": 40.0},
+				},
+			},
+		},
+		ExtraConfig: config.ExtraConfig{
+			server.Namespace: map[string]interface{}{"max_url_length": 20.0},
+		},
+	}
+
+	go func() { r.Run(serviceCfg) }()
+
+	time.Sleep(5 * time.Millisecond)
+
+	for _, tc := range []struct {
+		path   string
+		length int
+		status int
+	}{
+		{path: "/short", length: 20, status: http.StatusOK},
+		{path: "/short", length: 21, status: http.StatusRequestURITooLong},
+		{path: "/long", length: 21, status: http.StatusOK},
+		{path: "/long", length: 40, status: http.StatusOK},
+		{path: "/long", length: 41, status: http.StatusRequestURITooLong},
+	} {
+		target := tc.path + "?q="
+		target += strings.Repeat("a", tc.length-len(target))
+		resp, err := http.Get("http://127.0.0.1:8075" + target)
+		if err != nil {
+			t.Error("Making the request:", err.Error())
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s (%d): unexpected status code. have: %d, want: %d", tc.path, tc.length, resp.StatusCode, tc.status)
+		}
+	}
+}
+
 func TestRunServer_ko(t *testing.T) {
 	buff := new(bytes.Buffer)
 	logger, err := logging.NewLogger("ERROR", buff, "")
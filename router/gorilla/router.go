@@ -28,7 +28,7 @@ func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
 func DefaultConfig(pf proxy.Factory, logger logging.Logger) mux.Config {
 	return mux.Config{
 		Engine:         gorillaEngine{gorilla.NewRouter()},
-		Middlewares:    []mux.HandlerMiddleware{},
+		Middlewares:    []mux.HandlerMiddleware{mux.NewAccessLogMiddleware(logger)},
 		HandlerFactory: mux.CustomEndpointHandler(mux.NewRequestBuilder(gorillaParamsExtractor)),
 		ProxyFactory:   pf,
 		Logger:         logger,
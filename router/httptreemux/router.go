@@ -27,7 +27,7 @@ func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
 func DefaultConfig(pf proxy.Factory, logger logging.Logger) mux.Config {
 	return mux.Config{
 		Engine:         NewEngine(httptreemux.NewContextMux()),
-		Middlewares:    []mux.HandlerMiddleware{},
+		Middlewares:    []mux.HandlerMiddleware{mux.NewAccessLogMiddleware(logger)},
 		HandlerFactory: mux.CustomEndpointHandler(mux.NewRequestBuilder(ParamsExtractor)),
 		ProxyFactory:   pf,
 		Logger:         logger,
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/router"
+)
+
+// NewAccessLogMiddleware returns a HandlerMiddleware logging every request with the injected
+// logger. Along with the method, the path, the status and the latency, every line includes the
+// pattern of the matched route as a distinct field, so the requests to the same endpoint can be
+// grouped regardless of their path params:
+//
+//	[SERVICE: Mux][AccessLog] GET /users/42 200 1.2ms route=/users/{id}
+func NewAccessLogMiddleware(logger logging.Logger) HandlerMiddleware {
+	return accessLogMiddleware{logger: logger}
+}
+
+type accessLogMiddleware struct {
+	logger logging.Logger
+}
+
+// Handler implements the HandlerMiddleware interface
+func (a accessLogMiddleware) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = r.WithContext(router.WithRoutePattern(r.Context()))
+		rw := &accessLogResponseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		h.ServeHTTP(rw, r)
+
+		pattern, _ := router.RoutePattern(r.Context())
+		a.logger.Info(logPrefix+"[AccessLog]", fmt.Sprintf("%s %s %d %s route=%s",
+			r.Method, r.URL.Path, rw.status, time.Since(start), pattern))
+	})
+}
+
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *accessLogResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *accessLogResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewAccessLogMiddleware(t *testing.T) {
+	buff := new(bytes.Buffer)
+	logger, err := logging.NewLogger("INFO", buff, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"id": 42}}, nil
+	}
+	engine := DefaultEngine()
+	engine.Handle("/users/", "GET", EndpointHandler(endpoint, p))
+
+	h := NewAccessLogMiddleware(logger).Handler(engine)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	line := buff.String()
+	if !strings.Contains(line, "[SERVICE: Mux][AccessLog] GET /users/42 200 ") {
+		t.Errorf("unexpected log line: %s", line)
+	}
+	if !strings.Contains(line, "route=/users/{id}") {
+		t.Errorf("the log line should contain the route pattern: %s", line)
+	}
+	if strings.Contains(line, "route=/users/42") {
+		t.Errorf("the log line should not contain the path as route: %s", line)
+	}
+}
+
+func TestDefaultFactory_accessLog(t *testing.T) {
+	rf, ok := DefaultFactory(nil, logging.NoOp).(factory)
+	if !ok {
+		t.Fatal("unexpected factory type")
+	}
+	if len(rf.cfg.Middlewares) != 1 {
+		t.Fatalf("unexpected middlewares: %v", rf.cfg.Middlewares)
+	}
+	if _, ok := rf.cfg.Middlewares[0].(accessLogMiddleware); !ok {
+		t.Errorf("the access log should be enabled by default: %T", rf.cfg.Middlewares[0])
+	}
+}
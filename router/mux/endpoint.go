@@ -9,10 +9,13 @@ import (
 	"net/http"
 	"net/textproto"
 	"strings"
+	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/core"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -36,6 +39,14 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 		cacheControlHeaderValue := fmt.Sprintf("public, max-age=%d", int(configuration.CacheTTL.Seconds()))
 		isCacheEnabled := configuration.CacheTTL.Seconds() != 0
 		render := getRender(configuration)
+		securityHeaders, err := server.EndpointSecurityHeaders(configuration)
+		if err != nil {
+			prxy = func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+				return nil, err
+			}
+		}
+		debugTrace := server.EndpointDebugTrace(configuration)
+		signature := server.EndpointResponseSignature(configuration)
 
 		headersToSend := configuration.HeadersToPass
 		if len(headersToSend) == 0 {
@@ -44,7 +55,14 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 		method := strings.ToTitle(configuration.Method)
 
 		return func(w http.ResponseWriter, r *http.Request) {
+			router.SetRoutePattern(r.Context(), configuration.Endpoint)
 			w.Header().Set(core.KrakendHeaderName, core.KrakendHeaderValue)
+			securityHeaders.Apply(w, r)
+			if signature != nil {
+				sw := signature.Writer(w)
+				defer sw.Close()
+				w = sw
+			}
 			if r.Method != method {
 				w.Header().Set(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
 				http.Error(w, "", http.StatusMethodNotAllowed)
@@ -53,7 +71,10 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 
 			requestCtx, cancel := context.WithTimeout(r.Context(), configuration.Timeout)
 
+			requestCtx, trace := debugTrace.Start(requestCtx, r)
+			start := time.Now()
 			response, err := prxy(requestCtx, rb(r, configuration.QueryString, headersToSend))
+			debugTrace.Apply(w, trace, time.Since(start))
 
 			select {
 			case <-requestCtx.Done():
@@ -63,6 +84,11 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 			default:
 			}
 
+			if t, ok := err.(client.PassthroughStatusError); ok && response != nil {
+				w = &statusResponseWriter{ResponseWriter: w, status: t.StatusCode()}
+				err = nil
+			}
+
 			if response != nil && len(response.Data) > 0 {
 				if response.IsComplete {
 					w.Header().Set(server.CompleteResponseHeaderName, server.HeaderCompleteResponseValue)
@@ -97,6 +123,29 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 	}
 }
 
+// statusResponseWriter sends the status of the relayed backend error unless the render
+// sets a different one
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(w.status)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
 // RequestBuilder is a function that creates a proxy.Request from the received http request
 type RequestBuilder func(r *http.Request, queryString, headersToSend []string) *proxy.Request
 
@@ -115,17 +164,16 @@ var NewRequest = NewRequestBuilder(NoopParamExtractor)
 func NewRequestBuilder(paramExtractor ParamExtractor) RequestBuilder {
 	return func(r *http.Request, queryString, headersToSend []string) *proxy.Request {
 		params := paramExtractor(r)
-		headers := make(map[string][]string, 3+len(headersToSend))
-
-		for _, k := range headersToSend {
-			if k == requestParamsAsterisk {
-				headers = r.Header
-
-				break
-			}
 
-			if h, ok := r.Header[textproto.CanonicalMIMEHeaderKey(k)]; ok {
-				headers[k] = h
+		var headers map[string][]string
+		if hasAsterisk(headersToSend) {
+			headers = r.Header
+		} else {
+			headers = make(map[string][]string, 3+len(headersToSend))
+			for _, k := range headersToSend {
+				if h, ok := r.Header[textproto.CanonicalMIMEHeaderKey(k)]; ok {
+					headers[k] = h
+				}
 			}
 		}
 
@@ -164,6 +212,15 @@ func NewRequestBuilder(paramExtractor ParamExtractor) RequestBuilder {
 	}
 }
 
+func hasAsterisk(names []string) bool {
+	for _, n := range names {
+		if n == requestParamsAsterisk {
+			return true
+		}
+	}
+	return false
+}
+
 type responseError interface {
 	error
 	StatusCode() int
@@ -5,17 +5,24 @@ package mux
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -211,6 +218,26 @@ func TestEndpointHandler_errored_responseError(t *testing.T) {
 	time.Sleep(5 * time.Millisecond)
 }
 
+func TestEndpointHandler_errored_passthrough(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: false,
+			Data:       map[string]interface{}{"error": "conflict", "id": 3},
+		}, client.PassthroughStatusError{Code: http.StatusConflict}
+	}
+	endpointHandlerTestCase{
+		timeout:            10,
+		proxy:              p,
+		method:             "GET",
+		expectedBody:       `{"error":"conflict","id":3}`,
+		expectedCache:      "",
+		expectedContent:    "application/json",
+		expectedStatusCode: http.StatusConflict,
+		completed:          false,
+	}.test(t)
+	time.Sleep(5 * time.Millisecond)
+}
+
 type dummyResponseError struct {
 	err    string
 	status int
@@ -301,3 +328,169 @@ func startMuxServer(handlerFunc http.HandlerFunc) *http.ServeMux {
 	router.Handle("/_mux_endpoint", handlerFunc)
 	return router
 }
+
+func TestEndpointHandler_securityHeaders(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Method:  "GET",
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{
+			server.Namespace: map[string]interface{}{
+				"security_headers": map[string]interface{}{
+					"headers": map[string]interface{}{
+						"X-Frame-Options":         "SAMEORIGIN",
+						"Content-Security-Policy": "",
+					},
+				},
+			},
+		},
+	}
+	serviceCfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			server.Namespace: map[string]interface{}{
+				"security_headers": map[string]interface{}{
+					"preset": "api",
+				},
+			},
+		},
+	}
+
+	for _, p := range []proxy.Proxy{
+		proxy.NoopProxy,
+		func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return nil, errors.New("this is a dummy error")
+		},
+	} {
+		h := server.NewSecurityHeadersHandler(serviceCfg, startMuxServer(EndpointHandler(endpoint, p)), nil)
+
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", http.NoBody)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		for k, v := range map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "SAMEORIGIN",
+			"Content-Security-Policy": "",
+		} {
+			if h := w.Header().Get(k); h != v {
+				t.Errorf("unexpected value for the header %s: '%s'", k, h)
+			}
+		}
+	}
+}
+
+func TestEndpointHandler_debugTrace(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"foo":"bar"}`))
+	}))
+	defer backend.Close()
+
+	endpoint := &config.EndpointConfig{
+		Method:  "GET",
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{
+			server.Namespace: map[string]interface{}{
+				"debug_trace": map[string]interface{}{"token": "s3cr3t"},
+			},
+		},
+	}
+	remote := &config.Backend{Method: "GET", Decoder: encoding.JSONDecoder}
+	p := func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		r.URL, _ = url.Parse(backend.URL + "/foo")
+		return proxy.NewHTTPProxyWithHTTPExecutor(remote, client.DefaultHTTPRequestExecutor(client.NewHTTPClient), remote.Decoder)(ctx, r)
+	}
+	router := startMuxServer(EndpointHandler(endpoint, p))
+
+	for token, traced := range map[string]bool{"": false, "wrong": false, "s3cr3t": true} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", http.NoBody)
+		if token != "" {
+			req.Header.Set(server.DefaultDebugTraceHeader, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code: %d", token, w.Code)
+		}
+		timings := w.Header().Values(server.ServerTimingHeaderName)
+		if !traced {
+			if len(timings) != 0 {
+				t.Errorf("%s: unexpected timings: %v", token, timings)
+			}
+			continue
+		}
+		if len(timings) != 2 {
+			t.Errorf("%s: unexpected timings: %v", token, timings)
+			continue
+		}
+		if !strings.HasPrefix(timings[0], `backend-0;desc="GET `+backend.URL+`/foo 200";dur=`) {
+			t.Errorf("%s: unexpected backend timing: %s", token, timings[0])
+		}
+		if !strings.HasPrefix(timings[1], "total;dur=") {
+			t.Errorf("%s: unexpected total timing: %s", token, timings[1])
+		}
+	}
+}
+
+func TestEndpointHandler_responseSignature(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Method:  "GET",
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{
+			server.Namespace: map[string]interface{}{
+				"response_signature": map[string]interface{}{"key": "s3cr3t"},
+			},
+		},
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"supu": "tupu"}}, nil
+	}
+	router := startMuxServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected content type: %s", w.Header().Get("Content-Type"))
+	}
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(w.Body.Bytes())
+	if sig := w.Header().Get(server.DefaultResponseSignatureHeader); sig != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected signature %s for the body %s", sig, w.Body.String())
+	}
+}
+
+func TestEndpointHandler_invalidSecurityHeaders(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/_mux_endpoint",
+		Method:   "GET",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			server.Namespace: map[string]interface{}{
+				"security_headers": map[string]interface{}{
+					"preset": "unknown",
+				},
+			},
+		},
+	}
+	calls := 0
+	h := startMuxServer(EndpointHandler(endpoint, func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		calls++
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"a": 1}}, nil
+	}))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", http.NoBody)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if calls != 0 {
+		t.Error("the proxy should not be called")
+	}
+}
@@ -3,14 +3,11 @@
 package mux
 
 import (
-	"encoding/json"
-	"io"
 	"net/http"
-	"sync"
 
 	"github.com/luraproject/lura/v2/config"
-	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router"
 )
 
 // Render defines the signature of the functions to be use for the final response
@@ -20,122 +17,18 @@ type Render func(http.ResponseWriter, *proxy.Response)
 // NEGOTIATE defines the value of the OutputEncoding for the negotiated render
 const NEGOTIATE = "negotiate"
 
-var (
-	mutex          = &sync.RWMutex{}
-	renderRegister = map[string]Render{
-		encoding.STRING:   stringRender,
-		encoding.JSON:     jsonRender,
-		encoding.NOOP:     noopRender,
-		"json-collection": jsonCollectionRender,
-	}
-)
-
-// RegisterRender allows clients to register their custom renders
+// RegisterRender allows clients to register their custom renders. The renders are stored
+// in the shared registry of the router package, so they are available for all the router
+// adapters based on the mux one.
 func RegisterRender(name string, r Render) {
-	mutex.Lock()
-	renderRegister[name] = r
-	mutex.Unlock()
+	router.RegisterRenderer(name, router.RendererFunc(func(w http.ResponseWriter, resp *proxy.Response, _ *config.EndpointConfig) {
+		r(w, resp)
+	}))
 }
 
 func getRender(cfg *config.EndpointConfig) Render {
-	fallback := jsonRender
-	if len(cfg.Backend) == 1 {
-		fallback = getWithFallback(cfg.Backend[0].Encoding, fallback)
-	}
-
-	if cfg.OutputEncoding == "" {
-		return fallback
-	}
-
-	return getWithFallback(cfg.OutputEncoding, fallback)
-}
-
-func getWithFallback(key string, fallback Render) Render {
-	mutex.RLock()
-	r, ok := renderRegister[key]
-	mutex.RUnlock()
-	if !ok {
-		return fallback
-	}
-	return r
-}
-
-var (
-	emptyResponse   = []byte("{}")
-	emptyCollection = []byte("[]")
-)
-
-func jsonRender(w http.ResponseWriter, response *proxy.Response) {
-	w.Header().Set("Content-Type", "application/json")
-	if response == nil {
-		w.Write(emptyResponse)
-		return
-	}
-
-	js, err := json.Marshal(response.Data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Write(js)
-}
-
-func jsonCollectionRender(w http.ResponseWriter, response *proxy.Response) {
-	w.Header().Set("Content-Type", "application/json")
-	if response == nil {
-		w.Write(emptyCollection)
-		return
-	}
-	col, ok := response.Data["collection"]
-	if !ok {
-		w.Write(emptyCollection)
-		return
-	}
-
-	js, err := json.Marshal(col)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Write(js)
-}
-
-func stringRender(w http.ResponseWriter, response *proxy.Response) {
-	w.Header().Set("Content-Type", "text/plain")
-	if response == nil {
-		w.Write([]byte{})
-		return
-	}
-	d, ok := response.Data["content"]
-	if !ok {
-		w.Write([]byte{})
-		return
-	}
-	msg, ok := d.(string)
-	if !ok {
-		w.Write([]byte{})
-		return
-	}
-	w.Write([]byte(msg))
-}
-
-func noopRender(w http.ResponseWriter, response *proxy.Response) {
-	if response == nil {
-		http.Error(w, "", http.StatusInternalServerError)
-		return
-	}
-
-	for k, vs := range response.Metadata.Headers {
-		for _, v := range vs {
-			w.Header().Add(k, v)
-		}
-	}
-	if response.Metadata.StatusCode != 0 {
-		w.WriteHeader(response.Metadata.StatusCode)
-	}
-
-	if response.Io == nil {
-		return
+	r := router.RendererFor(cfg)
+	return func(w http.ResponseWriter, resp *proxy.Response) {
+		r.Render(w, resp, cfg)
 	}
-	io.Copy(w, response.Io)
 }
@@ -5,6 +5,7 @@ package mux
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -15,6 +16,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router"
 )
 
 func TestRender_unknown(t *testing.T) {
@@ -365,3 +367,33 @@ func TestRender_noop_nilResponse(t *testing.T) {
 		t.Error("Unexpected status code:", w.Result().StatusCode)
 	}
 }
+
+func TestRender_sharedRegistry(t *testing.T) {
+	router.RegisterRenderer("mux-csv", router.RendererFunc(func(w http.ResponseWriter, resp *proxy.Response, cfg *config.EndpointConfig) {
+		w.Header().Set("Content-Type", "text/csv")
+		fmt.Fprintf(w, "%s,%v", cfg.Endpoint, resp.Data["a"])
+	}))
+
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"a": "b"}}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Endpoint:       "/_mux_endpoint",
+		Method:         "GET",
+		Timeout:        time.Second,
+		OutputEncoding: "mux-csv",
+	}
+
+	s := startMuxServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", http.NoBody)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	if ct := w.Result().Header.Get("Content-Type"); ct != "text/csv" {
+		t.Error("Unexpected Content-Type:", ct)
+	}
+	if body := w.Body.String(); body != "/_mux_endpoint,b" {
+		t.Error("Unexpected body:", body)
+	}
+}
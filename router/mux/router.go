@@ -14,6 +14,7 @@ import (
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
 	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -49,7 +50,7 @@ func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
 	return factory{
 		Config{
 			Engine:         DefaultEngine(),
-			Middlewares:    []HandlerMiddleware{},
+			Middlewares:    []HandlerMiddleware{NewAccessLogMiddleware(logger)},
 			HandlerFactory: EndpointHandler,
 			ProxyFactory:   pf,
 			Logger:         logger,
@@ -134,24 +135,38 @@ func (r httpRouter) Run(cfg config.ServiceConfig) {
 
 	server.InitHTTPDefaultTransport(cfg)
 
-	r.registerKrakendEndpoints(cfg.Endpoints)
+	warmCtx, stopWarmPools := context.WithCancel(r.ctx)
+	warmPools := client.StartWarmPools(warmCtx, cfg, client.NewHTTPClient, r.cfg.Logger)
+
+	r.registerKrakendEndpoints(cfg)
 
 	if err := r.RunServer(r.ctx, cfg, r.handler()); err != nil {
 		r.cfg.Logger.Error(logPrefix, err.Error())
 	}
 
+	stopWarmPools()
+	warmPools.Wait()
+
 	r.cfg.Logger.Info(logPrefix, "Router execution ended")
 }
 
-func (r httpRouter) registerKrakendEndpoints(endpoints []*config.EndpointConfig) {
-	for _, c := range endpoints {
+func (r httpRouter) registerKrakendEndpoints(cfg config.ServiceConfig) {
+	for _, c := range cfg.Endpoints {
 		proxyStack, err := r.cfg.ProxyFactory.New(c)
 		if err != nil {
 			r.cfg.Logger.Error(logPrefix, "Calling the ProxyFactory", err.Error())
 			continue
 		}
+		proxy.WarmUp(r.ctx, cfg, c, proxyStack, r.cfg.Logger)
 
-		r.registerKrakendEndpoint(c.Method, c, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
+		h := r.cfg.HandlerFactory(c, proxyStack)
+		if allowed := server.GetAllowedContentTypes(c.ExtraConfig); len(allowed) > 0 {
+			h = server.NewAllowedContentTypesHandler(allowed, h).ServeHTTP
+		}
+		if max := server.EndpointMaxURLLength(cfg, c); max > 0 {
+			h = server.NewMaxURLLengthHandler(max, h).ServeHTTP
+		}
+		r.registerKrakendEndpoint(c.Method, c, h, len(c.Backend))
 	}
 }
 
@@ -33,6 +33,8 @@ func DefaultConfig(pf proxy.Factory, logger logging.Logger, middlewares []negron
 func DefaultConfigWithRouter(pf proxy.Factory, logger logging.Logger, muxEngine *gorilla.Router, middlewares []negroni.Handler) mux.Config {
 	cfg := luragorilla.DefaultConfig(pf, logger)
 	cfg.Engine = newNegroniEngine(muxEngine, middlewares...)
+	// the classic negroni engine already logs every request
+	cfg.Middlewares = []mux.HandlerMiddleware{}
 	return cfg
 }
 
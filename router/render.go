@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// JSONCollection is the name of the renderer returning the collection wrapped by the
+// response data as a JSON array
+const JSONCollection = "json-collection"
+
+// JSONSorted is the name of the renderer returning the response data as a JSON object with
+// the keys of all the nested objects sorted
+const JSONSorted = "json-sorted"
+
+// Renderer encodes and writes the final response of an endpoint
+type Renderer interface {
+	Render(http.ResponseWriter, *proxy.Response, *config.EndpointConfig)
+}
+
+// RendererFunc type is an adapter to allow the use of ordinary functions as renderers.
+type RendererFunc func(http.ResponseWriter, *proxy.Response, *config.EndpointConfig)
+
+// Render implements the Renderer interface
+func (f RendererFunc) Render(w http.ResponseWriter, r *proxy.Response, cfg *config.EndpointConfig) {
+	f(w, r, cfg)
+}
+
+// builtinRenderer marks the default renderers of the shared registry
+type builtinRenderer struct {
+	RendererFunc
+}
+
+var (
+	renderersMutex = &sync.RWMutex{}
+	renderers      = map[string]Renderer{
+		encoding.STRING: builtinRenderer{stringRender},
+		encoding.JSON:   builtinRenderer{jsonRender},
+		encoding.NOOP:   builtinRenderer{noopRender},
+		JSONCollection:  builtinRenderer{jsonCollectionRender},
+		JSONSorted:      builtinRenderer{sortedJSONRender},
+	}
+)
+
+// IsBuiltinRenderer reports if the renderer is one of the defaults of the shared registry,
+// so the router adapters with their own version of the same encoding can keep using it
+func IsBuiltinRenderer(r Renderer) bool {
+	_, ok := r.(builtinRenderer)
+	return ok
+}
+
+// JSONRenderer returns the default JSON renderer, even if another one has been registered
+// under its name
+func JSONRenderer() Renderer {
+	return builtinRenderer{jsonRender}
+}
+
+// RegisterRenderer registers the renderer under the received output encoding name, so it
+// can be selected by the router adapters using the 'output_encoding' of the endpoints
+func RegisterRenderer(name string, r Renderer) {
+	renderersMutex.Lock()
+	renderers[name] = r
+	renderersMutex.Unlock()
+}
+
+// GetRenderer returns the renderer registered under the received output encoding name
+func GetRenderer(name string) (Renderer, bool) {
+	renderersMutex.RLock()
+	r, ok := renderers[name]
+	renderersMutex.RUnlock()
+	return r, ok
+}
+
+// RendererFor returns the renderer registered for the output encoding of the endpoint.
+// If it is not defined or not registered, it returns the renderer of the encoding of the
+// backend (just for endpoints with a single backend), falling back to the JSON renderer.
+func RendererFor(cfg *config.EndpointConfig) Renderer {
+	fallback := JSONRenderer()
+	if len(cfg.Backend) == 1 {
+		if r, ok := GetRenderer(cfg.Backend[0].Encoding); ok {
+			fallback = r
+		}
+	}
+
+	if cfg.OutputEncoding == "" {
+		return fallback
+	}
+	if r, ok := GetRenderer(cfg.OutputEncoding); ok {
+		return r
+	}
+	return fallback
+}
+
+var (
+	emptyResponse   = []byte("{}")
+	emptyCollection = []byte("[]")
+)
+
+func jsonRender(w http.ResponseWriter, response *proxy.Response, _ *config.EndpointConfig) {
+	w.Header().Set("Content-Type", "application/json")
+	if response == nil {
+		w.Write(emptyResponse)
+		return
+	}
+
+	js, err := json.Marshal(response.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(js)
+}
+
+// sortedJSONRender renders the response data with the keys of every object sorted, including
+// the ones of the embedded raw JSON messages and the values encoded as structs, so the same
+// data always generates the same output
+func sortedJSONRender(w http.ResponseWriter, response *proxy.Response, _ *config.EndpointConfig) {
+	w.Header().Set("Content-Type", "application/json")
+	if response == nil {
+		w.Write(emptyResponse)
+		return
+	}
+
+	js, err := SortJSONKeys(response.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(js)
+}
+
+// SortJSONKeys encodes the received value as JSON with the keys of all the objects sorted
+// recursively, including the objects inside arrays
+func SortJSONKeys(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// the encoding of the generic maps sorts their keys, so decoding the output normalizes
+	// the order of the raw messages, the structs and the custom marshalers
+	var normalized interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&normalized); err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalized)
+}
+
+func jsonCollectionRender(w http.ResponseWriter, response *proxy.Response, _ *config.EndpointConfig) {
+	w.Header().Set("Content-Type", "application/json")
+	if response == nil {
+		w.Write(emptyCollection)
+		return
+	}
+	col, ok := response.Data["collection"]
+	if !ok {
+		w.Write(emptyCollection)
+		return
+	}
+
+	js, err := json.Marshal(col)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(js)
+}
+
+func stringRender(w http.ResponseWriter, response *proxy.Response, _ *config.EndpointConfig) {
+	w.Header().Set("Content-Type", "text/plain")
+	if response == nil {
+		w.Write([]byte{})
+		return
+	}
+	d, ok := response.Data["content"]
+	if !ok {
+		w.Write([]byte{})
+		return
+	}
+	msg, ok := d.(string)
+	if !ok {
+		w.Write([]byte{})
+		return
+	}
+	w.Write([]byte(msg))
+}
+
+func noopRender(w http.ResponseWriter, response *proxy.Response, _ *config.EndpointConfig) {
+	if response == nil {
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	for k, vs := range response.Metadata.Headers {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	if response.Metadata.StatusCode != 0 {
+		w.WriteHeader(response.Metadata.StatusCode)
+	}
+
+	if response.Io == nil {
+		return
+	}
+	io.Copy(w, response.Io)
+}
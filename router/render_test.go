@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestRendererFor(t *testing.T) {
+	RegisterRenderer("test-renderer", RendererFunc(func(w http.ResponseWriter, _ *proxy.Response, cfg *config.EndpointConfig) {
+		w.Write([]byte("custom " + cfg.Endpoint))
+	}))
+
+	response := &proxy.Response{Data: map[string]interface{}{"content": "foo", "collection": []interface{}{1}}}
+
+	for _, tc := range []struct {
+		name     string
+		cfg      *config.EndpointConfig
+		expected string
+	}{
+		{
+			name:     "default",
+			cfg:      &config.EndpointConfig{},
+			expected: `{"collection":[1],"content":"foo"}`,
+		},
+		{
+			name:     "unknown",
+			cfg:      &config.EndpointConfig{OutputEncoding: "unknown"},
+			expected: `{"collection":[1],"content":"foo"}`,
+		},
+		{
+			name:     "backend encoding",
+			cfg:      &config.EndpointConfig{Backend: []*config.Backend{{Encoding: encoding.STRING}}},
+			expected: "foo",
+		},
+		{
+			name: "unknown with backend encoding",
+			cfg: &config.EndpointConfig{
+				OutputEncoding: "unknown",
+				Backend:        []*config.Backend{{Encoding: encoding.STRING}},
+			},
+			expected: "foo",
+		},
+		{
+			name:     "collection",
+			cfg:      &config.EndpointConfig{OutputEncoding: JSONCollection},
+			expected: "[1]",
+		},
+		{
+			name:     "custom",
+			cfg:      &config.EndpointConfig{Endpoint: "/foo", OutputEncoding: "test-renderer"},
+			expected: "custom /foo",
+		},
+	} {
+		w := httptest.NewRecorder()
+		RendererFor(tc.cfg).Render(w, response, tc.cfg)
+		if body := w.Body.String(); body != tc.expected {
+			t.Errorf("%s: unexpected body: %s", tc.name, body)
+		}
+	}
+}
+
+func TestGetRenderer(t *testing.T) {
+	for _, name := range []string{encoding.JSON, encoding.STRING, encoding.NOOP, JSONCollection, JSONSorted} {
+		if _, ok := GetRenderer(name); !ok {
+			t.Errorf("the renderer %s is not registered", name)
+		}
+	}
+	if _, ok := GetRenderer("unknown"); ok {
+		t.Error("unexpected renderer")
+	}
+}
+
+func TestRendererFor_noop(t *testing.T) {
+	cfg := &config.EndpointConfig{OutputEncoding: encoding.NOOP}
+	w := httptest.NewRecorder()
+	RendererFor(cfg).Render(w, &proxy.Response{
+		Metadata: proxy.Metadata{StatusCode: http.StatusTeapot, Headers: map[string][]string{"X-Foo": {"bar"}}},
+		Io:       strings.NewReader("supu"),
+	}, cfg)
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if h := w.Header().Get("X-Foo"); h != "bar" {
+		t.Errorf("unexpected header: %s", h)
+	}
+	if body := w.Body.String(); body != "supu" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+type unsortedStruct struct {
+	Zeta  int `json:"zeta"`
+	Alpha int `json:"alpha"`
+}
+
+func TestRendererFor_sortedJSON(t *testing.T) {
+	cfg := &config.EndpointConfig{OutputEncoding: JSONSorted}
+	expected := `{"a":{"b":2,"c":[{"x":1,"y":2},{"m":[{"a":true,"b":null}],"n":"foo"}]},` +
+		`"raw":{"a":1,"b":{"c":3,"d":4}},"struct":{"alpha":2,"zeta":1},"z":1.5}`
+
+	for i := 0; i < 10; i++ {
+		response := &proxy.Response{Data: map[string]interface{}{
+			"z":   1.5,
+			"raw": json.RawMessage(`{"b":{"d":4,"c":3},"a":1}`),
+			"a": map[string]interface{}{
+				"c": []interface{}{
+					map[string]interface{}{"y": 2, "x": 1},
+					map[string]interface{}{"n": "foo", "m": []interface{}{map[string]interface{}{"b": nil, "a": true}}},
+				},
+				"b": 2,
+			},
+			"struct": unsortedStruct{Zeta: 1, Alpha: 2},
+		}}
+		w := httptest.NewRecorder()
+		RendererFor(cfg).Render(w, response, cfg)
+		if body := w.Body.String(); body != expected {
+			t.Errorf("#%d: unexpected body:\nhave: %s\nwant: %s", i, body, expected)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("#%d: unexpected content type: %s", i, ct)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	RendererFor(cfg).Render(w, nil, cfg)
+	if body := w.Body.String(); body != "{}" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
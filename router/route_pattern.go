@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"sync"
+)
+
+type routePatternKey struct{}
+
+type routePattern struct {
+	mu      *sync.RWMutex
+	pattern string
+}
+
+// WithRoutePattern returns a copy of the context able to keep the pattern of the route
+// matching the request, so the layers wrapping the router (like the access loggers) can read
+// the pattern stored by the endpoint handlers with SetRoutePattern
+func WithRoutePattern(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routePatternKey{}, &routePattern{mu: new(sync.RWMutex)})
+}
+
+// SetRoutePattern stores the pattern of the route matching the request in the context. It
+// does nothing if the context was not created with WithRoutePattern.
+func SetRoutePattern(ctx context.Context, pattern string) {
+	if p, ok := ctx.Value(routePatternKey{}).(*routePattern); ok {
+		p.mu.Lock()
+		p.pattern = pattern
+		p.mu.Unlock()
+	}
+}
+
+// RoutePattern returns the pattern of the route matching the request, if it was stored in
+// the context
+func RoutePattern(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(routePatternKey{}).(*routePattern)
+	if !ok {
+		return "", false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pattern, p.pattern != ""
+}
@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRoutePattern(t *testing.T) {
+	SetRoutePattern(context.Background(), "/users/{id}")
+	if _, ok := RoutePattern(context.Background()); ok {
+		t.Error("a context without holder should not report a pattern")
+	}
+
+	ctx := WithRoutePattern(context.Background())
+	if _, ok := RoutePattern(ctx); ok {
+		t.Error("the pattern should not be reported before being stored")
+	}
+
+	SetRoutePattern(context.WithValue(ctx, struct{}{}, "derived"), "/users/{id}")
+	if p, ok := RoutePattern(ctx); !ok || p != "/users/{id}" {
+		t.Errorf("unexpected pattern: %s", p)
+	}
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/luraproject/lura/v2/random"
+)
+
+const (
+	// DefaultAdaptiveDecay is the default weight of the last result in the error rate of a host
+	DefaultAdaptiveDecay = 0.1
+	// DefaultAdaptiveMinWeight is the default minimum weight of a host
+	DefaultAdaptiveMinWeight = 0.05
+)
+
+// FeedbackBalancer is a Balancer adjusting its decisions with the results of the requests
+// sent to the selected hosts
+type FeedbackBalancer interface {
+	Balancer
+	// Report records the result of a request sent to the host
+	Report(host string, success bool)
+}
+
+// AdaptiveConfig defines how the adaptive balancer weights the hosts
+type AdaptiveConfig struct {
+	// Decay is the weight of the last result in the moving average of the error rate of a
+	// host, in (0, 1]. The higher, the faster the balancer reacts
+	Decay float64
+	// MinWeight is the minimum weight of a host, in (0, 1], so the failing hosts keep
+	// receiving some traffic and they can recover
+	MinWeight float64
+}
+
+// NewAdaptiveLB returns a FeedbackBalancer selecting the hosts randomly, with a weight
+// decreasing as their recent error rate rises. The error rate of every host is an
+// exponentially weighted moving average of the reported results, so the weight of a
+// degraded host recovers as its requests succeed again. The weights never drop below the
+// configured minimum.
+func NewAdaptiveLB(subscriber Subscriber, src random.Source, cfg AdaptiveConfig) FeedbackBalancer {
+	if src == nil {
+		src = random.Default()
+	}
+	if cfg.Decay <= 0 || cfg.Decay > 1 {
+		cfg.Decay = DefaultAdaptiveDecay
+	}
+	if cfg.MinWeight <= 0 || cfg.MinWeight > 1 {
+		cfg.MinWeight = DefaultAdaptiveMinWeight
+	}
+	return &adaptiveLB{
+		balancer:   balancer{subscriber: subscriber},
+		src:        src,
+		cfg:        cfg,
+		errorRates: map[string]float64{},
+		mu:         new(sync.RWMutex),
+	}
+}
+
+type adaptiveLB struct {
+	balancer
+	src        random.Source
+	cfg        AdaptiveConfig
+	errorRates map[string]float64
+	mu         *sync.RWMutex
+}
+
+// Host implements the Balancer interface
+func (a *adaptiveLB) Host() (string, error) {
+	return a.host(a.src)
+}
+
+// HostContext implements the ContextBalancer interface
+func (a *adaptiveLB) HostContext(ctx context.Context) (string, error) {
+	return a.host(random.ForContext(ctx, a.src))
+}
+
+func (a *adaptiveLB) host(src random.Source) (string, error) {
+	hosts, err := a.hosts()
+	if err != nil {
+		return "", err
+	}
+	a.prune(hosts)
+	if len(hosts) == 1 {
+		return hosts[0], nil
+	}
+
+	weights := make([]float64, len(hosts))
+	total := 0.0
+	a.mu.RLock()
+	for i, h := range hosts {
+		weights[i] = a.weight(h)
+		total += weights[i]
+	}
+	a.mu.RUnlock()
+
+	r := src.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return hosts[i], nil
+		}
+		r -= w
+	}
+	return hosts[len(hosts)-1], nil
+}
+
+// Report implements the FeedbackBalancer interface
+func (a *adaptiveLB) Report(host string, success bool) {
+	result := 0.0
+	if !success {
+		result = 1
+	}
+	a.mu.Lock()
+	a.errorRates[host] = a.errorRates[host]*(1-a.cfg.Decay) + result*a.cfg.Decay
+	a.mu.Unlock()
+}
+
+// prune removes the error rates of the hosts no longer returned by the subscriber. Only the
+// reported hosts have an error rate, so there are stale entries when there are more rates
+// than hosts.
+func (a *adaptiveLB) prune(hosts []string) {
+	a.mu.RLock()
+	stale := len(a.errorRates) > len(hosts)
+	a.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	current := make(map[string]struct{}, len(hosts))
+	for _, h := range hosts {
+		current[h] = struct{}{}
+	}
+	a.mu.Lock()
+	for h := range a.errorRates {
+		if _, ok := current[h]; !ok {
+			delete(a.errorRates, h)
+		}
+	}
+	a.mu.Unlock()
+}
+
+// Weight returns the current weight of the host
+func (a *adaptiveLB) Weight(host string) float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.weight(host)
+}
+
+func (a *adaptiveLB) weight(host string) float64 {
+	w := 1 - a.errorRates[host]
+	if w < a.cfg.MinWeight {
+		return a.cfg.MinWeight
+	}
+	return w
+}
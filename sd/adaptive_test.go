@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sd
+
+import (
+	"testing"
+
+	"github.com/luraproject/lura/v2/random"
+)
+
+func TestAdaptiveLB(t *testing.T) {
+	lb := NewAdaptiveLB(FixedSubscriber{"a", "b"}, random.NewSource(42), AdaptiveConfig{Decay: 0.2, MinWeight: 0.1})
+
+	share := func() float64 {
+		hits := 0
+		for i := 0; i < 2000; i++ {
+			h, err := lb.Host()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h == "b" {
+				hits++
+			}
+		}
+		return float64(hits) / 2000
+	}
+
+	last := share()
+	if last < 0.45 || last > 0.55 {
+		t.Errorf("the healthy hosts should get the same traffic. share of b: %f", last)
+	}
+
+	// b degrades progressively
+	for phase := 0; phase < 3; phase++ {
+		for i := 0; i < 3; i++ {
+			lb.Report("a", true)
+			lb.Report("b", false)
+		}
+		current := share()
+		if current >= last {
+			t.Errorf("phase %d: the degrading host should get less traffic. have: %f, previous: %f", phase, current, last)
+		}
+		last = current
+	}
+
+	for i := 0; i < 100; i++ {
+		lb.Report("b", false)
+	}
+	if w := lb.(*adaptiveLB).Weight("b"); w != 0.1 {
+		t.Errorf("the weight of a failing host should be bounded. have: %f", w)
+	}
+	if s := share(); s < 0.05 {
+		t.Errorf("the failing host should keep receiving some traffic. share: %f", s)
+	}
+
+	// b heals
+	for i := 0; i < 100; i++ {
+		lb.Report("b", true)
+	}
+	if s := share(); s < 0.45 {
+		t.Errorf("the healed host should recover its traffic. share: %f", s)
+	}
+}
+
+func TestAdaptiveLB_defaults(t *testing.T) {
+	lb := NewAdaptiveLB(FixedSubscriber{"a"}, nil, AdaptiveConfig{}).(*adaptiveLB)
+	if lb.cfg.Decay != DefaultAdaptiveDecay || lb.cfg.MinWeight != DefaultAdaptiveMinWeight {
+		t.Errorf("unexpected config: %+v", lb.cfg)
+	}
+	if h, err := lb.Host(); err != nil || h != "a" {
+		t.Errorf("unexpected host: %s, %v", h, err)
+	}
+
+	if _, err := NewAdaptiveLB(FixedSubscriber{}, nil, AdaptiveConfig{}).Host(); err != ErrNoHosts {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAdaptiveLB_prune(t *testing.T) {
+	subscriber := FixedSubscriber{"a", "b", "c"}
+	lb := NewAdaptiveLB(&subscriber, random.NewSource(42), AdaptiveConfig{}).(*adaptiveLB)
+	for _, h := range subscriber {
+		lb.Report(h, false)
+	}
+
+	subscriber = FixedSubscriber{"a"}
+	if h, err := lb.Host(); err != nil || h != "a" {
+		t.Errorf("unexpected host: %s, %v", h, err)
+	}
+	if len(lb.errorRates) != 1 {
+		t.Errorf("the removed hosts should be pruned: %v", lb.errorRates)
+	}
+	if w := lb.Weight("a"); w == 1 {
+		t.Error("the error rate of the remaining host should be kept")
+	}
+}
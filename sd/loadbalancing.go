@@ -3,11 +3,12 @@
 package sd
 
 import (
+	"context"
 	"errors"
 	"runtime"
 	"sync/atomic"
 
-	"github.com/valyala/fastrand"
+	"github.com/luraproject/lura/v2/random"
 )
 
 // Balancer applies a balancing stategy in order to select the backend host to be used
@@ -15,12 +16,19 @@ type Balancer interface {
 	Host() (string, error)
 }
 
+// ContextBalancer is a Balancer able to take its random decisions on behalf of the request
+// of the context, so they can be recorded (see random.ForContext)
+type ContextBalancer interface {
+	Balancer
+	HostContext(ctx context.Context) (string, error)
+}
+
 // ErrNoHosts is the error the balancer must return when there are 0 hosts ready
 var ErrNoHosts = errors.New("no hosts available")
 
 // NewBalancer returns the best perfomant balancer depending on the number of available processors.
 // If GOMAXPROCS = 1, it returns a round robin LB due there is no contention over the atomic counter.
-// If GOMAXPROCS > 1, it returns a pseudo random LB optimized for scaling over the number of CPUs.
+// If GOMAXPROCS > 1, it returns a pseudo random LB drawing from the shared random.Default source.
 func NewBalancer(subscriber Subscriber) Balancer {
 	if p := runtime.GOMAXPROCS(-1); p == 1 {
 		return NewRoundRobinLB(subscriber)
@@ -28,16 +36,31 @@ func NewBalancer(subscriber Subscriber) Balancer {
 	return NewRandomLB(subscriber)
 }
 
+// NewBalancerWithSource is like NewBalancer, but the random decisions of the balancer are
+// taken with the received source
+func NewBalancerWithSource(subscriber Subscriber, src random.Source) Balancer {
+	if p := runtime.GOMAXPROCS(-1); p == 1 {
+		return NewRoundRobinLBWithSource(subscriber, src)
+	}
+	return NewRandomLBWithSource(subscriber, src)
+}
+
 // NewRoundRobinLB returns a new balancer using a round robin strategy and starting at a random
 // position in the set of hosts.
 func NewRoundRobinLB(subscriber Subscriber) Balancer {
+	return NewRoundRobinLBWithSource(subscriber, random.Default())
+}
+
+// NewRoundRobinLBWithSource returns a new balancer using a round robin strategy and starting at
+// a position in the set of hosts selected with the received source.
+func NewRoundRobinLBWithSource(subscriber Subscriber, src random.Source) Balancer {
 	s, ok := subscriber.(FixedSubscriber)
 	start := uint64(0)
 	if ok {
 		if l := len(s); l == 1 {
 			return nopBalancer(s[0])
 		} else if l > 1 {
-			start = uint64(fastrand.Uint32n(uint32(l)))
+			start = uint64(src.Intn(l))
 		}
 	}
 	return &roundRobinLB{
@@ -61,29 +84,39 @@ func (r *roundRobinLB) Host() (string, error) {
 	return hosts[offset], nil
 }
 
-// NewRandomLB returns a new balancer using a fastrand pseudorandom generator
+// NewRandomLB returns a new balancer selecting the hosts with the shared random.Default source
 func NewRandomLB(subscriber Subscriber) Balancer {
+	return NewRandomLBWithSource(subscriber, random.Default())
+}
+
+// NewRandomLBWithSource returns a new balancer selecting the hosts with the received source
+func NewRandomLBWithSource(subscriber Subscriber, src random.Source) Balancer {
 	if s, ok := subscriber.(FixedSubscriber); ok && len(s) == 1 {
 		return nopBalancer(s[0])
 	}
 	return &randomLB{
 		balancer: balancer{subscriber: subscriber},
-		rand:     fastrand.Uint32n,
+		src:      src,
 	}
 }
 
 type randomLB struct {
 	balancer
-	rand func(uint32) uint32
+	src random.Source
 }
 
 // Host implements the balancer interface
 func (r *randomLB) Host() (string, error) {
+	return r.HostContext(context.Background())
+}
+
+// HostContext implements the ContextBalancer interface
+func (r *randomLB) HostContext(ctx context.Context) (string, error) {
 	hosts, err := r.hosts()
 	if err != nil {
 		return "", err
 	}
-	return hosts[int(r.rand(uint32(len(hosts))))], nil
+	return hosts[random.ForContext(ctx, r.src).Intn(len(hosts))], nil
 }
 
 type balancer struct {
@@ -3,12 +3,14 @@
 package sd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"testing"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/random"
 )
 
 func ExampleNewRoundRobinLB() {
@@ -89,16 +91,7 @@ func ExampleNewRandomLB() {
 	balancer := NewRandomLB(FixedSubscriber([]string{"a", "b", "c"}))
 
 	// code required in order to make the test deterministic
-	{
-		var counter uint32
-		balancer.(*randomLB).rand = func(max uint32) uint32 {
-			if max != 3 {
-				fmt.Println("unexpected max:", max)
-			}
-			defer func() { counter++ }()
-			return counter % max
-		}
-	}
+	balancer.(*randomLB).src = &sequenceSource{}
 
 	for i := 0; i < 5; i++ {
 		h, err := balancer.Host()
@@ -117,6 +110,18 @@ func ExampleNewRandomLB() {
 	// b
 }
 
+// sequenceSource returns the counter of its calls, modulo n
+type sequenceSource struct {
+	counter int
+}
+
+func (s *sequenceSource) Intn(n int) int {
+	defer func() { s.counter++ }()
+	return s.counter % n
+}
+
+func (s *sequenceSource) Float64() float64 { return 0 }
+
 func TestRandomLB(t *testing.T) {
 	var (
 		endpoints  = []string{"a", "b", "c", "d", "e", "f", "g"}
@@ -132,7 +137,7 @@ func TestRandomLB(t *testing.T) {
 	}
 
 	subscriber := FixedSubscriber(endpoints)
-	balancer := NewRandomLB(subscriber)
+	balancer := NewRandomLBWithSource(subscriber, random.NewSource(1))
 
 	for i := 0; i < iterations; i++ {
 		endpoint, err := balancer.Host()
@@ -150,6 +155,99 @@ func TestRandomLB(t *testing.T) {
 	}
 }
 
+func TestRandomLBWithSource(t *testing.T) {
+	var (
+		endpoints  = []string{"a", "b", "c", "d", "e", "f", "g"}
+		n          = len(endpoints)
+		counts     = make(map[string]int, n)
+		want       = make(map[string]int, n)
+		iterations = 10000
+		expected   = random.NewSource(42)
+	)
+
+	balancer := NewRandomLBWithSource(FixedSubscriber(endpoints), random.NewSource(42))
+
+	for i := 0; i < iterations; i++ {
+		endpoint, err := balancer.Host()
+		if err != nil {
+			t.Fail()
+		}
+		e := endpoints[expected.Intn(n)]
+		if endpoint != e {
+			t.Errorf("#%d: want %s, have %s", i, e, endpoint)
+			return
+		}
+		counts[endpoint]++
+		want[e]++
+	}
+
+	for e, have := range counts {
+		if have != want[e] {
+			t.Errorf("%s: want %d, have %d", e, want[e], have)
+		}
+	}
+}
+
+func TestRandomLB_HostContext(t *testing.T) {
+	endpoints := []string{"a", "b", "c"}
+	expected := random.NewSource(42)
+	balancer := NewRandomLBWithSource(FixedSubscriber(endpoints), random.NewDecisionLog(random.NewSource(42))).(ContextBalancer)
+
+	decisions := random.NewDecisions()
+	ctx := decisions.WithContext(context.Background())
+	for i := 0; i < 3; i++ {
+		endpoint, err := balancer.HostContext(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if e := endpoints[expected.Intn(len(endpoints))]; endpoint != e {
+			t.Errorf("#%d: want %s, have %s", i, e, endpoint)
+		}
+	}
+	if _, err := balancer.Host(); err != nil {
+		t.Error(err)
+	}
+	if entries := decisions.Entries(); len(entries) != 3 {
+		t.Errorf("only the draws of the request should be recorded: %v", entries)
+	}
+}
+
+func TestRoundRobinLBWithSource(t *testing.T) {
+	endpoints := []string{"a", "b", "c"}
+	start := random.NewSource(7).Intn(len(endpoints))
+	balancer := NewRoundRobinLBWithSource(FixedSubscriber(endpoints), random.NewSource(7))
+
+	for i := 0; i < 10; i++ {
+		endpoint, err := balancer.Host()
+		if err != nil {
+			t.Fail()
+		}
+		if want := endpoints[(start+i)%len(endpoints)]; endpoint != want {
+			t.Errorf("#%d: want %s, have %s", i, want, endpoint)
+		}
+	}
+}
+
+func TestNewRandomFixedSubscriberWithSource(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d", "e"}
+	a := NewRandomFixedSubscriberWithSource(hosts, random.NewSource(3))
+	b := NewRandomFixedSubscriberWithSource(hosts, random.NewSource(3))
+	if fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("the same seed should produce the same order: %v != %v", a, b)
+	}
+	if fmt.Sprint(hosts) != "[a b c d e]" {
+		t.Errorf("the received hosts should not be modified: %v", hosts)
+	}
+	seen := map[string]bool{}
+	for _, h := range a {
+		seen[h] = true
+	}
+	if len(seen) != len(hosts) {
+		t.Errorf("unexpected hosts: %v", a)
+	}
+}
+
 func TestRandomLB_single(t *testing.T) {
 	endpoints := []string{"a"}
 	iterations := 1000000
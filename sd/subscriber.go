@@ -6,9 +6,8 @@
 package sd
 
 import (
-	"math/rand"
-
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/random"
 )
 
 // Subscriber keeps the set of backend hosts up to date
@@ -39,11 +38,17 @@ func FixedSubscriberFactory(cfg *config.Backend) Subscriber {
 
 // NewRandomFixedSubscriber randomizes a list of hosts and builds a FixedSubscriber with it
 func NewRandomFixedSubscriber(hosts []string) FixedSubscriber {
+	return NewRandomFixedSubscriberWithSource(hosts, random.Default())
+}
+
+// NewRandomFixedSubscriberWithSource randomizes a list of hosts with the received source and
+// builds a FixedSubscriber with it
+func NewRandomFixedSubscriberWithSource(hosts []string, src random.Source) FixedSubscriber {
 	res := make([]string, len(hosts))
-	j := 0
-	for _, i := range rand.Perm(len(hosts)) {
-		res[j] = hosts[i]
-		j++
+	copy(res, hosts)
+	for i := len(res) - 1; i > 0; i-- {
+		j := src.Intn(i + 1)
+		res[i], res[j] = res[j], res[i]
 	}
 	return FixedSubscriber(res)
 }
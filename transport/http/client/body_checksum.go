@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/textproto"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	bodyChecksumKey = "body_checksum"
+
+	// DefaultBodyChecksumTrailer is the default name of the trailer carrying the checksum of
+	// the streamed request bodies
+	DefaultBodyChecksumTrailer = "X-Body-Checksum"
+)
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// BodyChecksumConfig defines how to hash the streamed request bodies
+type BodyChecksumConfig struct {
+	// Algorithm is the hash function: md5, sha1, sha256 (default) or sha512
+	Algorithm string
+	// Trailer is the name of the trailer carrying the checksum
+	Trailer string
+	// Base64 encodes the checksum in base64 instead of hex
+	Base64 bool
+}
+
+// BodyChecksumConfigError is the error returned by the backends enabling the body checksum
+// along with a component that buffers the request bodies
+type BodyChecksumConfigError struct {
+	Backend string
+	Err     error
+}
+
+// Error returns a string representation of the BodyChecksumConfigError
+func (b BodyChecksumConfigError) Error() string {
+	return fmt.Sprintf("invalid body checksum config for the backend %s: %s", b.Backend, b.Err.Error())
+}
+
+// Unwrap returns the configuration error
+func (b BodyChecksumConfigError) Unwrap() error {
+	return b.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (BodyChecksumConfigError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// NewBodyChecksumHTTPRequestExecutor wraps the received executor so the request bodies are
+// streamed to the backend while they are hashed, if the backend defines the 'body_checksum'
+// key in its extra config:
+//
+//	"body_checksum": { "algorithm": "sha256", "trailer": "X-Body-Checksum", "encoding": "hex" }
+//
+// The requests are sent with a chunked encoding and the checksum is added as a trailer once
+// the whole body has been sent, so the backend can verify it. The retries and the HTTP/2
+// executor with its HTTP/1.1 fallback keep a copy of the bodies to replay them, so the
+// backends combining them with the checksum fail every request with a BodyChecksumConfigError
+// instead of buffering the bodies. Otherwise, it returns the received executor.
+func NewBodyChecksumHTTPRequestExecutor(remote *config.Backend, re HTTPRequestExecutor) HTTPRequestExecutor {
+	cfg, ok := getBodyChecksumConfig(remote.ExtraConfig)
+	if !ok {
+		return re
+	}
+	if err := checkStreamedBodies(remote.ExtraConfig); err != nil {
+		err := BodyChecksumConfigError{Backend: remote.URLPattern, Err: err}
+		return func(_ context.Context, req *http.Request) (*http.Response, error) {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	return BodyChecksumHTTPRequestExecutor(cfg, re)
+}
+
+func checkStreamedBodies(extra config.ExtraConfig) error {
+	if _, ok := getRetryConfig(extra); ok {
+		return errors.New("the retries buffer the request bodies")
+	}
+	if cfg, ok := getHTTP2Config(extra); ok && !cfg.DisableFallback {
+		return errors.New("the HTTP/1.1 fallback buffers the request bodies")
+	}
+	return nil
+}
+
+// BodyChecksumHTTPRequestExecutor wraps the received executor, adding the checksum of the
+// request bodies as a trailer with the received config
+func BodyChecksumHTTPRequestExecutor(cfg BodyChecksumConfig, re HTTPRequestExecutor) HTTPRequestExecutor {
+	newHash, ok := checksumAlgorithms[cfg.Algorithm]
+	if !ok {
+		newHash = sha256.New
+	}
+	trailer := DefaultBodyChecksumTrailer
+	if cfg.Trailer != "" {
+		trailer = textproto.CanonicalMIMEHeaderKey(cfg.Trailer)
+	}
+	encode := hex.EncodeToString
+	if cfg.Base64 {
+		encode = base64.StdEncoding.EncodeToString
+	}
+
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if req.Body == nil || req.Body == http.NoBody {
+			return re(ctx, req)
+		}
+		if req.Trailer == nil {
+			req.Trailer = http.Header{}
+		}
+		req.Trailer[trailer] = nil
+		req.ContentLength = -1
+		// a body obtained from GetBody would not be hashed
+		req.GetBody = nil
+		req.Body = &checksumReader{
+			ReadCloser: req.Body,
+			hash:       newHash(),
+			done: func(sum []byte) {
+				req.Trailer.Set(trailer, encode(sum))
+			},
+		}
+		return re(ctx, req)
+	}
+}
+
+// checksumReader hashes the content read from the wrapped body and reports the checksum
+// once it reaches the end of the body
+type checksumReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	done func([]byte)
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && c.done != nil {
+		c.done(c.hash.Sum(nil))
+		c.done = nil
+	}
+	return n, err
+}
+
+func getBodyChecksumConfig(extra config.ExtraConfig) (BodyChecksumConfig, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return BodyChecksumConfig{}, false
+	}
+	tmp, ok := e[bodyChecksumKey].(map[string]interface{})
+	if !ok {
+		return BodyChecksumConfig{}, false
+	}
+	cfg := BodyChecksumConfig{}
+	cfg.Algorithm, _ = tmp["algorithm"].(string)
+	cfg.Trailer, _ = tmp["trailer"].(string)
+	if encoding, _ := tmp["encoding"].(string); encoding == "base64" {
+		cfg.Base64 = true
+	}
+	return cfg, true
+}
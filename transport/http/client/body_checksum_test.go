@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewBodyChecksumHTTPRequestExecutor(t *testing.T) {
+	received := make(chan struct{})
+	var body, checksum, encoding string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first := make([]byte, 5)
+		if _, err := io.ReadFull(r.Body, first); err != nil {
+			t.Error(err)
+		}
+		close(received)
+		rest, _ := io.ReadAll(r.Body)
+		body = string(first) + string(rest)
+		checksum = r.Trailer.Get(DefaultBodyChecksumTrailer)
+		encoding = strings.Join(r.TransferEncoding, ",")
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{bodyChecksumKey: map[string]interface{}{}},
+		},
+	}
+	re := NewBodyChecksumHTTPRequestExecutor(remote, DefaultHTTPRequestExecutor(NewHTTPClient))
+
+	// the second part of the body is only available once the backend got the first one
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("first"))
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			pw.CloseWithError(io.ErrUnexpectedEOF)
+			return
+		}
+		pw.Write([]byte(" and second"))
+		pw.Close()
+	}()
+
+	req, _ := http.NewRequest("POST", ts.URL, pr)
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+
+	if body != "first and second" {
+		t.Errorf("unexpected body: '%s'", body)
+	}
+	if encoding != "chunked" {
+		t.Errorf("unexpected transfer encoding: %s", encoding)
+	}
+	sum := sha256.Sum256([]byte("first and second"))
+	if checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected checksum: '%s'", checksum)
+	}
+}
+
+func TestBodyChecksumHTTPRequestExecutor(t *testing.T) {
+	checksums := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		checksums = append(checksums, r.Trailer.Get("X-Md5"))
+	}))
+	defer ts.Close()
+
+	re := BodyChecksumHTTPRequestExecutor(
+		BodyChecksumConfig{Algorithm: "md5", Trailer: "x-md5", Base64: true},
+		DefaultHTTPRequestExecutor(NewHTTPClient),
+	)
+
+	for _, b := range []io.Reader{strings.NewReader("some content"), nil} {
+		req, _ := http.NewRequest("PUT", ts.URL, b)
+		resp, err := re(context.Background(), req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}
+
+	sum := md5.Sum([]byte("some content"))
+	if len(checksums) != 2 || checksums[0] != base64.StdEncoding.EncodeToString(sum[:]) || checksums[1] != "" {
+		t.Errorf("unexpected checksums: %v", checksums)
+	}
+}
+
+func TestNewBodyChecksumHTTPRequestExecutor_disabled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TransferEncoding) > 0 || r.ContentLength != 4 {
+			t.Errorf("unexpected request: %v %d", r.TransferEncoding, r.ContentLength)
+		}
+	}))
+	defer ts.Close()
+
+	re := NewBodyChecksumHTTPRequestExecutor(&config.Backend{}, DefaultHTTPRequestExecutor(NewHTTPClient))
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("body"))
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+}
+
+func TestNewBodyChecksumHTTPRequestExecutor_bufferedBodies(t *testing.T) {
+	for name, extra := range map[string]map[string]interface{}{
+		"retry": {"max_retries": 1.0},
+		"http2": {"h2c": true},
+	} {
+		ns := map[string]interface{}{"body_checksum": map[string]interface{}{}}
+		ns[name] = extra
+		re := NewBodyChecksumHTTPRequestExecutor(&config.Backend{
+			URLPattern:  "/upload",
+			ExtraConfig: config.ExtraConfig{Namespace: ns},
+		}, func(_ context.Context, _ *http.Request) (*http.Response, error) {
+			t.Errorf("%s: the executor should not be called", name)
+			return nil, nil
+		})
+
+		req, _ := http.NewRequest("POST", "http://example.com", strings.NewReader("body"))
+		_, err := re(context.Background(), req)
+		var cfgErr BodyChecksumConfigError
+		if !errors.As(err, &cfgErr) || cfgErr.StatusCode() != http.StatusInternalServerError {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	re := NewBodyChecksumHTTPRequestExecutor(&config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"body_checksum": map[string]interface{}{},
+			"http2":         map[string]interface{}{"disable_fallback": true},
+		}},
+	}, func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	req, _ := http.NewRequest("POST", "http://example.com", strings.NewReader("body"))
+	if _, err := re(context.Background(), req); err != nil {
+		t.Errorf("the http2 executor without fallback streams the bodies: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/net/http2"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const http2Key = "http2"
+
+// HTTP2Config defines how to reach a backend over HTTP/2
+type HTTP2Config struct {
+	// H2C sends the requests over cleartext HTTP/2 with prior knowledge, instead of
+	// negotiating the protocol over TLS
+	H2C bool
+	// DisableFallback stops the executor from retrying the requests failing with a protocol
+	// error over HTTP/1.1
+	DisableFallback bool
+}
+
+// NewHTTP2HTTPRequestExecutor returns an executor sending the requests over HTTP/2 if the
+// backend defines the 'http2' key in its extra config:
+//
+//	"http2": { "h2c": true }
+//
+// The requests failing with a protocol error (the backend can not speak HTTP/2, it refuses
+// the stream or it requires HTTP/1.1) are sent again, once, with the clients of the received
+// factory. Otherwise, it returns the default executor with the received client factory.
+func NewHTTP2HTTPRequestExecutor(remote *config.Backend, cf HTTPClientFactory) HTTPRequestExecutor {
+	cfg, ok := getHTTP2Config(remote.ExtraConfig)
+	if !ok {
+		return DefaultHTTPRequestExecutor(cf)
+	}
+	return HTTP2HTTPRequestExecutorWithClientFactory(cfg, cf)
+}
+
+// HTTP2HTTPRequestExecutor returns an executor sending the requests over HTTP/2 with the
+// received config and the default client
+func HTTP2HTTPRequestExecutor(cfg HTTP2Config) HTTPRequestExecutor {
+	return HTTP2HTTPRequestExecutorWithClientFactory(cfg, NewHTTPClient)
+}
+
+// HTTP2HTTPRequestExecutorWithClientFactory returns an executor sending the requests over
+// HTTP/2 with the received config. The HTTP/2 transport copies the TLS config, the dialer and
+// the timeouts of the transport of the first client returned by the factory (or the default
+// transport, if the client does not define its own one), and the fallback requests are sent
+// with the clients of the factory.
+//
+// The requests are sent again over HTTP/1.1 only if they are idempotent or if the failure
+// happened before writing them, so the backend can not process them twice. The bodies are
+// replayed with the GetBody function of the request or, if it is not defined, buffered in
+// memory before the first attempt.
+func HTTP2HTTPRequestExecutorWithClientFactory(cfg HTTP2Config, cf HTTPClientFactory) HTTPRequestExecutor {
+	var once sync.Once
+	var h2Client *http.Client
+	newClient := func(ctx context.Context) *http.Client {
+		once.Do(func() {
+			h2Client = newHTTP2Client(cfg, cf(ctx))
+		})
+		return h2Client
+	}
+	if cfg.DisableFallback {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return newClient(ctx).Do(req.WithContext(ctx))
+		}
+	}
+
+	h1 := DefaultHTTPRequestExecutor(cf)
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		getBody := req.GetBody
+		if req.Body != nil && req.Body != http.NoBody && getBody == nil {
+			b, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			getBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+		} else if req.Body != nil {
+			// every attempt gets a fresh body from GetBody
+			req.Body.Close()
+		}
+
+		var written int32
+		trace := &httptrace.ClientTrace{WroteHeaders: func() { atomic.StoreInt32(&written, 1) }}
+		r, err := withBody(httptrace.WithClientTrace(ctx, trace), req, getBody)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := newClient(ctx).Do(r)
+		if err == nil || ctx.Err() != nil || !isHTTP2ProtocolError(err) {
+			return resp, err
+		}
+		if atomic.LoadInt32(&written) == 1 && !isIdempotent(req.Method) {
+			return resp, err
+		}
+		if r, err = withBody(ctx, req, getBody); err != nil {
+			return nil, err
+		}
+		return h1(ctx, r)
+	}
+}
+
+// newHTTP2Client returns a client speaking HTTP/2 with the settings of the received one
+func newHTTP2Client(cfg HTTP2Config, c *http.Client) *http.Client {
+	base, _ := c.Transport.(*http.Transport)
+	if c.Transport == nil {
+		base, _ = http.DefaultTransport.(*http.Transport)
+	}
+	if base == nil {
+		base = &http.Transport{}
+	}
+
+	dial := base.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	h2 := &http2.Transport{
+		TLSClientConfig:    base.TLSClientConfig.Clone(),
+		DisableCompression: base.DisableCompression,
+	}
+	if cfg.H2C {
+		h2.AllowHTTP = true
+		h2.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		}
+	} else {
+		h2.DialTLSContext = func(ctx context.Context, network, addr string, tlsCfg *tls.Config) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			if base.TLSHandshakeTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, base.TLSHandshakeTimeout)
+				defer cancel()
+			}
+			tlsConn := tls.Client(conn, tlsCfg)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+
+	return &http.Client{
+		Transport:     h2,
+		CheckRedirect: c.CheckRedirect,
+		Jar:           c.Jar,
+		Timeout:       c.Timeout,
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func withBody(ctx context.Context, req *http.Request, getBody func() (io.ReadCloser, error)) (*http.Request, error) {
+	r := req.Clone(ctx)
+	if getBody == nil {
+		return r, nil
+	}
+	body, err := getBody()
+	if err != nil {
+		return nil, err
+	}
+	r.Body, r.GetBody = body, getBody
+	return r, nil
+}
+
+// isHTTP2ProtocolError reports if the error signals that the backend is not able or not
+// willing to process the request over HTTP/2. An unexpected EOF is the result of sending
+// the HTTP/2 preface to a backend speaking HTTP/1.1 only, or a connection reset if the
+// backend closes the connection while the client is still writing.
+func isHTTP2ProtocolError(err error) bool {
+	var connErr http2.ConnectionError
+	if errors.As(err, &connErr) {
+		return true
+	}
+	var streamErr http2.StreamError
+	if errors.As(err, &streamErr) {
+		return isHTTP2ProtocolErrCode(streamErr.Code)
+	}
+	var goAwayErr http2.GoAwayError
+	if errors.As(err, &goAwayErr) {
+		return isHTTP2ProtocolErrCode(goAwayErr.ErrCode)
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+func isHTTP2ProtocolErrCode(code http2.ErrCode) bool {
+	return code == http2.ErrCodeProtocol || code == http2.ErrCodeHTTP11Required
+}
+
+func getHTTP2Config(extra config.ExtraConfig) (HTTP2Config, bool) {
+	m, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return HTTP2Config{}, false
+	}
+	tmp, ok := m[http2Key].(map[string]interface{})
+	if !ok {
+		return HTTP2Config{}, false
+	}
+	cfg := HTTP2Config{}
+	cfg.H2C, _ = tmp["h2c"].(bool)
+	cfg.DisableFallback, _ = tmp["disable_fallback"].(bool)
+	return cfg, true
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewHTTP2HTTPRequestExecutor_h2c(t *testing.T) {
+	ts := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}), &http2.Server{}))
+	defer ts.Close()
+
+	re := NewHTTP2HTTPRequestExecutor(http2Backend(), NewHTTPClient)
+	req, _ := http.NewRequest("GET", ts.URL, http.NoBody)
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Fatal("unexpected error:", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if b, _ := io.ReadAll(resp.Body); string(b) != "HTTP/2.0" {
+		t.Errorf("unexpected protocol: %s", string(b))
+	}
+}
+
+func TestNewHTTP2HTTPRequestExecutor_fallback(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PRI" {
+			// the HTTP/2 preface received by the HTTP/1.1 server
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		attempts++
+		b, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Proto, string(b))
+	}))
+	defer ts.Close()
+
+	re := NewHTTP2HTTPRequestExecutor(http2Backend(), NewHTTPClient)
+	req, _ := http.NewRequest("PUT", ts.URL, io.NopCloser(strings.NewReader("payload")))
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Fatal("unexpected error:", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if b, _ := io.ReadAll(resp.Body); string(b) != "HTTP/1.1 payload" {
+		t.Errorf("unexpected response: %s", string(b))
+	}
+	if attempts != 1 {
+		t.Errorf("unexpected number of requests processed by the backend: %d", attempts)
+	}
+}
+
+func TestNewHTTP2HTTPRequestExecutor_noFallbackForWrittenRequests(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PRI" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		attempts++
+	}))
+	defer ts.Close()
+
+	re := NewHTTP2HTTPRequestExecutor(http2Backend(), NewHTTPClient)
+	req, _ := http.NewRequest("POST", ts.URL, io.NopCloser(strings.NewReader("payload")))
+	if _, err := re(context.Background(), req); err == nil {
+		t.Error("expecting an error")
+	}
+	if attempts != 0 {
+		t.Errorf("the request should not be sent again: %d", attempts)
+	}
+}
+
+func TestNewHTTP2HTTPRequestExecutor_clientTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	remote := http2Backend()
+	remote.ExtraConfig[Namespace].(map[string]interface{})[http2Key] = map[string]interface{}{"disable_fallback": true}
+
+	// the default client does not trust the certificate of the test server
+	req, _ := http.NewRequest("GET", ts.URL, http.NoBody)
+	if _, err := NewHTTP2HTTPRequestExecutor(remote, NewHTTPClient)(context.Background(), req); err == nil {
+		t.Error("expecting a TLS error")
+	}
+
+	c := ts.Client()
+	re := NewHTTP2HTTPRequestExecutor(remote, func(_ context.Context) *http.Client { return c })
+	req, _ = http.NewRequest("GET", ts.URL, http.NoBody)
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Fatal("unexpected error:", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if b, _ := io.ReadAll(resp.Body); string(b) != "HTTP/2.0" {
+		t.Errorf("unexpected protocol: %s", string(b))
+	}
+}
+
+func TestNewHTTP2HTTPRequestExecutor_disabledFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}))
+	defer ts.Close()
+
+	remote := http2Backend()
+	remote.ExtraConfig[Namespace].(map[string]interface{})[http2Key].(map[string]interface{})["disable_fallback"] = true
+	re := NewHTTP2HTTPRequestExecutor(remote, NewHTTPClient)
+	req, _ := http.NewRequest("GET", ts.URL, http.NoBody)
+	if _, err := re(context.Background(), req); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestIsHTTP2ProtocolError(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected bool
+	}{
+		{err: http2.ConnectionError(http2.ErrCodeProtocol), expected: true},
+		{err: http2.StreamError{Code: http2.ErrCodeHTTP11Required}, expected: true},
+		{err: http2.StreamError{Code: http2.ErrCodeCancel}},
+		{err: http2.GoAwayError{ErrCode: http2.ErrCodeProtocol}, expected: true},
+		{err: http2.GoAwayError{ErrCode: http2.ErrCodeNo}},
+		{err: fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF), expected: true},
+		{err: errors.New("connection refused")},
+	} {
+		if res := isHTTP2ProtocolError(tc.err); res != tc.expected {
+			t.Errorf("%v: unexpected result %v", tc.err, res)
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for _, m := range []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"} {
+		if !isIdempotent(m) {
+			t.Errorf("%s should be idempotent", m)
+		}
+	}
+	for _, m := range []string{"POST", "PATCH"} {
+		if isIdempotent(m) {
+			t.Errorf("%s should not be idempotent", m)
+		}
+	}
+}
+
+func http2Backend() *config.Backend {
+	return &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				http2Key: map[string]interface{}{"h2c": true},
+			},
+		},
+	}
+}
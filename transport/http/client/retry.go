@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/luraproject/lura/v2/backoff"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/random"
+)
+
+const (
+	retryKey = "retry"
+
+	// DefaultIdempotencyKeyHeader is the default name of the header carrying the idempotency
+	// key of the retried requests
+	DefaultIdempotencyKeyHeader = "Idempotency-Key"
+)
+
+var defaultRetryStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// IdempotencyKeyMismatchError is the error returned when the backend echoes an idempotency key
+// different from the one sent, so the request may have been processed more than once
+type IdempotencyKeyMismatchError struct {
+	Sent   string
+	Echoed string
+}
+
+// Error returns a string representation of the IdempotencyKeyMismatchError
+func (i IdempotencyKeyMismatchError) Error() string {
+	return fmt.Sprintf("the backend echoed the idempotency key '%s' instead of '%s'", i.Echoed, i.Sent)
+}
+
+// StatusCode returns the status code to send to the client
+func (IdempotencyKeyMismatchError) StatusCode() int {
+	return http.StatusBadGateway
+}
+
+// RetryConfig defines how the requests to a backend are retried
+type RetryConfig struct {
+	MaxRetries int
+	Backoff    backoff.TimeToWaitBeforeRetry
+	// Strategy is the name of a backoff strategy. If defined, it replaces the Backoff and the
+	// jitter is drawn from the source of the request context (see random.NewContext)
+	Strategy string
+	Statuses   map[int]struct{}
+	// KeyHeader is the header carrying the idempotency key. The requests without it get a
+	// random one, so all the attempts share the same key
+	KeyHeader string
+	// EchoHeader is the header of the response where the backend echoes the idempotency key
+	EchoHeader string
+}
+
+// NewRetryHTTPRequestExecutor wraps the executor, retrying the requests failing with a
+// transport error or with one of the retryable statuses (502, 503 and 504 by default), as
+// defined at the 'retry' key of the backend extra config:
+//
+//	"retry": {
+//		"max_retries": 2,
+//		"backoff": "exponential",
+//		"statuses": [ 503 ],
+//		"idempotency_key_header": "Idempotency-Key",
+//		"echo_header": "X-Idempotency-Key"
+//	}
+//
+// Every attempt carries the same idempotency key. If the backend echoes a different one, the
+// request may have been processed twice, so the executor returns an IdempotencyKeyMismatchError
+// without retrying. The bodies are replayed with the GetBody function of the request, and
+// the requests without it have their body buffered in memory before the first attempt. The
+// executor is returned as it is if the backend does not define retries.
+func NewRetryHTTPRequestExecutor(remote *config.Backend, re HTTPRequestExecutor) HTTPRequestExecutor {
+	cfg, ok := getRetryConfig(remote.ExtraConfig)
+	if !ok {
+		return re
+	}
+	return RetryHTTPRequestExecutor(cfg, re)
+}
+
+// RetryHTTPRequestExecutor wraps the executor with the received retry config
+func RetryHTTPRequestExecutor(cfg RetryConfig, re HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		body, getBody := req.Body, req.GetBody
+		if body != nil && body != http.NoBody && getBody == nil {
+			b, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				return nil, err
+			}
+			body = io.NopCloser(bytes.NewReader(b))
+			getBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+		}
+
+		key := req.Header.Get(cfg.KeyHeader)
+		header := req.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		if key == "" {
+			key = newIdempotencyKey()
+			header.Set(cfg.KeyHeader, key)
+		}
+
+		wait := cfg.Backoff
+		if cfg.Strategy != "" {
+			wait = backoff.GetByNameWithSource(cfg.Strategy, random.ForContext(ctx, random.FromContext(ctx, random.Default())))
+		}
+
+		var resp *http.Response
+		var err error
+		for attempt := 0; ; attempt++ {
+			r := req.Clone(ctx)
+			r.Header = header
+			r.Body, r.GetBody = body, getBody
+			if attempt > 0 && getBody != nil {
+				if r.Body, err = getBody(); err != nil {
+					return nil, err
+				}
+			}
+
+			resp, err = re(ctx, r)
+			if resp != nil {
+				if echoed := resp.Header.Get(cfg.EchoHeader); echoed != "" && echoed != key {
+					resp.Body.Close()
+					return nil, IdempotencyKeyMismatchError{Sent: key, Echoed: echoed}
+				}
+			}
+
+			if attempt >= cfg.MaxRetries || ctx.Err() != nil || !cfg.retryable(resp, err) {
+				return resp, err
+			}
+			if resp != nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+
+			if wait == nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait(attempt + 1)):
+			}
+		}
+	}
+}
+
+func (r RetryConfig) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	_, ok := r.Statuses[resp.StatusCode]
+	return ok
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func getRetryConfig(extra config.ExtraConfig) (RetryConfig, bool) {
+	m, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return RetryConfig{}, false
+	}
+	tmp, ok := m[retryKey].(map[string]interface{})
+	if !ok {
+		return RetryConfig{}, false
+	}
+	maxRetries, ok := tmp["max_retries"].(float64)
+	if !ok || maxRetries < 1 {
+		return RetryConfig{}, false
+	}
+
+	cfg := RetryConfig{
+		MaxRetries: int(maxRetries),
+		Statuses:   map[int]struct{}{},
+		KeyHeader:  DefaultIdempotencyKeyHeader,
+	}
+	if strategy, ok := tmp["backoff"].(string); ok && strategy != "" {
+		cfg.Strategy = strategy
+	}
+	statuses, _ := tmp["statuses"].([]interface{})
+	for _, v := range statuses {
+		if s, ok := v.(float64); ok {
+			cfg.Statuses[int(s)] = struct{}{}
+		}
+	}
+	if len(cfg.Statuses) == 0 {
+		for _, s := range defaultRetryStatuses {
+			cfg.Statuses[s] = struct{}{}
+		}
+	}
+	if h, ok := tmp["idempotency_key_header"].(string); ok && h != "" {
+		cfg.KeyHeader = textproto.CanonicalMIMEHeaderKey(h)
+	}
+	cfg.EchoHeader = cfg.KeyHeader
+	if h, ok := tmp["echo_header"].(string); ok && h != "" {
+		cfg.EchoHeader = textproto.CanonicalMIMEHeaderKey(h)
+	}
+	return cfg, true
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/random"
+)
+
+func TestNewRetryHTTPRequestExecutor_matchingEcho(t *testing.T) {
+	var mu sync.Mutex
+	keys := []string{}
+	bodies := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		bodies = append(bodies, string(b))
+		attempt := len(keys)
+		mu.Unlock()
+
+		w.Header().Set("X-Idempotency-Key", r.Header.Get("Idempotency-Key"))
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	re := NewRetryHTTPRequestExecutor(retryBackend(), DefaultHTTPRequestExecutor(NewHTTPClient))
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("payload"))
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Fatal("unexpected error:", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("unexpected number of attempts: %d", len(keys))
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("the attempts should share the idempotency key: %v", keys)
+	}
+	for i, b := range bodies {
+		if b != "payload" {
+			t.Errorf("unexpected body at the attempt #%d: '%s'", i, b)
+		}
+	}
+	if req.Header.Get("Idempotency-Key") != "" {
+		t.Error("the headers of the original request should not be modified")
+	}
+}
+
+func TestNewRetryHTTPRequestExecutor_mismatchingEcho(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.Header().Set("X-Idempotency-Key", "another-key")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	re := NewRetryHTTPRequestExecutor(retryBackend(), DefaultHTTPRequestExecutor(NewHTTPClient))
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("payload"))
+	req.Header.Set("Idempotency-Key", "my-key")
+	resp, err := re(context.Background(), req)
+	if resp != nil {
+		t.Error("unexpected response:", resp)
+	}
+
+	var mismatch IdempotencyKeyMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mismatch.Sent != "my-key" || mismatch.Echoed != "another-key" {
+		t.Errorf("unexpected error: %+v", mismatch)
+	}
+	if mismatch.StatusCode() != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", mismatch.StatusCode())
+	}
+	if attempts != 1 {
+		t.Errorf("the mismatching echoes should not be retried. attempts: %d", attempts)
+	}
+}
+
+func TestNewRetryHTTPRequestExecutor_exhausted(t *testing.T) {
+	attempts := 0
+	re := NewRetryHTTPRequestExecutor(retryBackend(), func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := re(context.Background(), req); err == nil || err.Error() != "connection refused" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("unexpected number of attempts: %d", attempts)
+	}
+}
+
+func TestNewRetryHTTPRequestExecutor_notConfigured(t *testing.T) {
+	attempts := 0
+	re := NewRetryHTTPRequestExecutor(&config.Backend{}, func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	re(context.Background(), req)
+	if attempts != 1 {
+		t.Errorf("unexpected number of attempts: %d", attempts)
+	}
+}
+
+func TestNewRetryHTTPRequestExecutor_notReplayableBody(t *testing.T) {
+	bodies := []string{}
+	re := NewRetryHTTPRequestExecutor(retryBackend(), func(_ context.Context, req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		return &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	req, _ := http.NewRequest("POST", "http://example.com", io.NopCloser(strings.NewReader("payload")))
+	if req.GetBody != nil {
+		t.Fatal("the body should not be replayable")
+	}
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Fatal("unexpected error:", err.Error())
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 3 {
+		t.Fatalf("unexpected number of attempts: %d", len(bodies))
+	}
+	for i, b := range bodies {
+		if b != "payload" {
+			t.Errorf("unexpected body at the attempt #%d: '%s'", i, b)
+		}
+	}
+}
+
+func TestNewRetryHTTPRequestExecutor_contextSource(t *testing.T) {
+	backend := retryBackend()
+	backend.ExtraConfig[Namespace].(map[string]interface{})["retry"].(map[string]interface{})["backoff"] = "linear-jitter"
+	re := NewRetryHTTPRequestExecutor(backend, func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+
+	decisions := random.NewDecisions()
+	src := random.WithName(random.NewDecisionLog(random.NewSource(1)), "[Retry]")
+	ctx, cancel := context.WithTimeout(random.NewContext(decisions.WithContext(context.Background()), src), 50*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequest("GET", "http://example.com", http.NoBody)
+	if _, err := re(ctx, req); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if entries := decisions.Entries(); len(entries) != 1 || !strings.HasPrefix(entries[0], "[Retry] Intn(") {
+		t.Errorf("the jitter should be drawn from the source of the context: %v", entries)
+	}
+}
+
+func retryBackend() *config.Backend {
+	return &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"retry": map[string]interface{}{
+					"max_retries": 2.0,
+					"echo_header": "X-Idempotency-Key",
+				},
+			},
+		},
+	}
+}
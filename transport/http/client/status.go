@@ -6,10 +6,12 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
 )
 
 // Namespace to be used in extra config
@@ -22,10 +24,107 @@ var ErrInvalidStatusCode = errors.New("invalid status code")
 // HTTPStatusHandler defines how we tread the http response code
 type HTTPStatusHandler func(context.Context, *http.Response) (*http.Response, error)
 
-// GetHTTPStatusHandler returns a status handler. If the 'return_error_details' key is defined
-// at the extra config, it returns a DetailedHTTPStatusHandler. Otherwise, it returns a
-// DefaultHTTPStatusHandler
+// HTTPStatusHandlerFactory creates the HTTPStatusHandler to use with the received backend
+type HTTPStatusHandlerFactory func(remote *config.Backend) HTTPStatusHandler
+
+const statusHandlerKey = "status_handler"
+
+var statusHandlers = register.NewUntyped()
+
+func init() {
+	RegisterHTTPStatusHandler("default", func(_ *config.Backend) HTTPStatusHandler { return DefaultHTTPStatusHandler })
+	RegisterHTTPStatusHandler("error_code", func(_ *config.Backend) HTTPStatusHandler { return ErrorHTTPStatusHandler })
+	RegisterHTTPStatusHandler("no-op", func(_ *config.Backend) HTTPStatusHandler { return NoOpHTTPStatusHandler })
+	RegisterHTTPStatusHandler("detailed", func(remote *config.Backend) HTTPStatusHandler {
+		name := "backend"
+		if m, ok := remote.ExtraConfig[Namespace].(map[string]interface{}); ok {
+			if v, ok := m["return_error_details"].(string); ok && v != "" {
+				name = v
+			}
+		}
+		return DetailedHTTPStatusHandler(name)
+	})
+
+	config.RegisterBackendValidator(ValidateHTTPStatusHandler)
+}
+
+// RegisterHTTPStatusHandler registers a HTTPStatusHandlerFactory under the given name, so the
+// backends can select it by declaring the name at the 'status_handler' key of their extra config.
+// The custom factories must be registered before parsing the configuration. The built-in
+// handlers are registered as 'default', 'error_code', 'detailed' and 'no-op'.
+func RegisterHTTPStatusHandler(name string, f HTTPStatusHandlerFactory) {
+	statusHandlers.Register(name, f)
+}
+
+// UnknownHTTPStatusHandlerError is the error returned by the configuration init process when
+// a backend selects a status handler not registered
+type UnknownHTTPStatusHandlerError struct {
+	Name     string
+	Endpoint string
+	Method   string
+	Backend  string
+}
+
+// Error returns a string representation of the UnknownHTTPStatusHandlerError
+func (u *UnknownHTTPStatusHandlerError) Error() string {
+	return fmt.Sprintf("unknown status handler '%s' for the backend %s of the endpoint %s %s",
+		u.Name, u.Backend, u.Method, u.Endpoint)
+}
+
+// ValidateHTTPStatusHandler checks that the status handler selected by the backend, if any,
+// has been registered
+func ValidateHTTPStatusHandler(remote *config.Backend) error {
+	name, ok := getHTTPStatusHandlerName(remote)
+	if !ok {
+		return nil
+	}
+	if _, ok := getHTTPStatusHandlerFactory(name); !ok {
+		return &UnknownHTTPStatusHandlerError{
+			Name:     name,
+			Endpoint: remote.ParentEndpoint,
+			Method:   remote.ParentEndpointMethod,
+			Backend:  remote.URLPattern,
+		}
+	}
+	return nil
+}
+
+func getHTTPStatusHandlerName(remote *config.Backend) (string, bool) {
+	m, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := m[statusHandlerKey].(string)
+	return name, ok
+}
+
+func getHTTPStatusHandlerFactory(name string) (HTTPStatusHandlerFactory, bool) {
+	v, ok := statusHandlers.Get(name)
+	if !ok {
+		return nil, false
+	}
+	f, ok := v.(HTTPStatusHandlerFactory)
+	return f, ok
+}
+
+// GetHTTPStatusHandler returns a status handler. If the 'status_handler' key is defined at the
+// extra config, it returns the handler registered under that name. Otherwise, if the
+// 'return_error_details' key is defined, it returns a DetailedHTTPStatusHandler and if the
+// 'return_error_code' flag is enabled, an ErrorHTTPStatusHandler. By default, it returns a
+// DefaultHTTPStatusHandler.
+// If the backend defines a status errors table, the returned handler is wrapped with a
+// StatusErrorsHTTPStatusHandler and, if it defines a list of passthrough statuses, with a
+// PassthroughHTTPStatusHandler.
 func GetHTTPStatusHandler(remote *config.Backend) HTTPStatusHandler {
+	return StatusErrorsHTTPStatusHandler(remote, PassthroughHTTPStatusHandler(remote, getHTTPStatusHandler(remote)))
+}
+
+func getHTTPStatusHandler(remote *config.Backend) HTTPStatusHandler {
+	if name, ok := getHTTPStatusHandlerName(remote); ok {
+		if f, ok := getHTTPStatusHandlerFactory(name); ok {
+			return f(remote)
+		}
+	}
 	if e, ok := remote.ExtraConfig[Namespace]; ok {
 		if m, ok := e.(map[string]interface{}); ok {
 			if v, ok := m["return_error_details"]; ok {
@@ -40,6 +139,110 @@ func GetHTTPStatusHandler(remote *config.Backend) HTTPStatusHandler {
 	return DefaultHTTPStatusHandler
 }
 
+// StatusErrorsHTTPStatusHandler returns a HTTPStatusHandler converting the status codes mapped
+// by the StatusErrors table of the backend into MappedStatusErrors. The rest of responses are
+// handled by the received status handler.
+func StatusErrorsHTTPStatusHandler(remote *config.Backend, next HTTPStatusHandler) HTTPStatusHandler {
+	if len(remote.StatusErrors) == 0 {
+		return next
+	}
+	return func(ctx context.Context, resp *http.Response) (*http.Response, error) {
+		statusErr, ok := remote.StatusErrors[resp.StatusCode]
+		if !ok {
+			return next(ctx, resp)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		code := statusErr.StatusCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return nil, MappedStatusError{
+			Code:          code,
+			Err:           statusErr.Error,
+			Msg:           statusErr.Message,
+			BackendStatus: resp.StatusCode,
+		}
+	}
+}
+
+// MappedStatusError is the error returned when the status code of the backend response is
+// mapped by the StatusErrors table of the backend
+type MappedStatusError struct {
+	Code          int    `json:"http_status_code"`
+	Err           string `json:"error"`
+	Msg           string `json:"message,omitempty"`
+	BackendStatus int    `json:"-"`
+}
+
+// Error returns the error message or, if it is not defined, the name of the error
+func (m MappedStatusError) Error() string {
+	if m.Msg != "" {
+		return m.Msg
+	}
+	return m.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (m MappedStatusError) StatusCode() int {
+	return m.Code
+}
+
+const passthroughStatusesKey = "passthrough_statuses"
+
+// PassthroughHTTPStatusHandler returns a HTTPStatusHandler relaying the responses with one of
+// the statuses listed at the 'passthrough_statuses' key of the extra config, so their bodies
+// are decoded as the regular ones and the status is sent to the client. The returned responses
+// are paired with a PassthroughStatusError. The rest of responses are handled by the received
+// status handler.
+func PassthroughHTTPStatusHandler(remote *config.Backend, next HTTPStatusHandler) HTTPStatusHandler {
+	statuses := getPassthroughStatuses(remote)
+	if len(statuses) == 0 {
+		return next
+	}
+	return func(ctx context.Context, resp *http.Response) (*http.Response, error) {
+		if _, ok := statuses[resp.StatusCode]; !ok {
+			return next(ctx, resp)
+		}
+		return resp, PassthroughStatusError{Code: resp.StatusCode}
+	}
+}
+
+func getPassthroughStatuses(remote *config.Backend) map[int]struct{} {
+	m, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	vs, ok := m[passthroughStatusesKey].([]interface{})
+	if !ok {
+		return nil
+	}
+	statuses := make(map[int]struct{}, len(vs))
+	for _, v := range vs {
+		if code, ok := v.(float64); ok {
+			statuses[int(code)] = struct{}{}
+		}
+	}
+	return statuses
+}
+
+// PassthroughStatusError is the error returned with the backend responses that must be relayed
+// to the client with their original status code
+type PassthroughStatusError struct {
+	Code int
+}
+
+// Error returns a string representation of the PassthroughStatusError
+func (p PassthroughStatusError) Error() string {
+	return fmt.Sprintf("backend status %d", p.Code)
+}
+
+// StatusCode returns the status code to send to the client
+func (p PassthroughStatusError) StatusCode() int {
+	return p.Code
+}
+
 // DefaultHTTPStatusHandler is the default implementation of HTTPStatusHandler
 func DefaultHTTPStatusHandler(_ context.Context, resp *http.Response) (*http.Response, error) {
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
@@ -173,3 +173,166 @@ var statusCodes = []int{
 	http.StatusNotExtended,
 	http.StatusNetworkAuthenticationRequired,
 }
+
+func TestGetHTTPStatusHandler_registered(t *testing.T) {
+	RegisterHTTPStatusHandler("test_accept_conflict", func(_ *config.Backend) HTTPStatusHandler {
+		return func(ctx context.Context, resp *http.Response) (*http.Response, error) {
+			if resp.StatusCode == http.StatusConflict {
+				return resp, nil
+			}
+			return DefaultHTTPStatusHandler(ctx, resp)
+		}
+	})
+
+	sh := GetHTTPStatusHandler(&config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{statusHandlerKey: "test_accept_conflict"},
+		},
+	})
+
+	for code, expectErr := range map[int]bool{http.StatusConflict: false, http.StatusOK: false, http.StatusBadGateway: true} {
+		_, err := sh(context.Background(), &http.Response{StatusCode: code, Body: io.NopCloser(&bytes.Buffer{})})
+		if expectErr != (err != nil) {
+			t.Errorf("unexpected error for the status %d: %v", code, err)
+		}
+	}
+}
+
+func TestGetHTTPStatusHandler_builtIn(t *testing.T) {
+	sh := GetHTTPStatusHandler(&config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				statusHandlerKey:       "detailed",
+				"return_error_details": "some",
+			},
+		},
+	})
+	_, err := sh(context.Background(), &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(&bytes.Buffer{})})
+	e, ok := err.(NamedHTTPResponseError)
+	if !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if e.Name() != "some" {
+		t.Errorf("unexpected name: %s", e.Name())
+	}
+}
+
+func TestValidateHTTPStatusHandler(t *testing.T) {
+	newSubject := func(name string) config.ServiceConfig {
+		return config.ServiceConfig{
+			Version: config.ConfigVersion,
+			Host:    []string{"http://127.0.0.1:8080"},
+			Endpoints: []*config.EndpointConfig{
+				{
+					Endpoint: "/supu",
+					Method:   "GET",
+					Backend: []*config.Backend{
+						{
+							URLPattern: "/tupu",
+							ExtraConfig: config.ExtraConfig{
+								Namespace: map[string]interface{}{statusHandlerKey: name},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	subject := newSubject("error_code")
+	if err := subject.Init(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	subject = newSubject("unknown")
+	err := subject.Init()
+	if _, ok := err.(*UnknownHTTPStatusHandlerError); !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if msg := err.Error(); msg != "unknown status handler 'unknown' for the backend /tupu of the endpoint GET /supu" {
+		t.Errorf("unexpected error message: %s", msg)
+	}
+}
+
+func TestStatusErrorsHTTPStatusHandler(t *testing.T) {
+	remote := &config.Backend{
+		StatusErrors: map[int]config.StatusError{
+			http.StatusConflict: {Error: "conflict", Message: "the resource already exists"},
+			http.StatusTeapot:   {Error: "teapot", StatusCode: http.StatusServiceUnavailable},
+		},
+	}
+	sh := GetHTTPStatusHandler(remote)
+
+	for _, tc := range []struct {
+		status      int
+		expectedErr error
+	}{
+		{
+			status: http.StatusConflict,
+			expectedErr: MappedStatusError{
+				Code:          http.StatusConflict,
+				Err:           "conflict",
+				Msg:           "the resource already exists",
+				BackendStatus: http.StatusConflict,
+			},
+		},
+		{
+			status: http.StatusTeapot,
+			expectedErr: MappedStatusError{
+				Code:          http.StatusServiceUnavailable,
+				Err:           "teapot",
+				BackendStatus: http.StatusTeapot,
+			},
+		},
+		{status: http.StatusNotFound, expectedErr: ErrInvalidStatusCode},
+		{status: http.StatusOK},
+	} {
+		resp := &http.Response{
+			StatusCode: tc.status,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"foo":"bar"}`)),
+		}
+		_, err := sh(context.Background(), resp)
+		if err != tc.expectedErr {
+			t.Errorf("%d: unexpected error: %v", tc.status, err)
+		}
+	}
+
+	err := MappedStatusError{Code: http.StatusConflict, Err: "conflict"}
+	if err.Error() != "conflict" || err.StatusCode() != http.StatusConflict {
+		t.Errorf("unexpected error: %s (%d)", err.Error(), err.StatusCode())
+	}
+}
+
+func TestPassthroughHTTPStatusHandler(t *testing.T) {
+	sh := GetHTTPStatusHandler(&config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"passthrough_statuses": []interface{}{404.0, 409.0},
+			},
+		},
+	})
+
+	for _, code := range []int{http.StatusNotFound, http.StatusConflict} {
+		resp := &http.Response{
+			StatusCode: code,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"msg":"foo"}`)),
+		}
+		r, err := sh(context.Background(), resp)
+		if r != resp {
+			t.Errorf("%d: the response should be relayed", code)
+		}
+		if e, ok := err.(PassthroughStatusError); !ok || e.StatusCode() != code {
+			t.Errorf("%d: unexpected error: %v", code, err)
+		}
+	}
+
+	r, err := sh(context.Background(), &http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Body:       io.NopCloser(bytes.NewBufferString(`{"msg":"foo"}`)),
+	})
+	if r != nil || err != ErrInvalidStatusCode {
+		t.Errorf("unexpected result: %v %v", r, err)
+	}
+}
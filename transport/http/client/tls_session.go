@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const tlsSessionCacheSizeKey = "tls_session_cache_size"
+
+// NewClientSessionCache returns a tls.ClientSessionCache enabling the TLS session resumption
+// against the backends. The size of the cache for the hosts of a backend can be set with
+// the 'tls_session_cache_size' key of its extra config, while the rest of hosts share the
+// cache defined by the 'client_session_cache_size' of the client TLS config.
+// It returns nil if neither of them are defined.
+func NewClientSessionCache(cfg config.ServiceConfig) tls.ClientSessionCache {
+	var fallback tls.ClientSessionCache
+	if cfg.ClientTLS != nil && cfg.ClientTLS.ClientSessionCacheSize > 0 {
+		fallback = tls.NewLRUClientSessionCache(cfg.ClientTLS.ClientSessionCacheSize)
+	}
+
+	caches := map[string]tls.ClientSessionCache{}
+	for _, e := range cfg.Endpoints {
+		for _, b := range e.Backend {
+			m, ok := b.ExtraConfig[Namespace].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			size, ok := m[tlsSessionCacheSizeKey].(float64)
+			if !ok || size <= 0 {
+				continue
+			}
+			for _, h := range b.Host {
+				name := hostname(h)
+				if _, ok := caches[name]; name == "" || ok {
+					continue
+				}
+				caches[name] = tls.NewLRUClientSessionCache(int(size))
+			}
+		}
+	}
+
+	if len(caches) == 0 {
+		return fallback
+	}
+	return perHostSessionCache{caches: caches, fallback: fallback}
+}
+
+// perHostSessionCache dispatches the sessions to the cache of the host they belong to.
+// The crypto/tls package uses the server name as the session key.
+type perHostSessionCache struct {
+	caches   map[string]tls.ClientSessionCache
+	fallback tls.ClientSessionCache
+}
+
+// Get implements the tls.ClientSessionCache interface
+func (p perHostSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	if c := p.cache(sessionKey); c != nil {
+		return c.Get(sessionKey)
+	}
+	return nil, false
+}
+
+// Put implements the tls.ClientSessionCache interface
+func (p perHostSessionCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	if c := p.cache(sessionKey); c != nil {
+		c.Put(sessionKey, cs)
+	}
+}
+
+func (p perHostSessionCache) cache(sessionKey string) tls.ClientSessionCache {
+	if c, ok := p.caches[hostname(sessionKey)]; ok {
+		return c
+	}
+	return p.fallback
+}
+
+func hostname(h string) string {
+	if strings.Contains(h, "://") {
+		u, err := url.Parse(h)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(h); err == nil {
+		return host
+	}
+	return h
+}
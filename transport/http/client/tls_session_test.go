@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewClientSessionCache_resumption(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	cfg := config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{
+				Backend: []*config.Backend{
+					{
+						Host: []string{ts.URL},
+						ExtraConfig: config.ExtraConfig{
+							Namespace: map[string]interface{}{
+								"tls_session_cache_size": 10.0,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cache := NewClientSessionCache(cfg)
+	if cache == nil {
+		t.Error("unexpected nil cache")
+		return
+	}
+
+	certs := x509.NewCertPool()
+	certs.AddCert(ts.Certificate())
+	c := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig: &tls.Config{
+				RootCAs:            certs,
+				ClientSessionCache: cache,
+			},
+		},
+	}
+
+	for i, expected := range []bool{false, true} {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %s", i, err.Error())
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.TLS == nil {
+			t.Errorf("#%d: missing TLS connection state", i)
+			return
+		}
+		if resp.TLS.DidResume != expected {
+			t.Errorf("#%d: unexpected resumption state. have: %t, want: %t", i, resp.TLS.DidResume, expected)
+		}
+	}
+}
+
+func TestNewClientSessionCache_fallback(t *testing.T) {
+	if cache := NewClientSessionCache(config.ServiceConfig{}); cache != nil {
+		t.Errorf("unexpected cache: %v", cache)
+	}
+
+	cfg := config.ServiceConfig{
+		ClientTLS: &config.ClientTLS{ClientSessionCacheSize: 10},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Backend: []*config.Backend{
+					{
+						Host: []string{"https://example.com"},
+						ExtraConfig: config.ExtraConfig{
+							Namespace: map[string]interface{}{
+								"tls_session_cache_size": 1.0,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cache := NewClientSessionCache(cfg)
+	if cache == nil {
+		t.Error("unexpected nil cache")
+		return
+	}
+
+	for _, key := range []string{"example.com", "example.org", "127.0.0.1:8080"} {
+		state := &tls.ClientSessionState{}
+		cache.Put(key, state)
+		if s, ok := cache.Get(key); !ok || s != state {
+			t.Errorf("the session for %s was not stored", key)
+		}
+	}
+
+	// the cache of example.com has room just for one session
+	cache.Put("example.com", &tls.ClientSessionState{})
+	cache.Put("example.com:443", &tls.ClientSessionState{})
+	if _, ok := cache.Get("example.com"); ok {
+		t.Error("the session should be evicted")
+	}
+	if _, ok := cache.Get("example.org"); !ok {
+		t.Error("the session stored in the fallback cache should not be evicted")
+	}
+}
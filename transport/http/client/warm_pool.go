@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const warmPoolKey = "warm_pool"
+
+// WarmPoolConfig defines how many connections to keep established against every host of a
+// backend and how to keep them alive
+type WarmPoolConfig struct {
+	// Size is the number of connections to keep established
+	Size int `json:"size"`
+	// Interval is the time between two maintenance rounds. Defaults to 30s
+	Interval string `json:"interval"`
+	// Path is the path of the lightweight requests sent over the pooled connections
+	Path string `json:"path"`
+	// Method is the method of the lightweight requests. Defaults to HEAD
+	Method string `json:"method"`
+}
+
+// WarmPools keeps established connections against the backends defining a warm pool,
+// so bursts of traffic do not pay the costs of the TCP and TLS handshakes.
+// Every maintenance round sends Size concurrent lightweight requests to each host, leaving
+// the connections idle in the transport pool (remember to set the MaxIdleConnsPerHost
+// value accordingly).
+type WarmPools struct {
+	wg *sync.WaitGroup
+}
+
+// Wait blocks until all the maintenance goroutines are finished
+func (w *WarmPools) Wait() {
+	w.wg.Wait()
+}
+
+// StartWarmPools starts the maintenance of the warm pools defined by the backends of the service.
+// The maintenance stops when the received context is canceled.
+func StartWarmPools(ctx context.Context, cfg config.ServiceConfig, cf HTTPClientFactory, logger logging.Logger) *WarmPools {
+	pools := &WarmPools{wg: new(sync.WaitGroup)}
+	visited := map[string]struct{}{}
+
+	for _, e := range cfg.Endpoints {
+		for _, b := range e.Backend {
+			wpCfg, ok := getWarmPoolConfig(b.ExtraConfig)
+			if !ok {
+				continue
+			}
+			interval, err := time.ParseDuration(wpCfg.Interval)
+			if err != nil || interval <= 0 {
+				interval = 30 * time.Second
+			}
+			method := strings.ToUpper(wpCfg.Method)
+			if method == "" {
+				method = http.MethodHead
+			}
+
+			for _, h := range b.Host {
+				target := strings.TrimRight(h, "/") + wpCfg.Path
+				if _, ok := visited[target]; ok {
+					continue
+				}
+				visited[target] = struct{}{}
+
+				logger.Debug(fmt.Sprintf("[SERVICE: Warm pool] Keeping %d connections alive against %s every %s",
+					wpCfg.Size, target, interval))
+
+				pools.wg.Add(1)
+				go func(target string) {
+					defer pools.wg.Done()
+					maintainWarmPool(ctx, cf, method, target, wpCfg.Size, interval, logger)
+				}(target)
+			}
+		}
+	}
+	return pools
+}
+
+func maintainWarmPool(ctx context.Context, cf HTTPClientFactory, method, target string, size int, interval time.Duration, logger logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		warmUp(ctx, cf, method, target, size, logger)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func warmUp(ctx context.Context, cf HTTPClientFactory, method, target string, size int, logger logging.Logger) {
+	wg := new(sync.WaitGroup)
+	for i := 0; i < size; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, method, target, nil)
+			if err != nil {
+				return
+			}
+			resp, err := cf(ctx).Do(req)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Debug(fmt.Sprintf("[SERVICE: Warm pool] Unable to warm up %s: %s", target, err.Error()))
+				}
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func getWarmPoolConfig(extra config.ExtraConfig) (WarmPoolConfig, bool) {
+	m, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return WarmPoolConfig{}, false
+	}
+	v, ok := m[warmPoolKey]
+	if !ok {
+		return WarmPoolConfig{}, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return WarmPoolConfig{}, false
+	}
+	cfg := WarmPoolConfig{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return WarmPoolConfig{}, false
+	}
+	return cfg, cfg.Size > 0
+}
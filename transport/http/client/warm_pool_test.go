@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestStartWarmPools(t *testing.T) {
+	var hits int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/__health" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		atomic.AddInt64(&hits, 1)
+	}))
+	defer ts.Close()
+
+	backend := &config.Backend{
+		Host: []string{ts.URL},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"warm_pool": map[string]interface{}{
+					"size":     3.0,
+					"interval": "10ms",
+					"path":     "/__health",
+				},
+			},
+		},
+	}
+	cfg := config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Backend: []*config.Backend{backend, backend}},
+			{Backend: []*config.Backend{{Host: []string{ts.URL}}}},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pools := StartWarmPools(ctx, cfg, NewHTTPClient, logging.NoOp)
+
+	time.Sleep(55 * time.Millisecond)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		pools.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("the warm pool maintenance did not stop")
+		return
+	}
+
+	total := atomic.LoadInt64(&hits)
+	if total < 6 {
+		t.Errorf("unexpected number of hits: %d", total)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if h := atomic.LoadInt64(&hits); h != total {
+		t.Errorf("the server received requests after the shutdown: %d", h-total)
+	}
+}
+
+func TestStartWarmPools_noConfig(t *testing.T) {
+	pools := StartWarmPools(context.Background(), config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Backend: []*config.Backend{{Host: []string{"http://127.0.0.1:1"}}}},
+		},
+	}, NewHTTPClient, logging.NoOp)
+	pools.Wait()
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/feature"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+const (
+	adminKey = "admin"
+
+	defaultAdminPath = "/__admin"
+	adminLogPrefix   = "[SERVICE: Admin]"
+)
+
+// AdminConfig defines the runtime control API of the service. The API is served on its own
+// port or, if the port is not defined, under the path of the main server. In both cases the
+// requests must be authenticated with one of the tokens.
+type AdminConfig struct {
+	// Port is the port of the dedicated admin server
+	Port int `json:"port"`
+	// Path is the prefix of the admin endpoints. Defaults to /__admin
+	Path string `json:"path"`
+	// Tokens maps the identity of the callers to their bearer tokens
+	Tokens map[string]string `json:"tokens"`
+}
+
+// GetAdminConfig parses the admin block of the service extra config
+func GetAdminConfig(cfg config.ServiceConfig) (AdminConfig, bool, error) {
+	v, ok := cfg.ExtraConfig[Namespace]
+	if !ok {
+		return AdminConfig{}, false, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return AdminConfig{}, false, nil
+	}
+	tmp, ok := e[adminKey]
+	if !ok {
+		return AdminConfig{}, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return AdminConfig{}, false, err
+	}
+	adminCfg := AdminConfig{}
+	if err := json.Unmarshal(b, &adminCfg); err != nil {
+		return AdminConfig{}, false, err
+	}
+	if adminCfg.Path == "" {
+		adminCfg.Path = defaultAdminPath
+	}
+	adminCfg.Path = "/" + strings.Trim(adminCfg.Path, "/")
+	if len(adminCfg.Tokens) == 0 {
+		return AdminConfig{}, false, fmt.Errorf("the admin endpoints under the path %s require a list of tokens, even when served on a dedicated port", adminCfg.Path)
+	}
+	return adminCfg, true, nil
+}
+
+// NewAdminHandler returns the handler of the runtime control API:
+//
+//	GET    {path}/log             returns the log levels
+//	PUT    {path}/log             updates the level of the logger or one of its modules
+//	GET    {path}/features        returns the feature flags
+//	PUT    {path}/features/{name} sets the feature flag
+//	DELETE {path}/features/{name} removes the feature flag
+//	POST   {path}/cache/flush     removes all the entries of the backend caches
+//
+// The log endpoints require a logger implementing the logging.LevelController interface.
+// Every mutation is logged with the identity of the caller, using the CRITICAL level so it
+// can not be hidden by changing the log levels through the same API.
+func NewAdminHandler(adminCfg AdminConfig, logger logging.Logger) http.Handler {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	return &adminHandler{cfg: adminCfg, logger: logger}
+}
+
+// NewAdminPathHandler mounts the admin handler under the configured path of the received
+// handler, if the service requires it and does not define a dedicated port for it.
+func NewAdminPathHandler(cfg config.ServiceConfig, next http.Handler, logger logging.Logger) http.Handler {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	adminCfg, ok, err := GetAdminConfig(cfg)
+	if err != nil {
+		logger.Error(adminLogPrefix, err.Error())
+		return next
+	}
+	if !ok || adminCfg.Port != 0 {
+		return next
+	}
+	logger.Debug(fmt.Sprintf("%s Serving the admin endpoints under %s", adminLogPrefix, adminCfg.Path))
+
+	admin := NewAdminHandler(adminCfg, logger)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == adminCfg.Path || strings.HasPrefix(r.URL.Path, adminCfg.Path+"/") {
+			admin.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RunAdminServer runs the dedicated admin server until the context is cancelled. It returns
+// immediately if the service does not define a port for the admin endpoints.
+func RunAdminServer(ctx context.Context, cfg config.ServiceConfig, logger logging.Logger) error {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	adminCfg, ok, err := GetAdminConfig(cfg)
+	if err != nil {
+		logger.Error(adminLogPrefix, err.Error())
+		return err
+	}
+	if !ok || adminCfg.Port == 0 {
+		return nil
+	}
+
+	s := &http.Server{
+		Addr:    net.JoinHostPort(cfg.Address, fmt.Sprintf("%d", adminCfg.Port)),
+		Handler: NewAdminHandler(adminCfg, logger),
+	}
+	logger.Debug(fmt.Sprintf("%s Listening on %s", adminLogPrefix, s.Addr))
+
+	done := make(chan error)
+	go func() {
+		done <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-done:
+		logger.Error(adminLogPrefix, err.Error())
+		return err
+	case <-ctx.Done():
+		return s.Shutdown(context.Background())
+	}
+}
+
+type adminHandler struct {
+	cfg    AdminConfig
+	logger logging.Logger
+}
+
+type adminLogLevel struct {
+	Module string `json:"module,omitempty"`
+	Level  string `json:"level"`
+}
+
+type adminLogLevels struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+type adminFeatureFlag struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ServeHTTP implements the http.Handler interface
+func (a *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller, ok := a.caller(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, a.cfg.Path)
+	switch {
+	case path == "/log":
+		a.serveLog(w, r, caller)
+	case path == "/features" && r.Method == http.MethodGet:
+		a.writeJSON(w, feature.All())
+	case strings.HasPrefix(path, "/features/") && len(path) > len("/features/"):
+		a.serveFeature(w, r, caller, path[len("/features/"):])
+	case path == "/cache/flush" && r.Method == http.MethodPost:
+		n := proxy.FlushBackendCaches()
+		a.logger.Critical(fmt.Sprintf("%s %s flushed the backend caches (%d entries)", adminLogPrefix, caller, n))
+		a.writeJSON(w, map[string]int{"flushed": n})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *adminHandler) serveLog(w http.ResponseWriter, r *http.Request, caller string) {
+	lc, ok := a.logger.(logging.LevelController)
+	if !ok {
+		http.Error(w, "the logger does not support runtime levels", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var l adminLogLevel
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		if l.Module == "" {
+			err = lc.SetLevel(l.Level)
+		} else {
+			err = lc.SetModuleLevel(l.Module, l.Level)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		module := l.Module
+		if module == "" {
+			module = "*"
+		}
+		a.logger.Critical(fmt.Sprintf("%s %s set the log level of '%s' to '%s'", adminLogPrefix, caller, module, l.Level))
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	a.writeJSON(w, adminLogLevels{Level: lc.Level(), Modules: lc.ModuleLevels()})
+}
+
+func (a *adminHandler) serveFeature(w http.ResponseWriter, r *http.Request, caller, name string) {
+	switch r.Method {
+	case http.MethodPut:
+		var f adminFeatureFlag
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		feature.Set(name, f.Enabled)
+		a.logger.Critical(fmt.Sprintf("%s %s set the feature flag '%s' to %t", adminLogPrefix, caller, name, f.Enabled))
+	case http.MethodDelete:
+		feature.Unset(name)
+		a.logger.Critical(fmt.Sprintf("%s %s removed the feature flag '%s'", adminLogPrefix, caller, name))
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	a.writeJSON(w, feature.All())
+}
+
+// caller returns the identity of the owner of the bearer token of the request
+func (a *adminHandler) caller(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	token := []byte(auth[len("Bearer "):])
+	for identity, t := range a.cfg.Tokens {
+		if t != "" && subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			return identity, true
+		}
+	}
+	return "", false
+}
+
+func (a *adminHandler) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		a.logger.Error(adminLogPrefix, err.Error())
+	}
+}
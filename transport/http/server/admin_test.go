@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/feature"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+var adminTestCfg = config.ServiceConfig{
+	ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			adminKey: map[string]interface{}{
+				"tokens": map[string]interface{}{"ops": "s3cr3t"},
+			},
+		},
+	},
+}
+
+func adminRequest(method, path, body, token string) *http.Request {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestNewAdminPathHandler_logLevel(t *testing.T) {
+	buff := bytes.NewBuffer(make([]byte, 0, 1024))
+	logger, _ := logging.NewDynamicLogger("WARNING", buff, "")
+
+	h := NewAdminPathHandler(adminTestCfg, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		logger.Debug("[ENDPOINT: /foo][Cache] served from the cache")
+		w.WriteHeader(http.StatusOK)
+	}), logger)
+
+	h.ServeHTTP(httptest.NewRecorder(), adminRequest("GET", "/foo", "", ""))
+	if strings.Contains(buff.String(), "served from the cache") {
+		t.Errorf("unexpected debug entry: %s", buff.String())
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("PUT", "/__admin/log", `{"module":"Cache","level":"DEBUG"}`, "wrong"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("PUT", "/__admin/log", `{"module":"Cache","level":"DEBUG"}`, "s3cr3t"))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+		return
+	}
+	var levels adminLogLevels
+	if err := json.Unmarshal(w.Body.Bytes(), &levels); err != nil {
+		t.Error(err)
+		return
+	}
+	if levels.Level != "WARNING" || levels.Modules["Cache"] != "DEBUG" {
+		t.Errorf("unexpected levels: %+v", levels)
+	}
+	if !strings.Contains(buff.String(), "ops set the log level of 'Cache' to 'DEBUG'") {
+		t.Errorf("the mutation was not logged: %s", buff.String())
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), adminRequest("GET", "/foo", "", ""))
+	if !strings.Contains(buff.String(), "DEBUG: [ENDPOINT: /foo][Cache] served from the cache") {
+		t.Errorf("the debug entry should be logged: %s", buff.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("PUT", "/__admin/log", `{"level":"UNKNOWN"}`, "s3cr3t"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("PUT", "/__admin/log", `{"level":"CRITICAL"}`, "s3cr3t"))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("PUT", "/__admin/log", `{"module":"SERVICE: Admin","level":"CRITICAL"}`, "s3cr3t"))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if !strings.Contains(buff.String(), "ops set the log level of 'SERVICE: Admin' to 'CRITICAL'") {
+		t.Errorf("the mutations should be logged with any level: %s", buff.String())
+	}
+}
+
+func TestRunAdminServer_noTokens(t *testing.T) {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{adminKey: map[string]interface{}{"port": 9091}},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := RunAdminServer(ctx, cfg, nil); err == nil {
+		t.Error("the admin server without tokens should not start")
+	}
+}
+
+func TestNewAdminPathHandler_features(t *testing.T) {
+	defer feature.Unset("shadow")
+
+	buff := bytes.NewBuffer(make([]byte, 0, 1024))
+	logger, _ := logging.NewDynamicLogger("WARNING", buff, "")
+	h := NewAdminPathHandler(adminTestCfg, http.NotFoundHandler(), logger)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("PUT", "/__admin/features/shadow", `{"enabled":false}`, "s3cr3t"))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+		return
+	}
+	if feature.Enabled("shadow", true) {
+		t.Error("the feature should be disabled")
+	}
+	if !strings.Contains(buff.String(), "ops set the feature flag 'shadow' to false") {
+		t.Errorf("the mutation was not logged: %s", buff.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("GET", "/__admin/features", "", "s3cr3t"))
+	if body := strings.TrimSpace(w.Body.String()); body != `{"shadow":false}` {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("DELETE", "/__admin/features/shadow", "", "s3cr3t"))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if !feature.Enabled("shadow", true) {
+		t.Error("the feature should use its default value")
+	}
+}
+
+func TestNewAdminPathHandler_flushCache(t *testing.T) {
+	calls := 0
+	p := proxy.NewBackendCacheMiddleware(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			proxy.Namespace: map[string]interface{}{
+				"cache": map[string]interface{}{"ttl": "1m"},
+			},
+		},
+	})(func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		calls++
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{}}, nil
+	})
+
+	buff := bytes.NewBuffer(make([]byte, 0, 1024))
+	logger, _ := logging.NewDynamicLogger("WARNING", buff, "")
+	h := NewAdminPathHandler(adminTestCfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p(r.Context(), &proxy.Request{Method: "GET", Path: r.URL.Path})
+	}), logger)
+
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), adminRequest("GET", "/foo", "", ""))
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, adminRequest("POST", "/__admin/cache/flush", "", "s3cr3t"))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if !strings.Contains(buff.String(), "ops flushed the backend caches") {
+		t.Errorf("the mutation was not logged: %s", buff.String())
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), adminRequest("GET", "/foo", "", ""))
+	if calls != 2 {
+		t.Errorf("the request after the flush should miss the cache. calls: %d", calls)
+	}
+}
+
+func TestGetAdminConfig(t *testing.T) {
+	if _, ok, err := GetAdminConfig(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+
+	_, _, err := GetAdminConfig(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{adminKey: map[string]interface{}{"path": "/admin"}},
+		},
+	})
+	if err == nil {
+		t.Error("the admin path without tokens should be rejected")
+	}
+
+	_, _, err = GetAdminConfig(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{adminKey: map[string]interface{}{"port": 9090}},
+		},
+	})
+	if err == nil {
+		t.Error("the admin port without tokens should be rejected")
+	}
+
+	cfg, ok, err := GetAdminConfig(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{adminKey: map[string]interface{}{
+				"port":   9090,
+				"tokens": map[string]interface{}{"ops": "s3cr3t"},
+			}},
+		},
+	})
+	if !ok || err != nil || cfg.Port != 9090 || cfg.Path != defaultAdminPath {
+		t.Errorf("unexpected config: %+v %v", cfg, err)
+	}
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+)
+
+const allowedContentTypesKey = "allowed_content_types"
+
+// GetAllowedContentTypes returns the list of media types accepted as the body of the requests
+// to the endpoint, as defined in the received extra config:
+//
+//	"allowed_content_types": [ "application/json", "text/*" ]
+//
+// Both the type and the subtype accept wildcards, as in encoding.MatchContentType, so
+// patterns like "application/*+json" are supported. It returns nil if the endpoint accepts any
+// content type.
+func GetAllowedContentTypes(extra config.ExtraConfig) []string {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	v, ok := e[allowedContentTypesKey].([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]string, 0, len(v))
+	for _, t := range v {
+		if s, ok := t.(string); ok && s != "" {
+			res = append(res, strings.ToLower(s))
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// ContentTypeAllowed reports if the content type of the request body is in the allowed list.
+// The requests without body are always allowed.
+func ContentTypeAllowed(r *http.Request, allowed []string) bool {
+	if r.Body == nil || r.Body == http.NoBody || (r.ContentLength == 0 && len(r.TransferEncoding) == 0) {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return encoding.MatchAnyContentType(allowed, mediaType)
+}
+
+// NewAllowedContentTypesHandler wraps the received handler, rejecting the requests with a body
+// of a content type not in the allowed list with a 415 status code. The handler is returned as
+// it is if the list is empty.
+func NewAllowedContentTypesHandler(allowed []string, next http.Handler) http.Handler {
+	if len(allowed) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ContentTypeAllowed(r, allowed) {
+			w.Header().Set(CompleteResponseHeaderName, HeaderIncompleteResponseValue)
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewAllowedContentTypesHandler(t *testing.T) {
+	h := NewAllowedContentTypesHandler([]string{"application/json", "text/*", "application/*+json"}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{contentType: "application/json", body: "{}", status: http.StatusOK},
+		{contentType: "Application/JSON; charset=utf-8", body: "{}", status: http.StatusOK},
+		{contentType: "text/plain", body: "foo", status: http.StatusOK},
+		{contentType: "text/csv", body: "a,b", status: http.StatusOK},
+		{contentType: "application/problem+json", body: "{}", status: http.StatusOK},
+		{contentType: "application/xml", body: "<a/>", status: http.StatusUnsupportedMediaType},
+		{contentType: "application/json-seq", body: "{}", status: http.StatusUnsupportedMediaType},
+		{contentType: "textile/plain", body: "foo", status: http.StatusUnsupportedMediaType},
+		{contentType: "", body: "{}", status: http.StatusUnsupportedMediaType},
+		{contentType: "not a media type;", body: "{}", status: http.StatusUnsupportedMediaType},
+		{contentType: "application/xml", body: "", status: http.StatusOK},
+	} {
+		req := httptest.NewRequest("POST", "/foo", strings.NewReader(tc.body))
+		if tc.body == "" {
+			req = httptest.NewRequest("POST", "/foo", http.NoBody)
+		}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status code. have: %d, want: %d", tc.contentType, w.Code, tc.status)
+		}
+		if tc.status == http.StatusUnsupportedMediaType && w.Header().Get(CompleteResponseHeaderName) != HeaderIncompleteResponseValue {
+			t.Errorf("%s: unexpected complete header: %s", tc.contentType, w.Header().Get(CompleteResponseHeaderName))
+		}
+	}
+}
+
+func TestNewAllowedContentTypesHandler_wildcard(t *testing.T) {
+	h := NewAllowedContentTypesHandler([]string{"*/*"}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("POST", "/foo", strings.NewReader("<a/>"))
+	req.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
+
+func TestGetAllowedContentTypes(t *testing.T) {
+	extra := config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			allowedContentTypesKey: []interface{}{"Application/JSON", 42, "", "text/*"},
+		},
+	}
+	if ct := GetAllowedContentTypes(extra); !reflect.DeepEqual(ct, []string{"application/json", "text/*"}) {
+		t.Errorf("unexpected content types: %v", ct)
+	}
+	if ct := GetAllowedContentTypes(config.ExtraConfig{}); ct != nil {
+		t.Errorf("unexpected content types: %v", ct)
+	}
+}
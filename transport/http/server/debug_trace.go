@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+const (
+	debugTraceKey = "debug_trace"
+
+	// DefaultDebugTraceHeader is the default name of the header carrying the debug token
+	DefaultDebugTraceHeader = "X-Debug-Trace"
+	// ServerTimingHeaderName is the name of the header exposing the traced timings
+	ServerTimingHeaderName = "Server-Timing"
+	// RandomDecisionsHeaderName is the name of the header exposing the traced random draws
+	RandomDecisionsHeaderName = "X-Random-Decisions"
+)
+
+// DebugTrace enables the tracing of the requests presenting the configured token in the
+// debug header. The traced requests get a Server-Timing header with the URL, the status and
+// the duration of every backend request, along with the total duration of the proxy. The
+// random draws taken by the sources wrapped with random.NewDecisionLog are listed in the
+// X-Random-Decisions header.
+type DebugTrace struct {
+	Header string
+	Token  string
+}
+
+// EndpointDebugTrace returns the debug trace config of the endpoint:
+//
+//	"debug_trace": { "header": "X-Debug-Trace", "token": "s3cr3t" }
+//
+// It returns nil if the endpoint does not define it or the token is empty.
+func EndpointDebugTrace(cfg *config.EndpointConfig) *DebugTrace {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	tmp, ok := e[debugTraceKey].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	d := &DebugTrace{Header: DefaultDebugTraceHeader}
+	d.Token, _ = tmp["token"].(string)
+	if d.Token == "" {
+		return nil
+	}
+	if h, ok := tmp["header"].(string); ok && h != "" {
+		d.Header = textproto.CanonicalMIMEHeaderKey(h)
+	}
+	return d
+}
+
+// Start returns a context tracing the backend requests if the request presents a valid
+// debug token. Otherwise, the context is returned as it is along with a nil trace.
+func (d *DebugTrace) Start(ctx context.Context, r *http.Request) (context.Context, *proxy.RequestTrace) {
+	if d == nil {
+		return ctx, nil
+	}
+	token := r.Header.Get(d.Header)
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(d.Token)) != 1 {
+		return ctx, nil
+	}
+	t := proxy.NewRequestTrace()
+	return t.WithContext(ctx), t
+}
+
+// Apply adds the Server-Timing and the X-Random-Decisions headers with the records of the
+// trace. It does nothing if the trace is nil.
+func (*DebugTrace) Apply(w http.ResponseWriter, t *proxy.RequestTrace, total time.Duration) {
+	if t == nil {
+		return
+	}
+	for i, b := range t.Backends() {
+		desc := fmt.Sprintf("%s %s %d", b.Method, b.URL, b.StatusCode)
+		if b.Error != "" {
+			desc += " " + b.Error
+		}
+		w.Header().Add(ServerTimingHeaderName, fmt.Sprintf("backend-%d;desc=%s;dur=%s", i, strconv.Quote(desc), milliseconds(b.Duration)))
+	}
+	w.Header().Add(ServerTimingHeaderName, "total;dur="+milliseconds(total))
+	for _, d := range t.Decisions() {
+		w.Header().Add(RandomDecisionsHeaderName, d)
+	}
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/random"
+)
+
+func TestEndpointDebugTrace(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		extra config.ExtraConfig
+		want  *DebugTrace
+	}{
+		{name: "missing", extra: config.ExtraConfig{}},
+		{
+			name:  "no token",
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{debugTraceKey: map[string]interface{}{"header": "X-Debug"}}},
+		},
+		{
+			name:  "default header",
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{debugTraceKey: map[string]interface{}{"token": "s3cr3t"}}},
+			want:  &DebugTrace{Header: DefaultDebugTraceHeader, Token: "s3cr3t"},
+		},
+		{
+			name: "custom header",
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{debugTraceKey: map[string]interface{}{
+				"token":  "s3cr3t",
+				"header": "x-debug",
+			}}},
+			want: &DebugTrace{Header: "X-Debug", Token: "s3cr3t"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := EndpointDebugTrace(&config.EndpointConfig{ExtraConfig: tc.extra})
+			if tc.want == nil {
+				if d != nil {
+					t.Errorf("unexpected debug trace: %+v", d)
+				}
+				return
+			}
+			if d == nil || *d != *tc.want {
+				t.Errorf("unexpected debug trace. have: %+v, want: %+v", d, tc.want)
+			}
+		})
+	}
+}
+
+func TestDebugTrace(t *testing.T) {
+	d := &DebugTrace{Header: DefaultDebugTraceHeader, Token: "s3cr3t"}
+
+	for _, tc := range []struct {
+		name   string
+		d      *DebugTrace
+		token  string
+		traced bool
+	}{
+		{name: "disabled", token: "s3cr3t"},
+		{name: "without the header", d: d},
+		{name: "with a wrong token", d: d, token: "wrong"},
+		{name: "with the header", d: d, token: "s3cr3t", traced: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://example.com", http.NoBody)
+			if tc.token != "" {
+				r.Header.Set(DefaultDebugTraceHeader, tc.token)
+			}
+			ctx, trace := tc.d.Start(context.Background(), r)
+			random.ForContext(ctx, random.WithName(random.NewDecisionLog(random.NewSource(1)), "[Balancer]")).Intn(2)
+			if (trace != nil) != tc.traced {
+				t.Errorf("unexpected trace: %v", trace)
+				return
+			}
+			if trace != nil {
+				trace.Add(proxy.BackendTrace{
+					Method:     "GET",
+					URL:        "http://backend/foo",
+					StatusCode: 200,
+					Duration:   1500 * time.Microsecond,
+				})
+			}
+
+			w := httptest.NewRecorder()
+			tc.d.Apply(w, trace, 2*time.Millisecond)
+			timings := w.Header().Values(ServerTimingHeaderName)
+			if !tc.traced {
+				if len(timings) != 0 {
+					t.Errorf("unexpected timings: %v", timings)
+				}
+				return
+			}
+			if len(timings) != 2 {
+				t.Errorf("unexpected timings: %v", timings)
+				return
+			}
+			if timings[0] != `backend-0;desc="GET http://backend/foo 200";dur=1.500` {
+				t.Errorf("unexpected backend timing: %s", timings[0])
+			}
+			if timings[1] != "total;dur=2.000" {
+				t.Errorf("unexpected total timing: %s", timings[1])
+			}
+			if decisions := w.Header().Values(RandomDecisionsHeaderName); len(decisions) != 1 || decisions[0] != "[Balancer] Intn(2) = 1" {
+				t.Errorf("unexpected random decisions: %v", decisions)
+			}
+		})
+	}
+}
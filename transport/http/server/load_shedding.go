@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// Namespace is the key to use to store and access the custom config data for the server
+const Namespace = "github_com/luraproject/lura/transport/http/server"
+
+const (
+	loadSheddingKey = "load_shedding"
+
+	// InFlightRequestsGauge is the name of the gauge tracking the requests being served
+	InFlightRequestsGauge = "server.requests.in_flight"
+	// ShedRequestsCounter is the name of the counter tracking the rejected requests
+	ShedRequestsCounter = "server.requests.shed"
+)
+
+var defaultHealthPaths = []string{"/__health", "/__debug/", "/__echo/"}
+
+// LoadSheddingConfig defines the global ceilings of in-flight requests
+type LoadSheddingConfig struct {
+	// MaxInFlight is the maximum number of concurrent requests accepted by the server
+	MaxInFlight int64 `json:"max_in_flight"`
+	// MaxInFlightHealth is the ceiling applied to the requests to the health and debug paths.
+	// It should be higher than MaxInFlight so they keep answering when the server is
+	// shedding load. Defaults to 110% of MaxInFlight
+	MaxInFlightHealth int64 `json:"max_in_flight_health"`
+	// HealthPaths are the path prefixes of the health and debug endpoints
+	HealthPaths []string `json:"health_paths"`
+	// RetryAfter is the value of the Retry-After header added to the rejected requests
+	RetryAfter string `json:"retry_after"`
+}
+
+// NewLoadSheddingHandler wraps the received handler with a global in-flight requests ceiling,
+// if the service config defines it. When the number of in-flight requests reaches the
+// ceiling, the new requests are rejected immediately with a 503 Service Unavailable
+// and a Retry-After header.
+func NewLoadSheddingHandler(cfg config.ServiceConfig, next http.Handler, logger logging.Logger) http.Handler {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	v, ok := cfg.ExtraConfig[Namespace]
+	if !ok {
+		return next
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return next
+	}
+	tmp, ok := e[loadSheddingKey]
+	if !ok {
+		return next
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		logger.Error(fmt.Sprintf("%s Unable to parse the load shedding config: %s", loggerPrefix, err.Error()))
+		return next
+	}
+	lsCfg := LoadSheddingConfig{}
+	if err := json.Unmarshal(b, &lsCfg); err != nil {
+		logger.Error(fmt.Sprintf("%s Unable to parse the load shedding config: %s", loggerPrefix, err.Error()))
+		return next
+	}
+	if lsCfg.MaxInFlight <= 0 {
+		return next
+	}
+
+	retryAfter := "1"
+	if lsCfg.RetryAfter != "" {
+		d, err := time.ParseDuration(lsCfg.RetryAfter)
+		if err != nil {
+			logger.Warning(fmt.Sprintf("%s Wrong retry_after value for the load shedding: %s", loggerPrefix, err.Error()))
+		} else {
+			retryAfter = strconv.Itoa(int(math.Ceil(d.Seconds())))
+		}
+	}
+
+	if lsCfg.MaxInFlightHealth < lsCfg.MaxInFlight {
+		lsCfg.MaxInFlightHealth = lsCfg.MaxInFlight + int64(math.Ceil(float64(lsCfg.MaxInFlight)/10))
+	}
+	if len(lsCfg.HealthPaths) == 0 {
+		lsCfg.HealthPaths = defaultHealthPaths
+	}
+
+	logger.Debug(fmt.Sprintf("%s Shedding load over %d in-flight requests (%d for the health endpoints)",
+		loggerPrefix, lsCfg.MaxInFlight, lsCfg.MaxInFlightHealth))
+
+	return &loadSheddingHandler{
+		max:         lsCfg.MaxInFlight,
+		maxHealth:   lsCfg.MaxInFlightHealth,
+		healthPaths: lsCfg.HealthPaths,
+		retryAfter:  retryAfter,
+		next:        next,
+	}
+}
+
+type loadSheddingHandler struct {
+	inFlight    int64
+	max         int64
+	maxHealth   int64
+	healthPaths []string
+	retryAfter  string
+	next        http.Handler
+}
+
+var (
+	regularRequestLabels = map[string]string{"class": "regular"}
+	healthRequestLabels  = map[string]string{"class": "health"}
+)
+
+// ServeHTTP implements the http.Handler interface
+func (l *loadSheddingHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	limit := l.max
+	labels := regularRequestLabels
+	if l.isHealthRequest(req) {
+		limit = l.maxHealth
+		labels = healthRequestLabels
+	}
+
+	recorder := events.DefaultRecorder()
+	current := atomic.AddInt64(&l.inFlight, 1)
+	defer func() {
+		recorder.Gauge(InFlightRequestsGauge, atomic.AddInt64(&l.inFlight, -1), nil)
+	}()
+	recorder.Gauge(InFlightRequestsGauge, current, nil)
+
+	if current > limit {
+		recorder.Counter(ShedRequestsCounter, 1, labels)
+		rw.Header().Set("Retry-After", l.retryAfter)
+		http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	l.next.ServeHTTP(rw, req)
+}
+
+func (l *loadSheddingHandler) isHealthRequest(req *http.Request) bool {
+	for _, p := range l.healthPaths {
+		if strings.HasPrefix(req.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
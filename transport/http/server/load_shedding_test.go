@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/events"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewLoadSheddingHandler(t *testing.T) {
+	recorder := &dummyRecorder{counters: map[string]int64{}, mu: new(sync.Mutex)}
+	events.SetDefaultRecorder(recorder)
+	defer events.SetDefaultRecorder(nil)
+
+	release := make(chan struct{})
+	entered := make(chan struct{}, 10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(rw http.ResponseWriter, _ *http.Request) {
+		entered <- struct{}{}
+		<-release
+		rw.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/__health", func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				loadSheddingKey: map[string]interface{}{
+					"max_in_flight":        2,
+					"max_in_flight_health": 5,
+					"retry_after":          "2s",
+				},
+			},
+		},
+	}
+	s := httptest.NewServer(NewLoadSheddingHandler(cfg, mux, logging.NoOp))
+	defer s.Close()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(s.URL + "/slow")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("unexpected status code: %d", resp.StatusCode)
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-entered:
+		case <-time.After(time.Second):
+			t.Fatal("the slow requests did not reach the handler")
+		}
+	}
+
+	shedWG := new(sync.WaitGroup)
+	for i := 0; i < 3; i++ {
+		shedWG.Add(1)
+		go func() {
+			defer shedWG.Done()
+			start := time.Now()
+			resp, err := http.Get(s.URL + "/slow")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("unexpected status code: %d", resp.StatusCode)
+			}
+			if v := resp.Header.Get("Retry-After"); v != "2" {
+				t.Errorf("unexpected Retry-After header: '%s'", v)
+			}
+			if d := time.Since(start); d > 500*time.Millisecond {
+				t.Errorf("the request was not shed fast enough: %s", d)
+			}
+		}()
+	}
+	shedWG.Wait()
+
+	resp, err := http.Get(s.URL + "/__health")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code for the health check: %d", resp.StatusCode)
+	}
+
+	close(release)
+	wg.Wait()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if v := recorder.counters[ShedRequestsCounter]; v != 3 {
+		t.Errorf("unexpected number of shed requests: %d", v)
+	}
+	if _, ok := recorder.gauges[InFlightRequestsGauge]; !ok {
+		t.Error("the in-flight requests gauge has not been reported")
+	}
+}
+
+func TestNewServerWithLogger_shedAdminPath(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				adminKey: map[string]interface{}{
+					"tokens": map[string]interface{}{"ops": "s3cr3t"},
+				},
+				loadSheddingKey: map[string]interface{}{"max_in_flight": 1},
+			},
+		},
+	}
+	srv := NewServerWithLogger(cfg, http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		close(entered)
+		<-release
+	}), logging.NoOp)
+	s := httptest.NewServer(srv.Handler)
+	defer s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := http.Get(s.URL + "/slow"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("the slow request did not reach the handler")
+	}
+
+	req, _ := http.NewRequest("GET", s.URL+"/__admin/features", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	resp, err := http.DefaultClient.Do(req)
+	close(release)
+	<-done
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("the admin requests should be shed. status code: %d", resp.StatusCode)
+	}
+}
+
+func TestNewLoadSheddingHandler_notConfigured(t *testing.T) {
+	h := http.NotFoundHandler()
+	if res := NewLoadSheddingHandler(config.ServiceConfig{}, h, nil); res == nil {
+		t.Error("nil handler")
+	} else if _, ok := res.(*loadSheddingHandler); ok {
+		t.Error("the handler should not be wrapped")
+	}
+}
+
+type dummyRecorder struct {
+	counters map[string]int64
+	gauges   map[string]int64
+	mu       *sync.Mutex
+}
+
+func (d *dummyRecorder) Counter(name string, delta int64, _ map[string]string) {
+	d.mu.Lock()
+	d.counters[name] += delta
+	d.mu.Unlock()
+}
+
+func (d *dummyRecorder) Gauge(name string, value int64, _ map[string]string) {
+	d.mu.Lock()
+	if d.gauges == nil {
+		d.gauges = map[string]int64{}
+	}
+	d.gauges[name] = value
+	d.mu.Unlock()
+}
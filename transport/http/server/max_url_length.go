@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const maxURLLengthKey = "max_url_length"
+
+// GetMaxURLLength returns the maximum length of the request URLs defined in the received
+// extra config, or 0 if there is no limit
+func GetMaxURLLength(extra config.ExtraConfig) int {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	v, ok := e[maxURLLengthKey].(float64)
+	if !ok || v < 1 {
+		return 0
+	}
+	return int(v)
+}
+
+// EndpointMaxURLLength returns the maximum length of the URLs accepted by the endpoint. The
+// limit defined by the endpoint overrides the one defined at the service level.
+func EndpointMaxURLLength(cfg config.ServiceConfig, e *config.EndpointConfig) int {
+	if l := GetMaxURLLength(e.ExtraConfig); l > 0 {
+		return l
+	}
+	return GetMaxURLLength(cfg.ExtraConfig)
+}
+
+// URLLength returns the length of the request target (path and query string) of the request
+func URLLength(r *http.Request) int {
+	if r.RequestURI != "" {
+		return len(r.RequestURI)
+	}
+	return len(r.URL.RequestURI())
+}
+
+// NewMaxURLLengthHandler wraps the received handler, rejecting the requests with a URL longer
+// than max with a 414 status code. The handler is returned as it is if max is not positive.
+func NewMaxURLLengthHandler(max int, next http.Handler) http.Handler {
+	if max <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if URLLength(r) > max {
+			w.Header().Set(CompleteResponseHeaderName, HeaderIncompleteResponseValue)
+			http.Error(w, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
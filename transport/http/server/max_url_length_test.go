@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewMaxURLLengthHandler(t *testing.T) {
+	h := NewMaxURLLengthHandler(20, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		length int
+		status int
+	}{
+		{length: 19, status: http.StatusOK},
+		{length: 20, status: http.StatusOK},
+		{length: 21, status: http.StatusRequestURITooLong},
+	} {
+		target := "/foo?q="
+		target += strings.Repeat("a", tc.length-len(target))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", target, http.NoBody))
+		if w.Code != tc.status {
+			t.Errorf("%d: unexpected status code. have: %d, want: %d", tc.length, w.Code, tc.status)
+		}
+		if tc.status == http.StatusRequestURITooLong && w.Header().Get(CompleteResponseHeaderName) != HeaderIncompleteResponseValue {
+			t.Errorf("%d: unexpected complete header: %s", tc.length, w.Header().Get(CompleteResponseHeaderName))
+		}
+	}
+}
+
+func TestNewMaxURLLengthHandler_noLimit(t *testing.T) {
+	next := http.NotFoundHandler()
+	if h := NewMaxURLLengthHandler(0, next); h == nil {
+		t.Error("unexpected nil handler")
+	}
+}
+
+func TestEndpointMaxURLLength(t *testing.T) {
+	service := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{maxURLLengthKey: 100.0},
+		},
+	}
+	endpoint := &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{maxURLLengthKey: 42.0},
+		},
+	}
+
+	if l := EndpointMaxURLLength(service, endpoint); l != 42 {
+		t.Errorf("the endpoint limit should override the service one: %d", l)
+	}
+	if l := EndpointMaxURLLength(service, &config.EndpointConfig{}); l != 100 {
+		t.Errorf("unexpected service limit: %d", l)
+	}
+	if l := EndpointMaxURLLength(config.ServiceConfig{}, &config.EndpointConfig{}); l != 0 {
+		t.Errorf("unexpected limit: %d", l)
+	}
+}
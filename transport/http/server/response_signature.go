@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	responseSignatureKey = "response_signature"
+
+	// DefaultResponseSignatureHeader is the default name of the header carrying the signature
+	// of the response body
+	DefaultResponseSignatureHeader = "X-Signature"
+	// HMACSHA256Signature is the name of the HMAC-SHA256 signature algorithm
+	HMACSHA256Signature = "hmac-sha256"
+	// RSASHA256Signature is the name of the RSASSA-PKCS1-v1_5 SHA256 signature algorithm
+	RSASHA256Signature = "rsa-sha256"
+)
+
+// ResponseSignature adds a detached signature of the encoded response body to the responses,
+// so the clients can verify their integrity
+type ResponseSignature struct {
+	Header string
+	sign   func([]byte) ([]byte, error)
+}
+
+// EndpointResponseSignature returns the response signature config of the endpoint:
+//
+//	"response_signature": { "algorithm": "hmac-sha256", "key": "env:SIGNING_KEY", "header": "X-Signature" }
+//
+// The key accepts the "env:" prefix. The rsa-sha256 signatures require a PEM encoded private
+// key (PKCS #1 or PKCS #8). If the key is not valid, the signature fails and the responses are
+// replaced by an internal server error, so no unsigned response is sent. It returns nil if the
+// endpoint does not define it.
+func EndpointResponseSignature(cfg *config.EndpointConfig) *ResponseSignature {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	tmp, ok := e[responseSignatureKey].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	s := &ResponseSignature{Header: DefaultResponseSignatureHeader}
+	if h, ok := tmp["header"].(string); ok && h != "" {
+		s.Header = textproto.CanonicalMIMEHeaderKey(h)
+	}
+	algorithm, _ := tmp["algorithm"].(string)
+	key, _ := tmp["key"].(string)
+	s.sign = newSigner(algorithm, config.ResolveEnvValue(key))
+	return s
+}
+
+// Sign returns the base64 encoded signature of the body
+func (s *ResponseSignature) Sign(body []byte) (string, error) {
+	sig, err := s.sign(body)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Writer returns a response writer buffering the response until it is closed, so the
+// signature header can be added before sending the body. The responses of a nil signature
+// are sent unsigned.
+func (s *ResponseSignature) Writer(w http.ResponseWriter) *SignedResponseWriter {
+	return &SignedResponseWriter{ResponseWriter: w, signature: s, status: http.StatusOK, buf: new(bytes.Buffer)}
+}
+
+// SignedResponseWriter is a response writer buffering the response body in order to sign it
+type SignedResponseWriter struct {
+	http.ResponseWriter
+	signature *ResponseSignature
+	status    int
+	buf       *bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (w *SignedResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+// Write buffers the body
+func (w *SignedResponseWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+// Close signs the buffered body and sends the response, with the signature header. If the
+// signature fails, it sends an internal server error instead.
+func (w *SignedResponseWriter) Close() error {
+	if w.signature == nil {
+		w.ResponseWriter.WriteHeader(w.status)
+		_, err := w.ResponseWriter.Write(w.buf.Bytes())
+		return err
+	}
+	sig, err := w.signature.Sign(w.buf.Bytes())
+	if err != nil {
+		w.Header().Del("Content-Type")
+		w.Header().Del(CompleteResponseHeaderName)
+		http.Error(w.ResponseWriter, ErrInternalError.Error(), http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set(w.signature.Header, sig)
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
+
+func newSigner(algorithm, key string) func([]byte) ([]byte, error) {
+	if key == "" {
+		return failingSigner(errors.New("empty response signature key"))
+	}
+	switch algorithm {
+	case "", HMACSHA256Signature:
+		return func(body []byte) ([]byte, error) {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write(body)
+			return mac.Sum(nil), nil
+		}
+	case RSASHA256Signature:
+		pk, err := parseRSAPrivateKey(key)
+		if err != nil {
+			return failingSigner(err)
+		}
+		return func(body []byte) ([]byte, error) {
+			sum := sha256.Sum256(body)
+			return rsa.SignPKCS1v15(rand.Reader, pk, crypto.SHA256, sum[:])
+		}
+	}
+	return failingSigner(fmt.Errorf("unknown response signature algorithm '%s'", algorithm))
+}
+
+func failingSigner(err error) func([]byte) ([]byte, error) {
+	return func(_ []byte) ([]byte, error) {
+		return nil, err
+	}
+}
+
+func parseRSAPrivateKey(key string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("the response signature key is not PEM encoded")
+	}
+	if pk, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return pk, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the response signature key is not a RSA key")
+	}
+	return pk, nil
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func signatureEndpoint(cfg map[string]interface{}) *config.EndpointConfig {
+	return &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{responseSignatureKey: cfg},
+		},
+	}
+}
+
+func TestEndpointResponseSignature_hmac(t *testing.T) {
+	os.Setenv("LURA_TEST_SIGNATURE_KEY", "s3cr3t")
+	defer os.Unsetenv("LURA_TEST_SIGNATURE_KEY")
+
+	s := EndpointResponseSignature(signatureEndpoint(map[string]interface{}{
+		"key":    "env:LURA_TEST_SIGNATURE_KEY",
+		"header": "x-body-signature",
+	}))
+	if s == nil {
+		t.Error("nil signature")
+		return
+	}
+
+	w := httptest.NewRecorder()
+	sw := s.Writer(w)
+	sw.Header().Set("Content-Type", "application/json")
+	sw.WriteHeader(http.StatusCreated)
+	sw.Write([]byte(`{"id":`))
+	sw.Write([]byte(`42}`))
+	if w.Body.Len() != 0 {
+		t.Error("the body should be buffered until the writer is closed")
+	}
+	if err := sw.Close(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if w.Body.String() != `{"id":42}` {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(`{"id":42}`))
+	if sig := w.Header().Get("X-Body-Signature"); sig != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected signature: %s", sig)
+	}
+}
+
+func TestEndpointResponseSignature_rsa(t *testing.T) {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pk)})
+
+	s := EndpointResponseSignature(signatureEndpoint(map[string]interface{}{
+		"algorithm": RSASHA256Signature,
+		"key":       string(key),
+	}))
+
+	w := httptest.NewRecorder()
+	sw := s.Writer(w)
+	sw.Write([]byte("some content"))
+	if err := sw.Close(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(w.Header().Get(DefaultResponseSignatureHeader))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sum := sha256.Sum256([]byte("some content"))
+	if err := rsa.VerifyPKCS1v15(&pk.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+		t.Errorf("invalid signature: %s", err.Error())
+	}
+}
+
+func TestEndpointResponseSignature_ko(t *testing.T) {
+	if s := EndpointResponseSignature(&config.EndpointConfig{}); s != nil {
+		t.Errorf("unexpected signature: %v", s)
+	}
+
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"key": "env:LURA_TEST_UNDEFINED_KEY"},
+		{"key": "not a pem", "algorithm": RSASHA256Signature},
+		{"key": "s3cr3t", "algorithm": "unknown"},
+	} {
+		w := httptest.NewRecorder()
+		sw := EndpointResponseSignature(signatureEndpoint(cfg)).Writer(w)
+		sw.Header().Set("Content-Type", "application/json")
+		sw.Write([]byte(`{"id":42}`))
+		if err := sw.Close(); err == nil {
+			t.Errorf("%v: error expected", cfg)
+		}
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%v: unexpected status code: %d", cfg, w.Code)
+		}
+		if w.Body.String() != "internal server error\n" {
+			t.Errorf("%v: unexpected body: %s", cfg, w.Body.String())
+		}
+		if w.Header().Get(DefaultResponseSignatureHeader) != "" {
+			t.Errorf("%v: unexpected signature", cfg)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	securityHeadersKey = "security_headers"
+
+	hstsHeader = "Strict-Transport-Security"
+)
+
+// SecurityHeadersPresets are the named sets of security headers available for the
+// 'preset' attribute of the security headers config
+var SecurityHeadersPresets = map[string]map[string]string{
+	"strict": {
+		hstsHeader:                "max-age=63072000; includeSubDomains; preload",
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "no-referrer",
+		"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+	},
+	"api": {
+		hstsHeader:                "max-age=31536000; includeSubDomains",
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+		"Content-Security-Policy": "frame-ancestors 'none'",
+	},
+}
+
+// SecurityHeaders is the set of security headers to add to the responses. An empty value
+// removes the header, so endpoints can drop the headers defined at the service level.
+type SecurityHeaders map[string]string
+
+// Apply sets the security headers in the response. The Strict-Transport-Security header
+// is only emitted when the request was received over TLS.
+func (s SecurityHeaders) Apply(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	for k, v := range s {
+		if k == hstsHeader && r.TLS == nil {
+			continue
+		}
+		if v == "" {
+			h.Del(k)
+			continue
+		}
+		h.Set(k, v)
+	}
+}
+
+// GetSecurityHeaders parses the security headers block of the received extra config. The
+// block contains an optional preset and a map of raw headers overriding the preset ones:
+//
+//	"security_headers": {
+//		"preset": "api",
+//		"headers": {"X-Frame-Options": "SAMEORIGIN"}
+//	}
+func GetSecurityHeaders(extra config.ExtraConfig) (SecurityHeaders, error) {
+	v, ok := extra[Namespace]
+	if !ok {
+		return nil, nil
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	tmp, ok := e[securityHeadersKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	res := SecurityHeaders{}
+	if name, ok := tmp["preset"].(string); ok && name != "" {
+		preset, ok := SecurityHeadersPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown security headers preset '%s'", name)
+		}
+		for k, v := range preset {
+			res[k] = v
+		}
+	}
+	if headers, ok := tmp["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			value, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid value for the security header '%s'", k)
+			}
+			res[textproto.CanonicalMIMEHeaderKey(k)] = value
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// NewSecurityHeadersHandler wraps the received handler, adding the security headers defined
+// at the service level to every response, including the errors and the responses of the
+// generated handlers (health, debug, not found...)
+func NewSecurityHeadersHandler(cfg config.ServiceConfig, next http.Handler, logger logging.Logger) http.Handler {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	headers, err := GetSecurityHeaders(cfg.ExtraConfig)
+	if err != nil {
+		logger.Error("[SERVICE: SecurityHeaders]", err.Error())
+		return next
+	}
+	if headers == nil {
+		return next
+	}
+	logger.Debug(fmt.Sprintf("[SERVICE: SecurityHeaders] Adding %d security headers to the responses", len(headers)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers.Apply(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SecurityHeadersConfigError is the error returned by the endpoints with an invalid security
+// headers config. The endpoint rejects all its requests instead of answering without the
+// headers it is expected to send.
+type SecurityHeadersConfigError struct {
+	Endpoint string
+	Err      error
+}
+
+// Error returns a string representation of the SecurityHeadersConfigError
+func (s SecurityHeadersConfigError) Error() string {
+	return fmt.Sprintf("invalid security headers for the endpoint %s: %s", s.Endpoint, s.Err.Error())
+}
+
+// Unwrap returns the error invalidating the security headers config
+func (s SecurityHeadersConfigError) Unwrap() error {
+	return s.Err
+}
+
+// StatusCode returns the status code to send to the client
+func (SecurityHeadersConfigError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// EndpointSecurityHeaders returns the security headers overriding the service ones for the
+// received endpoint. It returns nil if the endpoint does not define them and a
+// SecurityHeadersConfigError if their config is invalid.
+func EndpointSecurityHeaders(cfg *config.EndpointConfig) (SecurityHeaders, error) {
+	headers, err := GetSecurityHeaders(cfg.ExtraConfig)
+	if err != nil {
+		return nil, SecurityHeadersConfigError{Endpoint: cfg.Endpoint, Err: err}
+	}
+	return headers, nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewSecurityHeadersHandler(t *testing.T) {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"security_headers": map[string]interface{}{
+					"preset": "strict",
+					"headers": map[string]interface{}{
+						"x-frame-options": "SAMEORIGIN",
+						"referrer-policy": "",
+					},
+				},
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/ko", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "ko", http.StatusInternalServerError)
+	})
+	h := NewSecurityHeadersHandler(cfg, mux, nil)
+
+	for _, tc := range []struct {
+		path       string
+		tls        bool
+		statusCode int
+	}{
+		{path: "/ok", statusCode: http.StatusOK},
+		{path: "/ko", statusCode: http.StatusInternalServerError},
+		{path: "/unknown", statusCode: http.StatusNotFound},
+		{path: "/ok", tls: true, statusCode: http.StatusOK},
+		{path: "/unknown", tls: true, statusCode: http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("GET", tc.path, http.NoBody)
+		if tc.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != tc.statusCode {
+			t.Errorf("%s: unexpected status code: %d", tc.path, w.Code)
+		}
+		for k, v := range map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "SAMEORIGIN",
+			"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+			"Referrer-Policy":         "",
+		} {
+			if h := w.Header().Get(k); h != v {
+				t.Errorf("%s: unexpected value for the header %s: '%s'", tc.path, k, h)
+			}
+		}
+
+		hsts := w.Header().Get("Strict-Transport-Security")
+		if tc.tls && hsts != "max-age=63072000; includeSubDomains; preload" {
+			t.Errorf("%s: unexpected HSTS header over TLS: '%s'", tc.path, hsts)
+		}
+		if !tc.tls && hsts != "" {
+			t.Errorf("%s: the HSTS header should not be emitted without TLS: '%s'", tc.path, hsts)
+		}
+	}
+}
+
+func TestNewSecurityHeadersHandler_noConfig(t *testing.T) {
+	next := http.NotFoundHandler()
+	for _, extra := range []config.ExtraConfig{
+		nil,
+		{Namespace: map[string]interface{}{}},
+		{Namespace: map[string]interface{}{"security_headers": map[string]interface{}{"preset": "unknown"}}},
+	} {
+		h := NewSecurityHeadersHandler(config.ServiceConfig{ExtraConfig: extra}, next, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", http.NoBody))
+		if v := w.Header().Get("X-Frame-Options"); v != "" {
+			t.Errorf("unexpected header: %s", v)
+		}
+	}
+}
+
+func TestEndpointSecurityHeaders(t *testing.T) {
+	headers, err := EndpointSecurityHeaders(&config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"security_headers": map[string]interface{}{
+					"preset": "api",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if len(headers) != len(SecurityHeadersPresets["api"]) {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+	if headers, err := EndpointSecurityHeaders(&config.EndpointConfig{}); headers != nil || err != nil {
+		t.Errorf("unexpected result: %v, %v", headers, err)
+	}
+
+	_, err = EndpointSecurityHeaders(&config.EndpointConfig{
+		Endpoint: "/foo",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"security_headers": map[string]interface{}{
+					"preset": "unknown",
+				},
+			},
+		},
+	})
+	sErr, ok := err.(SecurityHeadersConfigError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sErr.Endpoint != "/foo" || sErr.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("unexpected error: %+v", sErr)
+	}
+}
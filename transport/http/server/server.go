@@ -20,6 +20,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/core"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 )
@@ -79,7 +80,18 @@ func InitHTTPDefaultTransportWithLogger(cfg config.ServiceConfig, logger logging
 	})
 }
 
+// newTransport builds the default transport. The session cache is not part of the parsed
+// client TLS config because it also depends on the backends with their own cache size (see
+// client.NewClientSessionCache).
 func newTransport(cfg config.ServiceConfig, logger logging.Logger) *http.Transport {
+	tlsConfig := ParseClientTLSConfigWithLogger(cfg.ClientTLS, logger)
+	if cache := client.NewClientSessionCache(cfg); cache != nil {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		tlsConfig.ClientSessionCache = cache
+	}
+
 	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -96,7 +108,7 @@ func newTransport(cfg config.ServiceConfig, logger logging.Logger) *http.Transpo
 		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
 		ExpectContinueTimeout: cfg.ExpectContinueTimeout,
 		TLSHandshakeTimeout:   10 * time.Second,
-		TLSClientConfig:       ParseClientTLSConfigWithLogger(cfg.ClientTLS, logger),
+		TLSClientConfig:       tlsConfig,
 	}
 }
 
@@ -111,6 +123,10 @@ func RunServerWithLoggerFactory(l logging.Logger) func(context.Context, config.S
 		done := make(chan error)
 		s := NewServerWithLogger(cfg, handler, l)
 
+		adminCtx, stopAdmin := context.WithCancel(ctx)
+		defer stopAdmin()
+		go RunAdminServer(adminCtx, cfg, l)
+
 		if s.TLSConfig == nil {
 			go func() {
 				done <- s.ListenAndServe()
@@ -141,7 +157,13 @@ func NewServer(cfg config.ServiceConfig, handler http.Handler) *http.Server {
 	return NewServerWithLogger(cfg, handler, nil)
 }
 
+// NewServerWithLogger returns a http.Server ready to serve the injected handler. The load
+// shedding handler wraps the rest of handlers, so the admin endpoints mounted in the service
+// port count as in-flight requests too, unless their path is declared as a health path.
 func NewServerWithLogger(cfg config.ServiceConfig, handler http.Handler, logger logging.Logger) *http.Server {
+	handler = NewSecurityHeadersHandler(cfg, handler, logger)
+	handler = NewAdminPathHandler(cfg, handler, logger)
+	handler = NewLoadSheddingHandler(cfg, handler, logger)
 	if cfg.UseH2C {
 		handler = h2c.NewHandler(handler, &http2.Server{})
 	}
@@ -204,7 +226,7 @@ func ParseClientTLSConfigWithLogger(cfg *config.ClientTLS, logger logging.Logger
 	if cfg == nil {
 		return nil
 	}
-	return &tls.Config{
+	tlsConfig := &tls.Config{
 		InsecureSkipVerify: cfg.AllowInsecureConnections,
 		RootCAs:            loadCertPool(cfg.DisableSystemCaPool, cfg.CaCerts, logger),
 		MinVersion:         parseTLSVersion(cfg.MinVersion),
@@ -213,6 +235,7 @@ func ParseClientTLSConfigWithLogger(cfg *config.ClientTLS, logger logging.Logger
 		CipherSuites:       parseCipherSuites(cfg.CipherSuites),
 		Certificates:       loadClientCerts(cfg.ClientCerts, logger),
 	}
+	return tlsConfig
 }
 
 func loadCertPool(disableSystemCaPool bool, caCerts []string, logger logging.Logger) *x509.CertPool {
@@ -470,3 +470,17 @@ func h2cClient() *http.Client {
 func newPort() int {
 	return 16666 + rand.Intn(40000)
 }
+
+func TestNewTransport_sessionCache(t *testing.T) {
+	clientTLS := &config.ClientTLS{ClientSessionCacheSize: 10}
+	if tlsConfig := ParseClientTLSConfigWithLogger(clientTLS, logging.NoOp); tlsConfig.ClientSessionCache != nil {
+		t.Error("the parsed client TLS config should not contain a session cache")
+	}
+	transport := newTransport(config.ServiceConfig{ClientTLS: clientTLS}, logging.NoOp)
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.ClientSessionCache == nil {
+		t.Error("the transport should resume the TLS sessions")
+	}
+	if transport := newTransport(config.ServiceConfig{}, logging.NoOp); transport.TLSClientConfig != nil {
+		t.Errorf("unexpected TLS config: %+v", transport.TLSClientConfig)
+	}
+}